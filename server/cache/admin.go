@@ -0,0 +1,283 @@
+package cache
+
+import (
+	"errors"
+	"time"
+
+	"github.com/dgraph-io/badger/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 列出 key 时的默认和最大条数
+const (
+	defaultKeyLimit = 100
+	maxKeyLimit     = 1000
+)
+
+// KeyInfo 单个缓存 key 的详情，TTL 为剩余秒数，-1 表示永不过期
+type KeyInfo struct {
+	Key      string `json:"key"`
+	Size     int    `json:"size"`
+	ExpireAt int64  `json:"expire_at"`
+	TTL      int64  `json:"ttl"`
+	Expired  bool   `json:"expired"`
+	InMemory bool   `json:"in_memory"`
+	OnDisk   bool   `json:"on_disk"`
+}
+
+// Stats 缓存统计，内存部分来自 ristretto 的 metrics，磁盘部分来自 badger
+type Stats struct {
+	Persistent   bool    `json:"persistent"`
+	Dir          string  `json:"dir"`
+	DiskEntries  int64   `json:"disk_entries"`
+	DiskBytes    int64   `json:"disk_bytes"` // 所有 key 和 value 的大小之和
+	LSMSize      int64   `json:"lsm_size"`
+	VLogSize     int64   `json:"vlog_size"`
+	MemHits      uint64  `json:"mem_hits"`
+	MemMisses    uint64  `json:"mem_misses"`
+	MemHitRatio  float64 `json:"mem_hit_ratio"`
+	MemKeysAdded uint64  `json:"mem_keys_added"`
+	MemEvicted   uint64  `json:"mem_keys_evicted"`
+	MemCost      int64   `json:"mem_cost"` // 已加入减去已淘汰的开销，为近似值
+	MemMaxCost   int64   `json:"mem_max_cost"`
+}
+
+var errDiskDisabled = errors.New("disk cache disabled")
+
+func (q *QCache) diskReady() bool {
+	return q.disk != nil && !q.disk.IsClosed()
+}
+
+// Keys 按前缀列出磁盘中的 key，所有写入都会落盘，因此磁盘中的 key 是完整的
+// 返回的 next 非空时表示还有更多，作为下一次调用的 after 参数
+func (q *QCache) Keys(prefix, after string, limit int) ([]KeyInfo, string, error) {
+	if !q.diskReady() {
+		return nil, "", errDiskDisabled
+	}
+	if limit <= 0 {
+		limit = defaultKeyLimit
+	}
+	if limit > maxKeyLimit {
+		limit = maxKeyLimit
+	}
+
+	now := time.Now().Unix()
+	var keys []KeyInfo
+	next := ""
+	err := q.disk.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.Prefix = []byte(prefix)
+		it := txn.NewIterator(opt)
+		defer it.Close()
+
+		start := []byte(prefix)
+		if after != "" {
+			start = []byte(after)
+		}
+		for it.Seek(start); it.Valid(); it.Next() {
+			entry := it.Item()
+			key := string(entry.Key())
+			if key == after {
+				continue
+			}
+			if len(keys) == limit {
+				next = keys[len(keys)-1].Key
+				break
+			}
+			info := KeyInfo{Key: key, OnDisk: true}
+			if err := entry.Value(func(val []byte) error {
+				return decodeInfo(val, now, &info)
+			}); err != nil {
+				// 损坏的数据也列出来，方便删除
+				info.Size = int(entry.ValueSize())
+			}
+			keys = append(keys, info)
+		}
+		return nil
+	})
+	return keys, next, err
+}
+
+// Inspect 查看单个 key 所在的层级、大小和剩余 TTL，不会删除过期的数据
+func (q *QCache) Inspect(key string) (*KeyInfo, bool, error) {
+	now := time.Now().Unix()
+	info := KeyInfo{Key: key}
+	found := false
+
+	if val, ok := q.memory.Get(key); ok {
+		if item, ok := val.(*CachedItem); ok {
+			found = true
+			info.InMemory = true
+			info.Size = len(item.Value)
+			setExpire(&info, item.ExpireAt, now)
+		}
+	}
+
+	if q.diskReady() {
+		err := q.disk.View(func(txn *badger.Txn) error {
+			entry, err := txn.Get([]byte(key))
+			if err != nil {
+				return err
+			}
+			info.OnDisk = true
+			return entry.Value(func(val []byte) error {
+				return decodeInfo(val, now, &info)
+			})
+		})
+		if err != nil && !errors.Is(err, badger.ErrKeyNotFound) {
+			return nil, false, err
+		}
+		found = found || info.OnDisk
+	}
+	return &info, found, nil
+}
+
+// PurgePrefix 删除内存和磁盘中所有以 prefix 开头的 key，返回删除的条数
+func (q *QCache) PurgePrefix(prefix string) (int, error) {
+	if !q.diskReady() {
+		return 0, errDiskDisabled
+	}
+
+	var keys [][]byte
+	err := q.disk.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		opt.Prefix = []byte(prefix)
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			keys = append(keys, it.Item().KeyCopy(nil))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	wb := q.disk.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range keys {
+		q.memory.Del(string(key))
+		if err := wb.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		return 0, err
+	}
+	return len(keys), nil
+}
+
+// Stats 汇总缓存统计，磁盘条数和大小需要遍历所有 key
+func (q *QCache) Stats() (*Stats, error) {
+	m := q.memory.Metrics
+	stats := &Stats{
+		Persistent:   q.persistent,
+		Dir:          q.dir,
+		MemHits:      m.Hits(),
+		MemMisses:    m.Misses(),
+		MemHitRatio:  m.Ratio(),
+		MemKeysAdded: m.KeysAdded(),
+		MemEvicted:   m.KeysEvicted(),
+		MemCost:      int64(m.CostAdded()) - int64(m.CostEvicted()),
+		MemMaxCost:   q.memory.MaxCost(),
+	}
+	if !q.diskReady() {
+		return stats, nil
+	}
+
+	stats.LSMSize, stats.VLogSize = q.disk.Size()
+	err := q.disk.View(func(txn *badger.Txn) error {
+		opt := badger.DefaultIteratorOptions
+		opt.PrefetchValues = false
+		it := txn.NewIterator(opt)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			entry := it.Item()
+			stats.DiskEntries++
+			stats.DiskBytes += int64(len(entry.Key())) + int64(entry.ValueSize())
+		}
+		return nil
+	})
+	return stats, err
+}
+
+// RunValueLogGC 触发 badger value log 回收，一直执行到没有可回收的文件为止，返回重写的文件数
+func (q *QCache) RunValueLogGC(discardRatio float64) (int, error) {
+	if !q.diskReady() {
+		return 0, errDiskDisabled
+	}
+
+	rewrites := 0
+	for {
+		err := q.disk.RunValueLogGC(discardRatio)
+		if errors.Is(err, badger.ErrNoRewrite) {
+			return rewrites, nil
+		}
+		if err != nil {
+			return rewrites, err
+		}
+		rewrites++
+	}
+}
+
+func decodeInfo(val []byte, now int64, info *KeyInfo) error {
+	var item CachedItem
+	if err := msgpack.Unmarshal(val, &item); err != nil {
+		return err
+	}
+	info.Size = len(item.Value)
+	setExpire(info, item.ExpireAt, now)
+	return nil
+}
+
+func setExpire(info *KeyInfo, expireAt, now int64) {
+	info.ExpireAt = expireAt
+	info.TTL = -1
+	if expireAt != 0 {
+		info.TTL = expireAt - now
+		info.Expired = info.TTL < 0
+		if info.Expired {
+			info.TTL = 0
+		}
+	}
+}
+
+// Keys 全局方法封装
+func Keys(prefix, after string, limit int) ([]KeyInfo, string, error) {
+	if globalCache == nil {
+		return nil, "", errors.New("cache not initialized")
+	}
+	return globalCache.Keys(prefix, after, limit)
+}
+
+func Inspect(key string) (*KeyInfo, bool, error) {
+	if globalCache == nil {
+		return nil, false, errors.New("cache not initialized")
+	}
+	return globalCache.Inspect(key)
+}
+
+func PurgePrefix(prefix string) (int, error) {
+	if globalCache == nil {
+		return 0, errors.New("cache not initialized")
+	}
+	return globalCache.PurgePrefix(prefix)
+}
+
+func GetStats() (*Stats, error) {
+	if globalCache == nil {
+		return nil, errors.New("cache not initialized")
+	}
+	return globalCache.Stats()
+}
+
+func RunValueLogGC(discardRatio float64) (int, error) {
+	if globalCache == nil {
+		return 0, errors.New("cache not initialized")
+	}
+	return globalCache.RunValueLogGC(discardRatio)
+}
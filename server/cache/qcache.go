@@ -14,19 +14,24 @@ import (
 	"github.com/google/uuid"
 )
 
-// QCache 是一个两级缓存：内存 + 磁盘（临时，不持久化）
+// QCache 是一个两级缓存：内存 + 磁盘
+// 默认磁盘目录是临时的，关闭时删除；持久化模式下目录在重启后复用
 type QCache struct {
-	memory *ristretto.Cache
-	disk   *badger.DB
-	dir    string
-	mu     sync.RWMutex
-	closed bool
+	memory     *ristretto.Cache
+	disk       *badger.DB
+	dir        string
+	persistent bool
+	mu         sync.RWMutex
+	closed     bool
 }
 
 // Options 包含创建 QCache 所需的配置选项。
 type Options struct {
 	MaxMemCost int64  // 内存缓存的最大开销
 	DiskDir    string // 磁盘缓存的目录路径
+	Persistent bool   // 是否使用固定目录 DiskDir/persistent 并在重启后保留数据
+	// Retain 持久化模式下打开时决定哪些 key 可以保留，其余的（可变数据）全部丢弃，为空则全部丢弃
+	Retain func(key string) bool
 }
 
 type CachedItem struct {
@@ -34,6 +39,9 @@ type CachedItem struct {
 	ExpireAt int64  `json:"expireAt"` // Unix 时间戳（秒）
 }
 
+// 持久化模式下磁盘缓存所在的子目录，与临时模式的 uuid 目录区分开
+const persistentDirName = "persistent"
+
 var (
 	globalCache *QCache
 	initOnce    sync.Once
@@ -44,6 +52,7 @@ func Create(option Options) (*QCache, error) {
 		NumCounters: option.MaxMemCost / 10,
 		MaxCost:     option.MaxMemCost,
 		BufferItems: 64,
+		Metrics:     true, // 供管理接口统计命中率
 	})
 
 	if err != nil {
@@ -54,14 +63,18 @@ func Create(option Options) (*QCache, error) {
 	}
 
 	cache := QCache{
-		memory: memoryCache,
-		disk:   nil,
-		dir:    "",
+		memory:     memoryCache,
+		disk:       nil,
+		dir:        "",
+		persistent: option.Persistent,
 	}
 
 	if option.DiskDir != "" {
 		// 初始化 badger
 		cache.dir = filepath.Join(option.DiskDir, uuid.New().String())
+		if cache.persistent {
+			cache.dir = filepath.Join(option.DiskDir, persistentDirName)
+		}
 		if err := os.MkdirAll(cache.dir, 0755); err != nil {
 			logger.GetLogger("quiver").Errorf("create cahce dir %s failed: %v", cache.dir, err)
 			return nil, err
@@ -72,8 +85,11 @@ func Create(option Options) (*QCache, error) {
 			return nil, err
 		}
 		cache.disk = db
+		if cache.persistent {
+			cache.prune(option.Retain)
+		}
 
-		logger.GetLogger("quiver").Infof("new disk cache success: %s", cache.dir)
+		logger.GetLogger("quiver").Infof("new disk cache success: %s, persistent: %v", cache.dir, cache.persistent)
 	}
 
 	return &cache, nil
@@ -198,9 +214,7 @@ func (q *QCache) ReOpen() (*badger.DB, error) {
 		if err := q.disk.Close(); err != nil {
 			logger.GetLogger("quiver").Errorf("close badger cache failed: %v", err)
 		}
-		if err := os.RemoveAll(q.dir); err != nil {
-			logger.GetLogger("quiver").Errorf("remove cache dir failed: %v", err)
-		}
+		q.removeDir()
 
 		q.closed = true
 		q.disk = nil
@@ -212,6 +226,15 @@ func (q *QCache) ReOpen() (*badger.DB, error) {
 		WithLogger(nil)        // 可选：禁用日志
 
 	db, err := badger.Open(opt)
+	if err != nil && q.persistent {
+		// 持久化目录可能被其他进程占用或已损坏，不删除，退回到临时目录
+		logger.GetLogger("quiver").Warnf("open persistent disk cache %s failed, fallback to temporary dir: %v", q.dir, err)
+		q.persistent = false
+		q.dir = filepath.Join(filepath.Dir(q.dir), uuid.New().String())
+		opt.Dir = q.dir
+		opt.ValueDir = q.dir
+		db, err = badger.Open(opt)
+	}
 	if err != nil {
 		// 尝试删除缓存目录
 		err := os.RemoveAll(q.dir)
@@ -221,7 +244,7 @@ func (q *QCache) ReOpen() (*badger.DB, error) {
 		}
 		// 尝试换个目录 获取 q.dir 的 父目录
 		q.dir = filepath.Join(filepath.Dir(q.dir), uuid.New().String())
-		logger.GetLogger("quiver").Infof("trying to change disk cache dir form %s to %s : %s", opt.ValueDir, q.dir)
+		logger.GetLogger("quiver").Infof("trying to change disk cache dir form %s to %s", opt.ValueDir, q.dir)
 		opt.Dir = q.dir
 		opt.ValueDir = q.dir
 		db, err = badger.Open(opt)
@@ -253,12 +276,67 @@ func (q *QCache) Close() error {
 	if err := q.disk.Close(); err != nil {
 		logger.GetLogger("quiver").Errorf("close badger cache failed: %v", err)
 	}
+	q.removeDir()
+
+	q.closed = true
+	return nil
+}
+
+// removeDir 删除临时磁盘目录，持久化目录保留
+func (q *QCache) removeDir() {
+	if q.persistent {
+		return
+	}
 	if err := os.RemoveAll(q.dir); err != nil {
 		logger.GetLogger("quiver").Errorf("remove cache dir failed: %v", err)
 	}
+}
 
-	q.closed = true
-	return nil
+// prune 持久化模式打开后清理上次运行留下的数据：
+// 不可变的数据（retain 返回 true）在未过期时保留，其余的可变数据可能已经过时，全部删除，由业务重新从数据库加载
+func (q *QCache) prune(retain func(key string) bool) {
+	now := time.Now().Unix()
+	var drop [][]byte
+	kept := 0
+
+	err := q.disk.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Rewind(); it.Valid(); it.Next() {
+			entry := it.Item()
+			key := entry.KeyCopy(nil)
+			if retain != nil && retain(string(key)) {
+				var item CachedItem
+				err := entry.Value(func(val []byte) error {
+					return msgpack.Unmarshal(val, &item)
+				})
+				if err == nil && (item.ExpireAt == 0 || item.ExpireAt > now) {
+					kept++
+					continue
+				}
+			}
+			drop = append(drop, key)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("scan disk cache %s failed: %v", q.dir, err)
+		return
+	}
+
+	wb := q.disk.NewWriteBatch()
+	defer wb.Cancel()
+	for _, key := range drop {
+		if err := wb.Delete(key); err != nil {
+			logger.GetLogger("quiver").Errorf("drop disk cache key %s failed: %v", key, err)
+			return
+		}
+	}
+	if err := wb.Flush(); err != nil {
+		logger.GetLogger("quiver").Errorf("drop disk cache keys failed: %v", err)
+		return
+	}
+	logger.GetLogger("quiver").Infof("reuse disk cache %s: kept %d entries, dropped %d", q.dir, kept, len(drop))
 }
 
 // Init 全局方法封装
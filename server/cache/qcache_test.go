@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCache(t *testing.T, dir string, retain func(key string) bool) *QCache {
+	t.Helper()
+	q, err := Create(Options{
+		MaxMemCost: 1 << 20,
+		DiskDir:    dir,
+		Persistent: true,
+		Retain:     retain,
+	})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	return q
+}
+
+// diskGet 只从磁盘读取，绕过内存缓存
+func diskGet(t *testing.T, q *QCache, key string) ([]byte, bool) {
+	t.Helper()
+	q.memory.Del(key)
+	q.memory.Wait()
+	value, found, err := q.Get(key)
+	if err != nil {
+		t.Fatalf("Get(%q) error = %v", key, err)
+	}
+	return value, found
+}
+
+func TestPersistentCacheSurvivesReopen(t *testing.T) {
+	dir := t.TempDir()
+	retainAll := func(string) bool { return true }
+
+	q := newTestCache(t, dir, retainAll)
+	if q.dir != filepath.Join(dir, persistentDirName) {
+		t.Fatalf("dir = %s, want %s", q.dir, filepath.Join(dir, persistentDirName))
+	}
+	if err := q.Set("release:fixed:dev:1", []byte("v1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newTestCache(t, dir, retainAll)
+	defer q.Close()
+	value, found := diskGet(t, q, "release:fixed:dev:1")
+	if !found || string(value) != "v1" {
+		t.Fatalf("after reopen got %q, %v; want \"v1\", true", value, found)
+	}
+}
+
+func TestPersistentCacheRetain(t *testing.T) {
+	dir := t.TempDir()
+	retainFixed := func(key string) bool { return strings.HasPrefix(key, "release:fixed:") }
+
+	q := newTestCache(t, dir, retainFixed)
+	entries := []struct {
+		key string
+		ttl time.Duration
+	}{
+		{"release:fixed:dev:1", 0},
+		{"release:fixed:dev:2", time.Hour},
+		{"release:fixed:dev:3", time.Second}, // 重新打开时已经过期
+		{"release:latest:dev:a:c:n", 0},      // 可变数据
+	}
+	for _, e := range entries {
+		if err := q.Set(e.key, []byte(e.key), e.ttl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+
+	q = newTestCache(t, dir, retainFixed)
+	defer q.Close()
+
+	tests := []struct {
+		key  string
+		kept bool
+	}{
+		{"release:fixed:dev:1", true},
+		{"release:fixed:dev:2", true},
+		{"release:fixed:dev:3", false},
+		{"release:latest:dev:a:c:n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, found := diskGet(t, q, tt.key); found != tt.kept {
+				t.Fatalf("found = %v, want %v", found, tt.kept)
+			}
+		})
+	}
+}
+
+func TestPersistentCacheNilRetainDropsAll(t *testing.T) {
+	dir := t.TempDir()
+
+	q := newTestCache(t, dir, nil)
+	if err := q.Set("release:fixed:dev:1", []byte("v1"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	q = newTestCache(t, dir, nil)
+	defer q.Close()
+	if _, found := diskGet(t, q, "release:fixed:dev:1"); found {
+		t.Fatal("key survived reopen without a Retain function")
+	}
+}
+
+func TestPersistentCacheLockedFallsBackToTemporaryDir(t *testing.T) {
+	dir := t.TempDir()
+	persistentDir := filepath.Join(dir, persistentDirName)
+
+	owner := newTestCache(t, dir, nil)
+	defer owner.Close()
+	if err := owner.Set("owner", []byte("1"), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	// 目录被 owner 锁住，第二个实例退回到临时目录
+	q := newTestCache(t, dir, nil)
+	if q.persistent {
+		t.Fatal("cache still persistent while the directory is locked")
+	}
+	if q.dir == persistentDir || filepath.Dir(q.dir) != dir {
+		t.Fatalf("fallback dir = %s, want a sibling of %s", q.dir, persistentDir)
+	}
+	if err := q.Set("other", []byte("2"), 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := diskGet(t, q, "owner"); found {
+		t.Fatal("fallback cache sees data of the locked directory")
+	}
+
+	// 临时目录关闭时删除，持久化目录和其中的数据不受影响
+	fallbackDir := q.dir
+	if err := q.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if matches, _ := filepath.Glob(fallbackDir); len(matches) != 0 {
+		t.Fatalf("temporary dir %s not removed on close", fallbackDir)
+	}
+	if value, found := diskGet(t, owner, "owner"); !found || string(value) != "1" {
+		t.Fatalf("owner lost its data: %q, %v", value, found)
+	}
+}
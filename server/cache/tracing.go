@@ -0,0 +1,37 @@
+package cache
+
+import (
+	"context"
+	"quiver/telemetry"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// GetContext 带链路追踪的 Get，span 挂在 ctx 中的父 span 下
+func GetContext(ctx context.Context, key string) ([]byte, bool, error) {
+	_, span := telemetry.Start(ctx, "cache.Get", attribute.String("cache.key", key))
+	data, ok, err := Get(key)
+	span.SetAttributes(attribute.Bool("cache.hit", ok), attribute.Int("cache.value_size", len(data)))
+	telemetry.End(span, err)
+	return data, ok, err
+}
+
+// SetContext 带链路追踪的 Set
+func SetContext(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, span := telemetry.Start(ctx, "cache.Set",
+		attribute.String("cache.key", key),
+		attribute.Int("cache.value_size", len(value)),
+		attribute.String("cache.ttl", ttl.String()))
+	err := Set(key, value, ttl)
+	telemetry.End(span, err)
+	return err
+}
+
+// DeleteContext 带链路追踪的 Delete
+func DeleteContext(ctx context.Context, key string) error {
+	_, span := telemetry.Start(ctx, "cache.Delete", attribute.String("cache.key", key))
+	err := Delete(key)
+	telemetry.End(span, err)
+	return err
+}
@@ -2,7 +2,6 @@ package cache
 
 import (
 	"errors"
-	"fmt"
 	"quiver/database"
 	"quiver/logger"
 	"quiver/models"
@@ -11,26 +10,40 @@ import (
 	"time"
 )
 
-type CacheableModel interface {
-	GetID() uint64
-	GetUpdateTime() time.Time
-	CacheKey(env string) string // 返回要删除的缓存 key
-	TableName() string
-}
+const (
+	messageBatchSize = 1000
+	// 变更消息保留时间，超过后由 watcher 顺带清理
+	messageRetention = 7 * 24 * time.Hour
+	messagePurgeGap  = time.Hour
+)
 
+// Watcher 按 seq 顺序 tail 每个环境的 release_message 表，
+// 删除消息对应的缓存 key，并把消息按顺序投递给对应类型的回调，每条消息在本实例只投递一次
 type Watcher struct {
-	watchTables map[string]WatchTable
-	mu          sync.Mutex
+	streams map[string]*messageStream // env -> stream
+	mu      sync.Mutex
 }
+
 type OnKeyUpdateCallback func(evn, key string)
+
+// WatchTable 订阅某个环境下某一类变更消息，Name 为消息类型（即原来的表名）
 type WatchTable struct {
-	Env           string
-	Name          string
-	UpdateTime    time.Time
-	Interval      time.Duration
-	LastCheckTime time.Time
-	Running       bool
-	KeyUpdateCB   OnKeyUpdateCallback
+	Env         string
+	Name        string
+	Interval    time.Duration
+	KeyUpdateCB OnKeyUpdateCallback
+}
+
+type messageStream struct {
+	env           string
+	lastSeq       uint64
+	initialized   bool
+	interval      time.Duration
+	lastCheckTime time.Time
+	lastPurgeTime time.Time
+	running       bool
+	pending       bool
+	callbacks     map[string]OnKeyUpdateCallback
 }
 
 var (
@@ -39,105 +52,145 @@ var (
 
 func (w *Watcher) watch() {
 	go func() {
-		ticker := time.NewTicker(time.Second)
+		ticker := time.NewTicker(100 * time.Millisecond)
 		defer ticker.Stop()
 		for range ticker.C {
 			utils.WithTryLock(&w.mu, func() {
-				for key := range w.watchTables {
-					item := w.watchTables[key]
-					if item.LastCheckTime.Add(item.Interval).After(time.Now()) || item.Running {
+				for _, stream := range w.streams {
+					if stream.running || stream.lastCheckTime.Add(stream.interval).After(time.Now()) {
 						continue
 					}
 
-					// 标记为正在运行（避免重复触发）
-					item.Running = true
-					item.LastCheckTime = time.Now()
-					w.watchTables[key] = item // 写回 map
-
-					// 启动异步检查
-					go func(k string, table *WatchTable) {
-						var newUpdateTime time.Time
-						defer utils.WithLock(&w.mu, func() {
-							table.Running = false
-							table.UpdateTime = newUpdateTime
-							w.watchTables[k] = *table
-						})
-						switch table.Name {
-						case "accesskey":
-							newUpdateTime = checkUpdate[*models.AccessKey](table.Env, table)
-						case "permission":
-							newUpdateTime = checkUpdate[*models.Permission](table.Env, table)
-						case "namespace_release":
-							newUpdateTime = checkUpdate[*models.NamespaceRelease](table.Env, table)
-						}
-					}(key, &item)
+					w.start(stream)
 				}
 			})
 		}
 	}()
 }
-func checkUpdate[T CacheableModel](env string, table *WatchTable) time.Time {
-	db := database.GetDB(env)
-	if db == nil || table == nil {
-		logger.GetLogger("quiver").Errorf("db is nil")
-		return time.Unix(0, 0)
-	}
 
-	const batchSize = 1000
-	var lastID uint64
-	updateTime := table.UpdateTime
+// start 异步拉取一次，调用方需持有 w.mu；拉取期间收到的通知会在结束后再拉一次
+func (w *Watcher) start(st *messageStream) {
+	// 标记为正在运行（避免重复触发）
+	st.running = true
+	st.lastCheckTime = time.Now()
+	go func() {
+		for {
+			st.poll()
+			again := false
+			utils.WithLock(&w.mu, func() {
+				if st.pending {
+					st.pending = false
+					st.lastCheckTime = time.Now()
+					again = true
+				} else {
+					st.running = false
+				}
+			})
+			if !again {
+				return
+			}
+		}
+	}()
+}
+
+// Notify 立即拉取 env 的变更日志，本实例提交变更后或收到其他实例的广播时调用
+func Notify(env string) {
+	if globalWatcher == nil {
+		return
+	}
 
-	for {
-		var items []T
-		err := db.Where("update_time > ? AND id > ?", updateTime, lastID).
-			Order("update_time ASC, id ASC").
-			Limit(batchSize).
-			Find(&items).Error
-
-		if err != nil {
-			//logger.GetLogger("quiver").Errorf("query error: %v", err)
-			return updateTime
+	utils.WithLock(&globalWatcher.mu, func() {
+		stream, ok := globalWatcher.streams[env]
+		if !ok {
+			return
+		}
+		if stream.running {
+			stream.pending = true
+			return
 		}
+		globalWatcher.start(stream)
+	})
+}
 
-		if len(items) == 0 {
-			break
+// poll 拉取 lastSeq 之后的消息并按顺序投递，running 标记保证同一个 stream 不会并发执行
+func (st *messageStream) poll() {
+	db := database.GetDB(st.env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", st.env)
+		return
+	}
+
+	// 启动时从当前最大序号开始，之前的变更已经体现在数据库里，冷缓存不需要失效
+	if !st.initialized {
+		var maxSeq uint64
+		if err := db.Model(&models.ReleaseMessage{}).Select("COALESCE(MAX(seq), 0)").Scan(&maxSeq).Error; err != nil {
+			logger.GetLogger("quiver").Errorf("query max release_message seq for env %s failed: %v", st.env, err)
+			return
 		}
+		st.lastSeq = maxSeq
+		st.initialized = true
+		logger.GetLogger("quiver").Infof("watch release_message for env %s from seq %d", st.env, st.lastSeq)
+	}
 
-		for _, item := range items {
-			lastID = max(item.GetID(), lastID)
-			if item.GetUpdateTime().After(updateTime) {
-				updateTime = item.GetUpdateTime()
-			}
-			key := item.CacheKey(env)
-			logger.GetLogger("quiver").Infof("ready to delete key: %s", key)
-			if err := Delete(key); err != nil {
-				logger.GetLogger("quiver").Errorf("delete %s error: %v", key, err)
-			}
+	for {
+		var messages []models.ReleaseMessage
+		if err := db.Where("seq > ?", st.lastSeq).
+			Order("seq ASC").
+			Limit(messageBatchSize).
+			Find(&messages).Error; err != nil {
+			logger.GetLogger("quiver").Errorf("query release_message for env %s failed: %v", st.env, err)
+			return
+		}
 
-			if table != nil && table.KeyUpdateCB != nil {
-				go func() {
-					table.KeyUpdateCB(env, key)
-				}()
-			}
+		for _, msg := range messages {
+			st.deliver(msg)
+			st.lastSeq = msg.Seq
 		}
 
-		if len(items) < batchSize {
+		if len(messages) < messageBatchSize {
 			break
 		}
 	}
 
-	// 更新传入的指针
-	return updateTime
+	if time.Since(st.lastPurgeTime) > messagePurgeGap {
+		st.lastPurgeTime = time.Now()
+		if err := db.Where("create_time < ?", time.Now().Add(-messageRetention)).
+			Delete(&models.ReleaseMessage{}).Error; err != nil {
+			logger.GetLogger("quiver").Warnf("purge release_message for env %s failed: %v", st.env, err)
+		}
+	}
+}
+
+func (st *messageStream) deliver(msg models.ReleaseMessage) {
+	logger.GetLogger("quiver").Infof("release_message %d %s ready to delete key: %s", msg.Seq, msg.Kind, msg.Message)
+	if err := Delete(msg.Message); err != nil {
+		logger.GetLogger("quiver").Errorf("delete %s error: %v", msg.Message, err)
+	}
+
+	cb, ok := st.callbacks[msg.Kind]
+	if !ok || cb == nil {
+		return
+	}
+	// 回调同步执行，保证同一环境的变更按 seq 顺序处理
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.GetLogger("quiver").Errorf("release_message %d callback panic: %v", msg.Seq, r)
+			}
+		}()
+		cb(st.env, msg.Message)
+	}()
 }
 
 // StartWatch 全局方法封装
 func startWatch() {
 	globalWatcher = &Watcher{
-		watchTables: make(map[string]WatchTable),
+		streams: make(map[string]*messageStream),
 	}
 	globalWatcher.watch()
 }
 
+// AddWatch 注册变更消息回调，同一环境的所有类型共用一个 stream，轮询间隔取最小值
 func AddWatch(items []WatchTable) error {
 	if globalWatcher == nil {
 		return errors.New("watcher not initialized")
@@ -145,14 +198,19 @@ func AddWatch(items []WatchTable) error {
 
 	utils.WithLock(&globalWatcher.mu, func() {
 		for _, item := range items {
-			key := fmt.Sprintf("%s:%s", item.Env, item.Name)
-			globalWatcher.watchTables[key] = WatchTable{
-				Env:         item.Env,
-				Name:        item.Name,
-				UpdateTime:  time.Now(),
-				Interval:    item.Interval,
-				KeyUpdateCB: item.KeyUpdateCB,
+			stream, ok := globalWatcher.streams[item.Env]
+			if !ok {
+				stream = &messageStream{
+					env:       item.Env,
+					interval:  item.Interval,
+					callbacks: make(map[string]OnKeyUpdateCallback),
+				}
+				globalWatcher.streams[item.Env] = stream
+			}
+			if item.Interval > 0 && (stream.interval <= 0 || item.Interval < stream.interval) {
+				stream.interval = item.Interval
 			}
+			stream.callbacks[item.Name] = item.KeyUpdateCB
 		}
 	})
 	return nil
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"quiver/sdk"
+	"sync"
+	"time"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// namespaceState 命名空间最近一次拉取到的完整配置
+type namespaceState struct {
+	ref       sdk.NamespaceRef
+	releaseID string
+	items     map[string]string
+	loaded    bool // 拉取到过配置；还没有任何发布时为 false
+	updatedAt time.Time
+}
+
+// agent 订阅命名空间的变化并渲染文件；与服务端断开时保留最后一次成功渲染的文件
+type agent struct {
+	conf   *agentConfig
+	client *sdk.Client
+
+	mu         sync.RWMutex
+	namespaces map[sdk.NamespaceRef]*namespaceState
+	order      []sdk.NamespaceRef
+	connected  bool
+	lastError  string
+	lastSync   time.Time
+
+	templates []*templateState
+	changed   chan struct{}
+}
+
+func newAgent(conf *agentConfig) (*agent, error) {
+	refs, err := conf.namespaceRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := sdk.NewClient(sdk.Config{
+		Server:     conf.Server,
+		AccessKey:  conf.AccessKey,
+		SecretKey:  conf.SecretKey,
+		TLS:        conf.TLS.Enabled,
+		CAFile:     conf.TLS.CAFile,
+		ServerName: conf.TLS.ServerName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	a := &agent{
+		conf:       conf,
+		client:     client,
+		namespaces: make(map[sdk.NamespaceRef]*namespaceState, len(refs)),
+		order:      refs,
+		changed:    make(chan struct{}, 1),
+	}
+	for _, ref := range refs {
+		a.namespaces[ref] = &namespaceState{ref: ref}
+	}
+	for i := range conf.Templates {
+		t, err := newTemplateState(&conf.Templates[i], a)
+		if err != nil {
+			return nil, fmt.Errorf("template %s: %v", conf.Templates[i].Destination, err)
+		}
+		a.templates = append(a.templates, t)
+	}
+	return a, nil
+}
+
+// run 同步一次后订阅变化，断开后按指数退避重连，直到 ctx 结束
+func (a *agent) run(ctx context.Context) {
+	go a.renderLoop(ctx)
+
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		started := time.Now()
+		err := a.sync(ctx)
+		if err == nil {
+			err = a.watch(ctx)
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		a.setError(err)
+
+		// 连接保持了一段时间后断开，从最小间隔重新开始
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		log.Printf("disconnected from %s: %v, retrying in %s", a.conf.Server, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+// sync 拉取所有命名空间的完整配置，有变化时触发渲染
+func (a *agent) sync(ctx context.Context) error {
+	changed := false
+	for _, ref := range a.order {
+		ok, err := a.fetch(ctx, ref)
+		if err != nil {
+			return err
+		}
+		changed = changed || ok
+	}
+
+	a.mu.Lock()
+	a.connected, a.lastError, a.lastSync = true, "", time.Now()
+	a.mu.Unlock()
+
+	if changed {
+		a.notify()
+	}
+	return nil
+}
+
+// fetch 拉取命名空间的完整配置，返回版本是否变化；还没有任何发布的命名空间跳过
+func (a *agent) fetch(ctx context.Context, ref sdk.NamespaceRef) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	latest, err := a.client.GetNamespace(ctx, a.conf.Env, a.conf.App, ref)
+	if errors.Is(err, sdk.ErrNoRelease) {
+		log.Printf("namespace %s has no release yet", ref)
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	ns := a.namespaces[ref]
+	if ns.loaded && ns.releaseID == latest.ReleaseID && equalItems(ns.items, latest.Items) {
+		return false, nil
+	}
+	ns.releaseID, ns.items, ns.loaded, ns.updatedAt = latest.ReleaseID, latest.Items, true, time.Now()
+	log.Printf("namespace %s updated to release %s (%d items)", ref, ns.releaseID, len(ns.items))
+	return true, nil
+}
+
+// watch 订阅所有命名空间，收到事件后拉取完整配置，直到连接断开
+func (a *agent) watch(ctx context.Context) error {
+	known := make(map[sdk.NamespaceRef]string, len(a.order))
+	a.mu.RLock()
+	for _, ref := range a.order {
+		known[ref] = a.namespaces[ref].releaseID
+	}
+	a.mu.RUnlock()
+
+	return a.client.Watch(ctx, a.conf.Env, a.conf.App, known, func(ref sdk.NamespaceRef, _ string) error {
+		// 事件中只有增量，拉取完整配置，保证删除的 key 不会残留
+		changed, err := a.fetch(ctx, ref)
+		if err != nil {
+			return err
+		}
+		if changed {
+			a.notify()
+		}
+		return nil
+	})
+}
+
+func (a *agent) notify() {
+	select {
+	case a.changed <- struct{}{}:
+	default:
+	}
+}
+
+func (a *agent) setError(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.connected = false
+	if err != nil {
+		a.lastError = err.Error()
+	}
+}
+
+// renderLoop 配置变化后渲染所有文件；有文件渲染失败或 reload 失败时定期重试
+func (a *agent) renderLoop(ctx context.Context) {
+	ticker := time.NewTicker(maxBackoff)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.changed:
+			a.renderAll(ctx)
+		case <-ticker.C:
+			if a.needsRetry() {
+				a.renderAll(ctx)
+			}
+		}
+	}
+}
+
+func (a *agent) needsRetry() bool {
+	for _, t := range a.templates {
+		t.mu.Lock()
+		retry := t.lastError != "" || t.reloadPending
+		t.mu.Unlock()
+		if retry {
+			return true
+		}
+	}
+	return false
+}
+
+// renderAll 渲染所有文件，返回第一个错误；渲染失败的文件保持不变
+func (a *agent) renderAll(ctx context.Context) error {
+	var first error
+	for _, t := range a.templates {
+		if err := t.render(ctx); err != nil {
+			log.Printf("render %s: %v", t.conf.Destination, err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// once 同步一次、渲染后返回，用于 init 容器等场景
+func (a *agent) once(ctx context.Context) error {
+	if err := a.sync(ctx); err != nil {
+		return err
+	}
+	for _, ref := range a.order {
+		if !a.namespaces[ref].loaded {
+			return fmt.Errorf("namespace %s has no release yet", ref)
+		}
+	}
+	return a.renderAll(ctx)
+}
+
+// lookup 供模板使用，name 为 "namespace" 或 "cluster/namespace"；返回副本，items 拉取后不再修改
+func (a *agent) lookup(name string) (namespaceState, error) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	var found *namespaceState
+	for _, ref := range a.order {
+		if ref.String() == name {
+			found = a.namespaces[ref]
+			break
+		}
+		if ref.Namespace == name {
+			if found != nil {
+				return namespaceState{}, fmt.Errorf("namespace %s is ambiguous, use cluster/namespace", name)
+			}
+			found = a.namespaces[ref]
+		}
+	}
+	if found == nil {
+		return namespaceState{}, fmt.Errorf("namespace %s is not in namespaces", name)
+	}
+	if !found.loaded {
+		return namespaceState{}, fmt.Errorf("namespace %s has no release yet", name)
+	}
+	return *found, nil
+}
+
+func equalItems(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
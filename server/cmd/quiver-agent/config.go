@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"quiver/render"
+	"quiver/sdk"
+	"strconv"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// agentConfig agent 的配置文件
+type agentConfig struct {
+	Server     string           `yaml:"server"`      // 服务端 gRPC 地址，如 quiver.internal:9090
+	Env        string           `yaml:"env"`         // 环境
+	App        string           `yaml:"app"`         // 应用
+	Cluster    string           `yaml:"cluster"`     // namespaces 中省略集群时使用的集群，默认 default
+	AccessKey  string           `yaml:"access_key"`  // 必填，也可以用 $QUIVER_ACCESS_KEY
+	SecretKey  string           `yaml:"secret_key"`  // 也可以用 $QUIVER_SECRET_KEY
+	TLS        tlsConfig        `yaml:"tls"`         // 经过 TLS 代理访问服务端时开启
+	HealthAddr string           `yaml:"health_addr"` // 健康检查地址，默认 127.0.0.1:9110，为 off 时不监听
+	Namespaces []string         `yaml:"namespaces"`  // 订阅的命名空间，"namespace" 或 "cluster/namespace"
+	Templates  []templateConfig `yaml:"templates"`   // 输出的文件
+}
+
+// tlsConfig 连接服务端的 TLS 配置
+type tlsConfig struct {
+	Enabled    bool   `yaml:"enabled"`
+	CAFile     string `yaml:"ca_file"`     // 为空时使用系统根证书
+	ServerName string `yaml:"server_name"` // 为空时使用 server 中的主机名
+}
+
+// templateConfig 一个输出文件，source、contents、format 三选一
+type templateConfig struct {
+	Destination    string        `yaml:"destination"`     // 输出文件
+	Source         string        `yaml:"source"`          // Go 模板文件
+	Contents       string        `yaml:"contents"`        // 内联的 Go 模板
+	Format         string        `yaml:"format"`          // 直接把 namespace 渲染为 properties、env、yaml、json、toml 或 ini
+	Namespace      string        `yaml:"namespace"`       // format 使用的命名空间
+	Mode           string        `yaml:"mode"`            // 文件权限，如 "0640"，默认沿用已有文件的权限，新文件为 0644
+	Command        string        `yaml:"command"`         // 文件变化后执行的命令，通过 sh -c 执行
+	CommandTimeout time.Duration `yaml:"command_timeout"` // 命令超时，默认 30s
+	Signal         string        `yaml:"signal"`          // 文件变化后向 pid_file 中的进程发送的信号，如 SIGHUP
+	PidFile        string        `yaml:"pid_file"`
+
+	mode os.FileMode
+}
+
+// loadConfig 读取并校验配置文件，补全缺省值
+func loadConfig(path string) (*agentConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading config file: %v", err)
+	}
+	var conf agentConfig
+	if err := yaml.UnmarshalStrict(data, &conf); err != nil {
+		return nil, fmt.Errorf("error unmarshalling YAML: %v", err)
+	}
+
+	if conf.Cluster == "" {
+		conf.Cluster = "default"
+	}
+	if conf.HealthAddr == "" {
+		conf.HealthAddr = "127.0.0.1:9110"
+	}
+	if conf.AccessKey == "" {
+		conf.AccessKey = os.Getenv("QUIVER_ACCESS_KEY")
+	}
+	if conf.SecretKey == "" {
+		conf.SecretKey = os.Getenv("QUIVER_SECRET_KEY")
+	}
+
+	switch {
+	case conf.Server == "":
+		return nil, errors.New("server is required")
+	case conf.Env == "":
+		return nil, errors.New("env is required")
+	case conf.App == "":
+		return nil, errors.New("app is required")
+	case len(conf.Namespaces) == 0:
+		return nil, errors.New("namespaces must not be empty")
+	case len(conf.Templates) == 0:
+		return nil, errors.New("templates must not be empty")
+	case conf.AccessKey == "" || conf.SecretKey == "":
+		return nil, errors.New("access_key and secret_key are required")
+	}
+
+	if _, err := conf.namespaceRefs(); err != nil {
+		return nil, err
+	}
+
+	// 相对路径相对于配置文件所在目录
+	dir := filepath.Dir(path)
+	seen := make(map[string]bool, len(conf.Templates))
+	for i := range conf.Templates {
+		t := &conf.Templates[i]
+		if err := t.validate(dir); err != nil {
+			return nil, fmt.Errorf("templates[%d]: %v", i, err)
+		}
+		if seen[t.Destination] {
+			return nil, fmt.Errorf("templates[%d]: duplicate destination %s", i, t.Destination)
+		}
+		seen[t.Destination] = true
+	}
+	return &conf, nil
+}
+
+// namespaceRefs 解析 namespaces，省略集群时使用 cluster
+func (conf *agentConfig) namespaceRefs() ([]sdk.NamespaceRef, error) {
+	refs := make([]sdk.NamespaceRef, 0, len(conf.Namespaces))
+	seen := make(map[sdk.NamespaceRef]bool, len(conf.Namespaces))
+	for _, name := range conf.Namespaces {
+		ref, err := sdk.ParseNamespace(name, conf.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		if seen[ref] {
+			return nil, fmt.Errorf("duplicate namespace %s", ref)
+		}
+		seen[ref] = true
+		refs = append(refs, ref)
+	}
+	if len(refs) > 100 {
+		return nil, errors.New("at most 100 namespaces can be watched")
+	}
+	return refs, nil
+}
+
+func (t *templateConfig) validate(dir string) error {
+	if t.Destination == "" {
+		return errors.New("destination is required")
+	}
+	if !filepath.IsAbs(t.Destination) {
+		t.Destination = filepath.Join(dir, t.Destination)
+	}
+
+	n := 0
+	for _, s := range []string{t.Source, t.Contents, t.Format} {
+		if s != "" {
+			n++
+		}
+	}
+	if n != 1 {
+		return errors.New("exactly one of source, contents and format is required")
+	}
+	if t.Source != "" && !filepath.IsAbs(t.Source) {
+		t.Source = filepath.Join(dir, t.Source)
+	}
+	if t.Format != "" {
+		if _, ok := render.Lookup(t.Format); !ok {
+			return fmt.Errorf("unsupported format %s", t.Format)
+		}
+		if t.Namespace == "" {
+			return errors.New("namespace is required with format")
+		}
+	}
+
+	if t.Mode != "" {
+		mode, err := strconv.ParseUint(t.Mode, 8, 32)
+		if err != nil || mode > 0o777 {
+			return fmt.Errorf("invalid mode %s", t.Mode)
+		}
+		t.mode = os.FileMode(mode)
+	}
+	if t.CommandTimeout <= 0 {
+		t.CommandTimeout = 30 * time.Second
+	}
+	if t.Signal != "" {
+		if t.PidFile == "" {
+			return errors.New("pid_file is required with signal")
+		}
+		if _, err := parseSignal(t.Signal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
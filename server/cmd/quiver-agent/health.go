@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+)
+
+// healthStatus 健康检查的响应
+type healthStatus struct {
+	// ok：已连接且所有文件都渲染成功；degraded：已经有文件渲染成功，但连接断开或有文件渲染失败；
+	// starting：还有文件从未渲染成功
+	Status     string            `json:"status"`
+	Connected  bool              `json:"connected"`
+	LastError  string            `json:"last_error,omitempty"`
+	LastSync   *time.Time        `json:"last_sync,omitempty"`
+	Namespaces []namespaceHealth `json:"namespaces"`
+	Templates  []templateHealth  `json:"templates"`
+}
+
+type namespaceHealth struct {
+	Name      string     `json:"name"`
+	ReleaseID string     `json:"release_id"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+type templateHealth struct {
+	Destination string     `json:"destination"`
+	RenderedAt  *time.Time `json:"rendered_at,omitempty"`
+	ChangedAt   *time.Time `json:"changed_at,omitempty"`
+	Error       string     `json:"error,omitempty"`
+}
+
+// health 当前状态
+func (a *agent) health() healthStatus {
+	a.mu.RLock()
+	h := healthStatus{
+		Connected:  a.connected,
+		LastError:  a.lastError,
+		LastSync:   timePtr(a.lastSync),
+		Namespaces: make([]namespaceHealth, 0, len(a.order)),
+		Templates:  make([]templateHealth, 0, len(a.templates)),
+	}
+	for _, ref := range a.order {
+		ns := a.namespaces[ref]
+		h.Namespaces = append(h.Namespaces, namespaceHealth{Name: ref.String(), ReleaseID: ns.releaseID, UpdatedAt: timePtr(ns.updatedAt)})
+	}
+	a.mu.RUnlock()
+
+	h.Status = "ok"
+	if !h.Connected {
+		h.Status = "degraded"
+	}
+	for _, t := range a.templates {
+		t.mu.Lock()
+		th := templateHealth{Destination: t.conf.Destination, RenderedAt: timePtr(t.renderedAt), ChangedAt: timePtr(t.changedAt), Error: t.lastError}
+		t.mu.Unlock()
+		h.Templates = append(h.Templates, th)
+
+		if th.Error != "" && h.Status == "ok" {
+			h.Status = "degraded"
+		}
+		if th.RenderedAt == nil {
+			h.Status = "starting"
+		}
+	}
+	return h
+}
+
+// serveHealth 在 addr 上提供 GET /health：starting 时返回 503，ok 和 degraded 返回 200，
+// 断开连接时仍在使用最后一次成功渲染的文件，不影响应用运行
+func (a *agent) serveHealth(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
+		h := a.health()
+		w.Header().Set("Content-Type", "application/json")
+		if h.Status == "starting" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(h)
+	})
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("health endpoint listening on http://%s/health", addr)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
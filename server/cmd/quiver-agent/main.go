@@ -0,0 +1,58 @@
+// quiver-agent 是部署在应用旁边的配置 agent：通过 gRPC 订阅命名空间的变化，
+// 用 Go 模板把配置渲染为本地文件并原子地替换，文件变化后执行 reload 命令或向应用发送信号。
+// 与服务端断开时保留最后一次成功渲染的文件，并在本地提供健康检查接口。
+//
+// 用法：
+//
+//	quiver-agent -config /etc/quiver-agent/agent.yaml
+//	quiver-agent -config agent.yaml -once   # 渲染一次后退出，用于 init 容器
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+func main() {
+	configPath := flag.String("config", "agent.yaml", "agent config file")
+	once := flag.Bool("once", false, "render all templates once and exit")
+	flag.Parse()
+
+	log.SetPrefix("quiver-agent ")
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+
+	conf, err := loadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("load config %s: %v", *configPath, err)
+	}
+	a, err := newAgent(conf)
+	if err != nil {
+		log.Fatalf("init agent: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if *once {
+		if err := a.once(ctx); err != nil {
+			log.Fatalf("render failed: %v", err)
+		}
+		return
+	}
+
+	if conf.HealthAddr != "off" {
+		go func() {
+			if err := a.serveHealth(ctx, conf.HealthAddr); err != nil {
+				log.Fatalf("health endpoint: %v", err)
+			}
+		}()
+	}
+
+	log.Printf("watching %d namespaces of %s/%s on %s", len(conf.Namespaces), conf.Env, conf.App, conf.Server)
+	a.run(ctx)
+	log.Printf("stopped")
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// parseSignal Windows 不支持向其他进程发送信号，请使用 command
+func parseSignal(name string) (syscall.Signal, error) {
+	return 0, errors.New("signal is not supported on windows, use command instead")
+}
+
+func sendSignal(pid int, sig syscall.Signal) error {
+	return errors.New("signal is not supported on windows")
+}
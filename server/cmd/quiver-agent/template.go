@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"quiver/render"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// templateState 一个输出文件及其最近一次渲染的结果
+type templateState struct {
+	conf  *templateConfig
+	agent *agent
+	tmpl  *template.Template
+
+	mu         sync.Mutex
+	renderedAt time.Time // 最近一次渲染成功（包括内容没有变化）的时间
+	changedAt  time.Time // 最近一次写入文件的时间
+	lastError  string
+
+	reloadPending bool // 文件已经更新但 command 或 signal 失败，下次渲染时重试
+}
+
+func newTemplateState(conf *templateConfig, a *agent) (*templateState, error) {
+	t := &templateState{conf: conf, agent: a}
+	if conf.Format != "" {
+		return t, nil
+	}
+
+	text := conf.Contents
+	if conf.Source != "" {
+		data, err := os.ReadFile(conf.Source)
+		if err != nil {
+			return nil, err
+		}
+		text = string(data)
+	}
+	tmpl, err := template.New(filepath.Base(conf.Destination)).Option("missingkey=error").Funcs(a.funcMap()).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	t.tmpl = tmpl
+	return t, nil
+}
+
+// funcMap 模板函数，namespace 参数为 "namespace" 或 "cluster/namespace"
+func (a *agent) funcMap() template.FuncMap {
+	return template.FuncMap{
+		// key 取配置项的值，不存在时渲染失败
+		"key": func(namespace, key string) (string, error) {
+			ns, err := a.lookup(namespace)
+			if err != nil {
+				return "", err
+			}
+			v, ok := ns.items[key]
+			if !ok {
+				return "", fmt.Errorf("key %s not found in namespace %s", key, namespace)
+			}
+			return v, nil
+		},
+		// keyOrDefault 配置项不存在时返回默认值
+		"keyOrDefault": func(namespace, key, def string) (string, error) {
+			ns, err := a.lookup(namespace)
+			if err != nil {
+				return "", err
+			}
+			if v, ok := ns.items[key]; ok {
+				return v, nil
+			}
+			return def, nil
+		},
+		// items 命名空间的全部配置项，range 时按 key 排序
+		"items": func(namespace string) (map[string]string, error) {
+			ns, err := a.lookup(namespace)
+			if err != nil {
+				return nil, err
+			}
+			return ns.items, nil
+		},
+		// release 命名空间当前的版本 ID
+		"release": func(namespace string) (string, error) {
+			ns, err := a.lookup(namespace)
+			if err != nil {
+				return "", err
+			}
+			return ns.releaseID, nil
+		},
+		// render 按服务端 RenderRelease 相同的规则把命名空间渲染为 properties、env、yaml、json、toml 或 ini
+		"render": func(namespace, format string) (string, error) {
+			content, err := a.renderNamespace(namespace, format)
+			return string(content), err
+		},
+		"env":       os.Getenv,
+		"toJSON":    toJSON,
+		"toUpper":   strings.ToUpper,
+		"toLower":   strings.ToLower,
+		"trimSpace": strings.TrimSpace,
+		"split":     strings.Split,
+		"join":      func(sep string, elems []string) string { return strings.Join(elems, sep) },
+		"replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+	}
+}
+
+func toJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	return string(data), err
+}
+
+// renderNamespace 把命名空间渲染为 format 格式，ini 的 section 为命名空间名称
+func (a *agent) renderNamespace(namespace, format string) ([]byte, error) {
+	f, ok := render.Lookup(format)
+	if !ok {
+		return nil, fmt.Errorf("unsupported format %s", format)
+	}
+	ns, err := a.lookup(namespace)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]render.Item, 0, len(ns.items))
+	for k, v := range ns.items {
+		items = append(items, render.Item{Key: k, Value: v})
+	}
+	return render.Render(f, items, ns.ref.Namespace)
+}
+
+// render 渲染并在内容变化时原子地替换文件，然后执行 command、发送 signal；
+// 渲染失败时保留原来的文件
+func (t *templateState) render(ctx context.Context) error {
+	err := t.renderFile(ctx)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.lastError = err.Error()
+		return err
+	}
+	t.lastError, t.renderedAt = "", time.Now()
+	return nil
+}
+
+func (t *templateState) renderFile(ctx context.Context) error {
+	var content []byte
+	if t.conf.Format != "" {
+		var err error
+		if content, err = t.agent.renderNamespace(t.conf.Namespace, t.conf.Format); err != nil {
+			return err
+		}
+	} else {
+		var buf bytes.Buffer
+		if err := t.tmpl.Execute(&buf, nil); err != nil {
+			return err
+		}
+		content = buf.Bytes()
+	}
+
+	written, err := writeAtomic(t.conf.Destination, content, t.conf.mode)
+	if err != nil {
+		return err
+	}
+	t.mu.Lock()
+	if written {
+		t.changedAt, t.reloadPending = time.Now(), true
+	}
+	pending := t.reloadPending
+	t.mu.Unlock()
+	if written {
+		log.Printf("rendered %s (%d bytes)", t.conf.Destination, len(content))
+	}
+	if !pending {
+		return nil
+	}
+
+	if err := t.reload(ctx); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	t.reloadPending = false
+	t.mu.Unlock()
+	return nil
+}
+
+// writeAtomic 内容与现有文件相同时不写入；否则写入同目录下的临时文件后 rename，
+// 读取方不会看到写了一半的文件。mode 为 0 时沿用现有文件的权限，新文件为 0644
+func writeAtomic(path string, content []byte, mode os.FileMode) (bool, error) {
+	current, err := os.ReadFile(path)
+	if err == nil && bytes.Equal(current, content) {
+		return false, nil
+	}
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return false, err
+	}
+	if mode == 0 {
+		mode = 0o644
+		if info, err := os.Stat(path); err == nil {
+			mode = info.Mode().Perm()
+		}
+	}
+
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return false, err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmp.Name(), path)
+}
+
+// reload 文件变化后执行 command，再向 pid_file 中的进程发送 signal
+func (t *templateState) reload(ctx context.Context) error {
+	if t.conf.Command != "" {
+		ctx, cancel := context.WithTimeout(ctx, t.conf.CommandTimeout)
+		defer cancel()
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", t.conf.Command)
+		} else {
+			cmd = exec.CommandContext(ctx, "sh", "-c", t.conf.Command)
+		}
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("command %q failed: %v: %s", t.conf.Command, err, bytes.TrimSpace(out))
+		}
+		log.Printf("command %q for %s succeeded", t.conf.Command, t.conf.Destination)
+	}
+
+	if t.conf.Signal != "" {
+		sig, err := parseSignal(t.conf.Signal)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(t.conf.PidFile)
+		if err != nil {
+			return err
+		}
+		pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil || pid <= 0 {
+			return fmt.Errorf("invalid pid in %s", t.conf.PidFile)
+		}
+		if err := sendSignal(pid, sig); err != nil {
+			return fmt.Errorf("send %s to %d: %v", t.conf.Signal, pid, err)
+		}
+		log.Printf("sent %s to %d for %s", t.conf.Signal, pid, t.conf.Destination)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+const defaultStopSignal = "SIGTERM"
+
+// errExecUnsupported 当前平台不能替换进程
+var errExecUnsupported = errors.New("exec is not supported on this platform")
+
+// forwardSignals 作为子进程运行时转发给命令的信号
+var forwardSignals = []os.Signal{
+	syscall.SIGHUP,
+	syscall.SIGINT,
+	syscall.SIGQUIT,
+	syscall.SIGTERM,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+}
+
+var signals = map[string]syscall.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGTERM": syscall.SIGTERM,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGUSR1": syscall.SIGUSR1,
+	"SIGUSR2": syscall.SIGUSR2,
+}
+
+// parseSignal 解析信号名称，SIG 前缀可以省略
+func parseSignal(name string) (os.Signal, error) {
+	name = strings.ToUpper(name)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	sig, ok := signals[name]
+	if !ok {
+		return nil, fmt.Errorf("unsupported signal %s", name)
+	}
+	return sig, nil
+}
+
+// isTerminating 收到后命令退出不再重启的信号
+func isTerminating(sig os.Signal) bool {
+	return sig == syscall.SIGINT || sig == syscall.SIGTERM || sig == syscall.SIGQUIT
+}
+
+func forwardSignal(p *os.Process, sig os.Signal) error {
+	return p.Signal(sig)
+}
+
+// execReplace 用命令替换当前进程，成功时不会返回
+func execReplace(path string, args, env []string) error {
+	return syscall.Exec(path, args, env)
+}
+
+// signaled 命令是否被信号终止
+func signaled(err *exec.ExitError) (int, bool) {
+	if ws, ok := err.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return int(ws.Signal()), true
+	}
+	return 0, false
+}
@@ -0,0 +1,44 @@
+//go:build windows
+
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// defaultStopSignal Windows 不支持向其他进程发送信号，重启时直接结束命令
+const defaultStopSignal = "SIGKILL"
+
+var errExecUnsupported = errors.New("exec is not supported on windows")
+
+var forwardSignals = []os.Signal{os.Interrupt}
+
+// forwardSignal 同一控制台中的命令会直接收到 Ctrl-C，不需要转发
+func forwardSignal(p *os.Process, sig os.Signal) error {
+	return nil
+}
+
+// parseSignal Windows 上只支持 SIGKILL
+func parseSignal(name string) (os.Signal, error) {
+	switch strings.ToUpper(name) {
+	case "SIGKILL", "KILL":
+		return os.Kill, nil
+	}
+	return nil, errors.New("only SIGKILL is supported on windows")
+}
+
+func isTerminating(sig os.Signal) bool {
+	return sig == os.Interrupt
+}
+
+// execReplace Windows 不能替换进程，由 supervise 作为子进程运行
+func execReplace(path string, args, env []string) error {
+	return errExecUnsupported
+}
+
+func signaled(err *exec.ExitError) (int, bool) {
+	return 0, false
+}
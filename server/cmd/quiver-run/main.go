@@ -0,0 +1,216 @@
+// quiver-run 拉取命名空间的最新版本，把配置项转换为环境变量后启动命令，
+// 只读取环境变量的服务（twelve-factor）不需要修改代码就可以接入 Quiver。
+// 指定 -restart 时订阅变化，有新的发布后用新的环境变量重启命令。
+//
+// 用法：
+//
+//	quiver-run -server quiver.internal:9090 -env pro -app X -cluster Y -ns a,b -- ./myservice -port 8080
+//
+// 退出码：不重启时直接替换为命令进程（Windows 上等待命令结束），退出码就是命令的退出码；
+// quiver-run 自身出错时为 125，命令无法执行为 126，命令不存在为 127，命令被信号终止时为 128+信号值。
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/exec"
+	"quiver/sdk"
+	"strings"
+	"time"
+)
+
+const (
+	exitError       = 125
+	exitCannotExec  = 126
+	exitNotFound    = 127
+	exitSignalBased = 128
+)
+
+const usage = `quiver-run - run a command with Quiver configs as environment variables
+
+Usage:
+  quiver-run [flags] -- command [args...]
+
+Every key of the namespaces becomes an environment variable: characters other
+than letters, digits and "_" are replaced with "_", the case is transformed and
+the prefix is prepended, e.g. db.host -> APP_DB_HOST with -prefix APP_.
+When a key appears in more than one namespace the later namespace wins.
+
+Flags:
+`
+
+// stringList 可以重复指定、逗号分隔的参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(v string) error {
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			*l = append(*l, s)
+		}
+	}
+	return nil
+}
+
+// runConfig 命令行参数
+type runConfig struct {
+	server     string
+	env        string
+	app        string
+	cluster    string
+	namespaces stringList
+	accessKey  string
+	secretKey  string
+	tls        bool
+	caFile     string
+	serverName string
+	timeout    time.Duration
+
+	prefix     string
+	caseMode   string
+	noOverride bool
+
+	restart       bool
+	restartDelay  time.Duration
+	restartSignal string
+	stopTimeout   time.Duration
+}
+
+func main() {
+	log.SetPrefix("quiver-run ")
+	log.SetFlags(log.LstdFlags | log.Lmsgprefix)
+	os.Exit(run())
+}
+
+func run() int {
+	var conf runConfig
+	flag.StringVar(&conf.server, "server", os.Getenv("QUIVER_SERVER"), "server gRPC address, e.g. quiver.internal:9090 ($QUIVER_SERVER)")
+	flag.StringVar(&conf.env, "env", os.Getenv("QUIVER_ENV"), "environment ($QUIVER_ENV)")
+	flag.StringVar(&conf.app, "app", "", "app name")
+	flag.StringVar(&conf.cluster, "cluster", "default", "cluster of namespaces given without one")
+	flag.Var(&conf.namespaces, "ns", `namespaces, comma separated or repeated, "namespace" or "cluster/namespace"`)
+	flag.StringVar(&conf.accessKey, "access-key", os.Getenv("QUIVER_ACCESS_KEY"), "access key ($QUIVER_ACCESS_KEY); the secret key is read from $QUIVER_SECRET_KEY")
+	flag.BoolVar(&conf.tls, "tls", false, "connect to the server over TLS")
+	flag.StringVar(&conf.caFile, "ca-file", "", "CA certificate for TLS (default system roots)")
+	flag.StringVar(&conf.serverName, "server-name", "", "server name for TLS (default host of -server)")
+	flag.DurationVar(&conf.timeout, "timeout", 30*time.Second, "timeout for fetching configs before starting the command")
+	flag.StringVar(&conf.prefix, "prefix", "", "prefix of environment variable names, e.g. APP_")
+	flag.StringVar(&conf.caseMode, "case", "upper", "case of environment variable names: upper, lower or keep")
+	flag.BoolVar(&conf.noOverride, "no-override", false, "keep variables already set in the environment instead of overriding them")
+	flag.BoolVar(&conf.restart, "restart", false, "restart the command when a new release changes its environment")
+	flag.DurationVar(&conf.restartDelay, "restart-delay", 2*time.Second, "wait for further releases before restarting")
+	flag.StringVar(&conf.restartSignal, "restart-signal", defaultStopSignal, "signal that asks the command to stop before a restart")
+	flag.DurationVar(&conf.stopTimeout, "stop-timeout", 10*time.Second, "kill the command if it has not exited this long after the restart signal")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	conf.secretKey = os.Getenv("QUIVER_SECRET_KEY")
+
+	args := flag.Args()
+	if err := conf.validate(args); err != nil {
+		log.Printf("%v", err)
+		flag.Usage()
+		return exitError
+	}
+
+	path, err := exec.LookPath(args[0])
+	if err != nil {
+		log.Printf("%v", err)
+		if errors.Is(err, fs.ErrPermission) {
+			return exitCannotExec
+		}
+		return exitNotFound
+	}
+
+	r, err := newRunner(&conf)
+	if err != nil {
+		log.Printf("init: %v", err)
+		return exitError
+	}
+	defer r.client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.timeout)
+	err = r.load(ctx)
+	cancel()
+	if err != nil {
+		log.Printf("fetch configs: %v", err)
+		return exitError
+	}
+	env, err := r.environ()
+	if err != nil {
+		log.Printf("%v", err)
+		return exitError
+	}
+
+	if !conf.restart {
+		// 替换当前进程，信号和退出码都直接由命令处理；不支持时退回到作为子进程运行
+		err := execReplace(path, args, env)
+		if !errors.Is(err, errExecUnsupported) {
+			log.Printf("exec %s: %v", args[0], err)
+			return exitCannotExec
+		}
+		return supervise(path, args, env, nil, &conf)
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	updates := make(chan []string, 1)
+	go r.watch(ctx, env, updates)
+	return supervise(path, args, env, updates, &conf)
+}
+
+// validate 校验参数，args 为要执行的命令
+func (conf *runConfig) validate(args []string) error {
+	if len(args) == 0 {
+		return errors.New("command is required")
+	}
+	if conf.server == "" || conf.env == "" || conf.app == "" {
+		return errors.New("-server, -env and -app are required")
+	}
+	if len(conf.namespaces) == 0 {
+		return errors.New("-ns is required")
+	}
+	if conf.accessKey == "" || conf.secretKey == "" {
+		return errors.New("-access-key and $QUIVER_SECRET_KEY are required")
+	}
+	if len(conf.namespaces) > 100 {
+		return errors.New("at most 100 namespaces can be watched")
+	}
+	switch conf.caseMode {
+	case "upper", "lower", "keep":
+	default:
+		return fmt.Errorf("invalid -case %s, use upper, lower or keep", conf.caseMode)
+	}
+	if conf.prefix != "" && envName("", conf.prefix, "keep") != conf.prefix {
+		return fmt.Errorf("invalid -prefix %s, use letters, digits and _", conf.prefix)
+	}
+	if _, err := parseSignal(conf.restartSignal); err != nil {
+		return err
+	}
+	if !conf.tls && (conf.caFile != "" || conf.serverName != "") {
+		return errors.New("-ca-file and -server-name require -tls")
+	}
+	return nil
+}
+
+// clientConfig 连接服务端的配置
+func (conf *runConfig) clientConfig() sdk.Config {
+	return sdk.Config{
+		Server:     conf.server,
+		AccessKey:  conf.accessKey,
+		SecretKey:  conf.secretKey,
+		TLS:        conf.tls,
+		CAFile:     conf.caFile,
+		ServerName: conf.serverName,
+	}
+}
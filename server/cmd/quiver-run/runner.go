@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"quiver/sdk"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 30 * time.Second
+)
+
+// runner 拉取命名空间的配置并转换为环境变量
+type runner struct {
+	conf   *runConfig
+	client *sdk.Client
+	order  []sdk.NamespaceRef
+
+	mu         sync.RWMutex
+	namespaces map[sdk.NamespaceRef]*sdk.Namespace
+}
+
+func newRunner(conf *runConfig) (*runner, error) {
+	r := &runner{conf: conf, namespaces: make(map[sdk.NamespaceRef]*sdk.Namespace, len(conf.namespaces))}
+	seen := make(map[sdk.NamespaceRef]bool, len(conf.namespaces))
+	for _, name := range conf.namespaces {
+		ref, err := sdk.ParseNamespace(name, conf.cluster)
+		if err != nil {
+			return nil, err
+		}
+		if seen[ref] {
+			return nil, fmt.Errorf("duplicate namespace %s", ref)
+		}
+		seen[ref] = true
+		r.order = append(r.order, ref)
+	}
+
+	client, err := sdk.NewClient(conf.clientConfig())
+	if err != nil {
+		return nil, err
+	}
+	r.client = client
+	return r, nil
+}
+
+// load 拉取所有命名空间的完整配置，有命名空间还没有任何发布时返回错误，避免命令缺少配置启动
+func (r *runner) load(ctx context.Context) error {
+	for _, ref := range r.order {
+		if _, err := r.fetch(ctx, ref); err != nil {
+			if errors.Is(err, sdk.ErrNoRelease) {
+				return fmt.Errorf("namespace %s has no release yet", ref)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch 拉取命名空间的完整配置，返回版本是否变化
+func (r *runner) fetch(ctx context.Context, ref sdk.NamespaceRef) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	latest, err := r.client.GetNamespace(ctx, r.conf.env, r.conf.app, ref)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ns, ok := r.namespaces[ref]; ok && ns.ReleaseID == latest.ReleaseID {
+		return false, nil
+	}
+	r.namespaces[ref] = latest
+	log.Printf("namespace %s at release %s (%d items)", ref, latest.ReleaseID, len(latest.Items))
+	return true, nil
+}
+
+// environ 当前进程的环境变量加上配置项，按名称排序；后面的命名空间覆盖前面的，
+// 同一个命名空间中多个 key 转换为同一个名称时返回错误
+func (r *runner) environ() ([]string, error) {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if name, value, ok := strings.Cut(kv, "="); ok {
+			vars[name] = value
+		}
+	}
+	inherited := make(map[string]bool, len(vars))
+	for name := range vars {
+		inherited[name] = true
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, ref := range r.order {
+		ns, ok := r.namespaces[ref]
+		if !ok {
+			continue
+		}
+		seen := make(map[string]string, len(ns.Items))
+		for _, key := range sortedKeys(ns.Items) {
+			name := envName(r.conf.prefix, key, r.conf.caseMode)
+			if other, ok := seen[name]; ok {
+				return nil, fmt.Errorf("keys %s and %s of namespace %s both map to %s", other, key, ref, name)
+			}
+			seen[name] = key
+
+			value := ns.Items[key]
+			if strings.IndexByte(value, 0) >= 0 {
+				return nil, fmt.Errorf("value of key %s in namespace %s contains a NUL byte", key, ref)
+			}
+			if r.conf.noOverride && inherited[name] {
+				continue
+			}
+			vars[name] = value
+		}
+	}
+
+	env := make([]string, 0, len(vars))
+	for _, name := range sortedKeys(vars) {
+		env = append(env, name+"="+vars[name])
+	}
+	return env, nil
+}
+
+// envName 把 key 转换为环境变量名称：字母、数字和 _ 以外的字符替换为 _，按 caseMode 转换大小写，
+// 加上前缀后以数字开头时再加一个 _
+func envName(prefix, key, caseMode string) string {
+	switch caseMode {
+	case "upper":
+		key = strings.ToUpper(key)
+	case "lower":
+		key = strings.ToLower(key)
+	}
+	b := []byte(prefix + key)
+	for i, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// watch 订阅所有命名空间，断开后按指数退避重连；环境变量变化并且 restart-delay 内没有新的发布后，
+// 把新的环境变量发送到 updates（只保留最新的一份），直到 ctx 结束
+func (r *runner) watch(ctx context.Context, current []string, updates chan []string) {
+	changed := make(chan struct{}, 1)
+	go r.subscribe(ctx, changed)
+
+	var delay <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changed:
+			delay = time.After(r.conf.restartDelay)
+		case <-delay:
+			delay = nil
+			env, err := r.environ()
+			if err != nil {
+				log.Printf("new release ignored: %v", err)
+				continue
+			}
+			if slices.Equal(env, current) {
+				continue
+			}
+			current = env
+			select {
+			case <-updates:
+			default:
+			}
+			updates <- env
+		}
+	}
+}
+
+// subscribe 订阅变化，收到事件后拉取完整配置，版本变化时通知 changed
+func (r *runner) subscribe(ctx context.Context, changed chan<- struct{}) {
+	backoff := minBackoff
+	for ctx.Err() == nil {
+		known := make(map[sdk.NamespaceRef]string, len(r.order))
+		r.mu.RLock()
+		for ref, ns := range r.namespaces {
+			known[ref] = ns.ReleaseID
+		}
+		r.mu.RUnlock()
+
+		started := time.Now()
+		err := r.client.Watch(ctx, r.conf.env, r.conf.app, known, func(ref sdk.NamespaceRef, _ string) error {
+			ok, err := r.fetch(ctx, ref)
+			if err != nil {
+				return err
+			}
+			if ok {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			}
+			return nil
+		})
+		if ctx.Err() != nil {
+			return
+		}
+
+		// 连接保持了一段时间后断开，从最小间隔重新开始
+		if time.Since(started) > maxBackoff {
+			backoff = minBackoff
+		}
+		log.Printf("disconnected from %s: %v, retrying in %s", r.conf.server, err, backoff)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"time"
+)
+
+// child 运行中的命令
+type child struct {
+	cmd  *exec.Cmd
+	done chan struct{}
+	err  error // Wait 的结果，done 关闭后可读
+}
+
+func startChild(path string, args, env []string) (*child, error) {
+	cmd := &exec.Cmd{
+		Path:   path,
+		Args:   args,
+		Env:    env,
+		Stdin:  os.Stdin,
+		Stdout: os.Stdout,
+		Stderr: os.Stderr,
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	c := &child{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		c.err = cmd.Wait()
+		close(c.done)
+	}()
+	return c, nil
+}
+
+// supervise 作为子进程运行命令并转发信号，返回命令的退出码。
+// updates 收到新的环境变量时先发送 restart-signal，命令退出后用新的环境变量重新启动，
+// 超过 stop-timeout 没有退出时强制结束；命令自己退出时 quiver-run 随之退出
+func supervise(path string, args, env []string, updates <-chan []string, conf *runConfig) int {
+	sigs := make(chan os.Signal, 8)
+	signal.Notify(sigs, forwardSignals...)
+	defer signal.Stop(sigs)
+
+	c, err := startChild(path, args, env)
+	if err != nil {
+		log.Printf("start %s: %v", args[0], err)
+		return exitCannotExec
+	}
+
+	var (
+		stopping   bool     // 收到了终止信号，命令退出后不再重启
+		pending    []string // 等待命令退出后使用的新环境变量
+		killTimer  *time.Timer
+		killTimerC <-chan time.Time
+	)
+	stopKillTimer := func() {
+		if killTimer != nil {
+			killTimer.Stop()
+			killTimer, killTimerC = nil, nil
+		}
+	}
+
+	for {
+		select {
+		case sig := <-sigs:
+			if isTerminating(sig) {
+				stopping = true
+			}
+			if err := forwardSignal(c.cmd.Process, sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+				log.Printf("forward %s: %v", sig, err)
+			}
+
+		case env := <-updates:
+			if stopping {
+				continue
+			}
+			if pending == nil {
+				log.Printf("configuration changed, restarting %s", args[0])
+				sig, _ := parseSignal(conf.restartSignal)
+				if err := c.cmd.Process.Signal(sig); err != nil && !errors.Is(err, os.ErrProcessDone) {
+					log.Printf("send %s: %v", conf.restartSignal, err)
+				}
+				killTimer = time.NewTimer(conf.stopTimeout)
+				killTimerC = killTimer.C
+			}
+			pending = env
+
+		case <-killTimerC:
+			killTimer, killTimerC = nil, nil
+			log.Printf("%s did not exit within %s, killing it", args[0], conf.stopTimeout)
+			_ = c.cmd.Process.Kill()
+
+		case <-c.done:
+			stopKillTimer()
+			if pending == nil || stopping {
+				return exitStatus(c.err)
+			}
+			next, err := startChild(path, args, pending)
+			if err != nil {
+				log.Printf("restart %s: %v", args[0], err)
+				return exitCannotExec
+			}
+			c, pending = next, nil
+			log.Printf("restarted %s (pid %d)", args[0], c.cmd.Process.Pid)
+		}
+	}
+}
+
+// exitStatus 命令的退出码，被信号终止时为 128+信号值
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		log.Printf("wait: %v", err)
+		return exitError
+	}
+	if sig, ok := signaled(exitErr); ok {
+		return exitSignalBased + sig
+	}
+	return exitErr.ExitCode()
+}
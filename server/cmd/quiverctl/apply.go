@@ -0,0 +1,244 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// applyChange 计划中的一项修改，与服务端 services.ApplyChange 一致
+type applyChange struct {
+	Action        string `json:"action"`
+	Kind          string `json:"kind"`
+	AppName       string `json:"app_name"`
+	ClusterName   string `json:"cluster_name,omitempty"`
+	NamespaceName string `json:"namespace_name,omitempty"`
+	Key           string `json:"key,omitempty"`
+	Value         string `json:"value,omitempty"`
+	OldValue      string `json:"old_value,omitempty"`
+	ReleaseID     string `json:"release_id,omitempty"`
+}
+
+// applyPlan 服务端返回的计划或执行结果
+type applyPlan struct {
+	Env     string `json:"env"`
+	DryRun  bool   `json:"dry_run"`
+	Applied bool   `json:"applied"`
+	PlanID  string `json:"plan_id"`
+	Summary struct {
+		Create  int `json:"create"`
+		Update  int `json:"update"`
+		Delete  int `json:"delete"`
+		Publish int `json:"publish"`
+	} `json:"summary"`
+	Changes []applyChange `json:"changes"`
+}
+
+// runApply 把清单提交给服务端计算计划并展示，确认后带上 plan_id 执行；
+// 展示之后服务端的状态有变化时返回冲突，不会执行与展示内容不同的计划
+func runApply(c *cli, args []string) error {
+	fs := c.flags("apply", "-f FILE [--prune=false] [--publish [--name NAME] [-m COMMENT]] [--dry-run | -y]")
+	var file, name, comment, operator string
+	var dryRun, publish, yes bool
+	fs.StringVar(&file, "file", "", "manifest file (YAML or JSON), - for stdin")
+	fs.StringVar(&file, "f", "", "shorthand for --file")
+	prune := fs.Bool("prune", true, "delete clusters, namespaces and items that are not listed in the manifest")
+	fs.BoolVar(&publish, "publish", false, "publish the namespaces whose items differ from their latest release")
+	fs.StringVar(&name, "name", "", "with --publish, release name (default <timestamp>-apply)")
+	fs.StringVar(&comment, "comment", "", "with --publish, release comment")
+	fs.StringVar(&comment, "m", "", "shorthand for --comment")
+	fs.StringVar(&operator, "operator", "", "with --publish, operator (default the logged-in user)")
+	fs.BoolVar(&dryRun, "dry-run", false, "only show the plan")
+	fs.BoolVar(&yes, "yes", false, "apply without asking for confirmation")
+	fs.BoolVar(&yes, "y", false, "shorthand for --yes")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	if file == "" {
+		return usagef("--file is required")
+	}
+	// 确认要从终端读取，计划以 JSON、YAML 输出时也没有给人看的地方
+	if !dryRun && !yes {
+		if file == "-" {
+			return usagef("--yes or --dry-run is required when reading the manifest from stdin")
+		}
+		if c.opts.output != "table" {
+			return usagef("--yes or --dry-run is required with -o %s", c.opts.output)
+		}
+	}
+
+	var manifest []byte
+	if file == "-" {
+		manifest, err = io.ReadAll(stdin)
+	} else {
+		manifest, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(manifest)) == "" {
+		return usagef("manifest %s is empty", file)
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{
+		"dry_run": {"true"},
+		"prune":   {strconv.FormatBool(*prune)},
+		"publish": {strconv.FormatBool(publish)},
+	}
+	if publish {
+		operator = firstNonEmpty(operator, cl.userName())
+		if operator == "" {
+			return usagef("--operator is required when not logged in")
+		}
+		if name == "" {
+			name = time.Now().Format("20060102150405") + "-apply"
+		}
+		query.Set("operator", operator)
+		query.Set("release_name", name)
+		query.Set("comment", comment)
+	}
+
+	body := rawBody{contentType: "application/yaml", data: manifest}
+	var plan applyPlan
+	if err := cl.do(http.MethodPost, "/apply", query, body, &plan); err != nil {
+		return err
+	}
+	if dryRun || len(plan.Changes) == 0 {
+		if c.opts.output == "table" {
+			printPlan(c, &plan)
+			return nil
+		}
+		return c.printValue(plan, nil)
+	}
+
+	if !yes {
+		printPlan(c, &plan)
+		answer, err := prompt("\nApply these changes to env "+cl.env+"? [y/N] ", false)
+		if err != nil {
+			return err
+		}
+		if a := strings.ToLower(strings.TrimSpace(answer)); a != "y" && a != "yes" {
+			c.message("Apply cancelled, no changes made.")
+			return nil
+		}
+	}
+
+	query.Set("dry_run", "false")
+	query.Set("plan_id", plan.PlanID)
+	var result applyPlan
+	if err := cl.do(http.MethodPost, "/apply", query, body, &result); err != nil {
+		return err
+	}
+	if c.opts.output != "table" {
+		return c.printValue(result, nil)
+	}
+	if yes {
+		printPlan(c, &result)
+		return nil
+	}
+	for _, ch := range result.Changes {
+		if ch.Action == "publish" {
+			fmt.Fprintf(c.stdout, "published %s as %s\n", ch.path(), ch.ReleaseID)
+		}
+	}
+	fmt.Fprintf(c.stdout, "Applied: %s\n", result.summary())
+	return nil
+}
+
+// applySymbols 表格中每种操作的前缀
+var applySymbols = map[string]string{
+	"create":  "+",
+	"update":  "~",
+	"delete":  "-",
+	"publish": ">",
+}
+
+// printPlan 每项修改一行：操作、类型、路径和内容，最后一行为汇总
+func printPlan(c *cli, plan *applyPlan) {
+	if len(plan.Changes) == 0 {
+		fmt.Fprintf(c.stdout, "no changes, env %s matches the manifest\n", plan.Env)
+		return
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	for _, ch := range plan.Changes {
+		line := applySymbols[ch.Action] + " " + ch.Kind + "\t" + ch.path()
+		if detail := ch.detail(); detail != "" {
+			line += "\t" + detail
+		}
+		fmt.Fprintln(w, line)
+	}
+	w.Flush()
+
+	if plan.Applied {
+		fmt.Fprintf(c.stdout, "Applied: %s\n", plan.summary())
+	} else {
+		fmt.Fprintf(c.stdout, "Plan: %s\n", plan.summary())
+	}
+}
+
+// summary 汇总各类修改的数量，执行后的结果用过去时
+func (p *applyPlan) summary() string {
+	verbs := []string{"to create", "to update", "to delete", "to publish"}
+	if p.Applied {
+		verbs = []string{"created", "updated", "deleted", "published"}
+	}
+	s := fmt.Sprintf("%d %s, %d %s, %d %s", p.Summary.Create, verbs[0], p.Summary.Update, verbs[1], p.Summary.Delete, verbs[2])
+	if p.Summary.Publish > 0 {
+		s += fmt.Sprintf(", %d %s", p.Summary.Publish, verbs[3])
+	}
+	return s
+}
+
+// path 应用/集群/命名空间
+func (ch *applyChange) path() string {
+	parts := []string{ch.AppName}
+	for _, p := range []string{ch.ClusterName, ch.NamespaceName} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+// detail 配置项为 key 和值，应用、集群、命名空间为描述，发布为生成的版本
+func (ch *applyChange) detail() string {
+	field := "description"
+	if ch.Kind == "item" {
+		field = ch.Key
+	}
+	switch ch.Action {
+	case "create":
+		if ch.Kind != "item" && ch.Value == "" {
+			return ""
+		}
+		return field + " = " + cell(ch.Value)
+	case "update":
+		return field + " = " + cell(ch.OldValue) + " -> " + cell(ch.Value)
+	case "delete":
+		if ch.Kind != "item" {
+			return ""
+		}
+		return field + " = " + cell(ch.OldValue)
+	case "publish":
+		if ch.ReleaseID != "" {
+			return "release " + ch.ReleaseID
+		}
+	}
+	return ""
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+)
+
+// cli 一次命令执行的上下文
+type cli struct {
+	stdout io.Writer
+	stderr io.Writer
+	opts   globalOptions
+}
+
+// globalOptions 所有命令都接受的参数
+type globalOptions struct {
+	server string
+	env    string
+	output string
+	config string
+}
+
+// usageError 参数错误，退出码为 exitUsage
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usagef(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// conflictError 客户端发现的冲突，例如编辑期间草稿被其他人修改，退出码为 exitConflict
+type conflictError struct {
+	msg string
+}
+
+func (e *conflictError) Error() string {
+	return e.msg
+}
+
+// errDiffFound release diff --exit-code 发现差异
+var errDiffFound = errors.New("differences found")
+
+// exitCode 把错误转换为进程退出码
+func exitCode(err error) int {
+	var usageErr *usageError
+	var conflictErr *conflictError
+	var apiErr *APIError
+	var urlErr *url.Error
+	switch {
+	case errors.Is(err, errDiffFound):
+		return exitDiff
+	case errors.As(err, &usageErr):
+		return exitUsage
+	case errors.As(err, &conflictErr):
+		return exitConflict
+	case errors.Is(err, errNotLoggedIn):
+		return exitAuth
+	case errors.As(err, &apiErr):
+		switch apiErr.Status {
+		case 400:
+			return exitUsage
+		case 401, 403:
+			return exitAuth
+		case 404:
+			return exitNotFound
+		case 409:
+			return exitConflict
+		}
+		return exitError
+	case errors.As(err, &urlErr):
+		return exitNetwork
+	}
+	return exitError
+}
+
+// flags 创建子命令的 FlagSet，并注册全局参数
+func (c *cli) flags(name, argsUsage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.opts.server, "server", "", "server address, e.g. http://127.0.0.1:8080")
+	fs.StringVar(&c.opts.server, "s", "", "shorthand for --server")
+	fs.StringVar(&c.opts.env, "env", "", "environment, dev or pro")
+	fs.StringVar(&c.opts.env, "e", "", "shorthand for --env")
+	fs.StringVar(&c.opts.output, "output", "table", "output format: table, json or yaml")
+	fs.StringVar(&c.opts.output, "o", "table", "shorthand for --output")
+	fs.StringVar(&c.opts.config, "config", "", "credentials file")
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: quiverctl %s %s\n\nFlags:\n", name, argsUsage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse 解析参数，flag 可以出现在位置参数之后；返回位置参数
+func (c *cli) parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, &usageError{msg: err.Error()}
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	switch c.opts.output {
+	case "table", "json", "yaml":
+	default:
+		return nil, usagef("unsupported output format %q, use table, json or yaml", c.opts.output)
+	}
+	return positional, nil
+}
+
+// scope 应用、集群、命名空间参数
+type scope struct {
+	app       string
+	cluster   string
+	namespace string
+}
+
+const (
+	scopeApp = iota + 1
+	scopeCluster
+	scopeNamespace
+)
+
+// register 注册到 level 为止的参数
+func (s *scope) register(fs *flag.FlagSet, level int) {
+	fs.StringVar(&s.app, "app", "", "app name")
+	fs.StringVar(&s.app, "a", "", "shorthand for --app")
+	if level >= scopeCluster {
+		fs.StringVar(&s.cluster, "cluster", "", "cluster name")
+		fs.StringVar(&s.cluster, "c", "", "shorthand for --cluster")
+	}
+	if level >= scopeNamespace {
+		fs.StringVar(&s.namespace, "namespace", "", "namespace name")
+		fs.StringVar(&s.namespace, "n", "", "shorthand for --namespace")
+	}
+}
+
+// path 校验参数后返回 level 对应的资源路径
+func (s *scope) path(level int) (string, error) {
+	if s.app == "" {
+		return "", usagef("--app is required")
+	}
+	p := "/apps/" + url.PathEscape(s.app)
+	if level >= scopeCluster {
+		if s.cluster == "" {
+			return "", usagef("--cluster is required")
+		}
+		p += "/clusters/" + url.PathEscape(s.cluster)
+	}
+	if level >= scopeNamespace {
+		if s.namespace == "" {
+			return "", usagef("--namespace is required")
+		}
+		p += "/namespaces/" + url.PathEscape(s.namespace)
+	}
+	return p, nil
+}
+
+// checkArgs 检查位置参数的个数
+func checkArgs(positional []string, min, max int, fs *flag.FlagSet) error {
+	if len(positional) < min || (max >= 0 && len(positional) > max) {
+		fs.Usage()
+		return usagef("wrong number of arguments")
+	}
+	return nil
+}
+
+// subcommand 取出子命令
+func subcommand(name string, args []string, subs map[string]func(c *cli, args []string) error) (func(c *cli, args []string) error, []string, error) {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" {
+		return nil, nil, usagef("%s requires a subcommand: %s", name, subNames(subs))
+	}
+	sub, ok := subs[args[0]]
+	if !ok {
+		return nil, nil, usagef("unknown subcommand %q for %s, use one of: %s", args[0], name, subNames(subs))
+	}
+	return sub, args[1:], nil
+}
+
+func subNames(subs map[string]func(c *cli, args []string) error) string {
+	names := make([]string, 0, len(subs))
+	for name := range subs {
+		names = append(names, name)
+	}
+	return joinSorted(names, ", ")
+}
+
+// getenv 读取环境变量，未设置时返回 def
+func getenv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
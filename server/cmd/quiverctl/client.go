@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// APIError 服务端返回的错误响应
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.Status)
+}
+
+// response 统一响应结构，与服务端 utils.Response 一致
+type response struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// rawBody 原样发送的请求体，其他类型的请求体编码为 JSON
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+// client 访问 /api/v1/envs/{env} 下的接口，令牌过期时用 refresh token 自动续期并写回凭证文件
+type client struct {
+	server string
+	env    string
+	creds  *credentials
+	sess   *session
+	http   *http.Client
+}
+
+// client 按 参数 > 环境变量 > 凭证文件 的顺序确定服务端地址和环境；
+// 设置了 $QUIVER_TOKEN 时直接使用它作为访问令牌，不读取保存的会话
+func (c *cli) client() (*client, error) {
+	path, err := credentialsPath(c.opts.config)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := loadCredentials(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cl := &client{
+		server: strings.TrimRight(firstNonEmpty(c.opts.server, getenv("QUIVER_SERVER", ""), creds.Server), "/"),
+		env:    firstNonEmpty(c.opts.env, getenv("QUIVER_ENV", ""), creds.Env),
+		creds:  creds,
+		http:   &http.Client{Timeout: 30 * time.Second},
+	}
+	if cl.server == "" {
+		return nil, usagef("no server configured, pass --server or run \"quiverctl login --server URL\"")
+	}
+	if cl.env == "" {
+		return nil, usagef("no env configured, pass --env or run \"quiverctl login --env ENV\"")
+	}
+
+	if token := getenv("QUIVER_TOKEN", ""); token != "" {
+		cl.sess = &session{Token: token}
+	} else if cl.server == creds.Server {
+		cl.sess = creds.Sessions[cl.env]
+	}
+	return cl, nil
+}
+
+// do 调用接口并把响应中的 data 解析到 out，out 为 nil 时忽略响应内容
+func (cl *client) do(method, path string, query url.Values, body, out interface{}) error {
+	data, err := cl.request(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+
+	var resp response
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("invalid response from server: %v", err)
+	}
+	if raw, ok := out.(*json.RawMessage); ok {
+		*raw = resp.Data
+		return nil
+	}
+	return json.Unmarshal(resp.Data, out)
+}
+
+// request 发送请求，返回 2xx 响应的原始内容；401 时尝试刷新令牌后重试一次
+func (cl *client) request(method, path string, query url.Values, body interface{}) ([]byte, error) {
+	if cl.sess != nil && cl.sess.RefreshToken != "" && !cl.sess.ExpiresAt.IsZero() && time.Now().After(cl.sess.ExpiresAt) {
+		if err := cl.refresh(); err != nil {
+			return nil, err
+		}
+	}
+
+	status, data, err := cl.send(method, path, query, body)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusUnauthorized && cl.sess != nil && cl.sess.RefreshToken != "" {
+		if err := cl.refresh(); err != nil {
+			return nil, err
+		}
+		if status, data, err = cl.send(method, path, query, body); err != nil {
+			return nil, err
+		}
+	}
+
+	if status < 200 || status > 299 {
+		return nil, apiError(status, data)
+	}
+	return data, nil
+}
+
+func (cl *client) send(method, path string, query url.Values, body interface{}) (int, []byte, error) {
+	u := cl.server + "/api/v1/envs/" + url.PathEscape(cl.env) + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	contentType := "application/json"
+	switch b := body.(type) {
+	case nil:
+	case rawBody:
+		reader, contentType = bytes.NewReader(b.data), b.contentType
+	default:
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequest(method, u, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if cl.sess != nil && cl.sess.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+cl.sess.Token)
+	}
+
+	resp, err := cl.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, data, nil
+}
+
+// refresh 用 refresh token 换取新的令牌并保存；refresh token 也失效时需要重新登录
+func (cl *client) refresh() error {
+	if !cl.sess.RefreshExpiresAt.IsZero() && time.Now().After(cl.sess.RefreshExpiresAt) {
+		return fmt.Errorf("%w (session expired)", errNotLoggedIn)
+	}
+
+	status, data, err := cl.send(http.MethodPost, "/auth/refresh", nil, map[string]string{"refresh_token": cl.sess.RefreshToken})
+	if err != nil {
+		return err
+	}
+	if status == http.StatusUnauthorized {
+		return fmt.Errorf("%w (%s)", errNotLoggedIn, apiError(status, data).Message)
+	}
+	if status < 200 || status > 299 {
+		return apiError(status, data)
+	}
+
+	var resp response
+	var tokens session
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return fmt.Errorf("invalid response from server: %v", err)
+	}
+	if err := json.Unmarshal(resp.Data, &tokens); err != nil {
+		return fmt.Errorf("invalid response from server: %v", err)
+	}
+	tokens.UserName = cl.sess.UserName
+	*cl.sess = tokens
+
+	return cl.creds.save()
+}
+
+func apiError(status int, data []byte) *APIError {
+	var resp response
+	if err := json.Unmarshal(data, &resp); err == nil && resp.Message != "" {
+		return &APIError{Status: status, Message: resp.Message}
+	}
+	msg := strings.TrimSpace(string(data))
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+	return &APIError{Status: status, Message: msg}
+}
+
+// userName 当前登录的用户名，用作发布、回滚的 operator
+func (cl *client) userName() string {
+	if cl.sess == nil {
+		return ""
+	}
+	return cl.sess.UserName
+}
+
+// list 分页拉取列表接口的全部记录，field 为响应中列表字段的名称
+func (cl *client) list(path string, query url.Values, field string) ([]json.RawMessage, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	const size = 200
+
+	var all []json.RawMessage
+	for page := 1; ; page++ {
+		query.Set("page", fmt.Sprint(page))
+		query.Set("size", fmt.Sprint(size))
+
+		var data map[string]json.RawMessage
+		if err := cl.do(http.MethodGet, path, query, nil, &data); err != nil {
+			return nil, err
+		}
+		var rows []json.RawMessage
+		if raw, ok := data[field]; ok && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &rows); err != nil {
+				return nil, fmt.Errorf("invalid response from server: %v", err)
+			}
+		}
+		var total int
+		if raw, ok := data["total"]; ok {
+			_ = json.Unmarshal(raw, &total)
+		}
+
+		all = append(all, rows...)
+		if len(rows) < size || len(all) >= total {
+			return all, nil
+		}
+	}
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// errNotLoggedIn 没有可用的登录凭证
+var errNotLoggedIn = errors.New("not logged in, run \"quiverctl login\" first")
+
+// credentials 保存在本地的登录凭证，令牌按环境分开保存
+type credentials struct {
+	Server   string              `yaml:"server"`
+	Env      string              `yaml:"env"`
+	Sessions map[string]*session `yaml:"sessions,omitempty"`
+
+	path string
+}
+
+// session 登录、刷新接口返回的令牌
+type session struct {
+	UserName         string    `yaml:"user_name" json:"user_name"`
+	SessionID        string    `yaml:"session_id" json:"session_id"`
+	Token            string    `yaml:"token" json:"token"`
+	ExpiresAt        time.Time `yaml:"expires_at" json:"expires_at"`
+	RefreshToken     string    `yaml:"refresh_token" json:"refresh_token"`
+	RefreshExpiresAt time.Time `yaml:"refresh_expires_at" json:"refresh_expires_at"`
+}
+
+// credentialsPath --config、$QUIVER_CONFIG，否则为用户配置目录下的 quiver/config.yaml
+func credentialsPath(flagValue string) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if p := os.Getenv("QUIVER_CONFIG"); p != "" {
+		return p, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "quiver", "config.yaml"), nil
+}
+
+// loadCredentials 读取凭证文件，文件不存在时返回空凭证
+func loadCredentials(path string) (*credentials, error) {
+	creds := &credentials{path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return creds, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(data, creds); err != nil {
+		return nil, errors.New("invalid credentials file " + path + ": " + err.Error())
+	}
+	return creds, nil
+}
+
+// save 写回凭证文件，文件只对当前用户可读写
+func (creds *credentials) save() error {
+	data, err := yaml.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(creds.path), 0o700); err != nil {
+		return err
+	}
+
+	tmp := creds.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, creds.path)
+}
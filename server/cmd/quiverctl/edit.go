@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"quiver/utils"
+	"runtime"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// editResult edit 实际提交的修改
+type editResult struct {
+	Set     []string `json:"set"`
+	Deleted []string `json:"deleted"`
+}
+
+// runEdit 把命名空间草稿区的配置项写入临时 YAML 文件并用编辑器打开，保存退出后把差异提交为修改和删除，
+// 与 kubectl edit 类似：文件内容有误时带着错误信息重新打开，不做任何修改直接退出则放弃编辑；
+// 提交前重新读取草稿，编辑期间草稿被其他人修改时不提交，保留临时文件并以冲突退出
+func runEdit(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("edit", "-a APP -c CLUSTER -n NAMESPACE")
+	s.register(fs, scopeNamespace)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	original, err := draftItems(cl, base)
+	if err != nil {
+		return err
+	}
+	digest := itemsDigest(original)
+	header := fmt.Sprintf("# Draft items of %s/%s/%s/%s, one \"key: value\" per line.\n"+
+		"# Edit values, add keys or delete lines to remove keys; values are always strings.\n"+
+		"# Saving an unchanged file or an empty file aborts the edit.\n",
+		cl.env, s.app, s.cluster, s.namespace)
+	body, err := encodeItems(original)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp("", "quiver-edit-*.yaml")
+	if err != nil {
+		return err
+	}
+	path := f.Name()
+	f.Close()
+	keep := false
+	defer func() {
+		if !keep {
+			os.Remove(path)
+		}
+	}()
+
+	var edited map[string]string
+	var lastErr error
+	for {
+		content := header
+		if lastErr != nil {
+			content += "#\n# Error: " + strings.ReplaceAll(lastErr.Error(), "\n", "\n#   ") + "\n"
+		}
+		content += "\n" + body
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			return err
+		}
+		if err := openEditor(path); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		newBody := stripComments(string(data))
+		if newBody == stripComments(content) {
+			if lastErr != nil {
+				return &usageError{msg: "edit aborted: " + lastErr.Error()}
+			}
+			c.message("Edit cancelled, no changes made.")
+			return nil
+		}
+		if strings.TrimSpace(newBody) == "" {
+			c.message("Edit cancelled, the file is empty.")
+			return nil
+		}
+
+		edited, lastErr = decodeItems([]byte(newBody))
+		body = newBody + "\n"
+		if lastErr == nil {
+			break
+		}
+	}
+
+	result := editResult{Set: []string{}, Deleted: []string{}}
+	for k, v := range edited {
+		if old, ok := original[k]; !ok || old != v {
+			result.Set = append(result.Set, k)
+		}
+	}
+	for k := range original {
+		if _, ok := edited[k]; !ok {
+			result.Deleted = append(result.Deleted, k)
+		}
+	}
+	sort.Strings(result.Set)
+	sort.Strings(result.Deleted)
+	if len(result.Set) == 0 && len(result.Deleted) == 0 {
+		c.message("Edit cancelled, no changes made.")
+		return nil
+	}
+
+	// 编辑可能持续很久，提交前确认草稿没有被其他人修改，避免覆盖别人的改动
+	current, err := draftItems(cl, base)
+	if err != nil {
+		keep = true
+		return fmt.Errorf("%w (your edits are saved in %s)", err, path)
+	}
+	if itemsDigest(current) != digest {
+		keep = true
+		return &conflictError{msg: fmt.Sprintf("draft items of %s/%s/%s/%s were changed by someone else while you were editing, nothing submitted; "+
+			"your edits are saved in %s, run edit again to apply them to the latest draft", cl.env, s.app, s.cluster, s.namespace, path)}
+	}
+
+	// 中途失败时保留临时文件，便于修正后重新提交
+	for _, k := range result.Set {
+		if err := cl.do(http.MethodPost, base+"/items", nil, map[string]string{"key": k, "value": edited[k]}, nil); err != nil {
+			keep = true
+			return fmt.Errorf("set %s: %w (your edits are saved in %s)", k, err, path)
+		}
+		c.message("item %s set", k)
+	}
+	for _, k := range result.Deleted {
+		if err := cl.do(http.MethodDelete, base+"/items/"+url.PathEscape(k), nil, nil, nil); err != nil {
+			keep = true
+			return fmt.Errorf("delete %s: %w (your edits are saved in %s)", k, err, path)
+		}
+		c.message("item %s deleted", k)
+	}
+
+	if c.opts.output != "table" {
+		return c.printValue(result, nil)
+	}
+	return nil
+}
+
+// itemsDigest 草稿配置项的摘要，用于检查编辑期间草稿是否被修改；JSON 编码按 key 排序，结果与 map 的遍历顺序无关
+func itemsDigest(kv map[string]string) string {
+	data, _ := json.Marshal(kv)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// encodeItems 按 key 排序输出 YAML，会被解析为其他类型的值会加引号，多行的值使用块格式
+func encodeItems(kv map[string]string) (string, error) {
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	doc := make(yaml.MapSlice, 0, len(keys))
+	for _, k := range keys {
+		doc = append(doc, yaml.MapItem{Key: k, Value: kv[k]})
+	}
+	if len(doc) == 0 {
+		return "", nil
+	}
+	out, err := yaml.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// decodeItems 解析编辑后的文件：值按原文作为字符串，不允许重复的 key、嵌套结构和空值，
+// key 的格式与服务端的校验一致，避免提交到一半才失败
+func decodeItems(data []byte) (map[string]string, error) {
+	kv := map[string]string{}
+	if err := yaml.UnmarshalStrict(data, &kv); err != nil {
+		return nil, err
+	}
+	var invalid, empty []string
+	for k, v := range kv {
+		if !utils.ValidateItemKey(k) {
+			invalid = append(invalid, k)
+		}
+		if v == "" {
+			empty = append(empty, k)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return nil, errors.New("invalid keys: " + strings.Join(invalid, ", "))
+	}
+	if len(empty) > 0 {
+		sort.Strings(empty)
+		return nil, errors.New("empty values are not allowed, delete the line to remove a key: " + strings.Join(empty, ", "))
+	}
+	return kv, nil
+}
+
+// stripComments 去掉行首的注释行，用于判断文件是否被修改
+func stripComments(s string) string {
+	var b bytes.Buffer
+	for _, line := range strings.SplitAfter(s, "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		b.WriteString(line)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// openEditor 依次使用 $QUIVER_EDITOR、$VISUAL、$EDITOR，都未设置时使用 vi（Windows 上为 notepad）；
+// 编辑器命令可以带参数，例如 "code --wait"
+func openEditor(path string) error {
+	editor := firstNonEmpty(os.Getenv("QUIVER_EDITOR"), os.Getenv("VISUAL"), os.Getenv("EDITOR"))
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	fields := strings.Fields(editor)
+	cmd := exec.Command(fields[0], append(fields[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
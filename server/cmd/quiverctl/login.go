@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var stdin = bufio.NewReader(os.Stdin)
+
+// runLogin 登录并把令牌保存到凭证文件，之后的命令默认使用这次登录的服务端地址和环境
+func runLogin(c *cli, args []string) error {
+	fs := c.flags("login", "[-u USER] [-p PASSWORD | --password-stdin] [--provider local|ldap]")
+	var user, password, provider string
+	var passwordStdin bool
+	fs.StringVar(&user, "user", "", "user name")
+	fs.StringVar(&user, "u", "", "shorthand for --user")
+	fs.StringVar(&password, "password", "", "password (prefer --password-stdin in scripts)")
+	fs.StringVar(&password, "p", "", "shorthand for --password")
+	fs.BoolVar(&passwordStdin, "password-stdin", false, "read the password from stdin")
+	fs.StringVar(&provider, "provider", "", "login provider: local or ldap (default decided by the server)")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+
+	path, err := credentialsPath(c.opts.config)
+	if err != nil {
+		return err
+	}
+	creds, err := loadCredentials(path)
+	if err != nil {
+		return err
+	}
+	server := strings.TrimRight(firstNonEmpty(c.opts.server, getenv("QUIVER_SERVER", ""), creds.Server), "/")
+	env := firstNonEmpty(c.opts.env, getenv("QUIVER_ENV", ""), creds.Env)
+	if server == "" {
+		return usagef("--server is required for the first login")
+	}
+	if env == "" {
+		return usagef("--env is required for the first login")
+	}
+
+	if user == "" {
+		if user, err = prompt("Username: ", false); err != nil {
+			return err
+		}
+	}
+	switch {
+	case passwordStdin:
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	case password == "":
+		if password, err = prompt("Password: ", true); err != nil {
+			return err
+		}
+	}
+
+	cl := &client{server: server, env: env, creds: creds, http: &http.Client{Timeout: 30 * time.Second}}
+	var sess session
+	body := map[string]string{"user_name": user, "password": password, "provider": provider}
+	if err := cl.do(http.MethodPost, "/auth/login", nil, body, &sess); err != nil {
+		return err
+	}
+
+	// 换了服务端时原来的会话都不再有效
+	if creds.Server != server {
+		creds.Sessions = nil
+	}
+	if creds.Sessions == nil {
+		creds.Sessions = make(map[string]*session)
+	}
+	creds.Server, creds.Env = server, env
+	creds.Sessions[env] = &sess
+	if err := creds.save(); err != nil {
+		return err
+	}
+
+	c.message("Logged in to %s (env %s) as %s", server, env, sess.UserName)
+	return nil
+}
+
+// runLogout 吊销当前会话并删除保存的令牌
+func runLogout(c *cli, args []string) error {
+	fs := c.flags("logout", "")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	if cl.sess == nil || cl.sess.RefreshToken == "" {
+		return errNotLoggedIn
+	}
+
+	// 会话已经过期或被吊销时直接删除本地令牌
+	err = cl.do(http.MethodPost, "/auth/logout", nil, nil, nil)
+	var apiErr *APIError
+	if err != nil && !errors.Is(err, errNotLoggedIn) && !(errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized) {
+		return err
+	}
+
+	delete(cl.creds.Sessions, cl.env)
+	if err := cl.creds.save(); err != nil {
+		return err
+	}
+	c.message("Logged out of %s (env %s)", cl.server, cl.env)
+	return nil
+}
+
+// prompt 从终端读取一行，secret 为 true 时关闭回显
+func prompt(label string, secret bool) (string, error) {
+	fmt.Fprint(os.Stderr, label)
+	if secret && stty("-echo") == nil {
+		defer func() {
+			_ = stty("echo")
+			fmt.Fprintln(os.Stderr)
+		}()
+	}
+
+	line, err := stdin.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// stty 设置终端模式，stdin 不是终端时返回错误
+func stty(arg string) error {
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	return cmd.Run()
+}
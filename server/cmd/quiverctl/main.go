@@ -0,0 +1,108 @@
+// quiverctl 是 Quiver 管理 API 的命令行客户端，用于登录、管理应用/集群/命名空间/配置项、按清单声明式调整以及发布、回滚和对比版本。
+//
+// 退出码：
+//
+//	0 成功
+//	1 其他错误（服务端内部错误等）
+//	2 用法错误或服务端返回 400
+//	3 资源不存在（404）
+//	4 冲突（409，例如回滚时有未发布的修改、apply 确认后计划发生了变化；edit 期间草稿被其他人修改）
+//	5 未登录或没有权限（401/403）
+//	6 release diff --exit-code 发现差异
+//	7 无法连接服务端
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+	exitConflict = 4
+	exitAuth     = 5
+	exitDiff     = 6
+	exitNetwork  = 7
+)
+
+const usage = `quiverctl - command-line client for the Quiver config center
+
+Usage:
+  quiverctl <command> [subcommand] [flags] [args]
+
+Commands:
+  login                         log in and store credentials
+  logout                        revoke the session and remove stored credentials
+  app       list|get|create|update|delete
+  cluster   list|get|create|delete            (-a APP)
+  namespace list|get|create|delete|discard    (-a APP -c CLUSTER)
+  item      list|get|set|delete               (-a APP -c CLUSTER -n NAMESPACE)
+  edit                          edit the draft items of a namespace in $EDITOR
+  release   list|publish|rollback|diff        (-a APP -c CLUSTER -n NAMESPACE)
+  apply                         reconcile apps, clusters, namespaces and items with a manifest (-f FILE)
+
+Global flags (accepted by every command):
+  -s, --server URL     server address (default from login, or $QUIVER_SERVER)
+  -e, --env ENV        environment, dev or pro (default from login, or $QUIVER_ENV)
+  -o, --output FORMAT  table, json or yaml (default table)
+      --config FILE    credentials file (default $QUIVER_CONFIG or <user config dir>/quiver/config.yaml)
+
+Run "quiverctl <command> -h" for the flags of a command.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stderr, usage)
+		if len(args) == 0 {
+			return exitUsage
+		}
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	err := cmd(&cli{stdout: stdout, stderr: stderr}, args[1:])
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return exitOK
+	}
+	if !errors.Is(err, errDiffFound) {
+		fmt.Fprintln(stderr, "error:", err)
+	}
+	return exitCode(err)
+}
+
+// commands 顶层命令，复数和缩写形式是别名
+var commands = map[string]func(c *cli, args []string) error{
+	"login":      runLogin,
+	"logout":     runLogout,
+	"app":        runApp,
+	"apps":       runApp,
+	"cluster":    runCluster,
+	"clusters":   runCluster,
+	"namespace":  runNamespace,
+	"namespaces": runNamespace,
+	"ns":         runNamespace,
+	"item":       runItem,
+	"items":      runItem,
+	"edit":       runEdit,
+	"release":    runRelease,
+	"releases":   runRelease,
+	"apply":      runApply,
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// column 表格的一列，key 为 JSON 字段名
+type column struct {
+	header string
+	key    string
+}
+
+// maxCellWidth 表格单元格的最大宽度，超出部分截断；完整内容用 -o json 或 -o yaml 查看
+const maxCellWidth = 60
+
+// print 按 -o 输出 JSON 数据；data 为数组时每个元素一行，为对象时只有一行
+func (c *cli) print(data json.RawMessage, columns []column) error {
+	switch c.opts.output {
+	case "json":
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return err
+		}
+		buf.WriteByte('\n')
+		_, err := c.stdout.Write(buf.Bytes())
+		return err
+	case "yaml":
+		v, err := decodeJSON(data)
+		if err != nil {
+			return err
+		}
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = c.stdout.Write(out)
+		return err
+	}
+
+	v, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	var rows []interface{}
+	switch v := v.(type) {
+	case []interface{}:
+		rows = v
+	case nil:
+	default:
+		rows = []interface{}{v}
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 3, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.header
+	}
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		obj, _ := row.(map[string]interface{})
+		cells := make([]string, len(columns))
+		for i, col := range columns {
+			cells[i] = cell(obj[col.key])
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+	return w.Flush()
+}
+
+// printValue 把任意值转换为 JSON 后输出
+func (c *cli) printValue(v interface{}, columns []column) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.print(data, columns)
+}
+
+// message 只在表格模式下输出的提示信息，json、yaml 输出不受影响
+func (c *cli) message(format string, args ...interface{}) {
+	if c.opts.output == "table" {
+		fmt.Fprintf(c.stdout, format+"\n", args...)
+	}
+}
+
+// decodeJSON 解析 JSON，整数保持为 int64，避免 YAML 输出为浮点数
+func decodeJSON(data json.RawMessage) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return normalize(v), nil
+}
+
+func normalize(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []interface{}:
+		for i := range v {
+			v[i] = normalize(v[i])
+		}
+	case map[string]interface{}:
+		for k := range v {
+			v[k] = normalize(v[k])
+		}
+	}
+	return v
+}
+
+// cell 表格单元格：时间转为本地时间，换行等控制字符转义，过长时截断
+func cell(v interface{}) string {
+	var s string
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			if t.IsZero() {
+				return ""
+			}
+			return t.Local().Format("2006-01-02 15:04:05")
+		}
+		s = v
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i := range v {
+			parts[i] = fmt.Sprint(v[i])
+		}
+		s = strings.Join(parts, ",")
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	s = escape(s)
+	if utf8.RuneCountInString(s) > maxCellWidth {
+		s = string([]rune(s)[:maxCellWidth-3]) + "..."
+	}
+	return s
+}
+
+// escape 转义换行和制表符，使每个值只占一行
+func escape(s string) string {
+	return strings.NewReplacer("\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(s)
+}
+
+func joinSorted(values []string, sep string) string {
+	sort.Strings(values)
+	return strings.Join(values, sep)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+var releaseColumns = []column{
+	{"RELEASE_ID", "release_id"},
+	{"NAME", "release_name"},
+	{"OPERATOR", "operator"},
+	{"COMMENT", "comment"},
+	{"TIME", "release_time"},
+}
+
+// runRelease 版本管理
+func runRelease(c *cli, args []string) error {
+	sub, rest, err := subcommand("release", args, map[string]func(c *cli, args []string) error{
+		"list":     releaseList,
+		"publish":  releasePublish,
+		"rollback": releaseRollback,
+		"diff":     releaseDiff,
+	})
+	if err != nil {
+		return err
+	}
+	return sub(c, rest)
+}
+
+func releaseList(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("release list", "-a APP -c CLUSTER -n NAMESPACE [--limit N]")
+	s.register(fs, scopeNamespace)
+	limit := fs.Int("limit", 20, "list at most N releases, newest first; 0 lists all")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	if *limit <= 0 {
+		rows, err := cl.list(base+"/releases", nil, "releases")
+		if err != nil {
+			return err
+		}
+		return c.printValue(rows, releaseColumns)
+	}
+
+	var data map[string]json.RawMessage
+	query := url.Values{"page": {"1"}, "size": {fmt.Sprint(*limit)}}
+	if err := cl.do(http.MethodGet, base+"/releases", query, nil, &data); err != nil {
+		return err
+	}
+	return c.print(data["releases"], releaseColumns)
+}
+
+func releasePublish(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("release publish", "-a APP -c CLUSTER -n NAMESPACE [--name NAME] [-m COMMENT] [KEY...]")
+	s.register(fs, scopeNamespace)
+	var name, comment, operator string
+	fs.StringVar(&name, "name", "", "release name (default <timestamp>-release)")
+	fs.StringVar(&comment, "comment", "", "release comment")
+	fs.StringVar(&comment, "m", "", "shorthand for --comment")
+	fs.StringVar(&operator, "operator", "", "operator (default the logged-in user)")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	operator = firstNonEmpty(operator, cl.userName())
+	if operator == "" {
+		return usagef("--operator is required when not logged in")
+	}
+	if name == "" {
+		name = time.Now().Format("20060102150405") + "-release"
+	}
+
+	// 指定了 KEY 时只发布这些配置项
+	body := map[string]interface{}{
+		"release_name": name,
+		"operator":     operator,
+		"comment":      comment,
+	}
+	if len(positional) > 0 {
+		body["keys"] = positional
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodPost, base+"/releases", nil, body, &data); err != nil {
+		return err
+	}
+	return c.print(data, []column{{"RELEASE_ID", "release_id"}, {"NAME", "release_name"}, {"TIME", "release_time"}})
+}
+
+func releaseRollback(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("release rollback", "-a APP -c CLUSTER -n NAMESPACE RELEASE_ID [-m COMMENT] [--restore-draft [--force]]")
+	s.register(fs, scopeNamespace)
+	var comment, operator string
+	var restoreDraft, force bool
+	fs.StringVar(&comment, "comment", "", "rollback comment")
+	fs.StringVar(&comment, "m", "", "shorthand for --comment")
+	fs.StringVar(&operator, "operator", "", "operator (default the logged-in user)")
+	fs.BoolVar(&restoreDraft, "restore-draft", false, "also reset the draft to the target release")
+	fs.BoolVar(&force, "force", false, "with --restore-draft, overwrite unpublished edits in the draft")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	operator = firstNonEmpty(operator, cl.userName())
+	if operator == "" {
+		return usagef("--operator is required when not logged in")
+	}
+
+	query := url.Values{}
+	if restoreDraft {
+		query.Set("restore_draft", "true")
+	}
+	if force {
+		query.Set("force", "true")
+	}
+	var data json.RawMessage
+	body := map[string]string{"operator": operator, "comment": comment}
+	if err := cl.do(http.MethodPost, base+"/rollback/"+url.PathEscape(positional[0]), query, body, &data); err != nil {
+		return err
+	}
+	return c.print(data, []column{{"RELEASE_ID", "release_id"}, {"NAME", "release_name"}, {"TIME", "release_time"}, {"DRAFT_RESTORED", "draft_restored"}})
+}
+
+// valueChange 修改前后的值
+type valueChange struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// diffResult 两个版本之间的差异
+type diffResult struct {
+	From    string                 `json:"from"`
+	To      string                 `json:"to"`
+	Added   map[string]string      `json:"added"`
+	Updated map[string]valueChange `json:"updated"`
+	Deleted map[string]string      `json:"deleted"`
+}
+
+func (d *diffResult) empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Deleted) == 0
+}
+
+// releaseDiff 对比两个版本：
+// 不带参数时对比最新版本与草稿（即下次发布的改动），一个参数时对比该版本与最新版本，两个参数时对比两个版本
+func releaseDiff(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("release diff", "-a APP -c CLUSTER -n NAMESPACE [FROM_RELEASE_ID [TO_RELEASE_ID]] [--exit-code]")
+	s.register(fs, scopeNamespace)
+	exitOnDiff := fs.Bool("exit-code", false, fmt.Sprintf("exit with code %d when there are differences", exitDiff))
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 2, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var from, to map[string]string
+	result := &diffResult{From: "latest", To: "draft"}
+	switch len(positional) {
+	case 0:
+		if to, err = draftItems(cl, base); err != nil {
+			return err
+		}
+		from, err = releaseItems(cl, base, "")
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && (apiErr.Status == http.StatusNotFound || apiErr.Message == "no releases found") {
+			// 还没有发布过，草稿的全部内容都是新增
+			from, err = map[string]string{}, nil
+		}
+	case 1:
+		result.From, result.To = positional[0], "latest"
+		if from, err = releaseItems(cl, base, positional[0]); err == nil {
+			to, err = releaseItems(cl, base, "")
+		}
+	case 2:
+		result.From, result.To = positional[0], positional[1]
+		if from, err = releaseItems(cl, base, positional[0]); err == nil {
+			to, err = releaseItems(cl, base, positional[1])
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	result.Added, result.Updated, result.Deleted = map[string]string{}, map[string]valueChange{}, map[string]string{}
+	for k, v := range to {
+		old, ok := from[k]
+		switch {
+		case !ok:
+			result.Added[k] = v
+		case old != v:
+			result.Updated[k] = valueChange{From: old, To: v}
+		}
+	}
+	for k, v := range from {
+		if _, ok := to[k]; !ok {
+			result.Deleted[k] = v
+		}
+	}
+
+	if c.opts.output == "table" {
+		printDiff(c, result)
+	} else if err := c.printValue(result, nil); err != nil {
+		return err
+	}
+	if *exitOnDiff && !result.empty() {
+		return errDiffFound
+	}
+	return nil
+}
+
+// printDiff 按 key 排序输出，- 为旧值，+ 为新值
+func printDiff(c *cli, d *diffResult) {
+	if d.empty() {
+		fmt.Fprintf(c.stdout, "no differences between %s and %s\n", d.From, d.To)
+		return
+	}
+
+	keys := make([]string, 0, len(d.Added)+len(d.Updated)+len(d.Deleted))
+	for k := range d.Added {
+		keys = append(keys, k)
+	}
+	for k := range d.Updated {
+		keys = append(keys, k)
+	}
+	for k := range d.Deleted {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(c.stdout, "--- %s\n+++ %s\n", d.From, d.To)
+	for _, k := range keys {
+		if v, ok := d.Deleted[k]; ok {
+			fmt.Fprintf(c.stdout, "- %s = %s\n", k, escape(v))
+		}
+		if ch, ok := d.Updated[k]; ok {
+			fmt.Fprintf(c.stdout, "- %s = %s\n+ %s = %s\n", k, escape(ch.From), k, escape(ch.To))
+		}
+		if v, ok := d.Added[k]; ok {
+			fmt.Fprintf(c.stdout, "+ %s = %s\n", k, escape(v))
+		}
+	}
+	fmt.Fprintf(c.stdout, "%d added, %d updated, %d deleted\n", len(d.Added), len(d.Updated), len(d.Deleted))
+}
+
+// releaseItems 通过渲染接口取得版本的完整内容，releaseID 为空时为最新版本
+func releaseItems(cl *client, base, releaseID string) (map[string]string, error) {
+	query := url.Values{}
+	if releaseID != "" {
+		query.Set("release_id", releaseID)
+	}
+	data, err := cl.request(http.MethodGet, base+"/render/json", query, nil)
+	if err != nil {
+		return nil, err
+	}
+	kv := map[string]string{}
+	if err := json.Unmarshal(data, &kv); err != nil {
+		return nil, fmt.Errorf("invalid response from server: %v", err)
+	}
+	return kv, nil
+}
+
+// draftItems 草稿区的全部配置项
+func draftItems(cl *client, base string) (map[string]string, error) {
+	rows, err := cl.list(base+"/items", nil, "items")
+	if err != nil {
+		return nil, err
+	}
+	kv := make(map[string]string, len(rows))
+	for _, row := range rows {
+		var item struct {
+			Key   string `json:"key"`
+			Value string `json:"value"`
+		}
+		if err := json.Unmarshal(row, &item); err != nil {
+			return nil, fmt.Errorf("invalid response from server: %v", err)
+		}
+		kv[item.Key] = item.Value
+	}
+	return kv, nil
+}
@@ -0,0 +1,627 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+var (
+	appColumns = []column{
+		{"NAME", "app_name"},
+		{"DESCRIPTION", "description"},
+		{"CREATED", "create_time"},
+		{"UPDATED", "update_time"},
+	}
+	clusterColumns = []column{
+		{"NAME", "cluster_name"},
+		{"DESCRIPTION", "description"},
+		{"CREATED", "create_time"},
+		{"UPDATED", "update_time"},
+	}
+	namespaceColumns = []column{
+		{"NAME", "namespace_name"},
+		{"DESCRIPTION", "description"},
+		{"CREATED", "create_time"},
+		{"UPDATED", "update_time"},
+	}
+	itemColumns = []column{
+		{"KEY", "key"},
+		{"VALUE", "value"},
+		{"RELEASED", "is_released"},
+		{"UPDATED", "update_time"},
+	}
+)
+
+// runApp 应用管理
+func runApp(c *cli, args []string) error {
+	sub, rest, err := subcommand("app", args, map[string]func(c *cli, args []string) error{
+		"list":   appList,
+		"get":    appGet,
+		"create": appCreate,
+		"update": appUpdate,
+		"delete": appDelete,
+	})
+	if err != nil {
+		return err
+	}
+	return sub(c, rest)
+}
+
+func appList(c *cli, args []string) error {
+	fs := c.flags("app list", "")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	rows, err := cl.list("/apps", nil, "apps")
+	if err != nil {
+		return err
+	}
+	return c.printValue(rows, appColumns)
+}
+
+func appGet(c *cli, args []string) error {
+	fs := c.flags("app get", "NAME")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodGet, "/apps/"+url.PathEscape(positional[0]), nil, nil, &data); err != nil {
+		return err
+	}
+	return c.print(data, appColumns)
+}
+
+func appCreate(c *cli, args []string) error {
+	fs := c.flags("app create", "NAME [-d DESCRIPTION]")
+	var description string
+	fs.StringVar(&description, "description", "", "description")
+	fs.StringVar(&description, "d", "", "shorthand for --description")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	body := map[string]string{"app_name": positional[0], "description": description}
+	if err := cl.do(http.MethodPost, "/apps", nil, body, &data); err != nil {
+		return err
+	}
+	return c.print(data, appColumns)
+}
+
+func appUpdate(c *cli, args []string) error {
+	fs := c.flags("app update", "NAME -d DESCRIPTION")
+	var description string
+	fs.StringVar(&description, "description", "", "description")
+	fs.StringVar(&description, "d", "", "shorthand for --description")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	body := map[string]string{"description": description}
+	if err := cl.do(http.MethodPut, "/apps/"+url.PathEscape(positional[0]), nil, body, &data); err != nil {
+		return err
+	}
+	return c.print(data, appColumns)
+}
+
+func appDelete(c *cli, args []string) error {
+	fs := c.flags("app delete", "NAME")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodDelete, "/apps/"+url.PathEscape(positional[0]), nil, nil, &data); err != nil {
+		return err
+	}
+	return c.done(data, "app %s deleted", positional[0])
+}
+
+// runCluster 集群管理
+func runCluster(c *cli, args []string) error {
+	sub, rest, err := subcommand("cluster", args, map[string]func(c *cli, args []string) error{
+		"list":   clusterList,
+		"get":    clusterGet,
+		"create": clusterCreate,
+		"delete": clusterDelete,
+	})
+	if err != nil {
+		return err
+	}
+	return sub(c, rest)
+}
+
+func clusterList(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("cluster list", "-a APP")
+	s.register(fs, scopeApp)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeApp)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	rows, err := cl.list(base+"/clusters", nil, "clusters")
+	if err != nil {
+		return err
+	}
+	return c.printValue(rows, clusterColumns)
+}
+
+func clusterGet(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("cluster get", "-a APP NAME")
+	s.register(fs, scopeApp)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	s.cluster = positional[0]
+	p, err := s.path(scopeCluster)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodGet, p, nil, nil, &data); err != nil {
+		return err
+	}
+	return c.print(data, clusterColumns)
+}
+
+func clusterCreate(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("cluster create", "-a APP NAME [-d DESCRIPTION]")
+	s.register(fs, scopeApp)
+	var description string
+	fs.StringVar(&description, "description", "", "description")
+	fs.StringVar(&description, "d", "", "shorthand for --description")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeApp)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	body := map[string]string{"cluster_name": positional[0], "description": description}
+	if err := cl.do(http.MethodPost, base+"/clusters", nil, body, &data); err != nil {
+		return err
+	}
+	return c.print(unwrap(data, "cluster"), clusterColumns)
+}
+
+func clusterDelete(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("cluster delete", "-a APP NAME")
+	s.register(fs, scopeApp)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	s.cluster = positional[0]
+	p, err := s.path(scopeCluster)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodDelete, p, nil, nil, &data); err != nil {
+		return err
+	}
+	return c.done(data, "cluster %s deleted", s.cluster)
+}
+
+// runNamespace 命名空间管理
+func runNamespace(c *cli, args []string) error {
+	sub, rest, err := subcommand("namespace", args, map[string]func(c *cli, args []string) error{
+		"list":    namespaceList,
+		"get":     namespaceGet,
+		"create":  namespaceCreate,
+		"delete":  namespaceDelete,
+		"discard": namespaceDiscard,
+	})
+	if err != nil {
+		return err
+	}
+	return sub(c, rest)
+}
+
+func namespaceList(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("namespace list", "-a APP -c CLUSTER")
+	s.register(fs, scopeCluster)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeCluster)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	rows, err := cl.list(base+"/namespaces", nil, "namespaces")
+	if err != nil {
+		return err
+	}
+	return c.printValue(rows, namespaceColumns)
+}
+
+func namespaceGet(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("namespace get", "-a APP -c CLUSTER NAME")
+	s.register(fs, scopeCluster)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	s.namespace = positional[0]
+	p, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodGet, p, nil, nil, &data); err != nil {
+		return err
+	}
+	return c.print(data, namespaceColumns)
+}
+
+func namespaceCreate(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("namespace create", "-a APP -c CLUSTER NAME [-d DESCRIPTION]")
+	s.register(fs, scopeCluster)
+	var description string
+	fs.StringVar(&description, "description", "", "description")
+	fs.StringVar(&description, "d", "", "shorthand for --description")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeCluster)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	body := map[string]string{"namespace_name": positional[0], "description": description}
+	if err := cl.do(http.MethodPost, base+"/namespaces", nil, body, &data); err != nil {
+		return err
+	}
+	return c.print(unwrap(data, "namespace"), namespaceColumns)
+}
+
+func namespaceDelete(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("namespace delete", "-a APP -c CLUSTER NAME")
+	s.register(fs, scopeCluster)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	s.namespace = positional[0]
+	p, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodDelete, p, nil, nil, &data); err != nil {
+		return err
+	}
+	return c.done(data, "namespace %s deleted", s.namespace)
+}
+
+func namespaceDiscard(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("namespace discard", "-a APP -c CLUSTER NAME")
+	s.register(fs, scopeCluster)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	s.namespace = positional[0]
+	p, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodPost, p+"/discard", nil, nil, &data); err != nil {
+		return err
+	}
+	return c.done(data, "unpublished edits of namespace %s discarded", s.namespace)
+}
+
+// runItem 配置项（草稿）管理
+func runItem(c *cli, args []string) error {
+	sub, rest, err := subcommand("item", args, map[string]func(c *cli, args []string) error{
+		"list":   itemList,
+		"get":    itemGet,
+		"set":    itemSet,
+		"delete": itemDelete,
+	})
+	if err != nil {
+		return err
+	}
+	return sub(c, rest)
+}
+
+func itemList(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("item list", "-a APP -c CLUSTER -n NAMESPACE [--search TEXT]")
+	s.register(fs, scopeNamespace)
+	var search string
+	fs.StringVar(&search, "search", "", "only list keys containing TEXT")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 0, 0, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	query := url.Values{}
+	if search != "" {
+		query.Set("search", search)
+	}
+	rows, err := cl.list(base+"/items", query, "items")
+	if err != nil {
+		return err
+	}
+	return c.printValue(rows, itemColumns)
+}
+
+func itemGet(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("item get", "-a APP -c CLUSTER -n NAMESPACE KEY")
+	s.register(fs, scopeNamespace)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodGet, base+"/items/"+url.PathEscape(positional[0]), nil, nil, &data); err != nil {
+		return err
+	}
+	return c.print(data, itemColumns)
+}
+
+func itemSet(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("item set", "-a APP -c CLUSTER -n NAMESPACE KEY (VALUE | --from-file FILE)")
+	s.register(fs, scopeNamespace)
+	var fromFile string
+	fs.StringVar(&fromFile, "from-file", "", "read the value from FILE, - for stdin")
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if fromFile == "" {
+		err = checkArgs(positional, 2, 2, fs)
+	} else {
+		err = checkArgs(positional, 1, 1, fs)
+	}
+	if err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+
+	key := positional[0]
+	var value string
+	switch fromFile {
+	case "":
+		value = positional[1]
+	case "-":
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	default:
+		data, err := os.ReadFile(fromFile)
+		if err != nil {
+			return err
+		}
+		value = string(data)
+	}
+	if value == "" {
+		return usagef("value must not be empty, use \"item delete\" to remove a key")
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	var data json.RawMessage
+	if err := cl.do(http.MethodPost, base+"/items", nil, map[string]string{"key": key, "value": value}, &data); err != nil {
+		return err
+	}
+	return c.print(data, itemColumns)
+}
+
+func itemDelete(c *cli, args []string) error {
+	var s scope
+	fs := c.flags("item delete", "-a APP -c CLUSTER -n NAMESPACE KEY")
+	s.register(fs, scopeNamespace)
+	positional, err := c.parse(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := checkArgs(positional, 1, 1, fs); err != nil {
+		return err
+	}
+	base, err := s.path(scopeNamespace)
+	if err != nil {
+		return err
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+
+	var data json.RawMessage
+	if err := cl.do(http.MethodDelete, base+"/items/"+url.PathEscape(positional[0]), nil, nil, &data); err != nil {
+		return err
+	}
+	return c.done(data, "item %s deleted", positional[0])
+}
+
+// done 表格模式下输出提示信息，json、yaml 模式下输出响应内容
+func (c *cli) done(data json.RawMessage, format string, args ...interface{}) error {
+	if c.opts.output == "table" {
+		c.message(format, args...)
+		return nil
+	}
+	return c.print(data, nil)
+}
+
+// unwrap 取出响应中包了一层的对象，例如创建集群返回的 {"env": ..., "cluster": {...}}
+func unwrap(data json.RawMessage, field string) json.RawMessage {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return data
+	}
+	if inner, ok := m[field]; ok {
+		return inner
+	}
+	return data
+}
@@ -2,15 +2,29 @@ package config
 
 import (
 	"fmt"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Config 全局配置结构体
 type Config struct {
-	Database map[string]DatabaseConfig `yaml:"database"`
-	Server   ServerConfig              `yaml:"server"`
+	Database  map[string]DatabaseConfig `yaml:"database"`
+	Server    ServerConfig              `yaml:"server"`
+	Tracing   TracingConfig             `yaml:"tracing"`
+	Log       LogConfig                 `yaml:"log"`
+	Auth      AuthConfig                `yaml:"auth"`
+	Cluster   ClusterConfig             `yaml:"cluster"`
+	Cache     CacheConfig               `yaml:"cache"`
+	Retention RetentionConfig           `yaml:"retention"`
+	Schedule  ScheduleConfig            `yaml:"schedule"`
+	Interp    InterpolationConfig       `yaml:"interpolation"`
+	GRPC      GRPCConfig                `yaml:"grpc"`
+	Git       GitConfig                 `yaml:"git"`
+	Webhook   WebhookConfig             `yaml:"webhook"`
 }
 
 // DatabaseConfig 数据库配置结构体
@@ -28,6 +42,197 @@ type ServerConfig struct {
 	Host string `yaml:"host"`
 }
 
+// TracingConfig 链路追踪配置结构体
+type TracingConfig struct {
+	Enabled     bool    `yaml:"enabled"`
+	Exporter    string  `yaml:"exporter"`     // otlp 或 stdout
+	Endpoint    string  `yaml:"endpoint"`     // OTLP HTTP 地址，如 127.0.0.1:4318
+	Insecure    bool    `yaml:"insecure"`     // OTLP 是否使用明文 HTTP
+	ServiceName string  `yaml:"service_name"` // 上报的服务名
+	SampleRatio float64 `yaml:"sample_ratio"` // 采样比例 0~1
+}
+
+// LogConfig 日志配置结构体
+type LogConfig struct {
+	Level string `yaml:"level"` // 默认日志级别：debug、info、warn、error
+}
+
+// AuthConfig 登录会话配置结构体
+type AuthConfig struct {
+	Required        bool           `yaml:"required"`          // 是否要求接口必须携带 access token
+	AccessTokenTTL  time.Duration  `yaml:"access_token_ttl"`  // access token 有效期，默认 15m
+	RefreshTokenTTL time.Duration  `yaml:"refresh_token_ttl"` // refresh token 有效期，默认 168h
+	AdminUsers      []string       `yaml:"admin_users"`       // 管理员用户名列表
+	LocalLogin      *bool          `yaml:"local_login"`       // 是否允许本地密码登录，默认允许
+	LDAP            LDAPConfig     `yaml:"ldap"`
+	OIDC            OIDCConfig     `yaml:"oidc"`
+	GroupMappings   []GroupMapping `yaml:"group_mappings"` // IdP 组到权限的映射
+}
+
+// LDAPConfig LDAP 登录配置结构体
+type LDAPConfig struct {
+	Enabled            bool   `yaml:"enabled"`
+	URL                string `yaml:"url"`                  // ldap://host:389 或 ldaps://host:636
+	StartTLS           bool   `yaml:"start_tls"`            // ldap:// 连接后是否升级 TLS
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"` // 跳过证书校验，仅用于测试
+	BindDN             string `yaml:"bind_dn"`              // 查询用的服务账号，为空则匿名查询
+	BindPassword       string `yaml:"bind_password"`
+	BaseDN             string `yaml:"base_dn"`       // 用户搜索根
+	UserFilter         string `yaml:"user_filter"`   // 用户过滤条件，%s 替换为用户名，默认 (uid=%s)
+	GroupBaseDN        string `yaml:"group_base_dn"` // 组搜索根，默认同 base_dn
+	GroupFilter        string `yaml:"group_filter"`  // 组过滤条件，%s 替换为用户 DN，默认 (member=%s)
+	GroupAttr          string `yaml:"group_attr"`    // 组名属性，默认 cn
+	EmailAttr          string `yaml:"email_attr"`    // 默认 mail
+	PhoneAttr          string `yaml:"phone_attr"`    // 默认 telephoneNumber
+}
+
+// OIDCConfig OpenID Connect 登录配置结构体
+type OIDCConfig struct {
+	Enabled       bool     `yaml:"enabled"`
+	Issuer        string   `yaml:"issuer"` // 如 https://sso.example.com/realms/corp
+	ClientID      string   `yaml:"client_id"`
+	ClientSecret  string   `yaml:"client_secret"`
+	RedirectURL   string   `yaml:"redirect_url"`   // 指向 /api/v1/envs/{env}/auth/oidc/callback
+	Scopes        []string `yaml:"scopes"`         // 默认 openid profile email
+	UsernameClaim string   `yaml:"username_claim"` // 默认 preferred_username
+	GroupsClaim   string   `yaml:"groups_claim"`   // 默认 groups
+}
+
+// GroupMapping 组成员自动获得的权限，resource_name 为 app、app/cluster 或 app/cluster/namespace
+type GroupMapping struct {
+	Group        string `yaml:"group"`
+	ResourceType string `yaml:"resource_type"` // APP、CLUSTER、NAMESPACE
+	ResourceName string `yaml:"resource_name"`
+	Action       string `yaml:"action"`
+}
+
+// LocalLoginEnabled 是否允许本地密码登录
+func (c AuthConfig) LocalLoginEnabled() bool {
+	return c.LocalLogin == nil || *c.LocalLogin
+}
+
+// ClusterConfig 多实例部署配置结构体
+type ClusterConfig struct {
+	Self         string        `yaml:"self"`          // 本实例对外地址，如 http://10.0.0.1:8080，用于从 peers 中排除自己
+	Peers        []string      `yaml:"peers"`         // 所有实例地址（可以包含自己）
+	Secret       string        `yaml:"secret"`        // 实例间通信的 HMAC 密钥，为空时不广播也不接收
+	Timeout      time.Duration `yaml:"timeout"`       // 单次广播超时，默认 2s
+	PollInterval time.Duration `yaml:"poll_interval"` // 变更日志兜底轮询间隔，默认 1s，配置了 peers 时默认 5s
+}
+
+// CacheConfig 本地缓存配置结构体
+type CacheConfig struct {
+	Dir               string        `yaml:"dir"`                 // 磁盘缓存目录，默认 $TMPDIR/quiver/cache
+	Persistent        bool          `yaml:"persistent"`          // 重启后保留磁盘缓存中不可变的发布快照
+	MaxMemCost        int64         `yaml:"max_mem_cost"`        // 内存缓存上限（字节），默认 1GB
+	WarmUp            *bool         `yaml:"warm_up"`             // 启动时是否预加载所有命名空间的最新发布，默认开启
+	WarmUpConcurrency int           `yaml:"warm_up_concurrency"` // 预加载并发数，默认 8
+	WarmUpTimeout     time.Duration `yaml:"warm_up_timeout"`     // 预加载超时，超时后直接就绪，默认 2m
+	ClientMaxAge      time.Duration `yaml:"client_max_age"`      // 客户端拉取配置响应的 Cache-Control max-age，默认 0，中间缓存每次都用 ETag 回源校验
+}
+
+// WarmUpEnabled 是否在启动时预加载
+func (c CacheConfig) WarmUpEnabled() bool {
+	return c.WarmUp == nil || *c.WarmUp
+}
+
+// RetentionConfig 发布记录保留策略配置结构体
+type RetentionConfig struct {
+	Enabled  bool                       `yaml:"enabled"`  // 是否开启后台定期清理
+	Interval time.Duration              `yaml:"interval"` // 清理间隔，默认 6h
+	Envs     map[string]RetentionPolicy `yaml:"envs"`     // 每个环境的保留策略
+}
+
+// RetentionPolicy 单个环境的保留策略，满足任意一个条件的发布都会保留，都为 0 时保留全部发布
+// 每个命名空间的当前版本始终保留
+type RetentionPolicy struct {
+	KeepReleases int `yaml:"keep_releases"` // 每个命名空间保留最近 N 个发布
+	KeepDays     int `yaml:"keep_days"`     // 保留最近 M 天内的发布
+}
+
+// Unlimited 是否保留全部发布（仍然会清理已删除命名空间的数据）
+func (p RetentionPolicy) Unlimited() bool {
+	return p.KeepReleases <= 0 && p.KeepDays <= 0
+}
+
+// ScheduleConfig 定时发布配置结构体
+type ScheduleConfig struct {
+	Interval  time.Duration `yaml:"interval"`   // 扫描到期任务的间隔，默认 10s
+	BatchSize int           `yaml:"batch_size"` // 每次最多执行的任务数，默认 100
+}
+
+// 配置项引用的解析时机
+const (
+	InterpolationModePublish = "publish" // 发布时解析并在版本中记录引用值
+	InterpolationModeFetch   = "fetch"   // 拉取时按被引用命名空间的最新版本解析
+)
+
+// InterpolationConfig 配置项引用（${key}、${namespace:key}）配置结构体
+type InterpolationConfig struct {
+	Enabled bool   `yaml:"enabled"` // 默认关闭，关闭时值按原文发布
+	Mode    string `yaml:"mode"`    // publish 或 fetch，默认 publish
+}
+
+// GRPCConfig 客户端 gRPC 接口配置结构体
+type GRPCConfig struct {
+	Enabled bool   `yaml:"enabled"` // 默认关闭
+	Addr    string `yaml:"addr"`    // 监听地址，默认 :9090
+}
+
+// GitConfig 命名空间绑定服务器本地 Git 仓库的配置结构体
+type GitConfig struct {
+	Enabled bool          `yaml:"enabled"` // 默认关闭
+	Roots   []string      `yaml:"roots"`   // 允许绑定的仓库所在目录，仓库必须位于其中之一
+	Binary  string        `yaml:"binary"`  // git 可执行文件，默认 git
+	Timeout time.Duration `yaml:"timeout"` // 单次 git 命令超时，默认 30s
+}
+
+// WebhookConfig 配置变更 webhook 投递配置结构体
+type WebhookConfig struct {
+	Interval    time.Duration `yaml:"interval"`     // 扫描待投递记录的间隔，默认 2s
+	BatchSize   int           `yaml:"batch_size"`   // 每次最多投递的记录数，默认 100
+	Timeout     time.Duration `yaml:"timeout"`      // 单次投递超时，默认 5s
+	MaxAttempts int           `yaml:"max_attempts"` // 最多投递次数，默认 8
+	BackoffBase time.Duration `yaml:"backoff_base"` // 第一次重试的间隔，之后每次翻倍，默认 10s
+	BackoffMax  time.Duration `yaml:"backoff_max"`  // 重试间隔上限，默认 1h
+	KeepDays    int           `yaml:"keep_days"`    // 投递记录保留天数，默认 30，待投递的记录不会被清理
+	AllowCIDRs  []string      `yaml:"allow_cidrs"`  // 允许投递的地址段，优先于 deny_cidrs，如内网的接收服务
+	DenyCIDRs   []string      `yaml:"deny_cidrs"`   // 禁止投递的地址段，省略时为回环、链路本地和私有地址，写为 [] 时不限制
+}
+
+// defaultWebhookDenyCIDRs 默认禁止投递的地址段，避免通过 webhook 访问服务端所在的内网
+var defaultWebhookDenyCIDRs = []string{
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"::/128",
+	"::1/128",
+	"fc00::/7",
+	"fe80::/10",
+}
+
+// AddrAllowed 投递地址是否允许：命中 allow_cidrs 时允许，否则命中 deny_cidrs 时拒绝。
+// IPv4-mapped IPv6 地址按 IPv4 判断；地址段在加载配置时已经校验过，无法解析的会被忽略
+func (c WebhookConfig) AddrAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap().WithZone("")
+	contains := func(cidrs []string) bool {
+		for _, cidr := range cidrs {
+			if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr) {
+				return true
+			}
+		}
+		return false
+	}
+	if contains(c.AllowCIDRs) {
+		return true
+	}
+	return !contains(c.DenyCIDRs)
+}
+
 var globalConfig *Config
 
 // LoadConfig 加载YAML配置文件
@@ -52,6 +257,32 @@ func LoadConfig(configPath string) (*Config, error) {
 	if cfg.Server.Host == "" {
 		cfg.Server.Host = "0.0.0.0"
 	}
+	cfg.Auth = withAuthDefaults(cfg.Auth)
+	cfg.Cluster = withClusterDefaults(cfg.Cluster)
+	cfg.Cache = withCacheDefaults(cfg.Cache)
+	cfg.Retention = withRetentionDefaults(cfg.Retention)
+	cfg.Schedule = withScheduleDefaults(cfg.Schedule)
+	cfg.Interp = withInterpolationDefaults(cfg.Interp)
+	cfg.GRPC = withGRPCDefaults(cfg.GRPC)
+	cfg.Git = withGitDefaults(cfg.Git)
+	cfg.Webhook = withWebhookDefaults(cfg.Webhook)
+	for _, cidr := range append(append([]string{}, cfg.Webhook.AllowCIDRs...), cfg.Webhook.DenyCIDRs...) {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return nil, fmt.Errorf("invalid webhook cidr %q: %v", cidr, err)
+		}
+	}
+	if cfg.Log.Level == "" {
+		cfg.Log.Level = "debug"
+	}
+	if cfg.Tracing.Exporter == "" {
+		cfg.Tracing.Exporter = "stdout"
+	}
+	if cfg.Tracing.ServiceName == "" {
+		cfg.Tracing.ServiceName = "quiver"
+	}
+	if cfg.Tracing.SampleRatio <= 0 || cfg.Tracing.SampleRatio > 1 {
+		cfg.Tracing.SampleRatio = 1
+	}
 
 	return &cfg, nil
 }
@@ -79,3 +310,247 @@ func GetServerConfig() ServerConfig {
 
 	return globalConfig.Server
 }
+
+// GetTracingConfig 获取链路追踪配置
+func GetTracingConfig() TracingConfig {
+	if globalConfig == nil {
+		return TracingConfig{
+			Exporter:    "stdout",
+			ServiceName: "quiver",
+			SampleRatio: 1,
+		}
+	}
+
+	return globalConfig.Tracing
+}
+
+// GetLogConfig 获取日志配置
+func GetLogConfig() LogConfig {
+	if globalConfig == nil {
+		return LogConfig{Level: "debug"}
+	}
+
+	return globalConfig.Log
+}
+
+// GetAuthConfig 获取登录会话配置
+func GetAuthConfig() AuthConfig {
+	if globalConfig == nil {
+		return withAuthDefaults(AuthConfig{})
+	}
+
+	return globalConfig.Auth
+}
+
+func withAuthDefaults(cfg AuthConfig) AuthConfig {
+	if cfg.AccessTokenTTL <= 0 {
+		cfg.AccessTokenTTL = 15 * time.Minute
+	}
+	if cfg.RefreshTokenTTL <= 0 {
+		cfg.RefreshTokenTTL = 7 * 24 * time.Hour
+	}
+	if len(cfg.AdminUsers) == 0 {
+		cfg.AdminUsers = []string{"admin"}
+	}
+	if cfg.LDAP.UserFilter == "" {
+		cfg.LDAP.UserFilter = "(uid=%s)"
+	}
+	if cfg.LDAP.GroupBaseDN == "" {
+		cfg.LDAP.GroupBaseDN = cfg.LDAP.BaseDN
+	}
+	if cfg.LDAP.GroupFilter == "" {
+		cfg.LDAP.GroupFilter = "(member=%s)"
+	}
+	if cfg.LDAP.GroupAttr == "" {
+		cfg.LDAP.GroupAttr = "cn"
+	}
+	if cfg.LDAP.EmailAttr == "" {
+		cfg.LDAP.EmailAttr = "mail"
+	}
+	if cfg.LDAP.PhoneAttr == "" {
+		cfg.LDAP.PhoneAttr = "telephoneNumber"
+	}
+	if len(cfg.OIDC.Scopes) == 0 {
+		cfg.OIDC.Scopes = []string{"openid", "profile", "email"}
+	}
+	if cfg.OIDC.UsernameClaim == "" {
+		cfg.OIDC.UsernameClaim = "preferred_username"
+	}
+	if cfg.OIDC.GroupsClaim == "" {
+		cfg.OIDC.GroupsClaim = "groups"
+	}
+	return cfg
+}
+
+// GetClusterConfig 获取多实例部署配置
+func GetClusterConfig() ClusterConfig {
+	if globalConfig == nil {
+		return withClusterDefaults(ClusterConfig{})
+	}
+
+	return globalConfig.Cluster
+}
+
+func withClusterDefaults(cfg ClusterConfig) ClusterConfig {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 2 * time.Second
+	}
+	if cfg.PollInterval <= 0 {
+		cfg.PollInterval = time.Second
+		if len(cfg.Peers) > 0 && cfg.Secret != "" {
+			cfg.PollInterval = 5 * time.Second
+		}
+	}
+	return cfg
+}
+
+// GetCacheConfig 获取本地缓存配置
+func GetCacheConfig() CacheConfig {
+	if globalConfig == nil {
+		return withCacheDefaults(CacheConfig{})
+	}
+
+	return globalConfig.Cache
+}
+
+func withCacheDefaults(cfg CacheConfig) CacheConfig {
+	if cfg.Dir == "" {
+		cfg.Dir = filepath.Join(os.TempDir(), "quiver", "cache")
+	}
+	if cfg.MaxMemCost <= 0 {
+		cfg.MaxMemCost = 1 << 30
+	}
+	if cfg.WarmUpConcurrency <= 0 {
+		cfg.WarmUpConcurrency = 8
+	}
+	if cfg.WarmUpTimeout <= 0 {
+		cfg.WarmUpTimeout = 2 * time.Minute
+	}
+	if cfg.ClientMaxAge < 0 {
+		cfg.ClientMaxAge = 0
+	}
+	return cfg
+}
+
+// GetRetentionConfig 获取发布记录保留策略配置
+func GetRetentionConfig() RetentionConfig {
+	if globalConfig == nil {
+		return withRetentionDefaults(RetentionConfig{})
+	}
+
+	return globalConfig.Retention
+}
+
+func withRetentionDefaults(cfg RetentionConfig) RetentionConfig {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 6 * time.Hour
+	}
+	return cfg
+}
+
+// GetScheduleConfig 获取定时发布配置
+func GetScheduleConfig() ScheduleConfig {
+	if globalConfig == nil {
+		return withScheduleDefaults(ScheduleConfig{})
+	}
+
+	return globalConfig.Schedule
+}
+
+func withScheduleDefaults(cfg ScheduleConfig) ScheduleConfig {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 10 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	return cfg
+}
+
+// GetInterpolationConfig 获取配置项引用配置
+func GetInterpolationConfig() InterpolationConfig {
+	if globalConfig == nil {
+		return withInterpolationDefaults(InterpolationConfig{})
+	}
+
+	return globalConfig.Interp
+}
+
+func withInterpolationDefaults(cfg InterpolationConfig) InterpolationConfig {
+	if cfg.Mode != InterpolationModeFetch {
+		cfg.Mode = InterpolationModePublish
+	}
+	return cfg
+}
+
+// GetGRPCConfig 获取 gRPC 接口配置
+func GetGRPCConfig() GRPCConfig {
+	if globalConfig == nil {
+		return withGRPCDefaults(GRPCConfig{})
+	}
+
+	return globalConfig.GRPC
+}
+
+func withGRPCDefaults(cfg GRPCConfig) GRPCConfig {
+	if cfg.Addr == "" {
+		cfg.Addr = ":9090"
+	}
+	return cfg
+}
+
+// GetGitConfig 获取 Git 仓库同步配置
+func GetGitConfig() GitConfig {
+	if globalConfig == nil {
+		return withGitDefaults(GitConfig{})
+	}
+
+	return globalConfig.Git
+}
+
+func withGitDefaults(cfg GitConfig) GitConfig {
+	if cfg.Binary == "" {
+		cfg.Binary = "git"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 30 * time.Second
+	}
+	return cfg
+}
+
+// GetWebhookConfig 获取 webhook 投递配置
+func GetWebhookConfig() WebhookConfig {
+	if globalConfig == nil {
+		return withWebhookDefaults(WebhookConfig{})
+	}
+
+	return globalConfig.Webhook
+}
+
+func withWebhookDefaults(cfg WebhookConfig) WebhookConfig {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 2 * time.Second
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 100
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 5 * time.Second
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 8
+	}
+	if cfg.BackoffBase <= 0 {
+		cfg.BackoffBase = 10 * time.Second
+	}
+	if cfg.BackoffMax <= 0 {
+		cfg.BackoffMax = time.Hour
+	}
+	if cfg.KeepDays <= 0 {
+		cfg.KeepDays = 30
+	}
+	if cfg.DenyCIDRs == nil {
+		cfg.DenyCIDRs = defaultWebhookDenyCIDRs
+	}
+	return cfg
+}
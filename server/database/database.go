@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"fmt"
 	gormlogger "gorm.io/gorm/logger"
 	"sync"
@@ -46,6 +47,11 @@ func Connect(env string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
+	if err := db.Use(&tracingPlugin{env: env}); err != nil {
+		logger.GetLogger("quiver").Errorf("register gorm tracing plugin for env %s failed: %v", env, err)
+		return nil, fmt.Errorf("failed to register tracing plugin: %w", err)
+	}
+
 	sqlDB, err := db.DB()
 	if err != nil {
 		logger.GetLogger("quiver").Errorf("Failed to get raw database instance:%+v for env : %s error: %v",
@@ -94,3 +100,12 @@ func GetDB(env string) *gorm.DB {
 
 	return nil
 }
+
+// GetDBContext 获取绑定了 ctx 的数据库实例，GORM 调用会作为 ctx 中 span 的子 span
+func GetDBContext(ctx context.Context, env string) *gorm.DB {
+	db := GetDB(env)
+	if db == nil || ctx == nil {
+		return db
+	}
+	return db.WithContext(ctx)
+}
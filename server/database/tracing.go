@@ -0,0 +1,99 @@
+package database
+
+import (
+	"context"
+	"quiver/telemetry"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	spanInstanceKey   = "quiver:span"
+	parentInstanceKey = "quiver:span_parent"
+)
+
+// tracingPlugin 为每一次 GORM 调用创建 span，父 span 取自 db.WithContext(ctx)
+type tracingPlugin struct {
+	env string
+}
+
+func (p *tracingPlugin) Name() string {
+	return "quiver:tracing"
+}
+
+func (p *tracingPlugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		name   string
+		before func(string, func(*gorm.DB)) error
+		after  func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("gorm:create").Register, cb.Create().After("gorm:create").Register},
+		{"query", cb.Query().Before("gorm:query").Register, cb.Query().After("gorm:query").Register},
+		{"update", cb.Update().Before("gorm:update").Register, cb.Update().After("gorm:update").Register},
+		{"delete", cb.Delete().Before("gorm:delete").Register, cb.Delete().After("gorm:delete").Register},
+		{"row", cb.Row().Before("gorm:row").Register, cb.Row().After("gorm:row").Register},
+		{"raw", cb.Raw().Before("gorm:raw").Register, cb.Raw().After("gorm:raw").Register},
+	}
+
+	for _, h := range hooks {
+		if err := h.before("quiver:trace_before_"+h.name, p.before(h.name)); err != nil {
+			return err
+		}
+		if err := h.after("quiver:trace_after_"+h.name, p.after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *tracingPlugin) before(op string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		if db.Statement == nil || db.Statement.Context == nil {
+			return
+		}
+		ctx, span := telemetry.Tracer().Start(db.Statement.Context, "gorm."+op,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system", "mysql"),
+				attribute.String("quiver.env", p.env),
+				attribute.String("db.sql.table", db.Statement.Table),
+			))
+		db.InstanceSet(parentInstanceKey, db.Statement.Context)
+		db.InstanceSet(spanInstanceKey, span)
+		db.Statement.Context = ctx
+	}
+}
+
+func (p *tracingPlugin) after(db *gorm.DB) {
+	v, ok := db.InstanceGet(spanInstanceKey)
+	if !ok {
+		return
+	}
+	span, ok := v.(trace.Span)
+	if !ok {
+		return
+	}
+	defer span.End()
+
+	// 还原父 ctx，避免同一 Statement 的后续调用挂在已结束的 span 下
+	if parent, ok := db.InstanceGet(parentInstanceKey); ok {
+		if ctx, ok := parent.(context.Context); ok {
+			db.Statement.Context = ctx
+		}
+	}
+
+	if db.Statement != nil {
+		span.SetAttributes(
+			attribute.String("db.statement", db.Statement.SQL.String()),
+			attribute.Int64("db.rows_affected", db.Statement.RowsAffected),
+		)
+	}
+	if db.Error != nil && db.Error != gorm.ErrRecordNotFound {
+		span.RecordError(db.Error)
+		span.SetStatus(codes.Error, db.Error.Error())
+	}
+}
@@ -0,0 +1,196 @@
+// Package gitrepo 通过 git 命令只读访问服务器本地的仓库（裸仓库或工作副本），
+// 只读取 commit 中的对象，不依赖也不修改工作区
+package gitrepo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 仓库中没有 ref 或 path
+var ErrNotFound = errors.New("not found in repository")
+
+// MaxFileSize 单个文件的大小上限，与 item.v 的 TEXT 类型一致
+const MaxFileSize = 65535
+
+// File commit 中的一个普通文件，Path 相对于仓库根目录
+type File struct {
+	Path string
+	Data []byte
+}
+
+// Repo 服务器本地的一个仓库
+type Repo struct {
+	dir     string
+	binary  string
+	timeout time.Duration
+}
+
+// Open 打开 dir 处的仓库，dir 必须是工作副本的根目录或裸仓库本身，不会向上查找父目录中的仓库
+func Open(ctx context.Context, dir, binary string, timeout time.Duration) (*Repo, error) {
+	r := &Repo{dir: dir, binary: binary, timeout: timeout}
+	out, err := r.git(ctx, nil, "rev-parse", "--is-bare-repository")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", dir, err)
+	}
+	if strings.TrimSpace(string(out)) != "true" {
+		top, err := r.git(ctx, nil, "rev-parse", "--show-toplevel")
+		if err != nil {
+			return nil, err
+		}
+		if !samePath(strings.TrimSpace(string(top)), dir) {
+			return nil, fmt.Errorf("%s is not the top-level directory of a git repository", dir)
+		}
+	}
+	return r, nil
+}
+
+// Resolve 把分支、标签或 commit 解析为 commit 的完整 SHA
+func (r *Repo) Resolve(ctx context.Context, ref string) (string, error) {
+	if err := ValidateRef(ref); err != nil {
+		return "", err
+	}
+	out, err := r.git(ctx, nil, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return "", fmt.Errorf("ref %s %w", ref, ErrNotFound)
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ReadFiles 读取 commit 中 p 处的文件，p 为目录时递归读取其中全部普通文件，为空时为仓库根目录。
+// 符号链接和子模块会被跳过；文件超过 MaxFileSize 时返回错误
+func (r *Repo) ReadFiles(ctx context.Context, commit, p string) ([]File, error) {
+	p = strings.Trim(path.Clean("/"+p), "/")
+	args := []string{"ls-tree", "-r", "-z", "-l", "--full-tree", commit}
+	if p != "" {
+		args = append(args, "--", p)
+	}
+	out, err := r.git(ctx, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	type blob struct {
+		sha  string
+		path string
+	}
+	var blobs []blob
+	var batch bytes.Buffer
+	for _, entry := range strings.Split(string(out), "\x00") {
+		if entry == "" {
+			continue
+		}
+		// <mode> SP <type> SP <object> SP* <size> TAB <path>
+		meta, name, ok := strings.Cut(entry, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected ls-tree output %q", entry)
+		}
+		if fields[1] != "blob" || (fields[0] != "100644" && fields[0] != "100755") {
+			continue
+		}
+		// ls-tree 按前缀匹配 path，排除 a/bc 匹配 a/b 的情况
+		if p != "" && name != p && !strings.HasPrefix(name, p+"/") {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[3], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected ls-tree output %q", entry)
+		}
+		if size > MaxFileSize {
+			return nil, fmt.Errorf("file %s is larger than %d bytes", name, MaxFileSize)
+		}
+		blobs = append(blobs, blob{sha: fields[2], path: name})
+		batch.WriteString(fields[2] + "\n")
+	}
+	if len(blobs) == 0 {
+		return nil, fmt.Errorf("path %q %w", p, ErrNotFound)
+	}
+
+	out, err = r.git(ctx, &batch, "cat-file", "--batch")
+	if err != nil {
+		return nil, err
+	}
+	rd := bufio.NewReader(bytes.NewReader(out))
+	files := make([]File, 0, len(blobs))
+	for _, b := range blobs {
+		header, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("read blob %s: %w", b.sha, err)
+		}
+		fields := strings.Fields(header)
+		if len(fields) != 3 || fields[0] != b.sha {
+			return nil, fmt.Errorf("unexpected cat-file output %q", strings.TrimSpace(header))
+		}
+		size, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected cat-file output %q", strings.TrimSpace(header))
+		}
+		data := make([]byte, size+1)
+		if _, err := io.ReadFull(rd, data); err != nil {
+			return nil, fmt.Errorf("read blob %s: %w", b.sha, err)
+		}
+		files = append(files, File{Path: b.path, Data: data[:size]})
+	}
+	return files, nil
+}
+
+// ValidateRef 拒绝可能被 git 当作选项或无效的 ref
+func ValidateRef(ref string) error {
+	if ref == "" || len(ref) > 255 || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\r\n\x00~^:?*[\\") {
+		return fmt.Errorf("invalid ref %q", ref)
+	}
+	return nil
+}
+
+// git 在仓库中执行命令，禁用交互提示，并把仓库的父目录设为查找上限
+func (r *Repo) git(ctx context.Context, stdin io.Reader, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, r.binary, append([]string{"-C", r.dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_CEILING_DIRECTORIES="+filepath.Dir(filepath.Clean(r.dir)),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_OPTIONAL_LOCKS=0",
+		"LC_ALL=C",
+	)
+	cmd.Stdin = stdin
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("git %s timed out after %s", args[0], r.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s: %w", args[0], msg, err)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+func samePath(a, b string) bool {
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	if errA != nil || errB != nil {
+		return filepath.Clean(a) == filepath.Clean(b)
+	}
+	return ra == rb
+}
@@ -0,0 +1,40 @@
+package gitrepo
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRef(t *testing.T) {
+	tests := []struct {
+		ref     string
+		wantErr bool
+	}{
+		{"main", false},
+		{"release/v1.2", false},
+		{"refs/heads/main", false},
+		{"v1.0.0", false},
+		{"0123456789abcdef0123456789abcdef01234567", false},
+		{"", true},
+		{"-main", true},
+		{"--upload-pack=evil", true},
+		{"main~1", true},
+		{"main^", true},
+		{"main:path", true},
+		{"ma?n", true},
+		{"ma*n", true},
+		{"ma[n", true},
+		{`ma\n`, true},
+		{"ma n", true},
+		{"main\n", true},
+		{"main\t", true},
+		{"ma\x00in", true},
+		{strings.Repeat("a", 255), false},
+		{strings.Repeat("a", 256), true},
+	}
+	for _, tt := range tests {
+		if err := ValidateRef(tt.ref); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateRef(%q) error = %v, wantErr %v", tt.ref, err, tt.wantErr)
+		}
+	}
+}
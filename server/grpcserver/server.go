@@ -0,0 +1,301 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"quiver/config"
+	"quiver/logger"
+	"quiver/models"
+	quiverv1 "quiver/proto/quiver/v1"
+	"quiver/services"
+	"quiver/telemetry"
+	"quiver/utils"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// accesskey 签名使用的 metadata，签名内容见 services.SignAccessKey
+const (
+	MetadataAccessKey = "x-quiver-accesskey"
+	MetadataTimestamp = "x-quiver-timestamp"
+	MetadataSignature = "x-quiver-signature"
+)
+
+// Start 按配置在独立端口启动 gRPC 服务，未开启时什么也不做；返回的函数用于停止服务
+func Start(conf config.GRPCConfig) (func(), error) {
+	if !conf.Enabled {
+		return func() {}, nil
+	}
+
+	lis, err := net.Listen("tcp", conf.Addr)
+	if err != nil {
+		return nil, err
+	}
+	server := grpc.NewServer()
+	quiverv1.RegisterConfigServiceServer(server, &configServer{releaseService: services.NewReleaseService()})
+
+	go func() {
+		logger.GetLogger("quiver").Infof("gRPC server starting on %s", conf.Addr)
+		if err := server.Serve(lis); err != nil {
+			logger.GetLogger("quiver").Errorf("gRPC server stopped: %v", err)
+		}
+	}()
+	return server.GracefulStop, nil
+}
+
+// configServer 实现 quiver.v1.ConfigService
+type configServer struct {
+	quiverv1.UnimplementedConfigServiceServer
+	releaseService *services.ReleaseService
+}
+
+// GetConfig 与 HTTP GetRelease 相同，返回最新版本相对 release_id 的增量
+func (s *configServer) GetConfig(ctx context.Context, req *quiverv1.GetConfigRequest) (_ *quiverv1.GetConfigResponse, err error) {
+	ctx, span := telemetry.Start(ctx, "grpc.GetConfig")
+	defer func() { telemetry.End(span, err) }()
+
+	if err := validate(req.GetEnv(), req.GetAppName(), req.GetClusterName(), req.GetNamespaceName()); err != nil {
+		return nil, err
+	}
+	ref := watchKey{cluster: req.GetClusterName(), namespace: req.GetNamespaceName()}
+	if _, err := authenticate(ctx, quiverv1.ConfigService_GetConfig_FullMethodName, req.GetEnv(), req.GetAppName(), []watchKey{ref}); err != nil {
+		return nil, err
+	}
+
+	delta, err := s.releaseService.WithContext(ctx).GetReleaseDelta(req.GetEnv(), req.GetAppName(), req.GetClusterName(), req.GetNamespaceName(), req.GetReleaseId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &quiverv1.GetConfigResponse{
+		Config: toConfig(req.GetEnv(), req.GetAppName(), req.GetClusterName(), req.GetNamespaceName(), req.GetReleaseId(), delta),
+	}, nil
+}
+
+// watchRecheckInterval Watch 订阅期间重新校验 accesskey 和权限的间隔
+const watchRecheckInterval = time.Minute
+
+// watchKey 订阅的命名空间
+type watchKey struct {
+	cluster   string
+	namespace string
+}
+
+// Watch 先推送已经落后的命名空间，之后每次发布、回滚推送相对上一次推送版本的增量，直到客户端断开；
+// 订阅期间定期以及每次推送前重新校验 accesskey 和权限，accesskey 删除、过期或权限收回时结束订阅
+func (s *configServer) Watch(req *quiverv1.WatchRequest, stream quiverv1.ConfigService_WatchServer) error {
+	ctx := stream.Context()
+	env, appName := req.GetEnv(), req.GetAppName()
+
+	if err := validate(env, appName, "", ""); err != nil {
+		return err
+	}
+	if len(req.GetNamespaces()) == 0 || len(req.GetNamespaces()) > services.MaxBatchReleaseQueries {
+		return status.Errorf(codes.InvalidArgument, "namespaces must contain 1 to %d entries", services.MaxBatchReleaseQueries)
+	}
+
+	known := make(map[watchKey]string, len(req.GetNamespaces()))
+	keys := make([]watchKey, 0, len(req.GetNamespaces()))
+	requested := make([]watchKey, 0, len(req.GetNamespaces()))
+	for _, ns := range req.GetNamespaces() {
+		if err := validate(env, appName, ns.GetClusterName(), ns.GetNamespaceName()); err != nil {
+			return err
+		}
+		key := watchKey{cluster: ns.GetClusterName(), namespace: ns.GetNamespaceName()}
+		if _, ok := known[key]; !ok {
+			keys = append(keys, key)
+		}
+		known[key] = ns.GetReleaseId()
+		requested = append(requested, key)
+	}
+	ak, err := authenticate(ctx, quiverv1.ConfigService_Watch_FullMethodName, env, appName, requested)
+	if err != nil {
+		return err
+	}
+
+	// 先订阅再做首次检查，两者之间的发布不会漏掉
+	sub := services.SubscribeReleases()
+	defer sub.Close()
+
+	for _, key := range keys {
+		if err := s.push(ctx, stream, env, appName, key, known); err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(watchRecheckInterval)
+	defer ticker.Stop()
+
+	logger.GetLogger("quiver").WithContext(ctx).Infof("gRPC watch %s/%s %d namespaces", env, appName, len(keys))
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := reauthorize(ctx, env, ak.AccessKey, appName, keys); err != nil {
+				return err
+			}
+		case <-sub.Notify():
+			var changed []watchKey
+			for _, ev := range sub.Drain() {
+				key := watchKey{cluster: ev.ClusterName, namespace: ev.NamespaceName}
+				if _, ok := known[key]; !ok || ev.Env != env || ev.AppName != appName {
+					continue
+				}
+				changed = append(changed, key)
+			}
+			if len(changed) == 0 {
+				continue
+			}
+			if err := reauthorize(ctx, env, ak.AccessKey, appName, keys); err != nil {
+				return err
+			}
+			for _, key := range changed {
+				if err := s.push(ctx, stream, env, appName, key, known); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// push 命名空间有比 known 更新的版本时推送增量，并记录已推送的版本；还没有发布的命名空间跳过
+func (s *configServer) push(ctx context.Context, stream quiverv1.ConfigService_WatchServer, env, appName string, key watchKey, known map[watchKey]string) error {
+	releaseID := known[key]
+	delta, err := s.releaseService.WithContext(ctx).GetReleaseDelta(env, appName, key.cluster, key.namespace, releaseID)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Warnf("gRPC watch %s/%s/%s/%s: %v", env, appName, key.cluster, key.namespace, err)
+		return nil
+	}
+	if delta.Release.ReleaseID == releaseID && len(delta.Items) == 0 {
+		return nil
+	}
+
+	event := &quiverv1.WatchEvent{Config: toConfig(env, appName, key.cluster, key.namespace, releaseID, delta)}
+	if err := stream.Send(event); err != nil {
+		return err
+	}
+	known[key] = delta.Release.ReleaseID
+	return nil
+}
+
+func validate(env, appName, clusterName, namespaceName string) error {
+	switch {
+	case !utils.ValidateEnv(env):
+		return status.Error(codes.InvalidArgument, "invalid env")
+	case !utils.ValidateAppName(appName):
+		return status.Error(codes.InvalidArgument, "invalid app_name")
+	case clusterName == "" && namespaceName == "":
+		return nil
+	case !utils.ValidateClusterName(clusterName):
+		return status.Error(codes.InvalidArgument, "invalid cluster_name")
+	case !utils.ValidateNamespaceName(namespaceName):
+		return status.Error(codes.InvalidArgument, "invalid namespace_name")
+	}
+	return nil
+}
+
+// authenticate 校验 metadata 中的 accesskey 签名，再校验 accesskey 的所有者能否读取每个命名空间；
+// 不论是否开启 auth.required，gRPC 请求都必须签名。namespaces 按请求中的顺序参与签名
+func authenticate(ctx context.Context, method, env, appName string, namespaces []watchKey) (*models.AccessKey, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	get := func(key string) string {
+		if values := md.Get(key); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	}
+
+	accessKey := get(MetadataAccessKey)
+	if accessKey == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing access key")
+	}
+
+	scope := make([]string, 0, len(namespaces))
+	for _, ns := range namespaces {
+		scope = append(scope, ns.cluster+"/"+ns.namespace)
+	}
+	payload := services.AccessKeyPayload(method, env, appName, scope)
+
+	ak, err := services.NewAccessKeyService().Authenticate(env, accessKey, get(MetadataTimestamp), get(MetadataSignature), payload)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Warnf("gRPC authenticate %s failed: %v", accessKey, err)
+		return nil, status.Error(codes.Unauthenticated, err.Error())
+	}
+	if err := authorize(ctx, env, ak, appName, namespaces); err != nil {
+		return nil, err
+	}
+	return ak, nil
+}
+
+// reauthorize 重新读取 accesskey 并校验每个命名空间的权限，不再校验签名；
+// accesskey 被删除或过期时返回 Unauthenticated，权限被收回时返回 PermissionDenied
+func reauthorize(ctx context.Context, env, accessKey, appName string, namespaces []watchKey) error {
+	ak, err := services.NewAccessKeyService().Revalidate(env, accessKey)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Warnf("gRPC revalidate %s failed: %v", accessKey, err)
+		return authStatus(err)
+	}
+	return authorize(ctx, env, ak, appName, namespaces)
+}
+
+// authorize 校验 accesskey 的所有者能否读取每个命名空间
+func authorize(ctx context.Context, env string, ak *models.AccessKey, appName string, namespaces []watchKey) error {
+	accessKeyService := services.NewAccessKeyService()
+	for _, ns := range namespaces {
+		if err := accessKeyService.Authorize(env, ak, appName, ns.cluster, ns.namespace); err != nil {
+			logger.GetLogger("quiver").WithContext(ctx).Warnf("gRPC authorize %s on %s/%s/%s/%s failed: %v", ak.AccessKey, env, appName, ns.cluster, ns.namespace, err)
+			return authStatus(err)
+		}
+	}
+	return nil
+}
+
+// authStatus 把 accesskey 校验的错误转换为 gRPC 状态码
+func authStatus(err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidAccessKey), errors.Is(err, services.ErrAccessKeyExpired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, services.ErrAccessDenied):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// toStatus 把服务层的错误转换为 gRPC 状态码，命名空间还没有发布时返回 NotFound
+func toStatus(err error) error {
+	if errors.Is(err, services.ErrNoRelease) || strings.Contains(err.Error(), "not found") {
+		return status.Error(codes.NotFound, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+func toConfig(env, appName, clusterName, namespaceName, releaseID string, delta *services.ReleaseDelta) *quiverv1.Config {
+	release := delta.Release
+	cfg := &quiverv1.Config{
+		Env:             env,
+		AppName:         appName,
+		ClusterName:     clusterName,
+		NamespaceName:   namespaceName,
+		ReleaseId:       release.ReleaseID,
+		ReleaseName:     release.ReleaseName,
+		ReleaseTime:     timestamppb.New(release.ReleaseTime),
+		Operator:        release.Operator,
+		Comment:         release.Comment,
+		Items:           make([]*quiverv1.Item, 0, len(delta.Items)),
+		SourceReleaseId: release.SourceRelease,
+	}
+	for _, item := range delta.Items {
+		cfg.Items = append(cfg.Items, &quiverv1.Item{Key: item.Key, Value: item.Value})
+	}
+	if releaseID != "" {
+		cfg.Changed = &quiverv1.ChangeSet{Added: delta.Added, Updated: delta.Updated, Deleted: delta.Deleted}
+	}
+	return cfg
+}
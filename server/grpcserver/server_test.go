@@ -0,0 +1,46 @@
+package grpcserver
+
+import (
+	"errors"
+	"fmt"
+	"quiver/services"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestToStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{services.ErrNoRelease, codes.NotFound},
+		{fmt.Errorf("get latest: %w", services.ErrNoRelease), codes.NotFound},
+		{errors.New("namespace not found"), codes.NotFound},
+		{errors.New("db not initialized"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(toStatus(tt.err)); got != tt.want {
+			t.Errorf("toStatus(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestAuthStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want codes.Code
+	}{
+		{services.ErrInvalidAccessKey, codes.Unauthenticated},
+		{services.ErrAccessKeyExpired, codes.Unauthenticated},
+		{services.ErrAccessDenied, codes.PermissionDenied},
+		{fmt.Errorf("authorize: %w", services.ErrAccessDenied), codes.PermissionDenied},
+		{errors.New("db not initialized"), codes.Internal},
+	}
+	for _, tt := range tests {
+		if got := status.Code(authStatus(tt.err)); got != tt.want {
+			t.Errorf("authStatus(%v) = %s, want %s", tt.err, got, tt.want)
+		}
+	}
+}
@@ -27,7 +27,7 @@ func (c *AppHandler) CreateApp(ctx *fiber.Ctx) error {
 
 	var app models.App
 	if err := ctx.BodyParser(&app); err != nil {
-		logger.GetLogger("quiver").Error("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
@@ -38,20 +38,20 @@ func (c *AppHandler) CreateApp(ctx *fiber.Ctx) error {
 	}
 
 	if len(app.Description) > 500 {
-		logger.GetLogger("quiver").Error("description is too long")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("description is too long")
 		return utils.BadRequest(ctx, "description is too long")
 	}
 
 	// 创建应用
-	if err := c.appService.CreateApp(env, &app); err != nil {
+	if err := c.appService.WithContext(ctx.UserContext()).CreateApp(env, &app); err != nil {
 		if err.Error() == "app_name already exists" {
-			logger.GetLogger("quiver").Errorf("app_name already exists")
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app_name already exists")
 			return utils.Conflict(ctx, err.Error())
 		}
-		logger.GetLogger("quiver").Errorf("app create failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app create failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
-	logger.GetLogger("quiver").Infof("app created: %+v", app)
+	logger.GetLogger("quiver").WithContext(ctx.UserContext()).Infof("app created: %+v", app)
 
 	return utils.Success(ctx, 0, "success", app)
 }
@@ -70,9 +70,9 @@ func (c *AppHandler) ListApp(ctx *fiber.Ctx) error {
 		size = 20
 	}
 
-	apps, total, err := c.appService.ListApp(env, page, size)
+	apps, total, err := c.appService.WithContext(ctx.UserContext()).ListApp(env, page, size)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("app list failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app list failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
@@ -98,9 +98,9 @@ func (c *AppHandler) GetApp(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	app, err := c.appService.GetApp(env, appName)
+	app, err := c.appService.WithContext(ctx.UserContext()).GetApp(env, appName)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("app_name %s not found", appName)
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app_name %s not found", appName)
 		return utils.NotFound(ctx, "app_name not found")
 	}
 
@@ -129,13 +129,13 @@ func (c *AppHandler) UpdateApp(ctx *fiber.Ctx) error {
 
 	var updates map[string]interface{}
 	if err := ctx.BodyParser(&updates); err != nil {
-		logger.GetLogger("quiver").Error("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
-	app, err := c.appService.UpdateApp(env, appName, updates)
+	app, err := c.appService.WithContext(ctx.UserContext()).UpdateApp(env, appName, updates)
 	if err != nil {
-		logger.GetLogger("quiver").Error("app update failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app update failed %s", err.Error())
 		if err.Error() == "app_name not exist" {
 			return utils.NotFound(ctx, "app_name not found")
 		}
@@ -164,11 +164,11 @@ func (c *AppHandler) DeleteApp(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	if err := c.appService.DeleteApp(env, appName); err != nil {
+	if err := c.appService.WithContext(ctx.UserContext()).DeleteApp(env, appName); err != nil {
 		if err.Error() == "app_name not exist" {
 			return utils.NotFound(ctx, "app_name not found")
 		}
-		logger.GetLogger("quiver").Errorf("app delete failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app delete failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
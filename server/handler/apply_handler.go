@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"errors"
+	"quiver/logger"
+	"quiver/services"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v2"
+)
+
+// ApplyHandler 声明式配置控制器
+type ApplyHandler struct{}
+
+// NewApplyHandler 创建声明式配置控制器实例
+func NewApplyHandler() *ApplyHandler {
+	return &ApplyHandler{}
+}
+
+// Apply 请求体为 YAML 或 JSON 清单，默认 dry_run 只返回计划；
+// dry_run=false 时在一个事务中执行，带上 plan_id 时计划与审阅时不同则返回 409 和新的计划
+func (h *ApplyHandler) Apply(c *fiber.Ctx) error {
+	env := c.Locals("env").(string)
+
+	var manifest services.Manifest
+	if err := yaml.UnmarshalStrict(c.Body(), &manifest); err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("invalid manifest: %v", err)
+		return utils.BadRequest(c, "invalid manifest: "+err.Error())
+	}
+
+	opts := services.ApplyOptions{
+		DryRun:      c.QueryBool("dry_run", true),
+		Prune:       c.QueryBool("prune", true),
+		Publish:     c.QueryBool("publish", false),
+		PlanID:      c.Query("plan_id"),
+		ReleaseName: c.Query("release_name"),
+		Operator:    c.Query("operator"),
+		Comment:     c.Query("comment"),
+	}
+	if opts.Operator == "" {
+		opts.Operator, _ = c.Locals("user_name").(string)
+	}
+
+	plan, err := services.NewApplyService().WithContext(c.UserContext()).Apply(env, &manifest, opts)
+	if err != nil {
+		if errors.Is(err, services.ErrPlanChanged) {
+			return utils.Error(c, fiber.StatusConflict, err.Error(), plan)
+		}
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("apply manifest for env %s failed: %v", env, err)
+		return utils.BadRequest(c, err.Error())
+	}
+	return utils.Success(c, 0, "success", plan)
+}
@@ -1,12 +1,13 @@
 package handler
 
 import (
+	"errors"
 	"github.com/gofiber/fiber/v2"
-	"github.com/vmihailenco/msgpack/v5"
-	"quiver/cache"
+	"quiver/config"
 	"quiver/logger"
 	"quiver/models"
 	"quiver/services"
+	"quiver/sso"
 	"quiver/utils"
 	"time"
 )
@@ -15,19 +16,40 @@ import (
 type LoginRequest struct {
 	UserName string `json:"user_name"`
 	Password string `json:"password"`
+	Provider string `json:"provider"` // local 或 ldap，为空时优先本地登录
+}
+
+// LinkIdentityRequest 关联外部身份请求体
+type LinkIdentityRequest struct {
+	Source     string `json:"source"`      // ldap 或 oidc
+	ExternalID string `json:"external_id"` // LDAP 用户 DN，OIDC 为 iss#sub
+}
+
+// RefreshRequest 刷新令牌请求体
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 // LoginResponse 响应结构
 type LoginResponse struct {
-	UserName  string    `json:"user_name"`
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
+	UserName         string    `json:"user_name"`
+	SessionID        string    `json:"session_id"`
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
 }
 
-type AuthHandler struct{}
+type AuthHandler struct {
+	sessionService *services.SessionService
+	ssoService     *services.SSOService
+}
 
 func NewAuthHandler() *AuthHandler {
-	return &AuthHandler{}
+	return &AuthHandler{
+		sessionService: services.NewSessionService(),
+		ssoService:     services.NewSSOService(),
+	}
 }
 
 func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
@@ -40,49 +62,263 @@ func (h *AuthHandler) Login(ctx *fiber.Ctx) error {
 		})
 	}
 
-	user := services.NewUserService()
-	u, err := user.GetUserByName(env, req.UserName)
+	authConf := config.GetAuthConfig()
+	provider := req.Provider
+	if provider == "" {
+		provider = sso.SourceLocal
+		if !authConf.LocalLoginEnabled() && authConf.LDAP.Enabled {
+			provider = sso.SourceLDAP
+		}
+	}
+
+	var u *models.User
+	switch provider {
+	case sso.SourceLocal:
+		if !authConf.LocalLoginEnabled() {
+			return utils.BadRequest(ctx, "local login disabled")
+		}
+		user := services.NewUserService()
+		found, err := user.GetUserByName(env, req.UserName)
+		if err != nil {
+			if err.Error() == "user not found" {
+				return utils.NotFound(ctx, "user not found")
+			}
+			return utils.InternalError(ctx, err.Error())
+		}
+		// SSO 用户只能通过身份提供方登录
+		if found.Source != "" && found.Source != sso.SourceLocal {
+			return utils.Unauthorized(ctx, "please login with "+found.Source)
+		}
+		if !found.CheckPassword(req.Password) {
+			return utils.Unauthorized(ctx, "invalid password")
+		}
+		u = found
+	case sso.SourceLDAP:
+		found, err := h.ssoService.LoginLDAP(env, req.UserName, req.Password)
+		if err != nil {
+			switch {
+			case errors.Is(err, sso.ErrInvalidCredentials):
+				return utils.Unauthorized(ctx, "invalid credentials")
+			case errors.Is(err, sso.ErrProviderDisabled):
+				return utils.BadRequest(ctx, "ldap login disabled")
+			case errors.Is(err, services.ErrIdentityNotLinked):
+				return utils.Forbidden(ctx, err.Error())
+			}
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("ldap login %s failed: %v", req.UserName, err)
+			return utils.InternalError(ctx, err.Error())
+		}
+		u = found
+	default:
+		return utils.BadRequest(ctx, "invalid provider")
+	}
+
+	return h.issueSession(ctx, env, u)
+}
+
+// OIDCLogin 跳转到 OIDC 身份提供方登录
+func (h *AuthHandler) OIDCLogin(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+
+	url, err := h.ssoService.OIDCLoginURL(ctx.UserContext(), env)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return utils.NotFound(ctx, "user not found")
+		if errors.Is(err, sso.ErrProviderDisabled) {
+			return utils.BadRequest(ctx, "oidc login disabled")
 		}
 		return utils.InternalError(ctx, err.Error())
 	}
-
-	if !u.CheckPassword(req.Password) {
-		return utils.Unauthorized(ctx, "invalid password")
+	// 前端自行跳转时只返回地址
+	if ctx.Query("redirect") == "false" {
+		return utils.Success(ctx, 0, "success", fiber.Map{"url": url})
 	}
+	return ctx.Redirect(url, fiber.StatusFound)
+}
 
-	// 生成临时access token
-	s := services.NewAccessKeyService()
-	ak, sk, err := s.GenerateKeys()
+// OIDCCallback 身份提供方回调，校验通过后创建会话
+func (h *AuthHandler) OIDCCallback(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
 
-	accessKey := &models.AccessKey{
-		UserID:     u.UserID,
-		AccessKey:  ak,
-		SecretKey:  sk,
-		ExpireAt:   utils.Ptr(time.Now().Add(time.Hour * 24)),
-		CreateTime: time.Now(),
-		UpdateTime: time.Now(),
+	if e := ctx.Query("error"); e != "" {
+		return utils.Unauthorized(ctx, e+": "+ctx.Query("error_description"))
 	}
 
-	// 把 accessKey 存入 cache
-	if data, err := msgpack.Marshal(&accessKey); err == nil && len(data) > 0 {
-		_ = cache.Set(accessKey.CacheKey(env), data, time.Hour*24)
-		logger.GetLogger("quiver").Infof("write accesskey %s to cache", accessKey.AccessKey)
+	u, err := h.ssoService.OIDCCallback(ctx.UserContext(), env, ctx.Query("code"), ctx.Query("state"))
+	if err != nil {
+		switch {
+		case err.Error() == "invalid state":
+			return utils.BadRequest(ctx, err.Error())
+		case errors.Is(err, sso.ErrInvalidCredentials):
+			return utils.Unauthorized(ctx, "invalid credentials")
+		case errors.Is(err, sso.ErrProviderDisabled):
+			return utils.BadRequest(ctx, "oidc login disabled")
+		case errors.Is(err, services.ErrIdentityNotLinked):
+			return utils.Forbidden(ctx, err.Error())
+		}
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("oidc callback failed: %v", err)
+		return utils.InternalError(ctx, err.Error())
 	}
 
+	return h.issueSession(ctx, env, u)
+}
+
+// issueSession 登录成功后创建会话并返回令牌
+func (h *AuthHandler) issueSession(ctx *fiber.Ctx, env string, u *models.User) error {
+	tokens, err := h.sessionService.CreateSession(env, u, ctx.IP(), ctx.Get(fiber.HeaderUserAgent))
+	if err != nil {
+		return utils.InternalError(ctx, err.Error())
+	}
+	logger.GetLogger("quiver").WithContext(ctx.UserContext()).Infof("user %s login, session %s", u.UserName, tokens.SessionID)
+
 	return utils.Success(ctx, 0, "success", LoginResponse{
-		ExpiresAt: *accessKey.ExpireAt,
-		Token:     accessKey.AccessKey,
-		UserName:  u.UserName,
+		UserName:         u.UserName,
+		SessionID:        tokens.SessionID,
+		Token:            tokens.AccessToken,
+		ExpiresAt:        tokens.AccessExpiresAt,
+		RefreshToken:     tokens.RefreshToken,
+		RefreshExpiresAt: tokens.RefreshExpiresAt,
 	})
 }
 
+// RefreshToken 用 refresh token 换取新的令牌，旧的 refresh token 随即失效
 func (h *AuthHandler) RefreshToken(ctx *fiber.Ctx) error {
-	return utils.Success(ctx, 0, "success", nil)
+	env := ctx.Locals("env").(string)
+
+	var req RefreshRequest
+	if err := ctx.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return utils.BadRequest(ctx, "refresh_token is required")
+	}
+
+	tokens, err := h.sessionService.Refresh(env, req.RefreshToken)
+	if err != nil {
+		switch err.Error() {
+		case "invalid token", "session expired or revoked":
+			return utils.Unauthorized(ctx, err.Error())
+		}
+		return utils.InternalError(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", fiber.Map{
+		"session_id":         tokens.SessionID,
+		"token":              tokens.AccessToken,
+		"expires_at":         tokens.AccessExpiresAt,
+		"refresh_token":      tokens.RefreshToken,
+		"refresh_expires_at": tokens.RefreshExpiresAt,
+	})
 }
 
+// Logout 吊销当前会话
 func (h *AuthHandler) Logout(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	userID, ok := ctx.Locals("user_id").(uint64)
+	if !ok {
+		return utils.Unauthorized(ctx, "login required")
+	}
+	sessionID := ctx.Locals("session_id").(string)
+
+	if err := h.sessionService.RevokeSession(env, userID, sessionID, "logout"); err != nil {
+		if err.Error() == "session not found" {
+			return utils.NotFound(ctx, err.Error())
+		}
+		return utils.InternalError(ctx, err.Error())
+	}
+	return utils.Success(ctx, 0, "success", nil)
+}
+
+// ListSessions 列出当前用户的有效会话
+func (h *AuthHandler) ListSessions(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	userID, ok := ctx.Locals("user_id").(uint64)
+	if !ok {
+		return utils.Unauthorized(ctx, "login required")
+	}
+	current, _ := ctx.Locals("session_id").(string)
+
+	sessions, err := h.sessionService.ListSessions(env, userID)
+	if err != nil {
+		return utils.InternalError(ctx, err.Error())
+	}
+
+	result := make([]fiber.Map, 0, len(sessions))
+	for _, s := range sessions {
+		result = append(result, fiber.Map{
+			"session_id":        s.SessionID,
+			"client_ip":         s.ClientIP,
+			"user_agent":        s.UserAgent,
+			"create_time":       s.CreateTime,
+			"last_seen_at":      s.LastSeenAt,
+			"refresh_expire_at": s.RefreshExpireAt,
+			"current":           s.SessionID == current,
+		})
+	}
+	return utils.Success(ctx, 0, "success", result)
+}
+
+// RevokeSession 吊销当前用户的某个会话
+func (h *AuthHandler) RevokeSession(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	userID, ok := ctx.Locals("user_id").(uint64)
+	if !ok {
+		return utils.Unauthorized(ctx, "login required")
+	}
+
+	sessionID := ctx.Params("session_id")
+	if sessionID == "" {
+		return utils.BadRequest(ctx, "session_id is required")
+	}
+
+	if err := h.sessionService.RevokeSession(env, userID, sessionID, "revoked by user"); err != nil {
+		if err.Error() == "session not found" {
+			return utils.NotFound(ctx, err.Error())
+		}
+		return utils.InternalError(ctx, err.Error())
+	}
 	return utils.Success(ctx, 0, "success", nil)
 }
+
+// ForceLogout 管理员强制用户下线，吊销其全部会话
+func (h *AuthHandler) ForceLogout(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	userID, err := services.GetUserID(ctx)
+	if userID == 0 {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid user_id: %v", err)
+		return err
+	}
+
+	operator, _ := ctx.Locals("user_name").(string)
+	count, err := h.sessionService.RevokeUserSessions(env, userID, "force logout by "+operator)
+	if err != nil {
+		return utils.InternalError(ctx, err.Error())
+	}
+	return utils.Success(ctx, 0, "success", fiber.Map{
+		"revoked": count,
+	})
+}
+
+// LinkIdentity 管理员把用户关联到外部身份，同名的本地用户需要关联后才能通过 SSO 登录
+func (h *AuthHandler) LinkIdentity(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	userID, err := services.GetUserID(ctx)
+	if userID == 0 {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid user_id: %v", err)
+		return err
+	}
+
+	var req LinkIdentityRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+
+	user, err := h.ssoService.LinkIdentity(env, userID, req.Source, req.ExternalID)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrIdentityLinked):
+			return utils.Conflict(ctx, err.Error())
+		case err.Error() == "user not found":
+			return utils.NotFound(ctx, err.Error())
+		case err.Error() == "invalid source", err.Error() == "invalid external_id":
+			return utils.BadRequest(ctx, err.Error())
+		}
+		return utils.InternalError(ctx, err.Error())
+	}
+	user.Password = ""
+	return utils.Success(ctx, 0, "success", user)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/url"
+	"quiver/cache"
+	"quiver/logger"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// value log 回收的默认丢弃比例
+const defaultDiscardRatio = 0.5
+
+// CacheHandler 本地缓存管理控制器，只作用于处理请求的这一个实例
+type CacheHandler struct{}
+
+// NewCacheHandler 创建缓存管理控制器实例
+func NewCacheHandler() *CacheHandler {
+	return &CacheHandler{}
+}
+
+// ListKeys 按前缀列出缓存 key，支持 after 翻页
+func (h *CacheHandler) ListKeys(c *fiber.Ctx) error {
+	prefix := c.Query("prefix")
+	after := c.Query("after")
+	limit := c.QueryInt("limit", 100)
+
+	keys, next, err := cache.Keys(prefix, after, limit)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("list cache keys %s failed: %v", prefix, err)
+		return utils.InternalError(c, err.Error())
+	}
+	return utils.Success(c, 0, "success", fiber.Map{
+		"keys": keys,
+		"next": next,
+	})
+}
+
+// GetKey 查看单个 key 的大小、剩余 TTL 和所在层级
+func (h *CacheHandler) GetKey(c *fiber.Ctx) error {
+	key, err := url.PathUnescape(c.Params("key"))
+	if err != nil || key == "" {
+		return utils.BadRequest(c, "invalid key")
+	}
+
+	info, found, err := cache.Inspect(key)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("inspect cache key %s failed: %v", key, err)
+		return utils.InternalError(c, err.Error())
+	}
+	if !found {
+		return utils.NotFound(c, "key not found")
+	}
+	return utils.Success(c, 0, "success", info)
+}
+
+// DeleteKey 删除单个 key
+func (h *CacheHandler) DeleteKey(c *fiber.Ctx) error {
+	key, err := url.PathUnescape(c.Params("key"))
+	if err != nil || key == "" {
+		return utils.BadRequest(c, "invalid key")
+	}
+
+	if err := cache.Delete(key); err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("delete cache key %s failed: %v", key, err)
+		return utils.InternalError(c, err.Error())
+	}
+	logger.GetLogger("quiver").WithContext(c.UserContext()).Infof("cache key %s deleted by admin api", key)
+	return utils.Success(c, 0, "success", nil)
+}
+
+// PurgeKeys 删除所有以 prefix 开头的 key，prefix 不能为空，避免误清空
+func (h *CacheHandler) PurgeKeys(c *fiber.Ctx) error {
+	prefix := c.Query("prefix")
+	if prefix == "" {
+		return utils.BadRequest(c, "prefix is required")
+	}
+
+	deleted, err := cache.PurgePrefix(prefix)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("purge cache prefix %s failed: %v", prefix, err)
+		return utils.InternalError(c, err.Error())
+	}
+	logger.GetLogger("quiver").WithContext(c.UserContext()).Infof("purged %d cache keys with prefix %s", deleted, prefix)
+	return utils.Success(c, 0, "success", fiber.Map{
+		"deleted": deleted,
+	})
+}
+
+// Stats 缓存统计
+func (h *CacheHandler) Stats(c *fiber.Ctx) error {
+	stats, err := cache.GetStats()
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("get cache stats failed: %v", err)
+		return utils.InternalError(c, err.Error())
+	}
+	return utils.Success(c, 0, "success", stats)
+}
+
+// RunGC 触发 badger value log 回收
+func (h *CacheHandler) RunGC(c *fiber.Ctx) error {
+	var request struct {
+		DiscardRatio float64 `json:"discard_ratio"`
+	}
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&request); err != nil {
+			return utils.BadRequest(c, "invalid request body")
+		}
+	}
+	if request.DiscardRatio == 0 {
+		request.DiscardRatio = defaultDiscardRatio
+	}
+	if request.DiscardRatio <= 0 || request.DiscardRatio >= 1 {
+		return utils.BadRequest(c, "discard_ratio must be between 0 and 1")
+	}
+
+	rewrites, err := cache.RunValueLogGC(request.DiscardRatio)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("run value log gc failed: %v", err)
+		return utils.InternalError(c, err.Error())
+	}
+	logger.GetLogger("quiver").WithContext(c.UserContext()).Infof("value log gc rewrote %d files", rewrites)
+	return utils.Success(c, 0, "success", fiber.Map{
+		"rewrites": rewrites,
+	})
+}
@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"quiver/cache"
+	"quiver/utils"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "quiver-handler-test")
+	if err != nil {
+		panic(err)
+	}
+	if err := cache.Init(cache.Options{MaxMemCost: 1 << 20, DiskDir: dir}); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	_ = cache.Close()
+	_ = os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newCacheApp() *fiber.App {
+	h := NewCacheHandler()
+	app := fiber.New()
+	app.Get("/cache/stats", h.Stats)
+	app.Get("/cache/keys", h.ListKeys)
+	app.Delete("/cache/keys", h.PurgeKeys)
+	app.Get("/cache/keys/:key", h.GetKey)
+	app.Delete("/cache/keys/:key", h.DeleteKey)
+	app.Post("/cache/gc", h.RunGC)
+	return app
+}
+
+// call 发起请求并解析统一响应结构
+func call(t *testing.T, app *fiber.App, method, target, body string, data interface{}) int {
+	t.Helper()
+	var reader io.Reader
+	if body != "" {
+		reader = strings.NewReader(body)
+	}
+	req := httptest.NewRequest(method, target, reader)
+	if body != "" {
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	}
+	resp, err := app.Test(req, -1)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, target, err)
+	}
+	defer resp.Body.Close()
+
+	response := utils.Response{Data: data}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		t.Fatalf("%s %s: decode response: %v", method, target, err)
+	}
+	return resp.StatusCode
+}
+
+func setKeys(t *testing.T, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		if err := cache.Set(key, []byte("value of "+key), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCacheHandlerKeys(t *testing.T) {
+	app := newCacheApp()
+	setKeys(t, "keys:a", "keys:b", "keys:c", "other:a")
+
+	var page struct {
+		Keys []cache.KeyInfo `json:"keys"`
+		Next string          `json:"next"`
+	}
+	if code := call(t, app, http.MethodGet, "/cache/keys?prefix=keys:&limit=2", "", &page); code != http.StatusOK {
+		t.Fatalf("list status = %d", code)
+	}
+	if len(page.Keys) != 2 || page.Keys[0].Key != "keys:a" || page.Keys[1].Key != "keys:b" || page.Next != "keys:b" {
+		t.Fatalf("first page = %+v", page)
+	}
+
+	page.Keys, page.Next = nil, ""
+	call(t, app, http.MethodGet, "/cache/keys?prefix=keys:&limit=2&after=keys:b", "", &page)
+	if len(page.Keys) != 1 || page.Keys[0].Key != "keys:c" || page.Next != "" {
+		t.Fatalf("second page = %+v", page)
+	}
+
+	var info cache.KeyInfo
+	if code := call(t, app, http.MethodGet, "/cache/keys/"+url.PathEscape("keys:a"), "", &info); code != http.StatusOK {
+		t.Fatalf("get status = %d", code)
+	}
+	if info.Key != "keys:a" || !info.OnDisk || info.Size != len("value of keys:a") {
+		t.Fatalf("key info = %+v", info)
+	}
+
+	if code := call(t, app, http.MethodDelete, "/cache/keys/"+url.PathEscape("keys:a"), "", nil); code != http.StatusOK {
+		t.Fatalf("delete status = %d", code)
+	}
+	if code := call(t, app, http.MethodGet, "/cache/keys/"+url.PathEscape("keys:a"), "", nil); code != http.StatusNotFound {
+		t.Fatalf("get deleted key status = %d, want 404", code)
+	}
+}
+
+func TestCacheHandlerPurge(t *testing.T) {
+	app := newCacheApp()
+	setKeys(t, "purge:1", "purge:2", "keep:1")
+
+	if code := call(t, app, http.MethodDelete, "/cache/keys", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("purge without prefix status = %d, want 400", code)
+	}
+
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	if code := call(t, app, http.MethodDelete, "/cache/keys?prefix=purge:", "", &result); code != http.StatusOK {
+		t.Fatalf("purge status = %d", code)
+	}
+	if result.Deleted != 2 {
+		t.Fatalf("deleted = %d, want 2", result.Deleted)
+	}
+	if _, found, _ := cache.Get("purge:1"); found {
+		t.Fatal("purged key still readable")
+	}
+	if _, found, _ := cache.Get("keep:1"); !found {
+		t.Fatal("key outside the prefix was purged")
+	}
+}
+
+func TestCacheHandlerGC(t *testing.T) {
+	app := newCacheApp()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"default ratio", "", http.StatusOK},
+		{"custom ratio", `{"discard_ratio": 0.7}`, http.StatusOK},
+		{"ratio too large", `{"discard_ratio": 1}`, http.StatusBadRequest},
+		{"negative ratio", `{"discard_ratio": -0.5}`, http.StatusBadRequest},
+		{"invalid body", `{"discard_ratio": "half"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := call(t, app, http.MethodPost, "/cache/gc", tt.body, nil); code != tt.want {
+				t.Fatalf("status = %d, want %d", code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCacheHandlerStats(t *testing.T) {
+	app := newCacheApp()
+	setKeys(t, "stats:1")
+
+	var stats cache.Stats
+	if code := call(t, app, http.MethodGet, "/cache/stats", "", &stats); code != http.StatusOK {
+		t.Fatalf("stats status = %d", code)
+	}
+	if stats.DiskEntries == 0 {
+		t.Fatalf("stats = %+v, want at least one disk key", stats)
+	}
+}
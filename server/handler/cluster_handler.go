@@ -28,7 +28,7 @@ func (c *ClusterHandler) CreateCluster(ctx *fiber.Ctx) error {
 
 	var cluster models.Cluster
 	if err := ctx.BodyParser(&cluster); err != nil {
-		logger.GetLogger("quiver").Error("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
@@ -40,13 +40,13 @@ func (c *ClusterHandler) CreateCluster(ctx *fiber.Ctx) error {
 
 	cluster.AppName = appName
 
-	if err := c.clusterService.CreateCluster(env, &cluster); err != nil {
+	if err := c.clusterService.WithContext(ctx.UserContext()).CreateCluster(env, &cluster); err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found %s", appName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found %s", appName)
 			return utils.NotFound(ctx, err.Error())
 		}
 		if err.Error() == "cluster already exists in this app" {
-			logger.GetLogger("quiver").Errorf("cluster already exists in this app %s", cluster.ClusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster already exists in this app %s", cluster.ClusterName)
 			return utils.BadRequest(ctx, err.Error())
 		}
 		return utils.InternalError(ctx, err.Error())
@@ -82,18 +82,18 @@ func (c *ClusterHandler) ListCluster(ctx *fiber.Ctx) error {
 	}
 
 	if !utils.ValidateAppName(appName) {
-		logger.GetLogger("quiver").Errorf("invalid app_name %s", appName)
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid app_name %s", appName)
 		return utils.BadRequest(ctx, "invalid app_name format")
 	}
 
-	clusters, total, err := c.clusterService.ListCluster(env, appName, page, size)
+	clusters, total, err := c.clusterService.WithContext(ctx.UserContext()).ListCluster(env, appName, page, size)
 	if err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found %s", appName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found %s", appName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
-		logger.GetLogger("quiver").Errorf("app_name %s not found", appName)
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app_name %s not found", appName)
 		return utils.InternalError(ctx, err.Error())
 	}
 
@@ -132,9 +132,9 @@ func (c *ClusterHandler) GetCluster(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	cluster, err := c.clusterService.GetCluster(env, appName, clusterName)
+	cluster, err := c.clusterService.WithContext(ctx.UserContext()).GetCluster(env, appName, clusterName)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 		return utils.NotFound(ctx, "Cluster not found")
 	}
 
@@ -163,12 +163,12 @@ func (c *ClusterHandler) DeleteCluster(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	if err := c.clusterService.DeleteCluster(env, appName, clusterName); err != nil {
+	if err := c.clusterService.WithContext(ctx.UserContext()).DeleteCluster(env, appName, clusterName); err != nil {
 		if err.Error() == "cluster not found" {
-			logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 			return utils.NotFound(ctx, err.Error())
 		}
-		logger.GetLogger("quiver").Errorf("cluster delete failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster delete failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
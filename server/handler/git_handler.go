@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"quiver/gitrepo"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/services"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// GitHandler 命名空间 Git 仓库同步控制器
+type GitHandler struct {
+	gitService *services.GitService
+}
+
+// NewGitHandler 创建 Git 仓库同步控制器实例
+func NewGitHandler() *GitHandler {
+	return &GitHandler{
+		gitService: services.NewGitService(),
+	}
+}
+
+// GitBindRequest 绑定仓库请求体
+type GitBindRequest struct {
+	RepoPath string `json:"repo_path"` // 服务器上的仓库路径，必须位于 git.roots 中
+	Ref      string `json:"ref"`       // 默认拉取的分支、标签或 commit，默认 HEAD
+	Path     string `json:"path"`      // 仓库中的目录或文件，默认仓库根目录
+	Format   string `json:"format"`    // files、properties、yaml 或 json，默认 files
+	Operator string `json:"operator"`
+}
+
+// GitPullRequest 拉取请求体
+type GitPullRequest struct {
+	Ref         string `json:"ref"`          // 默认为绑定的 ref
+	Mode        string `json:"mode"`         // draft 或 publish，默认 draft
+	ReleaseName string `json:"release_name"` // publish 时的发布名称，默认 git-<commit 前 12 位>
+	Force       bool   `json:"force"`        // 覆盖草稿区未发布的改动
+	Operator    string `json:"operator"`
+	Comment     string `json:"comment"`
+}
+
+// Bind 绑定或修改命名空间的仓库
+func (h *GitHandler) Bind(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	var req GitBindRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body: %v", err)
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if len(req.RepoPath) == 0 {
+		return utils.BadRequest(ctx, "repo_path is required")
+	}
+	if len(req.Operator) == 0 {
+		return utils.BadRequest(ctx, "operator is required")
+	}
+
+	binding := models.NamespaceGitBinding{
+		RepoPath: req.RepoPath,
+		Ref:      req.Ref,
+		Path:     req.Path,
+		Format:   req.Format,
+		Operator: req.Operator,
+	}
+	if err := h.gitService.WithContext(ctx.UserContext()).Bind(env, appName, clusterName, namespaceName, &binding); err != nil {
+		return gitError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", binding)
+}
+
+// GetBinding 查看命名空间绑定的仓库和最近一次拉取的 commit
+func (h *GitHandler) GetBinding(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	binding, err := h.gitService.WithContext(ctx.UserContext()).GetBinding(env, appName, clusterName, namespaceName)
+	if err != nil {
+		return gitError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", binding)
+}
+
+// Unbind 解除绑定
+func (h *GitHandler) Unbind(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	if err := h.gitService.WithContext(ctx.UserContext()).Unbind(env, appName, clusterName, namespaceName); err != nil {
+		return gitError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", nil)
+}
+
+// Pull 把仓库中某个 commit 的内容导入草稿区，mode 为 publish 时直接发布
+func (h *GitHandler) Pull(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	var req GitPullRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body: %v", err)
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if len(req.Operator) == 0 {
+		return utils.BadRequest(ctx, "operator is required")
+	}
+
+	result, err := h.gitService.WithContext(ctx.UserContext()).Pull(env, appName, clusterName, namespaceName, services.GitPullOptions{
+		Ref:         req.Ref,
+		Mode:        req.Mode,
+		ReleaseName: req.ReleaseName,
+		Operator:    req.Operator,
+		Comment:     req.Comment,
+		Force:       req.Force,
+	})
+	if errors.Is(err, services.ErrUnpublishedEdits) {
+		return utils.Error(ctx, fiber.StatusConflict, err.Error(), fiber.Map{"unpublished_edits": result.UnpublishedEdits})
+	}
+	if err != nil {
+		return gitError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", result)
+}
+
+// Drift 对比仓库中 ref 指向的 commit 与命名空间的最新版本
+func (h *GitHandler) Drift(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	report, err := h.gitService.WithContext(ctx.UserContext()).Drift(env, appName, clusterName, namespaceName, ctx.Query("ref"))
+	if err != nil {
+		return gitError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", report)
+}
+
+// gitError 没有绑定仓库、仓库中没有 ref 或 path 时返回 404，其他错误返回 400
+func gitError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrGitBindingNotFound) || errors.Is(err, gitrepo.ErrNotFound) {
+		return utils.NotFound(ctx, err.Error())
+	}
+	return utils.BadRequest(ctx, err.Error())
+}
@@ -33,7 +33,7 @@ func (c *ItemHandler) SetItem(ctx *fiber.Ctx) error {
 	}
 
 	if err := ctx.BodyParser(&request); err != nil {
-		logger.GetLogger("quiver").Errorf("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
@@ -44,28 +44,28 @@ func (c *ItemHandler) SetItem(ctx *fiber.Ctx) error {
 	}
 
 	if len(request.Value) == 0 {
-		logger.GetLogger("quiver").Errorf("invalid item value")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid item value")
 		return utils.BadRequest(ctx, "invalid item value")
 	}
 
-	err = c.itemService.SetItem(env, appName, clusterName, namespaceName, request.Key, request.Value)
+	err = c.itemService.WithContext(ctx.UserContext()).SetItem(env, appName, clusterName, namespaceName, request.Key, request.Value)
 	if err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found")
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found")
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "cluster not found" {
-			logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "namespace not found" {
-			logger.GetLogger("quiver").Errorf("namespace not found")
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("namespace not found")
 			return utils.NotFound(ctx, err.Error())
 		}
 
-		logger.GetLogger("quiver").Errorf("set item item failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("set item item failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
@@ -96,20 +96,20 @@ func (c *ItemHandler) GetItem(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	item, err := c.itemService.GetItem(env, appName, clusterName, namespaceName, key)
+	item, err := c.itemService.WithContext(ctx.UserContext()).GetItem(env, appName, clusterName, namespaceName, key)
 	if err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found")
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found")
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "cluster not found" {
-			logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "namespace not found" {
-			logger.GetLogger("quiver").Errorf("namespace not found")
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("namespace not found")
 			return utils.NotFound(ctx, err.Error())
 		}
 
@@ -155,9 +155,9 @@ func (c *ItemHandler) ListItem(ctx *fiber.Ctx) error {
 		size = 100
 	}
 
-	items, total, err := c.itemService.ListItem(env, appName, clusterName, namespaceName, search, page, size)
+	items, total, err := c.itemService.WithContext(ctx.UserContext()).ListItem(env, appName, clusterName, namespaceName, search, page, size)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("items list failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("items list failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
@@ -186,7 +186,7 @@ func (c *ItemHandler) DeleteItem(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	err = c.itemService.DeleteItem(env, appName, clusterName, namespaceName, key)
+	err = c.itemService.WithContext(ctx.UserContext()).DeleteItem(env, appName, clusterName, namespaceName, key)
 	if err != nil {
 		if err.Error() == "app not found" {
 			return utils.NotFound(ctx, err.Error())
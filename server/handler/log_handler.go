@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"quiver/logger"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// LogHandler 日志管理控制器
+type LogHandler struct{}
+
+// NewLogHandler 创建日志管理控制器实例
+func NewLogHandler() *LogHandler {
+	return &LogHandler{}
+}
+
+// ListLoggers 列出所有 logger 及其当前级别
+func (h *LogHandler) ListLoggers(c *fiber.Ctx) error {
+	return utils.Success(c, 0, "success", fiber.Map{
+		"loggers": logger.ListLevels(),
+	})
+}
+
+// SetLogLevel 运行时调整 logger 级别，name 为 * 时调整全部
+func (h *LogHandler) SetLogLevel(c *fiber.Ctx) error {
+	name := c.Params("name")
+
+	var request struct {
+		Level string `json:"level"`
+	}
+	if err := c.BodyParser(&request); err != nil || request.Level == "" {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("invalid request body")
+		return utils.BadRequest(c, "invalid request body")
+	}
+
+	var err error
+	if name == "*" {
+		err = logger.SetDefaultLevel(request.Level)
+	} else {
+		err = logger.SetLevel(name, request.Level)
+	}
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("set logger %s level %s failed: %v", name, request.Level, err)
+		return utils.BadRequest(c, err.Error())
+	}
+
+	logger.GetLogger("quiver").WithContext(c.UserContext()).Infof("logger %s level set to %s", name, request.Level)
+	return utils.Success(c, 0, "success", fiber.Map{
+		"name":  name,
+		"level": request.Level,
+	})
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"quiver/logger"
+	"quiver/services"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// MigrationHandler 数据迁移控制器
+type MigrationHandler struct{}
+
+// NewMigrationHandler 创建数据迁移控制器实例
+func NewMigrationHandler() *MigrationHandler {
+	return &MigrationHandler{}
+}
+
+// MigrateReleases 把旧格式发布迁移为按 item_release.id 引用，并报告哈希碰撞，默认 dry_run
+func (h *MigrationHandler) MigrateReleases(c *fiber.Ctx) error {
+	env := c.Locals("env").(string)
+	dryRun := c.QueryBool("dry_run", true)
+
+	report, err := services.NewMigrationService().WithContext(c.UserContext()).MigrateReleases(env, dryRun)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("migrate releases for env %s failed: %v", env, err)
+		return utils.InternalError(c, err.Error())
+	}
+	return utils.Success(c, 0, "success", report)
+}
@@ -29,7 +29,7 @@ func (c *NamespaceHandler) CreateNamespace(ctx *fiber.Ctx) error {
 
 	var namespace models.Namespace
 	if err := ctx.BodyParser(&namespace); err != nil {
-		logger.GetLogger("quiver").Errorf("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
@@ -42,18 +42,18 @@ func (c *NamespaceHandler) CreateNamespace(ctx *fiber.Ctx) error {
 	namespace.AppName = appName
 	namespace.ClusterName = clusterName
 
-	if err := c.namespaceService.CreateNamespace(env, &namespace); err != nil {
+	if err := c.namespaceService.WithContext(ctx.UserContext()).CreateNamespace(env, &namespace); err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found %s", appName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found %s", appName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "cluster not found" {
-			logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 			return utils.NotFound(ctx, err.Error())
 		}
 		if err.Error() == "namespace already exists" {
-			logger.GetLogger("quiver").Errorf("namespace already exists %s", namespace.NamespaceName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("namespace already exists %s", namespace.NamespaceName)
 			return utils.BadRequest(ctx, err.Error())
 		}
 		return utils.InternalError(ctx, err.Error())
@@ -89,18 +89,18 @@ func (c *NamespaceHandler) ListNamespace(ctx *fiber.Ctx) error {
 		size = 20
 	}
 
-	namespaces, total, err := c.namespaceService.ListNamespace(env, appName, clusterName, page, size)
+	namespaces, total, err := c.namespaceService.WithContext(ctx.UserContext()).ListNamespace(env, appName, clusterName, page, size)
 	if err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found %s", appName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found %s", appName)
 			return utils.NotFound(ctx, err.Error())
 		}
 		if err.Error() == "cluster not found" {
-			logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
-		logger.GetLogger("quiver").Errorf("list namespace failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("list namespace failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
@@ -142,24 +142,24 @@ func (c *NamespaceHandler) GetNamespace(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	namespace, err := c.namespaceService.GetNamespace(env, appName, clusterName, namespaceName)
+	namespace, err := c.namespaceService.WithContext(ctx.UserContext()).GetNamespace(env, appName, clusterName, namespaceName)
 	if err != nil {
 		if err.Error() == "app not found" {
-			logger.GetLogger("quiver").Errorf("app not found %s", appName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("app not found %s", appName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "cluster not found" {
-			logger.GetLogger("quiver").Errorf("cluster not found %s", clusterName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("cluster not found %s", clusterName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
 		if err.Error() == "namespace not found" {
-			logger.GetLogger("quiver").Errorf("namespace not found %s", namespaceName)
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("namespace not found %s", namespaceName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
-		logger.GetLogger("quiver").Errorf("get namespace %s failed %s",
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("get namespace %s failed %s",
 			appName+"/"+clusterName+"/"+namespaceName, err.Error())
 
 		return utils.BadRequest(ctx, err.Error())
@@ -192,14 +192,14 @@ func (c *NamespaceHandler) DeleteNamespace(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	if err := c.namespaceService.DeleteNamespace(env, appName, clusterName, namespaceName); err != nil {
+	if err := c.namespaceService.WithContext(ctx.UserContext()).DeleteNamespace(env, appName, clusterName, namespaceName); err != nil {
 		if err.Error() == "namespace not found" {
-			logger.GetLogger("quiver").Errorf("namespace not found %s",
+			logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("namespace not found %s",
 				appName+"/"+clusterName+"/"+namespaceName)
 			return utils.NotFound(ctx, err.Error())
 		}
 
-		logger.GetLogger("quiver").Errorf("delete namespace %s failed %s",
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("delete namespace %s failed %s",
 			appName+"/"+clusterName+"/"+namespaceName, err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
@@ -224,7 +224,7 @@ func (c *NamespaceHandler) DiscardDraft(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	err = c.namespaceService.DiscardDraft(env, appName, clusterName, namespaceName)
+	err = c.namespaceService.WithContext(ctx.UserContext()).DiscardDraft(env, appName, clusterName, namespaceName)
 	if err != nil {
 		return utils.BadRequest(ctx, err.Error())
 	}
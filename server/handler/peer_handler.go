@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"encoding/json"
+	"quiver/cache"
+	"quiver/logger"
+	"quiver/peer"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PeerHandler struct{}
+
+func NewPeerHandler() *PeerHandler {
+	return &PeerHandler{}
+}
+
+// Notify 接收其他实例的变更广播，校验签名后立即拉取对应环境的变更日志
+func (h *PeerHandler) Notify(ctx *fiber.Ctx) error {
+	body := ctx.Body()
+	if err := peer.Verify(ctx.Get(peer.HeaderTimestamp), ctx.Get(peer.HeaderSignature), body); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Warnf("reject peer notify from %s: %v", ctx.IP(), err)
+		return utils.Unauthorized(ctx, err.Error())
+	}
+
+	var event peer.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if !utils.ValidateEnv(event.Env) {
+		return utils.BadRequest(ctx, "invalid env")
+	}
+
+	logger.GetLogger("quiver").WithContext(ctx.UserContext()).Debugf("peer notify from %s env %s", event.Origin, event.Env)
+	cache.Notify(event.Env)
+	return utils.Success(ctx, 0, "success", nil)
+}
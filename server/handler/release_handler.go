@@ -1,11 +1,18 @@
 package handler
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"quiver/config"
 	"quiver/logger"
+	"quiver/models"
+	"quiver/render"
 	"quiver/services"
 	"quiver/utils"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // ReleaseHandler 命名空间控制器
@@ -20,6 +27,14 @@ func NewReleaseHandler() *ReleaseHandler {
 	}
 }
 
+// PublishRequest 发布请求体，keys 为空时发布草稿区全部配置项，否则只发布这些 key 的改动
+type PublishRequest struct {
+	ReleaseName string   `json:"release_name"`
+	Operator    string   `json:"operator"`
+	Comment     string   `json:"comment"`
+	Keys        []string `json:"keys"`
+}
+
 func (c *ReleaseHandler) PublishRelease(ctx *fiber.Ctx) error {
 	env := ctx.Locals("env").(string) // 类型断言
 	appName := ctx.Params("app_name")
@@ -31,20 +46,25 @@ func (c *ReleaseHandler) PublishRelease(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	var body map[string]string
+	var body PublishRequest
 	if err := ctx.BodyParser(&body); err != nil {
-		logger.GetLogger("quiver").Error("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
-	operator, comment, releaseName := body["operator"], body["comment"], body["release_name"]
+	operator, comment, releaseName := body.Operator, body.Comment, body.ReleaseName
 
 	// 验证输入
 	if len(operator) == 0 || len(releaseName) == 0 {
 		return utils.BadRequest(ctx, "operator  and release_name is required")
 	}
+	for _, key := range body.Keys {
+		if !utils.ValidateItemKey(key) {
+			return utils.BadRequest(ctx, "invalid key "+key)
+		}
+	}
 
-	release, err := c.releaseService.PublishRelease(env, appName, clusterName, namespaceName, releaseName, operator, comment)
+	release, err := c.releaseService.WithContext(ctx.UserContext()).PublishRelease(env, appName, clusterName, namespaceName, releaseName, operator, comment, body.Keys)
 	if err != nil {
 		return utils.BadRequest(ctx, err.Error())
 	}
@@ -58,6 +78,9 @@ func (c *ReleaseHandler) PublishRelease(ctx *fiber.Ctx) error {
 		"release_id":     release.ReleaseID,
 		"release_time":   release.ReleaseTime,
 	}
+	if len(body.Keys) > 0 {
+		response["keys"] = body.Keys
+	}
 
 	return utils.Success(ctx, 0, "success", response)
 }
@@ -84,9 +107,9 @@ func (c *ReleaseHandler) ListReleases(ctx *fiber.Ctx) error {
 		size = 100
 	}
 
-	releases, total, err := c.releaseService.ListRelease(env, appName, clusterName, namespaceName, page, size)
+	releases, total, err := c.releaseService.WithContext(ctx.UserContext()).ListRelease(env, appName, clusterName, namespaceName, page, size)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("releases list failed %s", err.Error())
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("releases list failed %s", err.Error())
 		return utils.InternalError(ctx, err.Error())
 	}
 
@@ -113,22 +136,38 @@ func (c *ReleaseHandler) GetRelease(ctx *fiber.Ctx) error {
 	if !valid {
 		return err
 	}
-	release, err := c.releaseService.GetRelease(env, appName, clusterName, namespaceName, release_id)
+	// 客户端已经是最新版本时直接返回 304，不再构造响应；计算失败时按原流程返回
+	if ifNoneMatch := ctx.Get(fiber.HeaderIfNoneMatch); ifNoneMatch != "" {
+		etag, err := c.releaseService.WithContext(ctx.UserContext()).ReleaseETag(env, appName, clusterName, namespaceName, release_id)
+		if err == nil && etagMatch(ifNoneMatch, etag) {
+			setReleaseCacheHeaders(ctx, etag)
+			return ctx.SendStatus(fiber.StatusNotModified)
+		}
+	}
+
+	// ETag 取自本次返回的版本，期间有新的发布也不会把新内容和旧 ETag 一起返回
+	delta, err := c.releaseService.WithContext(ctx.UserContext()).GetReleaseDelta(env, appName, clusterName, namespaceName, release_id)
 	if err != nil {
 		return utils.BadRequest(ctx, err.Error())
 	}
+	if delta.ETag != "" {
+		setReleaseCacheHeaders(ctx, delta.ETag)
+	}
 
-	response := release
+	response := delta.Response(env, appName, clusterName, namespaceName, release_id)
 
 	return utils.Success(ctx, 0, "success", response)
 }
 
+// RollbackRelease 回滚到指定版本，restore_draft=true 时同时把草稿区恢复为该版本，草稿区有未发布的改动时需要 force=true
 func (c *ReleaseHandler) RollbackRelease(ctx *fiber.Ctx) error {
 	env := ctx.Locals("env").(string) // 类型断言
 	appName := ctx.Params("app_name")
 	clusterName := ctx.Params("cluster_name")
 	namespaceName := ctx.Params("namespace_name")
 	releaseId := ctx.Params("release_id")
+	restoreDraft := ctx.QueryBool("restore_draft", false)
+	force := ctx.QueryBool("force", false)
 
 	// 验证输入
 	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
@@ -138,7 +177,7 @@ func (c *ReleaseHandler) RollbackRelease(ctx *fiber.Ctx) error {
 
 	var body map[string]string
 	if err := ctx.BodyParser(&body); err != nil {
-		logger.GetLogger("quiver").Error("invalid request body")
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("invalid request body")
 		return utils.BadRequest(ctx, "invalid request body")
 	}
 
@@ -149,22 +188,208 @@ func (c *ReleaseHandler) RollbackRelease(ctx *fiber.Ctx) error {
 		return utils.BadRequest(ctx, "operator is required")
 	}
 
-	release, err := c.releaseService.RollbackRelease(env, appName, clusterName, namespaceName, releaseId, operator, comment)
+	result, err := c.releaseService.WithContext(ctx.UserContext()).RollbackRelease(env, appName, clusterName, namespaceName, releaseId, operator, comment, restoreDraft, force)
+	if errors.Is(err, services.ErrUnpublishedEdits) {
+		return utils.Error(ctx, fiber.StatusConflict, err.Error(), fiber.Map{"unpublished_edits": result.UnpublishedEdits})
+	}
 	if err != nil {
 		return utils.BadRequest(ctx, err.Error())
 	}
 
+	release := result.Release
 	response := fiber.Map{
-		"env":            env,
-		"app_name":       appName,
-		"cluster_name":   clusterName,
-		"namespace_name": namespaceName,
-		"release_name":   release.ReleaseName,
-		"release_id":     release.ReleaseID,
-		"release_time":   release.ReleaseTime,
-		"operator":       operator,
-		"comment":        comment,
+		"env":               env,
+		"app_name":          appName,
+		"cluster_name":      clusterName,
+		"namespace_name":    namespaceName,
+		"release_name":      release.ReleaseName,
+		"release_id":        release.ReleaseID,
+		"release_time":      release.ReleaseTime,
+		"operator":          operator,
+		"comment":           comment,
+		"draft_restored":    result.DraftRestored,
+		"unpublished_edits": result.UnpublishedEdits,
+		"discarded_edits":   result.DiscardedEdits,
+		"pending_changes":   result.PendingChanges,
+	}
+
+	return utils.Success(ctx, 0, "success", response)
+}
+
+// PromoteRequest 提升版本请求体
+type PromoteRequest struct {
+	Targets     []services.PromoteTarget `json:"targets"`
+	Mode        string                   `json:"mode"`         // draft 或 publish，默认 draft
+	ReleaseName string                   `json:"release_name"` // publish 时的发布名称，默认 promote-<源版本名称>
+	Force       bool                     `json:"force"`        // 覆盖目标草稿区未发布的改动
+	Operator    string                   `json:"operator"`
+	Comment     string                   `json:"comment"`
+}
+
+// PromoteRelease 把版本的配置提升到同一应用的其他集群，返回每个目标的结果
+func (c *ReleaseHandler) PromoteRelease(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string) // 类型断言
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+	releaseId := ctx.Params("release_id")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	var body PromoteRequest
+	if err := ctx.BodyParser(&body); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Error("invalid request body")
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if len(body.Operator) == 0 {
+		return utils.BadRequest(ctx, "operator is required")
+	}
+	if body.Mode == "" {
+		body.Mode = models.PromoteModeDraft
+	}
+
+	results, err := services.NewPromotionService().WithContext(ctx.UserContext()).Promote(env, appName, clusterName, namespaceName, releaseId,
+		body.Targets, body.Mode, body.ReleaseName, body.Operator, body.Comment, body.Force)
+	if err != nil {
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	response := fiber.Map{
+		"env":               env,
+		"app_name":          appName,
+		"source_release_id": releaseId,
+		"mode":              body.Mode,
+		"results":           results,
 	}
 
 	return utils.Success(ctx, 0, "success", response)
 }
+
+// RenderRelease 把最新版本或 release_id 指定的版本渲染为配置文件下载，section 只用于 ini，默认为命名空间名称
+func (c *ReleaseHandler) RenderRelease(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+	format, ok := render.Lookup(ctx.Params("format"))
+	if !ok {
+		return utils.BadRequest(ctx, "unsupported format, use properties, env, yaml, json, toml or ini")
+	}
+	section := ctx.Query("section", namespaceName)
+	if strings.ContainsAny(section, "[]\r\n") {
+		return utils.BadRequest(ctx, "invalid section")
+	}
+
+	file, err := c.releaseService.WithContext(ctx.UserContext()).RenderRelease(env, appName, clusterName, namespaceName, ctx.Query("release_id"), format, section)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return utils.NotFound(ctx, err.Error())
+		}
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	etag := `"` + file.Checksum + `"`
+	setReleaseCacheHeaders(ctx, etag)
+	ctx.Set(HeaderChecksum, file.Checksum)
+	ctx.Set(HeaderReleaseID, file.Release.ReleaseID)
+	if etagMatch(ctx.Get(fiber.HeaderIfNoneMatch), etag) {
+		return ctx.SendStatus(fiber.StatusNotModified)
+	}
+
+	ctx.Set(fiber.HeaderContentType, format.ContentType())
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s.%s"`, namespaceName, format))
+	return ctx.Send(file.Content)
+}
+
+// 渲染文件的响应头
+const (
+	HeaderChecksum  = "X-Quiver-Checksum-Sha256"
+	HeaderReleaseID = "X-Quiver-Release-Id"
+)
+
+// BatchReleaseRequest 批量拉取配置请求体
+type BatchReleaseRequest struct {
+	Namespaces []services.ReleaseQuery `json:"namespaces"`
+}
+
+// BatchGetRelease 一次拉取同一应用下多个命名空间的最新配置或增量，单个命名空间的错误在结果中返回
+func (c *ReleaseHandler) BatchGetRelease(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+
+	var req BatchReleaseRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body: %v", err)
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+
+	results, err := c.releaseService.WithContext(ctx.UserContext()).BatchGetRelease(env, appName, req.Namespaces)
+	if err != nil {
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	response := fiber.Map{
+		"env":        env,
+		"app_name":   appName,
+		"namespaces": results,
+	}
+
+	return utils.Success(ctx, 0, "success", response)
+}
+
+// ListPromotions 查询版本被提升到了哪些集群
+func (c *ReleaseHandler) ListPromotions(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string) // 类型断言
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+	releaseId := ctx.Params("release_id")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	promotions, err := services.NewPromotionService().WithContext(ctx.UserContext()).ListPromotions(env, appName, clusterName, namespaceName, releaseId)
+	if err != nil {
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", promotions)
+}
+
+// setReleaseCacheHeaders 设置客户端拉取配置的 ETag 和 Cache-Control，中间缓存和 CDN 可以缓存响应并用 If-None-Match 回源校验；
+// 开启登录校验时只允许客户端自己缓存
+func setReleaseCacheHeaders(ctx *fiber.Ctx, etag string) {
+	scope := "public"
+	if config.GetAuthConfig().Required {
+		scope = "private"
+	}
+	maxAge := int(config.GetCacheConfig().ClientMaxAge / time.Second)
+	ctx.Set(fiber.HeaderETag, etag)
+	ctx.Set(fiber.HeaderCacheControl, fmt.Sprintf("%s, max-age=%d, must-revalidate", scope, maxAge))
+}
+
+// etagMatch If-None-Match 中是否有与 etag 相同的值，按弱比较忽略 W/ 前缀
+func etagMatch(ifNoneMatch, etag string) bool {
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package handler
+
+import "testing"
+
+func TestETagMatch(t *testing.T) {
+	const etag = `"0123456789abcdef0123456789abcdef"`
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"exact", etag, true},
+		{"weak", "W/" + etag, true},
+		{"list", `"other", ` + etag, true},
+		{"weak in list", `"other",W/` + etag + ` , "more"`, true},
+		{"wildcard", "*", true},
+		{"wildcard in list", `"other", *`, true},
+		{"no match", `"other"`, false},
+		{"unquoted", "0123456789abcdef0123456789abcdef", false},
+		{"prefix only", `"0123456789abcdef"`, false},
+		{"empty", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatch(tt.ifNoneMatch, etag); got != tt.want {
+				t.Fatalf("etagMatch(%q) = %v, want %v", tt.ifNoneMatch, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package handler
+
+import (
+	"quiver/logger"
+	"quiver/services"
+	"quiver/utils"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RetentionHandler 发布记录保留策略控制器
+type RetentionHandler struct{}
+
+// NewRetentionHandler 创建保留策略控制器实例
+func NewRetentionHandler() *RetentionHandler {
+	return &RetentionHandler{}
+}
+
+// RunGC 按当前环境的保留策略清理历史发布，默认 dry_run，只有 dry_run=false 时才真正删除
+func (h *RetentionHandler) RunGC(c *fiber.Ctx) error {
+	env := c.Locals("env").(string)
+	dryRun := c.QueryBool("dry_run", true)
+
+	report, err := services.NewRetentionService().WithContext(c.UserContext()).Run(env, dryRun)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(c.UserContext()).Errorf("run retention gc for env %s failed: %v", env, err)
+		return utils.InternalError(c, err.Error())
+	}
+	return utils.Success(c, 0, "success", report)
+}
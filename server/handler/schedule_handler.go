@@ -0,0 +1,187 @@
+package handler
+
+import (
+	"errors"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/services"
+	"quiver/utils"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// ScheduleHandler 定时发布控制器
+type ScheduleHandler struct {
+	scheduleService *services.ScheduleService
+}
+
+// NewScheduleHandler 创建定时发布控制器实例
+func NewScheduleHandler() *ScheduleHandler {
+	return &ScheduleHandler{
+		scheduleService: services.NewScheduleService(),
+	}
+}
+
+// ScheduleRequest 创建定时任务请求体
+type ScheduleRequest struct {
+	Action       string     `json:"action"`        // publish 或 rollback
+	ExecuteAt    time.Time  `json:"execute_at"`    // RFC3339
+	RevertAt     *time.Time `json:"revert_at"`     // 可选，执行成功后在该时间回滚到执行前的版本
+	ReleaseName  string     `json:"release_name"`  // publish 必填
+	Keys         []string   `json:"keys"`          // publish 可选，只发布这些 key
+	ReleaseID    string     `json:"release_id"`    // rollback 必填
+	RestoreDraft bool       `json:"restore_draft"` // rollback 时是否恢复草稿区
+	Force        bool       `json:"force"`         // 恢复草稿时覆盖未发布的改动
+	Operator     string     `json:"operator"`
+	Comment      string     `json:"comment"`
+}
+
+// CreateSchedule 创建定时发布或回滚
+func (h *ScheduleHandler) CreateSchedule(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	var req ScheduleRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body: %v", err)
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if len(req.Operator) == 0 {
+		return utils.BadRequest(ctx, "operator is required")
+	}
+	for _, key := range req.Keys {
+		if !utils.ValidateItemKey(key) {
+			return utils.BadRequest(ctx, "invalid key "+key)
+		}
+	}
+
+	schedule := models.ScheduledRelease{
+		Action:       req.Action,
+		ExecuteAt:    req.ExecuteAt,
+		RevertAt:     req.RevertAt,
+		ReleaseName:  req.ReleaseName,
+		Keys:         req.Keys,
+		ReleaseID:    req.ReleaseID,
+		RestoreDraft: req.RestoreDraft,
+		Force:        req.Force,
+		Operator:     req.Operator,
+		Comment:      req.Comment,
+	}
+	if err := h.scheduleService.WithContext(ctx.UserContext()).CreateSchedule(env, appName, clusterName, namespaceName, &schedule); err != nil {
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", schedule)
+}
+
+// ListSchedules 获取命名空间的定时任务，可按 status 过滤
+func (h *ScheduleHandler) ListSchedules(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	size, _ := strconv.Atoi(ctx.Query("size", "100"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 100
+	}
+
+	schedules, total, err := h.scheduleService.WithContext(ctx.UserContext()).ListSchedules(env, appName, clusterName, namespaceName, ctx.Query("status"), page, size)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("schedules list failed %s", err.Error())
+		return utils.InternalError(ctx, err.Error())
+	}
+
+	response := fiber.Map{
+		"env":       env,
+		"total":     total,
+		"page":      page,
+		"size":      size,
+		"schedules": schedules,
+	}
+
+	return utils.Success(ctx, 0, "success", response)
+}
+
+// GetSchedule 获取单个定时任务，包括执行结果或失败原因
+func (h *ScheduleHandler) GetSchedule(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+	scheduleID, err := strconv.ParseUint(ctx.Params("schedule_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid schedule_id")
+	}
+
+	schedule, err := h.scheduleService.WithContext(ctx.UserContext()).GetSchedule(env, appName, clusterName, namespaceName, scheduleID)
+	if err != nil {
+		if err.Error() == "schedule not found" {
+			return utils.NotFound(ctx, err.Error())
+		}
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", schedule)
+}
+
+// CancelSchedule 取消尚未执行的定时任务，operator 取 query 参数，未传时使用登录用户名
+func (h *ScheduleHandler) CancelSchedule(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+	clusterName := ctx.Params("cluster_name")
+	namespaceName := ctx.Params("namespace_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if !valid {
+		return err
+	}
+	scheduleID, err := strconv.ParseUint(ctx.Params("schedule_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid schedule_id")
+	}
+
+	operator := ctx.Query("operator")
+	if operator == "" {
+		operator, _ = ctx.Locals("user_name").(string)
+	}
+	if operator == "" {
+		return utils.BadRequest(ctx, "operator is required")
+	}
+
+	schedule, err := h.scheduleService.WithContext(ctx.UserContext()).CancelSchedule(env, appName, clusterName, namespaceName, scheduleID, operator)
+	if err != nil {
+		if err.Error() == "schedule not found" {
+			return utils.NotFound(ctx, err.Error())
+		}
+		if errors.Is(err, services.ErrScheduleNotPending) {
+			return utils.Conflict(ctx, err.Error())
+		}
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", schedule)
+}
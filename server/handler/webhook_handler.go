@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"errors"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/services"
+	"quiver/utils"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// WebhookHandler 配置变更 webhook 控制器
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+}
+
+// NewWebhookHandler 创建 webhook 控制器实例
+func NewWebhookHandler() *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: services.NewWebhookService(),
+	}
+}
+
+// WebhookRequest 创建、修改 webhook 请求体，修改时未传的字段保持不变
+type WebhookRequest struct {
+	ClusterName   string    `json:"cluster_name"`   // 与 namespace_name 同时传入时只订阅该命名空间，创建后不能修改
+	NamespaceName string    `json:"namespace_name"` // 创建后不能修改
+	URL           *string   `json:"url"`
+	Secret        *string   `json:"secret"`  // 创建时为空则自动生成；修改时传空字符串重新生成
+	Events        *[]string `json:"events"`  // 为空时订阅全部事件
+	Enabled       *bool     `json:"enabled"` // 创建时默认 true
+	Description   *string   `json:"description"`
+	Operator      string    `json:"operator"` // 未传时使用登录用户名
+}
+
+// CreateWebhook 为应用或命名空间创建 webhook，只有本次响应包含 secret
+func (h *WebhookHandler) CreateWebhook(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+
+	var req WebhookRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body: %v", err)
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if req.URL == nil || *req.URL == "" {
+		return utils.BadRequest(ctx, "url is required")
+	}
+	if req.ClusterName != "" && !utils.ValidateClusterName(req.ClusterName) {
+		return utils.BadRequest(ctx, "invalid cluster_name")
+	}
+	if req.NamespaceName != "" && !utils.ValidateNamespaceName(req.NamespaceName) {
+		return utils.BadRequest(ctx, "invalid namespace_name")
+	}
+	if req.Operator == "" {
+		req.Operator, _ = ctx.Locals("user_name").(string)
+	}
+	if req.Operator == "" {
+		return utils.BadRequest(ctx, "operator is required")
+	}
+
+	hook := models.Webhook{
+		ClusterName:   req.ClusterName,
+		NamespaceName: req.NamespaceName,
+		URL:           *req.URL,
+		Enabled:       true,
+		Operator:      req.Operator,
+	}
+	if req.Secret != nil {
+		hook.Secret = *req.Secret
+	}
+	if req.Events != nil {
+		hook.Events = *req.Events
+	}
+	if req.Enabled != nil {
+		hook.Enabled = *req.Enabled
+	}
+	if req.Description != nil {
+		hook.Description = *req.Description
+	}
+	if err := h.webhookService.WithContext(ctx.UserContext()).CreateWebhook(env, appName, &hook); err != nil {
+		return utils.BadRequest(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", hook)
+}
+
+// ListWebhooks 获取应用的全部 webhook，包括命名空间级别的
+func (h *WebhookHandler) ListWebhooks(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+
+	hooks, err := h.webhookService.WithContext(ctx.UserContext()).ListWebhooks(env, appName)
+	if err != nil {
+		return utils.InternalError(ctx, err.Error())
+	}
+
+	return utils.Success(ctx, 0, "success", hooks)
+}
+
+// GetWebhook 获取单个 webhook
+func (h *WebhookHandler) GetWebhook(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+	webhookID, err := strconv.ParseUint(ctx.Params("webhook_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid webhook_id")
+	}
+
+	hook, err := h.webhookService.WithContext(ctx.UserContext()).GetWebhook(env, appName, webhookID)
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", hook)
+}
+
+// UpdateWebhook 修改 webhook 的地址、事件、启用状态、备注或 secret
+func (h *WebhookHandler) UpdateWebhook(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+	webhookID, err := strconv.ParseUint(ctx.Params("webhook_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid webhook_id")
+	}
+
+	var req WebhookRequest
+	if err := ctx.BodyParser(&req); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx.UserContext()).Errorf("invalid request body: %v", err)
+		return utils.BadRequest(ctx, "invalid request body")
+	}
+	if req.ClusterName != "" || req.NamespaceName != "" {
+		return utils.BadRequest(ctx, "cluster_name and namespace_name cannot be changed")
+	}
+
+	hook, err := h.webhookService.WithContext(ctx.UserContext()).UpdateWebhook(env, appName, webhookID, services.WebhookUpdate{
+		URL:         req.URL,
+		Events:      req.Events,
+		Enabled:     req.Enabled,
+		Description: req.Description,
+		Secret:      req.Secret,
+	})
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", hook)
+}
+
+// DeleteWebhook 删除 webhook 及其投递记录
+func (h *WebhookHandler) DeleteWebhook(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+	webhookID, err := strconv.ParseUint(ctx.Params("webhook_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid webhook_id")
+	}
+
+	if err := h.webhookService.WithContext(ctx.UserContext()).DeleteWebhook(env, appName, webhookID); err != nil {
+		return webhookError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", nil)
+}
+
+// ListDeliveries 获取 webhook 的投递记录，可按 status 过滤
+func (h *WebhookHandler) ListDeliveries(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+	webhookID, err := strconv.ParseUint(ctx.Params("webhook_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid webhook_id")
+	}
+
+	page, _ := strconv.Atoi(ctx.Query("page", "1"))
+	size, _ := strconv.Atoi(ctx.Query("size", "100"))
+	if page < 1 {
+		page = 1
+	}
+	if size < 1 {
+		size = 100
+	}
+
+	deliveries, total, err := h.webhookService.WithContext(ctx.UserContext()).ListDeliveries(env, appName, webhookID, ctx.Query("status"), page, size)
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	response := fiber.Map{
+		"env":        env,
+		"total":      total,
+		"page":       page,
+		"size":       size,
+		"deliveries": deliveries,
+	}
+
+	return utils.Success(ctx, 0, "success", response)
+}
+
+// GetDelivery 获取单条投递记录
+func (h *WebhookHandler) GetDelivery(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+	webhookID, err := strconv.ParseUint(ctx.Params("webhook_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid webhook_id")
+	}
+	deliveryID, err := strconv.ParseUint(ctx.Params("delivery_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid delivery_id")
+	}
+
+	delivery, err := h.webhookService.WithContext(ctx.UserContext()).GetDelivery(env, appName, webhookID, deliveryID)
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", delivery)
+}
+
+// ReplayDelivery 重新投递，生成一条新的投递记录
+func (h *WebhookHandler) ReplayDelivery(ctx *fiber.Ctx) error {
+	env := ctx.Locals("env").(string)
+	appName := ctx.Params("app_name")
+
+	valid, err := services.CheckACNKFormat(ctx, &env, &appName, nil, nil, nil)
+	if !valid {
+		return err
+	}
+	webhookID, err := strconv.ParseUint(ctx.Params("webhook_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid webhook_id")
+	}
+	deliveryID, err := strconv.ParseUint(ctx.Params("delivery_id"), 10, 64)
+	if err != nil {
+		return utils.BadRequest(ctx, "invalid delivery_id")
+	}
+
+	delivery, err := h.webhookService.WithContext(ctx.UserContext()).ReplayDelivery(env, appName, webhookID, deliveryID)
+	if err != nil {
+		return webhookError(ctx, err)
+	}
+
+	return utils.Success(ctx, 0, "success", delivery)
+}
+
+// webhookError 订阅或投递记录不存在时返回 404，其他错误返回 400
+func webhookError(ctx *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrWebhookNotFound) || errors.Is(err, services.ErrWebhookDeliveryNotFound) {
+		return utils.NotFound(ctx, err.Error())
+	}
+	return utils.BadRequest(ctx, err.Error())
+}
@@ -0,0 +1,210 @@
+package interp
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// 引用中的命名空间和 key 只允许与命名空间名称、配置项 key 相同的字符
+var namePattern = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+
+// Ref 值中的一个引用，${key} 的 Namespace 为空，表示当前命名空间
+type Ref struct {
+	Namespace string
+	Key       string
+}
+
+func (r Ref) String() string {
+	if r.Namespace == "" {
+		return r.Key
+	}
+	return r.Namespace + ":" + r.Key
+}
+
+// Value 引用目标的值，Resolved 为 true 时已经是解析后的值，不再展开其中的引用
+type Value struct {
+	Text     string
+	Resolved bool
+}
+
+// Source 按命名空间和 key 查找引用目标，不存在时返回 false
+type Source func(namespace, key string) (Value, bool, error)
+
+// segment 解析后的值片段，ref 为空时是普通文本
+type segment struct {
+	text string
+	ref  *Ref
+}
+
+// HasRefs 值中是否可能包含引用或转义，不包含时不需要解析
+func HasRefs(text string) bool {
+	return strings.Contains(text, "${")
+}
+
+// Refs 返回值中直接引用的目标，按出现顺序，不去重
+func Refs(text string) ([]Ref, error) {
+	segments, err := parse(text)
+	if err != nil {
+		return nil, err
+	}
+	var refs []Ref
+	for _, seg := range segments {
+		if seg.ref != nil {
+			refs = append(refs, *seg.ref)
+		}
+	}
+	return refs, nil
+}
+
+// parse 把值拆成文本和引用，$${ 转义为字面量 ${
+func parse(text string) ([]segment, error) {
+	var segments []segment
+	var buf strings.Builder
+	for i := 0; i < len(text); {
+		switch {
+		case strings.HasPrefix(text[i:], "$${"):
+			buf.WriteString("${")
+			i += 3
+		case strings.HasPrefix(text[i:], "${"):
+			end := strings.IndexByte(text[i+2:], '}')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated reference at offset %d", i)
+			}
+			ref, err := parseRef(text[i+2 : i+2+end])
+			if err != nil {
+				return nil, err
+			}
+			if buf.Len() > 0 {
+				segments = append(segments, segment{text: buf.String()})
+				buf.Reset()
+			}
+			segments = append(segments, segment{ref: ref})
+			i += end + 3
+		default:
+			buf.WriteByte(text[i])
+			i++
+		}
+	}
+	if buf.Len() > 0 {
+		segments = append(segments, segment{text: buf.String()})
+	}
+	return segments, nil
+}
+
+func parseRef(body string) (*Ref, error) {
+	ref := &Ref{Key: body}
+	if i := strings.IndexByte(body, ':'); i >= 0 {
+		ref.Namespace, ref.Key = body[:i], body[i+1:]
+		if !namePattern.MatchString(ref.Namespace) {
+			return nil, fmt.Errorf("invalid reference ${%s}", body)
+		}
+	}
+	if !namePattern.MatchString(ref.Key) {
+		return nil, fmt.Errorf("invalid reference ${%s}", body)
+	}
+	return ref, nil
+}
+
+// Resolver 递归解析引用，结果按 命名空间:key 缓存，同一个 Resolver 中每个 key 只解析一次
+type Resolver struct {
+	source   Source
+	resolved map[Ref]string
+	visiting map[Ref]bool
+	stack    []Ref
+}
+
+// NewResolver 创建解析器，引用目标的值从 source 获取
+func NewResolver(source Source) *Resolver {
+	return &Resolver{
+		source:   source,
+		resolved: make(map[Ref]string),
+		visiting: make(map[Ref]bool),
+	}
+}
+
+// Pin 指定某个引用目标解析后的值，之后不再通过 source 查找
+func (r *Resolver) Pin(namespace, key, value string) {
+	r.resolved[Ref{Namespace: namespace, Key: key}] = value
+}
+
+// Expand 在 namespace 中展开值里的引用，引用不存在或有循环时返回错误
+func (r *Resolver) Expand(namespace, text string) (string, error) {
+	if !HasRefs(text) {
+		return text, nil
+	}
+	segments, err := parse(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	for _, seg := range segments {
+		if seg.ref == nil {
+			out.WriteString(seg.text)
+			continue
+		}
+		target := *seg.ref
+		if target.Namespace == "" {
+			target.Namespace = namespace
+		}
+		value, err := r.Resolve(target.Namespace, target.Key)
+		if err != nil {
+			return "", err
+		}
+		out.WriteString(value)
+	}
+	return out.String(), nil
+}
+
+// Resolve 返回 namespace:key 解析后的值
+func (r *Resolver) Resolve(namespace, key string) (string, error) {
+	node := Ref{Namespace: namespace, Key: key}
+	if value, ok := r.resolved[node]; ok {
+		return value, nil
+	}
+	if r.visiting[node] {
+		return "", fmt.Errorf("reference cycle: %s", r.cycle(node))
+	}
+
+	value, ok, err := r.source(namespace, key)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		if len(r.stack) > 0 {
+			return "", fmt.Errorf("%s references %s which does not exist", r.stack[len(r.stack)-1], node)
+		}
+		return "", fmt.Errorf("%s does not exist", node)
+	}
+	if value.Resolved {
+		r.resolved[node] = value.Text
+		return value.Text, nil
+	}
+
+	r.visiting[node] = true
+	r.stack = append(r.stack, node)
+	text, err := r.Expand(namespace, value.Text)
+	r.stack = r.stack[:len(r.stack)-1]
+	delete(r.visiting, node)
+	if err != nil {
+		return "", err
+	}
+
+	r.resolved[node] = text
+	return text, nil
+}
+
+// cycle 从栈中第一次出现 node 的位置开始，拼出循环路径
+func (r *Resolver) cycle(node Ref) string {
+	var path []string
+	for i, n := range r.stack {
+		if n == node {
+			for _, m := range r.stack[i:] {
+				path = append(path, m.String())
+			}
+			break
+		}
+	}
+	return strings.Join(append(path, node.String()), " -> ")
+}
@@ -0,0 +1,236 @@
+package interp
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mapSource 以 命名空间 -> key -> 值 提供引用目标，记录查找次数
+type mapSource struct {
+	values  map[string]map[string]string
+	lookups map[Ref]int
+}
+
+func newMapSource(values map[string]map[string]string) *mapSource {
+	return &mapSource{values: values, lookups: make(map[Ref]int)}
+}
+
+func (m *mapSource) source(namespace, key string) (Value, bool, error) {
+	m.lookups[Ref{Namespace: namespace, Key: key}]++
+	v, ok := m.values[namespace][key]
+	return Value{Text: v}, ok, nil
+}
+
+func TestRefs(t *testing.T) {
+	tests := []struct {
+		text    string
+		want    []Ref
+		wantErr bool
+	}{
+		{"plain text", nil, false},
+		{"${a}", []Ref{{Key: "a"}}, false},
+		{"${db:url}?x=${timeout}&${db:url}", []Ref{{Namespace: "db", Key: "url"}, {Key: "timeout"}, {Namespace: "db", Key: "url"}}, false},
+		{"$${escaped} and ${real}", []Ref{{Key: "real"}}, false},
+		{"$ {a} $a {b}", nil, false},
+		{"${a.b-c_d}", []Ref{{Key: "a.b-c_d"}}, false},
+		{"${unterminated", nil, true},
+		{"${}", nil, true},
+		{"${a b}", nil, true},
+		{"${:key}", nil, true},
+		{"${ns:}", nil, true},
+		{"${a:b:c}", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Refs(tt.text)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Refs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Refs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpand(t *testing.T) {
+	values := map[string]map[string]string{
+		"app": {
+			"host":    "db.local",
+			"port":    "3306",
+			"addr":    "${host}:${port}",
+			"url":     "mysql://${addr}/${common:dbname}",
+			"literal": "cost $${price}",
+			"empty":   "",
+		},
+		"common": {
+			"dbname": "quiver",
+			"host":   "common.local",
+			"self":   "${host}", // 无命名空间的引用指向被引用值所在的命名空间
+		},
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		text      string
+		want      string
+		wantErr   string
+	}{
+		{"no references", "app", "plain", "plain", ""},
+		{"local reference", "app", "${host}", "db.local", ""},
+		{"nested references", "app", "${url}", "mysql://db.local:3306/quiver", ""},
+		{"other namespace", "app", "${common:host}", "common.local", ""},
+		{"relative to referenced namespace", "app", "${common:self}", "common.local", ""},
+		{"escape is literal", "app", "$${host}", "${host}", ""},
+		{"escape in referenced value is kept", "app", "${literal}", "cost ${price}", ""},
+		{"escape next to reference", "app", "$${host}${port}", "${host}3306", ""},
+		{"empty value", "app", "[${empty}]", "[]", ""},
+		{"missing key", "app", "${nope}", "", "app:nope does not exist"},
+		{"missing namespace", "app", "${other:host}", "", "other:host does not exist"},
+		{"unterminated", "app", "${host", "", "unterminated reference"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewResolver(newMapSource(values).source).Expand(tt.namespace, tt.text)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expand() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expand() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("Expand() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandMissingNestedRef(t *testing.T) {
+	src := newMapSource(map[string]map[string]string{
+		"app": {"a": "${b}"},
+	})
+	_, err := NewResolver(src.source).Expand("app", "${a}")
+	if err == nil || err.Error() != "app:a references app:b which does not exist" {
+		t.Fatalf("Expand() error = %v", err)
+	}
+}
+
+func TestExpandCycle(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]map[string]string
+		text   string
+		want   string
+	}{
+		{
+			name:   "self reference",
+			values: map[string]map[string]string{"app": {"a": "${a}"}},
+			text:   "${a}",
+			want:   "reference cycle: app:a -> app:a",
+		},
+		{
+			name:   "two keys",
+			values: map[string]map[string]string{"app": {"a": "x${b}", "b": "y${a}"}},
+			text:   "${a}",
+			want:   "reference cycle: app:a -> app:b -> app:a",
+		},
+		{
+			name: "across namespaces",
+			values: map[string]map[string]string{
+				"app":    {"a": "${common:b}"},
+				"common": {"b": "${c}", "c": "${app:a}"},
+			},
+			text: "${a}",
+			want: "reference cycle: app:a -> common:b -> common:c -> app:a",
+		},
+		{
+			name:   "cycle below an acyclic prefix",
+			values: map[string]map[string]string{"app": {"top": "${a}", "a": "${b}", "b": "${a}"}},
+			text:   "${top}",
+			want:   "reference cycle: app:a -> app:b -> app:a",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewResolver(newMapSource(tt.values).source).Expand("app", tt.text)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("Expand() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolverCachesAndPins(t *testing.T) {
+	src := newMapSource(map[string]map[string]string{
+		"app": {"a": "${b}-${b}", "b": "value", "c": "${b}"},
+	})
+	r := NewResolver(src.source)
+
+	// Pin 的值优先于 source，且不再展开其中的引用
+	r.Pin("app", "b", "pinned ${x}")
+	got, err := r.Expand("app", "${a}|${c}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "pinned ${x}-pinned ${x}|pinned ${x}"; got != want {
+		t.Fatalf("Expand() = %q, want %q", got, want)
+	}
+	if n := src.lookups[Ref{Namespace: "app", Key: "b"}]; n != 0 {
+		t.Fatalf("pinned key looked up %d times", n)
+	}
+
+	// 同一个 Resolver 中每个 key 只查找一次
+	if _, err := r.Expand("app", "${a}${a}${c}"); err != nil {
+		t.Fatal(err)
+	}
+	for ref, n := range src.lookups {
+		if n != 1 {
+			t.Errorf("%s looked up %d times, want 1", ref, n)
+		}
+	}
+}
+
+func TestResolvedValueNotExpanded(t *testing.T) {
+	source := func(namespace, key string) (Value, bool, error) {
+		return Value{Text: "${already}", Resolved: true}, true, nil
+	}
+	got, err := NewResolver(source).Expand("app", "${a}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "${already}" {
+		t.Fatalf("Expand() = %q, want the resolved value as is", got)
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	boom := errors.New("db down")
+	source := func(namespace, key string) (Value, bool, error) {
+		return Value{}, false, boom
+	}
+	if _, err := NewResolver(source).Expand("app", "${a}"); !errors.Is(err, boom) {
+		t.Fatalf("Expand() error = %v, want %v", err, boom)
+	}
+}
+
+func TestHasRefs(t *testing.T) {
+	for text, want := range map[string]bool{
+		"":        false,
+		"plain":   false,
+		"$ {a}":   false,
+		"${a}":    true,
+		"$${a}":   true, // 转义同样需要解析
+		"x${":     true,
+		"price $": false,
+	} {
+		if got := HasRefs(text); got != want {
+			t.Errorf("HasRefs(%q) = %v, want %v", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Fields 日志结构化字段
+type Fields map[string]any
+
+type fieldsKey struct{}
+
+// ContextWithFields 把日志字段合并进 ctx，之后通过 WithContext(ctx) 输出的日志都会带上这些字段
+func ContextWithFields(ctx context.Context, fields Fields) context.Context {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	merged := make(Fields, len(fields))
+	for k, v := range FieldsFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// FieldsFromContext 取出 ctx 中的日志字段
+func FieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+	fields, _ := ctx.Value(fieldsKey{}).(Fields)
+	return fields
+}
+
+func withContext(entry *logrus.Entry, ctx context.Context) *logrus.Entry {
+	if ctx == nil {
+		return entry
+	}
+	entry = entry.WithContext(ctx).WithFields(logrus.Fields(FieldsFromContext(ctx)))
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		entry = entry.WithField("trace_id", sc.TraceID().String())
+	}
+	return entry
+}
+
+// Entry 带上下文字段的日志条目，用法与 Logger 一致
+type Entry struct {
+	*logrus.Entry
+}
+
+func (e *Entry) entry() *logrus.Entry {
+	return e.Entry.WithField("caller", getCaller())
+}
+
+// WithFields 返回追加了字段的日志条目
+func (e *Entry) WithFields(fields Fields) *Entry {
+	return &Entry{Entry: e.Entry.WithFields(logrus.Fields(fields))}
+}
+
+func (e *Entry) Debug(args ...any) {
+	e.entry().Debug(args...)
+}
+
+func (e *Entry) Debugf(format string, args ...any) {
+	e.entry().Debugf(format, args...)
+}
+
+func (e *Entry) Info(args ...any) {
+	e.entry().Info(args...)
+}
+
+func (e *Entry) Infof(format string, args ...any) {
+	e.entry().Infof(format, args...)
+}
+
+func (e *Entry) Warn(args ...any) {
+	e.entry().Warn(args...)
+}
+
+func (e *Entry) Warnf(format string, args ...any) {
+	e.entry().Warnf(format, args...)
+}
+
+func (e *Entry) Error(args ...any) {
+	e.entry().Error(args...)
+}
+
+func (e *Entry) Errorf(format string, args ...any) {
+	e.entry().Errorf(format, args...)
+}
+
+func (e *Entry) Fatal(args ...any) {
+	e.entry().Fatal(args...)
+}
+
+func (e *Entry) Fatalf(format string, args ...any) {
+	e.entry().Fatalf(format, args...)
+}
+
+func (e *Entry) Panic(args ...any) {
+	e.entry().Panic(args...)
+}
+
+func (e *Entry) Panicf(format string, args ...any) {
+	e.entry().Panicf(format, args...)
+}
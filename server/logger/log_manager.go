@@ -2,6 +2,7 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -23,8 +24,9 @@ const (
 )
 
 var (
-	loggers = make(map[string]*logrus.Logger)
-	mu      sync.Mutex
+	loggers      = make(map[string]*logrus.Logger)
+	mu           sync.Mutex
+	defaultLevel = logrus.DebugLevel
 )
 
 // getLoggerKey 标准化 logger 名称（避免路径问题）
@@ -40,9 +42,9 @@ type Logger struct {
 }
 
 // getCaller 获取调用 Infof/Debugf 等函数的源码位置
-// skip = 2: 跳过 getCaller 和 外层日志函数（如 Infof）
-func (l *Logger) getCaller() string {
-	pc, file, line, ok := runtime.Caller(2)
+// skip = 3: 跳过 runtime.Caller、getCaller 和 外层日志函数（如 Infof）
+func getCaller() string {
+	pc, file, line, ok := runtime.Caller(3)
 	if !ok {
 		return "???:0"
 	}
@@ -53,70 +55,72 @@ func (l *Logger) getCaller() string {
 	return fmt.Sprintf("%s:%s:%d", filename, parts[len(parts)-1], line)
 }
 
+func (l *Logger) entry() *logrus.Entry {
+	return l.Logger.WithField("caller", getCaller())
+}
+
+// WithContext 返回带有 ctx 中日志字段（request_id、env、user、resource 等）和 trace_id 的日志条目
+func (l *Logger) WithContext(ctx context.Context) *Entry {
+	return &Entry{Entry: withContext(logrus.NewEntry(l.Logger), ctx)}
+}
+
+// WithFields 返回带有额外字段的日志条目
+func (l *Logger) WithFields(fields Fields) *Entry {
+	return &Entry{Entry: l.Logger.WithFields(logrus.Fields(fields))}
+}
+
 // === Debug 级别 ===
 func (l *Logger) Debug(args ...any) {
-	caller := l.getCaller()
-	l.Logger.Debug(fmt.Sprintf("%s %v", caller, fmt.Sprint(args...)))
+	l.entry().Debug(args...)
 }
 
 func (l *Logger) Debugf(format string, args ...any) {
-	caller := l.getCaller()
-	l.Logger.Debugf("%s "+format, append([]any{caller}, args...)...)
+	l.entry().Debugf(format, args...)
 }
 
 // === Info 级别 ===
 func (l *Logger) Info(args ...any) {
-	caller := l.getCaller()
-	l.Logger.Info(fmt.Sprintf("%s %v", caller, fmt.Sprint(args...)))
+	l.entry().Info(args...)
 }
 
 func (l *Logger) Infof(format string, args ...any) {
-	caller := l.getCaller()
-	l.Logger.Infof("%s "+format, append([]any{caller}, args...)...)
+	l.entry().Infof(format, args...)
 }
 
 // === Warn 级别 ===
 func (l *Logger) Warn(args ...any) {
-	caller := l.getCaller()
-	l.Logger.Warn(fmt.Sprintf("%s %v", caller, fmt.Sprint(args...)))
+	l.entry().Warn(args...)
 }
 
 func (l *Logger) Warnf(format string, args ...any) {
-	caller := l.getCaller()
-	l.Logger.Warnf("%s "+format, append([]any{caller}, args...)...)
+	l.entry().Warnf(format, args...)
 }
 
 // === Error 级别 ===
 func (l *Logger) Error(args ...any) {
-	caller := l.getCaller()
-	l.Logger.Error(fmt.Sprintf("%s %v", caller, fmt.Sprint(args...)))
+	l.entry().Error(args...)
 }
 
 func (l *Logger) Errorf(format string, args ...any) {
-	caller := l.getCaller()
-	l.Logger.Errorf("%s "+format, append([]any{caller}, args...)...)
+	l.entry().Errorf(format, args...)
 }
 
 // === Fatal 级别（会 os.Exit(1)）===
 func (l *Logger) Fatal(args ...any) {
-	caller := l.getCaller()
-	l.Logger.Fatal(fmt.Sprintf("%s %v", caller, fmt.Sprint(args...)))
+	l.entry().Fatal(args...)
 }
 
 func (l *Logger) Fatalf(format string, args ...any) {
-	caller := l.getCaller()
-	l.Logger.Fatalf("%s "+format, append([]any{caller}, args...)...)
+	l.entry().Fatalf(format, args...)
 }
 
 // === Panic 级别（会 panic）===
 func (l *Logger) Panic(args ...any) {
-	caller := l.getCaller()
-	l.Logger.Panic(fmt.Sprintf("%s %v", caller, fmt.Sprint(args...)))
+	l.entry().Panic(args...)
 }
 
 func (l *Logger) Panicf(format string, args ...any) {
-	caller := l.getCaller()
-	l.Logger.Panicf("%s "+format, append([]any{caller}, args...)...)
+	l.entry().Panicf(format, args...)
 }
 
 // === GetLogger：返回封装后的 Logger ===
@@ -149,12 +153,15 @@ func GetLogger(name string) *Logger {
 		MaxAge:     MaxAge,
 		Compress:   Compress,
 	})
-	logger.SetLevel(logrus.DebugLevel)
-
-	logger.SetFormatter(&logrus.TextFormatter{
-		ForceColors:     true,
-		FullTimestamp:   true,
-		TimestampFormat: "2006-01-02 15:04:05",
+	logger.SetLevel(defaultLevel)
+
+	logger.SetFormatter(&logrus.JSONFormatter{
+		TimestampFormat: "2006-01-02T15:04:05.000Z07:00",
+		FieldMap: logrus.FieldMap{
+			logrus.FieldKeyTime:  "time",
+			logrus.FieldKeyLevel: "level",
+			logrus.FieldKeyMsg:   "msg",
+		},
 	})
 
 	// 缓存原始 *logrus.Logger
@@ -163,3 +170,49 @@ func GetLogger(name string) *Logger {
 	// 返回封装的 Logger
 	return &Logger{logger}
 }
+
+// SetDefaultLevel 设置新建 logger 的默认级别，同时作用于已创建的 logger
+func SetDefaultLevel(level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	defaultLevel = lvl
+	for _, l := range loggers {
+		l.SetLevel(lvl)
+	}
+	return nil
+}
+
+// SetLevel 运行时调整指定 logger 的级别
+func SetLevel(name, level string) error {
+	lvl, err := logrus.ParseLevel(level)
+	if err != nil {
+		return err
+	}
+
+	key := getLoggerKey(name)
+	mu.Lock()
+	l, exists := loggers[key]
+	mu.Unlock()
+	if !exists {
+		return fmt.Errorf("logger %s not found", name)
+	}
+	l.SetLevel(lvl)
+	return nil
+}
+
+// ListLevels 返回所有已创建 logger 的当前级别
+func ListLevels() map[string]string {
+	mu.Lock()
+	defer mu.Unlock()
+
+	levels := make(map[string]string, len(loggers))
+	for key, l := range loggers {
+		levels[strings.TrimSuffix(key, ".log")] = l.GetLevel().String()
+	}
+	return levels
+}
@@ -1,25 +1,27 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/filesystem"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"io"
 	"io/fs"
 	"net/http"
-	"os"
 	"path/filepath"
 	"quiver/cache"
 	"quiver/config"
 	"quiver/database"
+	"quiver/grpcserver"
 	LOG "quiver/logger"
 	"quiver/middleware"
+	"quiver/models"
 	"quiver/routes"
 	"quiver/services"
+	"quiver/telemetry"
 	"quiver/utils"
 	"quiver/web"
 	"strings"
@@ -45,21 +47,46 @@ func main() {
 	// 加载配置
 	conf, _ := config.LoadConfig("config/config.yaml")
 	log.Infof("get config : %+v", conf)
+	if err := LOG.SetDefaultLevel(config.GetLogConfig().Level); err != nil {
+		log.Warnf("set log level error: %v", err)
+	}
 
-	// 初始化缓存
-	err := cache.Init(cache.Options{MaxMemCost: 1 << 30, DiskDir: os.TempDir() + "/quiver/cache"})
+	// 初始化链路追踪
+	shutdownTracing, err := telemetry.Init(config.GetTracingConfig())
 	if err != nil {
-		log.Warnf("init cache error: %v", err)
+		log.Warnf("init tracing error: %v", err)
+		shutdownTracing = func(context.Context) error { return nil }
 	}
-	err = cache.AddWatch([]cache.WatchTable{
-		{Env: "dev", Name: "accesskey", Interval: 5 * time.Second, KeyUpdateCB: services.OnKeyUpdate4AccessKey},
-		{Env: "dev", Name: "permission", Interval: 5 * time.Second, KeyUpdateCB: services.OnKeyUpdate4Permission},
-		{Env: "dev", Name: "namespace_release", Interval: time.Second, KeyUpdateCB: services.OnKeyUpdate4Release},
-
-		{Env: "pro", Name: "accesskey", Interval: 5 * time.Second, KeyUpdateCB: services.OnKeyUpdate4AccessKey},
-		{Env: "pro", Name: "permission", Interval: 5 * time.Second, KeyUpdateCB: services.OnKeyUpdate4Permission},
-		{Env: "pro", Name: "namespace_release", Interval: time.Second, KeyUpdateCB: services.OnKeyUpdate4Release},
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = shutdownTracing(ctx)
+	}()
+
+	// 初始化缓存，持久化模式下只保留不可变的发布快照
+	cacheConf := config.GetCacheConfig()
+	err = cache.Init(cache.Options{
+		MaxMemCost: cacheConf.MaxMemCost,
+		DiskDir:    cacheConf.Dir,
+		Persistent: cacheConf.Persistent,
+		Retain:     models.IsReleaseCacheKey,
 	})
+	if err != nil {
+		log.Warnf("init cache error: %v", err)
+	}
+	// 按 seq 顺序消费各环境的 release_message，删除失效缓存并刷新
+	// 实例间广播会立即触发拉取，这里的轮询只是兜底
+	pollInterval := config.GetClusterConfig().PollInterval
+	var watches []cache.WatchTable
+	for _, env := range []string{"dev", "pro"} {
+		watches = append(watches,
+			cache.WatchTable{Env: env, Name: models.MessageKindAccessKey, Interval: pollInterval, KeyUpdateCB: services.OnKeyUpdate4AccessKey},
+			cache.WatchTable{Env: env, Name: models.MessageKindPermission, Interval: pollInterval, KeyUpdateCB: services.OnKeyUpdate4Permission},
+			cache.WatchTable{Env: env, Name: models.MessageKindRelease, Interval: pollInterval, KeyUpdateCB: services.OnKeyUpdate4Release},
+			cache.WatchTable{Env: env, Name: models.MessageKindSession, Interval: pollInterval, KeyUpdateCB: services.OnKeyUpdate4Session},
+		)
+	}
+	err = cache.AddWatch(watches)
 
 	if err != nil {
 		log.Warnf("add watch error: %v", err)
@@ -78,13 +105,14 @@ func main() {
 
 	// 中间件
 	app.Use(recover.New())
-	app.Use(logger.New(logger.Config{
-		Format: "[${ip}]:${port} ${status} - ${method} ${path}\n",
-	}))
+	app.Use(middleware.TracingMiddleware())
+	app.Use(middleware.RequestIDMiddleware())
+	app.Use(middleware.AccessLogMiddleware())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
-		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin,Content-Type,Accept,Authorization",
+		AllowOrigins:  "*",
+		AllowMethods:  "GET,POST,PUT,DELETE,OPTIONS",
+		AllowHeaders:  "Origin,Content-Type,Accept,Authorization,traceparent,tracestate,X-Request-ID",
+		ExposeHeaders: "X-Request-ID,X-Trace-Id",
 	}))
 
 	// 设置路由
@@ -101,6 +129,14 @@ func main() {
 		})
 	})
 
+	// 就绪检查，启动预加载完成前返回 503
+	app.Get("/ready", func(c *fiber.Ctx) error {
+		if !services.Ready() {
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{"status": "warming"})
+		}
+		return c.JSON(fiber.Map{"status": "ready"})
+	})
+
 	// 前端静态文件
 	dist, err := fs.Sub(web.WebDistFS, "dist")
 	if err != nil {
@@ -140,6 +176,25 @@ func main() {
 	database.GetDB("dev")
 	database.GetDB("pro")
 
+	// 预加载各命名空间的最新发布
+	go services.WarmUp([]string{"dev", "pro"})
+
+	// 按保留策略定期清理历史发布
+	services.StartRetentionGC()
+
+	// 执行到期的定时发布、回滚
+	services.StartScheduler([]string{"dev", "pro"})
+
+	// 投递配置变更 webhook，失败按指数退避重试
+	services.StartWebhookDispatcher([]string{"dev", "pro"})
+
+	// 客户端 gRPC 接口，与 HTTP 使用不同端口
+	stopGRPC, err := grpcserver.Start(config.GetGRPCConfig())
+	if err != nil {
+		log.Fatalf("gRPC server failed to start: %v", err)
+	}
+	defer stopGRPC()
+
 	// 启动服务器
 	host := config.GetServerConfig().Host
 	port := config.GetServerConfig().Port
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"quiver/logger"
+	"quiver/services"
+	"quiver/utils"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthMiddleware 校验 Authorization: Bearer <access token>，必须放在 EnvMiddleware 之后；
+// 登录等公开接口需要注册在它之前
+// required 为 false 时未携带令牌的请求直接放行，携带了无效或已吊销的令牌仍然返回 401
+func AuthMiddleware(required bool) fiber.Handler {
+	sessionService := services.NewSessionService()
+	return func(c *fiber.Ctx) error {
+		token := bearerToken(c)
+		if token == "" {
+			if required {
+				return utils.Unauthorized(c, "missing access token")
+			}
+			return c.Next()
+		}
+
+		env := c.Locals("env").(string)
+		session, err := sessionService.Authenticate(env, token)
+		if err != nil {
+			logger.GetLogger("quiver").WithContext(c.UserContext()).Warnf("authenticate failed: %v", err)
+			return utils.Unauthorized(c, err.Error())
+		}
+
+		c.Locals("user_id", session.UserID)
+		c.Locals("user_name", session.UserName)
+		c.Locals("session_id", session.SessionID)
+		c.Locals("is_admin", services.IsAdmin(session.UserName))
+		c.SetUserContext(logger.ContextWithFields(c.UserContext(), logger.Fields{
+			"user": session.UserName,
+		}))
+		return c.Next()
+	}
+}
+
+// AdminOnly 只允许管理员访问
+func AdminOnly() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("user_id").(uint64); !ok {
+			return utils.Unauthorized(c, "login required")
+		}
+		if isAdmin, _ := c.Locals("is_admin").(bool); !isAdmin {
+			return utils.Forbidden(c, "admin required")
+		}
+		return c.Next()
+	}
+}
+
+func bearerToken(c *fiber.Ctx) string {
+	auth := c.Get(fiber.HeaderAuthorization)
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+	return ""
+}
@@ -2,6 +2,7 @@
 package middleware
 
 import (
+	"quiver/logger"
 	"quiver/utils"
 	"strings"
 
@@ -21,6 +22,25 @@ func EnvMiddleware() fiber.Handler {
 			return utils.NotFound(c, "invalid env")
 		}
 		c.Locals("env", env)
+		c.SetUserContext(logger.ContextWithFields(c.UserContext(), logger.Fields{
+			"env":      env,
+			"resource": resourcePath(c.Path()),
+		}))
 		return c.Next()
 	}
 }
+
+// resourcePath 从请求路径中提取 app/cluster/namespace 资源路径，便于日志检索
+// 例如 /api/v1/envs/dev/apps/a1/clusters/c1/namespaces/n1/items -> a1/c1/n1
+func resourcePath(path string) string {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	var resource []string
+	for i := 0; i+1 < len(parts); i++ {
+		switch parts[i] {
+		case "apps", "clusters", "namespaces":
+			resource = append(resource, parts[i+1])
+			i++
+		}
+	}
+	return strings.Join(resource, "/")
+}
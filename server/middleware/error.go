@@ -1,7 +1,7 @@
 package middleware
 
 import (
-	"log"
+	"quiver/logger"
 	"quiver/utils"
 
 	"github.com/gofiber/fiber/v2"
@@ -20,7 +20,10 @@ func ErrorHandler(c *fiber.Ctx, err error) error {
 	}
 
 	// 记录错误日志
-	log.Printf("Error [%d]: %s - Path: %s", code, message, c.Path())
+	logger.GetLogger("quiver").WithContext(c.UserContext()).WithFields(logger.Fields{
+		"status": code,
+		"error":  err.Error(),
+	}).Errorf("request failed: %s", message)
 
 	// 返回错误响应
 	return utils.Error(c, code, message, nil)
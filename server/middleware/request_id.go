@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"quiver/logger"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID 请求 ID 头，客户端传入时沿用，否则由服务端生成
+const HeaderRequestID = "X-Request-ID"
+
+// RequestIDMiddleware 生成或透传请求 ID，写回响应头，并放入日志上下文
+func RequestIDMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		requestID := c.Get(HeaderRequestID)
+		if requestID == "" || len(requestID) > 128 {
+			requestID = uuid.NewString()
+		}
+
+		c.Set(HeaderRequestID, requestID)
+		c.Locals("request_id", requestID)
+		c.SetUserContext(logger.ContextWithFields(c.UserContext(), logger.Fields{
+			"request_id": requestID,
+			"path":       c.Path(),
+		}))
+		return c.Next()
+	}
+}
+
+// AccessLogMiddleware 以结构化 JSON 记录访问日志
+func AccessLogMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		start := time.Now()
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+
+		logger.GetLogger("access").WithContext(c.UserContext()).WithFields(logger.Fields{
+			"method":     c.Method(),
+			"route":      c.Route().Path,
+			"status":     status,
+			"ip":         c.IP(),
+			"latency_ms": float64(time.Since(start).Microseconds()) / 1000,
+			"bytes_out":  len(c.Response().Body()),
+		}).Info("access")
+		return err
+	}
+}
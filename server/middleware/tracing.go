@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"fmt"
+	"quiver/telemetry"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TracingMiddleware 从请求头中提取 W3C trace-context，为每个请求创建 server span
+// span 放入 c.UserContext()，handler 通过 UserContext 把它传递给 service、GORM 和 QCache
+func TracingMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		carrier := propagation.HeaderCarrier{}
+		c.Request().Header.VisitAll(func(key, value []byte) {
+			carrier.Set(string(key), string(value))
+		})
+		ctx := otel.GetTextMapPropagator().Extract(c.UserContext(), carrier)
+
+		// Fiber 没有开启 Immutable，Method、Path 引用的内存在请求结束后会被复用，
+		// 而 span 由 BatchSpanProcessor 在请求结束后导出，属性需要复制一份
+		method, path := strings.Clone(c.Method()), strings.Clone(c.Path())
+		ctx, span := telemetry.Tracer().Start(ctx, method+" "+path,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", method),
+				attribute.String("url.path", path),
+				attribute.String("client.address", c.IP()),
+			))
+		defer span.End()
+
+		c.SetUserContext(ctx)
+		if sc := span.SpanContext(); sc.HasTraceID() {
+			c.Set("X-Trace-Id", sc.TraceID().String())
+		}
+
+		err := c.Next()
+
+		// 路由匹配完成后再用路由模板命名，避免高基数的 span 名
+		span.SetName(method + " " + c.Route().Path)
+		span.SetAttributes(attribute.String("http.route", c.Route().Path))
+		if env, ok := c.Locals("env").(string); ok {
+			span.SetAttributes(attribute.String("quiver.env", strings.Clone(env)))
+		}
+
+		status := c.Response().StatusCode()
+		if e, ok := err.(*fiber.Error); ok {
+			status = e.Code
+		}
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if err != nil {
+			span.RecordError(err)
+		}
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("http status %d", status))
+		}
+		return err
+	}
+}
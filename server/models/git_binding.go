@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Git 仓库中的文件导入为配置项的方式
+const (
+	GitFormatFiles      = "files"      // path 为目录，每个文件是一个配置项
+	GitFormatProperties = "properties" // path 为文件，按 Java properties 解析
+	GitFormatYAML       = "yaml"       // path 为文件，嵌套映射按 . 展开
+	GitFormatJSON       = "json"       // path 为文件，嵌套对象按 . 展开
+)
+
+// NamespaceGitBinding 命名空间绑定的服务器本地 Git 仓库，一个命名空间最多绑定一个
+type NamespaceGitBinding struct {
+	ID            uint64     `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	AppID         uint64     `json:"-" gorm:"column:app_id;not null"`
+	AppName       string     `json:"app_name" gorm:"column:app_name;size:128;not null"`
+	ClusterID     uint64     `json:"-" gorm:"column:cluster_id;not null"`
+	ClusterName   string     `json:"cluster_name" gorm:"column:cluster_name;size:128;not null"`
+	NamespaceID   uint64     `json:"-" gorm:"column:namespace_id;not null;uniqueIndex:uk_namespace_id"`
+	NamespaceName string     `json:"namespace_name" gorm:"column:namespace_name;size:128;not null"`
+	RepoPath      string     `json:"repo_path" gorm:"column:repo_path;size:1024;not null"` // 仓库在服务器上的路径，裸仓库或工作副本
+	Ref           string     `json:"ref" gorm:"column:ref;size:255;not null"`              // 默认拉取的分支、标签或 commit
+	Path          string     `json:"path" gorm:"column:path;size:1024;not null"`           // 仓库中的目录或文件，为空时为仓库根目录
+	Format        string     `json:"format" gorm:"column:format;size:16;not null"`
+	Operator      string     `json:"operator" gorm:"column:operator;size:64;not null"`
+	PulledCommit  string     `json:"pulled_commit,omitempty" gorm:"column:pulled_commit;size:64"` // 最近一次拉取到草稿区的 commit
+	PulledHash    string     `json:"-" gorm:"column:pulled_hash;size:64"`                         // 拉取内容的摘要，发布时草稿与之相同才记录 commit
+	PulledBy      string     `json:"pulled_by,omitempty" gorm:"column:pulled_by;size:64"`
+	PulledTime    *time.Time `json:"pulled_time,omitempty" gorm:"column:pulled_time"`
+	CreateTime    time.Time  `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+	UpdateTime    time.Time  `json:"update_time" gorm:"column:update_time;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (b *NamespaceGitBinding) TableName() string {
+	return "namespace_git_binding"
+}
@@ -48,7 +48,8 @@ type ItemRelease struct {
 	NamespaceID uint64 `json:"-" gorm:"column:namespace_id;not null"`
 	K           string `json:"key" gorm:"column:k;size:255;not null"`
 	V           string `json:"value" gorm:"column:v;type:text"`
-	KvID        uint64 `json:"kv_id" gorm:"column:kv_id;not null;index:idx_kv_id"`     // 使用 uint64 代替 BIGINT
+	KvID        uint64 `json:"kv_id" gorm:"column:kv_id;not null;index:idx_kv_id"`     // MurmurHash64(k,v)，可能碰撞，仅用于兼容旧版本发布
+	KvHash      string `json:"kv_hash" gorm:"column:kv_hash;size:64"`                  // ContentHash(k,v)，同一命名空间内唯一
 	IsDeleted   uint8  `json:"is_deleted" gorm:"column:is_deleted;not null;default:0"` // 逻辑删除
 
 	CreateTime time.Time `json:"create_time" gorm:"column:create_time;autoCreateTime"`
@@ -2,6 +2,7 @@ package models
 
 import (
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -33,35 +34,58 @@ func (n *Namespace) TableName() string {
 }
 
 type NamespaceRelease struct {
-	ID            uint64    `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
-	AppID         uint64    `json:"-" gorm:"column:app_id;not null;index:idx_app_id"`
-	AppName       string    `json:"app_name" gorm:"column:app_name;size:128;not null"`
-	ClusterID     uint64    `json:"-" gorm:"column:cluster_id;not null;index:idx_cluster_id"`
-	ClusterName   string    `json:"cluster_name" gorm:"column:cluster_name;size:128;not null"`
-	NamespaceID   uint64    `json:"-" gorm:"column:namespace_id;not null;index:idx_namespace_id"`
-	NamespaceName string    `json:"namespace_name" gorm:"column:namespace_name;size:128;not null;index:idx_namespace_name"`
-	ReleaseID     string    `json:"release_id" gorm:"column:release_id;size:64;not null;uniqueIndex:uk_release_id"`
-	ReleaseName   string    `json:"release_name" gorm:"column:release_name;size:128;not null"`
-	ReleaseTime   time.Time `json:"release_time" gorm:"column:create_time;autoCreateTime"`
-	Operator      string    `json:"operator" gorm:"column:operator;size:64"`
-	Comment       string    `json:"comment" gorm:"column:comment;type:varchar(1024)"`
-	Config        []byte    `json:"-" gorm:"column:config;type:blob"`
-	CreateTime    time.Time `json:"-" gorm:"column:create_time;autoCreateTime"`
-	UpdateTime    time.Time `json:"-" gorm:"column:update_time;autoUpdateTime"`
+	ID            uint64       `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	AppID         uint64       `json:"-" gorm:"column:app_id;not null;index:idx_app_id"`
+	AppName       string       `json:"app_name" gorm:"column:app_name;size:128;not null"`
+	ClusterID     uint64       `json:"-" gorm:"column:cluster_id;not null;index:idx_cluster_id"`
+	ClusterName   string       `json:"cluster_name" gorm:"column:cluster_name;size:128;not null"`
+	NamespaceID   uint64       `json:"-" gorm:"column:namespace_id;not null;index:idx_namespace_id"`
+	NamespaceName string       `json:"namespace_name" gorm:"column:namespace_name;size:128;not null;index:idx_namespace_name"`
+	ReleaseID     string       `json:"release_id" gorm:"column:release_id;size:64;not null;uniqueIndex:uk_release_id"`
+	ReleaseName   string       `json:"release_name" gorm:"column:release_name;size:128;not null"`
+	ReleaseTime   time.Time    `json:"release_time" gorm:"column:create_time;autoCreateTime"`
+	Operator      string       `json:"operator" gorm:"column:operator;size:64"`
+	Comment       string       `json:"comment" gorm:"column:comment;type:varchar(1024)"`
+	SourceRelease string       `json:"source_release_id,omitempty" gorm:"column:source_release_id;size:64"` // 由其他集群的哪个版本提升而来
+	GitCommit     string       `json:"git_commit,omitempty" gorm:"column:git_commit;size:64"`               // 内容与绑定的 Git 仓库中哪个 commit 一致
+	Interpolated  bool         `json:"interpolated" gorm:"column:interpolated;not null;default:0"`          // 发布时已解析引用，拉取时按 refs 展开
+	Refs          []ReleaseRef `json:"refs,omitempty" gorm:"column:refs;type:mediumtext;serializer:json"`   // 发布时解析的引用及其值
+	Config        []byte       `json:"-" gorm:"column:config;type:blob"`
+	CreateTime    time.Time    `json:"-" gorm:"column:create_time;autoCreateTime"`
+	UpdateTime    time.Time    `json:"-" gorm:"column:update_time;autoUpdateTime"`
 
 	// 关联关系（可选加载）
-	KvIDs   []uint64      `json:"-" gorm:"-"`
 	Items   []ItemRelease `json:"-" gorm:"-"`
 	App     App           `json:"-" gorm:"foreignKey:AppID;references:AppID"`
 	Cluster Cluster       `json:"-" gorm:"foreignKey:ClusterID;references:AppID"`
 }
 
+// ReleaseRef 发布时解析的一个引用，release_id 为引用值所在的版本
+type ReleaseRef struct {
+	Namespace string `json:"namespace"`
+	Key       string `json:"key"`
+	ReleaseID string `json:"release_id"`
+	Value     string `json:"value"`
+}
+
 func (nr *NamespaceRelease) GetID() uint64            { return nr.ID }
 func (nr *NamespaceRelease) GetUpdateTime() time.Time { return nr.UpdateTime }
 func (nr *NamespaceRelease) CacheKey(env string) string {
 	return fmt.Sprintf("release:latest:%s:%s:%s:%s", env, nr.AppName, nr.ClusterName, nr.NamespaceName)
 }
 
+// ReleaseCacheKey 某次发布的完整快照，发布后内容不再变化
+func ReleaseCacheKey(env, releaseID string) string {
+	return fmt.Sprintf("release:%s:%s", env, releaseID)
+}
+
+// IsReleaseCacheKey 判断 key 是否为不可变的发布快照（release:<env>:<release_id>），
+// release:latest:* 等可变指针不算
+func IsReleaseCacheKey(key string) bool {
+	parts := strings.Split(key, ":")
+	return len(parts) == 3 && parts[0] == "release" && parts[1] != "latest" && parts[2] != ""
+}
+
 // TableName 指定表名
 func (nr *NamespaceRelease) TableName() string {
 	return "namespace_release"
@@ -12,6 +12,7 @@ type Permission struct {
 	ResourceID   uint64    `json:"resource_id" gorm:"column:resource_id;not null"`
 	ResourceName string    `json:"resource_name" gorm:"column:resource_name;size:128;not null"`
 	Action       string    `json:"action" gorm:"column:action;not null"`
+	Source       string    `json:"source" gorm:"column:source;size:16;not null;default:manual"` // manual 手工授权，sso 由组映射同步
 	CreateTime   time.Time `json:"-" gorm:"column:create_time;autoCreateTime"`
 	UpdateTime   time.Time `json:"-" gorm:"column:update_time;autoUpdateTime"`
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// 发布快照格式版本
+const (
+	// ReleaseConfigLegacy 旧格式：msgpack 数组，元素为 MurmurHash64(k,v)，按 (namespace_id, kv_id) 查 item_release，可能碰撞
+	ReleaseConfigLegacy = 1
+	// ReleaseConfigV2 新格式：msgpack map，元素为 item_release 的主键 id，item_release 按 (namespace_id, kv_hash) 去重
+	ReleaseConfigV2 = 2
+)
+
+// ReleaseConfig namespace_release.config 中保存的发布快照
+type ReleaseConfig struct {
+	Version int      `msgpack:"v"`
+	ItemIDs []uint64 `msgpack:"item_ids"` // V2：item_release.id
+	KvIDs   []uint64 `msgpack:"-"`        // Legacy：item_release.kv_id
+}
+
+// EncodeReleaseConfig 以新格式序列化发布快照
+func EncodeReleaseConfig(itemIDs []uint64) ([]byte, error) {
+	return msgpack.Marshal(&ReleaseConfig{Version: ReleaseConfigV2, ItemIDs: itemIDs})
+}
+
+// DecodeReleaseConfig 解析发布快照，兼容旧格式
+func DecodeReleaseConfig(data []byte) (*ReleaseConfig, error) {
+	if len(data) == 0 {
+		return &ReleaseConfig{Version: ReleaseConfigV2}, nil
+	}
+
+	dec := msgpack.NewDecoder(bytes.NewReader(data))
+	code, err := dec.PeekCode()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &ReleaseConfig{}
+	if msgpcode.IsFixedArray(code) || code == msgpcode.Array16 || code == msgpcode.Array32 {
+		cfg.Version = ReleaseConfigLegacy
+		if err := dec.Decode(&cfg.KvIDs); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	if err := dec.Decode(cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Version != ReleaseConfigV2 {
+		return nil, errors.New("unsupported release config version")
+	}
+	return cfg, nil
+}
+
+// Len 快照中的配置项数量
+func (c *ReleaseConfig) Len() int {
+	if c.Version == ReleaseConfigLegacy {
+		return len(c.KvIDs)
+	}
+	return len(c.ItemIDs)
+}
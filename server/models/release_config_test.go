@@ -0,0 +1,77 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestDecodeReleaseConfig(t *testing.T) {
+	mustMarshal := func(v interface{}) []byte {
+		data, err := msgpack.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+	v2, err := EncodeReleaseConfig([]uint64{3, 1, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 超过 15 个元素时 msgpack 用 array16 编码
+	var many []uint64
+	for i := uint64(1); i <= 20; i++ {
+		many = append(many, i<<32|i)
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *ReleaseConfig
+		wantLen int
+		wantErr bool
+	}{
+		{"empty", nil, &ReleaseConfig{Version: ReleaseConfigV2}, 0, false},
+		{"v2", v2, &ReleaseConfig{Version: ReleaseConfigV2, ItemIDs: []uint64{3, 1, 2}}, 3, false},
+		{"v2 without items", mustMarshal(&ReleaseConfig{Version: ReleaseConfigV2}), &ReleaseConfig{Version: ReleaseConfigV2}, 0, false},
+		{"legacy fixarray", mustMarshal([]uint64{7, 8}), &ReleaseConfig{Version: ReleaseConfigLegacy, KvIDs: []uint64{7, 8}}, 2, false},
+		{"legacy array16", mustMarshal(many), &ReleaseConfig{Version: ReleaseConfigLegacy, KvIDs: many}, 20, false},
+		{"legacy empty array", mustMarshal([]uint64{}), &ReleaseConfig{Version: ReleaseConfigLegacy, KvIDs: []uint64{}}, 0, false},
+		{"unknown version", mustMarshal(map[string]interface{}{"v": 3, "item_ids": []uint64{1}}), nil, 0, true},
+		{"map without version", mustMarshal(map[string]interface{}{"item_ids": []uint64{1}}), nil, 0, true},
+		{"not msgpack", []byte{0xc1}, nil, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := DecodeReleaseConfig(tt.data)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("DecodeReleaseConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("DecodeReleaseConfig() = %+v, want %+v", got, tt.want)
+			}
+			if got.Len() != tt.wantLen {
+				t.Fatalf("Len() = %d, want %d", got.Len(), tt.wantLen)
+			}
+		})
+	}
+}
+
+func TestEncodeReleaseConfigRoundTrip(t *testing.T) {
+	ids := []uint64{1, 1 << 40, 42}
+	data, err := EncodeReleaseConfig(ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := DecodeReleaseConfig(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Version != ReleaseConfigV2 || !reflect.DeepEqual(cfg.ItemIDs, ids) || cfg.KvIDs != nil {
+		t.Fatalf("round trip = %+v", cfg)
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// 变更消息类型，与原先 watcher 监听的表名保持一致
+const (
+	MessageKindRelease    = "namespace_release"
+	MessageKindPermission = "permission"
+	MessageKindAccessKey  = "accesskey"
+	MessageKindSession    = "session"
+)
+
+// ReleaseMessage 变更日志，与业务数据在同一事务中写入
+// seq 在事务内通过 sequence 表加锁分配，按提交顺序单调递增且没有空洞
+type ReleaseMessage struct {
+	Seq        uint64    `json:"seq" gorm:"column:seq;primaryKey;autoIncrement:false"`
+	Kind       string    `json:"kind" gorm:"column:kind;size:32;not null"`
+	Message    string    `json:"message" gorm:"column:message;size:512;not null"` // 需要失效的缓存 key
+	CreateTime time.Time `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (m *ReleaseMessage) TableName() string {
+	return "release_message"
+}
+
+// Sequence 命名序列，目前只有 release_message 使用
+type Sequence struct {
+	Name  string `gorm:"column:name;primaryKey;size:64"`
+	Value uint64 `gorm:"column:value;not null"`
+}
+
+// TableName 指定表名
+func (s *Sequence) TableName() string {
+	return "sequence"
+}
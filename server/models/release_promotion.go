@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+)
+
+// 版本提升方式
+const (
+	PromoteModeDraft   = "draft"   // 只写入目标命名空间的草稿区
+	PromoteModePublish = "publish" // 写入草稿区后直接发布
+)
+
+// ReleasePromotion 版本提升记录，记录哪个版本被提升到了哪个集群
+type ReleasePromotion struct {
+	ID                uint64    `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	AppName           string    `json:"app_name" gorm:"column:app_name;size:128;not null"`
+	SourceReleaseID   string    `json:"source_release_id" gorm:"column:source_release_id;size:64;not null"`
+	SourceClusterName string    `json:"source_cluster_name" gorm:"column:source_cluster_name;size:128;not null"`
+	SourceNamespace   string    `json:"source_namespace" gorm:"column:source_namespace;size:128;not null"`
+	TargetClusterName string    `json:"target_cluster_name" gorm:"column:target_cluster_name;size:128;not null"`
+	TargetNamespace   string    `json:"target_namespace" gorm:"column:target_namespace;size:128;not null"`
+	TargetNamespaceID uint64    `json:"-" gorm:"column:target_namespace_id;not null"`
+	Mode              string    `json:"mode" gorm:"column:mode;size:16;not null"`
+	ResultReleaseID   string    `json:"result_release_id,omitempty" gorm:"column:result_release_id;size:64"` // publish 时生成的版本
+	Operator          string    `json:"operator" gorm:"column:operator;size:64;not null"`
+	Comment           string    `json:"comment" gorm:"column:comment;type:varchar(1024)"`
+	CreateTime        time.Time `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (p *ReleasePromotion) TableName() string {
+	return "release_promotion"
+}
@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+)
+
+// 定时任务的动作
+const (
+	ScheduleActionPublish  = "publish"
+	ScheduleActionRollback = "rollback"
+)
+
+// 定时任务的状态，只有 pending 的任务会被执行或取消
+const (
+	ScheduleStatusPending   = "pending"
+	ScheduleStatusSucceeded = "succeeded"
+	ScheduleStatusFailed    = "failed"
+	ScheduleStatusCancelled = "cancelled"
+)
+
+// ScheduledRelease 定时发布或回滚
+// 执行成功时状态与新版本在同一事务中更新，多实例同时执行时只有一个能提交
+type ScheduledRelease struct {
+	ID            uint64     `json:"schedule_id" gorm:"column:id;primaryKey;autoIncrement"`
+	AppID         uint64     `json:"-" gorm:"column:app_id;not null"`
+	AppName       string     `json:"app_name" gorm:"column:app_name;size:128;not null"`
+	ClusterID     uint64     `json:"-" gorm:"column:cluster_id;not null"`
+	ClusterName   string     `json:"cluster_name" gorm:"column:cluster_name;size:128;not null"`
+	NamespaceID   uint64     `json:"-" gorm:"column:namespace_id;not null;index:idx_namespace_id"`
+	NamespaceName string     `json:"namespace_name" gorm:"column:namespace_name;size:128;not null"`
+	Action        string     `json:"action" gorm:"column:action;size:16;not null"`
+	ExecuteAt     time.Time  `json:"execute_at" gorm:"column:execute_at;not null"`
+	RevertAt      *time.Time `json:"revert_at,omitempty" gorm:"column:revert_at"`                  // 执行成功后在该时间回滚到执行前的版本
+	ReleaseName   string     `json:"release_name,omitempty" gorm:"column:release_name;size:128"`   // publish 使用
+	Keys          []string   `json:"keys,omitempty" gorm:"column:keys;type:text;serializer:json"`  // publish 使用，为空时发布全部草稿
+	ReleaseID     string     `json:"release_id,omitempty" gorm:"column:release_id;size:64"`        // rollback 的目标版本
+	RestoreDraft  bool       `json:"restore_draft" gorm:"column:restore_draft;not null;default:0"` // rollback 使用
+	Force         bool       `json:"force" gorm:"column:force;not null;default:0"`                 // rollback 使用
+	Operator      string     `json:"operator" gorm:"column:operator;size:64;not null"`
+	Comment       string     `json:"comment" gorm:"column:comment;type:varchar(1024)"`
+	Status        string     `json:"status" gorm:"column:status;size:16;not null;default:pending"`
+	Reason        string     `json:"reason,omitempty" gorm:"column:reason;type:varchar(1024)"`            // 失败原因
+	ResultID      string     `json:"result_release_id,omitempty" gorm:"column:result_release_id;size:64"` // 执行生成的版本
+	ParentID      uint64     `json:"parent_id,omitempty" gorm:"column:parent_id;not null;default:0"`      // 由哪个任务的 revert_at 生成
+	ExecuteTime   *time.Time `json:"execute_time,omitempty" gorm:"column:execute_time"`                   // 实际执行或取消的时间
+	CancelledBy   string     `json:"cancelled_by,omitempty" gorm:"column:cancelled_by;size:64"`           // 取消人
+	CreateTime    time.Time  `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+	UpdateTime    time.Time  `json:"update_time" gorm:"column:update_time;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (s *ScheduledRelease) TableName() string {
+	return "scheduled_release"
+}
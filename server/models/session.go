@@ -0,0 +1,42 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Session 登录会话，access token 与 refresh token 只保存 sha256 摘要
+type Session struct {
+	ID               uint64     `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	SessionID        string     `json:"session_id" gorm:"column:session_id;size:64;not null;uniqueIndex:uk_session_id"`
+	UserID           uint64     `json:"user_id" gorm:"column:user_id;not null;index:idx_user_revoked"`
+	UserName         string     `json:"user_name" gorm:"column:user_name;size:128;not null"`
+	AccessTokenHash  string     `json:"-" gorm:"column:access_token_hash;size:64;not null"`
+	RefreshTokenHash string     `json:"-" gorm:"column:refresh_token_hash;size:64;not null"`
+	AccessExpireAt   time.Time  `json:"access_expire_at" gorm:"column:access_expire_at;not null"`
+	RefreshExpireAt  time.Time  `json:"refresh_expire_at" gorm:"column:refresh_expire_at;not null"`
+	ClientIP         string     `json:"client_ip" gorm:"column:client_ip;size:64"`
+	UserAgent        string     `json:"user_agent" gorm:"column:user_agent;size:256"`
+	Revoked          uint8      `json:"revoked" gorm:"column:revoked;not null;default:0"`
+	RevokeReason     string     `json:"revoke_reason,omitempty" gorm:"column:revoke_reason;size:128"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty" gorm:"column:revoked_at"`
+	LastSeenAt       time.Time  `json:"last_seen_at" gorm:"column:last_seen_at"`
+	CreateTime       time.Time  `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+	UpdateTime       time.Time  `json:"-" gorm:"column:update_time;autoUpdateTime"`
+}
+
+func (s *Session) GetID() uint64            { return s.ID }
+func (s *Session) GetUpdateTime() time.Time { return s.UpdateTime }
+func (s *Session) CacheKey(env string) string {
+	return fmt.Sprintf("session:%s:%s", env, s.SessionID)
+}
+
+// IsActive 会话未被吊销且 refresh token 未过期
+func (s *Session) IsActive(now time.Time) bool {
+	return s.Revoked == 0 && now.Before(s.RefreshExpireAt)
+}
+
+// TableName 指定表名
+func (s *Session) TableName() string {
+	return "session"
+}
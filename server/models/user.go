@@ -12,6 +12,8 @@ type User struct {
 	Password   string    `json:"password,omitempty" gorm:"column:password;size:256;not null"`
 	Email      string    `json:"email" gorm:"column:email;size:128"`
 	Phone      string    `json:"phone" gorm:"column:phone;size:32"`
+	Source     string    `json:"source" gorm:"column:source;size:16;not null;default:local;uniqueIndex:uk_source_external_id"` // local、ldap、oidc
+	ExternalID *string   `json:"external_id,omitempty" gorm:"column:external_id;size:512;uniqueIndex:uk_source_external_id"`   // 外部身份标识，本地用户为 NULL
 	CreateTime time.Time `json:"-" gorm:"column:create_time;autoCreateTime"`
 	UpdateTime time.Time `json:"-" gorm:"column:update_time;autoUpdateTime"`
 }
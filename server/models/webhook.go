@@ -0,0 +1,92 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// webhook 事件类型
+const (
+	WebhookEventPublish         = "publish"          // 发布，包括定时发布、直接发布的版本提升和 Git 拉取
+	WebhookEventRollback        = "rollback"         // 回滚
+	WebhookEventItemSet         = "item.set"         // 新增或修改草稿配置项
+	WebhookEventItemDelete      = "item.delete"      // 删除草稿配置项
+	WebhookEventNamespaceDelete = "namespace.delete" // 删除命名空间
+	WebhookEventClusterDelete   = "cluster.delete"   // 删除集群
+	WebhookEventAppDelete       = "app.delete"       // 删除应用
+)
+
+// WebhookEvents 全部事件类型
+var WebhookEvents = []string{
+	WebhookEventPublish,
+	WebhookEventRollback,
+	WebhookEventItemSet,
+	WebhookEventItemDelete,
+	WebhookEventNamespaceDelete,
+	WebhookEventClusterDelete,
+	WebhookEventAppDelete,
+}
+
+// 投递状态，只有 pending 的记录会被投递
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // 达到最多投递次数
+)
+
+// Webhook 应用或命名空间的事件订阅，按名称匹配，删除应用后仍然保留，可以收到 app.delete
+type Webhook struct {
+	ID            uint64    `json:"webhook_id" gorm:"column:id;primaryKey;autoIncrement"`
+	AppName       string    `json:"app_name" gorm:"column:app_name;size:128;not null;index:idx_app_name"`
+	ClusterName   string    `json:"cluster_name,omitempty" gorm:"column:cluster_name;size:128;not null;default:''"`     // 与 namespace_name 同时为空时订阅整个应用
+	NamespaceName string    `json:"namespace_name,omitempty" gorm:"column:namespace_name;size:128;not null;default:''"` // 订阅单个命名空间
+	URL           string    `json:"url" gorm:"column:url;size:1024;not null"`
+	Secret        string    `json:"secret,omitempty" gorm:"column:secret;size:128;not null"`  // 签名密钥，只在创建时返回
+	Events        []string  `json:"events" gorm:"column:events;type:text;serializer:json"`    // 为空时订阅全部事件
+	Enabled       bool      `json:"enabled" gorm:"column:enabled;not null"`                   // 停用时不产生新的投递，待投递的记录暂停
+	Description   string    `json:"description" gorm:"column:description;type:varchar(1024)"` // 备注
+	Operator      string    `json:"operator" gorm:"column:operator;size:64;not null"`
+	CreateTime    time.Time `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+	UpdateTime    time.Time `json:"update_time" gorm:"column:update_time;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (w *Webhook) TableName() string {
+	return "webhook"
+}
+
+// WebhookDelivery 一次事件投递，与产生事件的变更在同一事务中写入；重放时复制为新的记录
+type WebhookDelivery struct {
+	ID              uint64     `json:"delivery_id" gorm:"column:id;primaryKey;autoIncrement"`
+	WebhookID       uint64     `json:"webhook_id" gorm:"column:webhook_id;not null;index:idx_webhook_id"`
+	EventID         string     `json:"event_id" gorm:"column:event_id;size:64;not null"` // 重放时不变，接收方可以据此去重
+	Event           string     `json:"event" gorm:"column:event;size:32;not null"`
+	Payload         string     `json:"-" gorm:"column:payload;type:mediumtext;not null"`
+	Status          string     `json:"status" gorm:"column:status;size:16;not null;default:pending"`
+	Attempts        int        `json:"attempts" gorm:"column:attempts;not null;default:0"`
+	NextAttemptTime time.Time  `json:"next_attempt_time" gorm:"column:next_attempt_time;not null"`
+	ResponseCode    int        `json:"response_code,omitempty" gorm:"column:response_code;not null;default:0"` // 最近一次投递的 HTTP 状态码
+	LastError       string     `json:"last_error,omitempty" gorm:"column:last_error;type:varchar(1024)"`
+	ReplayOf        uint64     `json:"replay_of,omitempty" gorm:"column:replay_of;not null;default:0"` // 由哪条记录重放而来
+	DeliveredTime   *time.Time `json:"delivered_time,omitempty" gorm:"column:delivered_time"`
+	CreateTime      time.Time  `json:"create_time" gorm:"column:create_time;autoCreateTime"`
+	UpdateTime      time.Time  `json:"update_time" gorm:"column:update_time;autoUpdateTime"`
+}
+
+// TableName 指定表名
+func (d *WebhookDelivery) TableName() string {
+	return "webhook_delivery"
+}
+
+// MarshalJSON 把 payload 原样输出为 JSON 对象
+func (d WebhookDelivery) MarshalJSON() ([]byte, error) {
+	type delivery WebhookDelivery
+	var payload json.RawMessage
+	if d.Payload != "" {
+		payload = json.RawMessage(d.Payload)
+	}
+	return json.Marshal(struct {
+		delivery
+		Payload json.RawMessage `json:"payload,omitempty"`
+	}{delivery(d), payload})
+}
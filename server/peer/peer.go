@@ -0,0 +1,123 @@
+package peer
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"quiver/config"
+	"quiver/logger"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// NotifyPath 实例间通知接口
+	NotifyPath = "/internal/v1/notify"
+
+	HeaderTimestamp = "X-Quiver-Timestamp"
+	HeaderSignature = "X-Quiver-Signature"
+
+	// 签名时间戳允许的偏差，防止重放
+	maxClockSkew = 5 * time.Minute
+)
+
+// Event 广播给其他实例的变更事件，收到后按 env 立即拉取变更日志
+type Event struct {
+	Origin string `json:"origin"`
+	Env    string `json:"env"`
+	Time   int64  `json:"time"`
+}
+
+var httpClient = &http.Client{}
+
+// Enabled 配置了密钥和 peers 时才广播
+func Enabled() bool {
+	conf := config.GetClusterConfig()
+	return conf.Secret != "" && len(conf.Peers) > 0
+}
+
+// Broadcast 异步通知其他实例 env 有新的变更，失败只记录日志，由兜底轮询保证最终一致
+func Broadcast(env string) {
+	if !Enabled() {
+		return
+	}
+	conf := config.GetClusterConfig()
+
+	body, err := json.Marshal(&Event{Origin: conf.Self, Env: env, Time: time.Now().UnixMilli()})
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("marshal peer event failed: %v", err)
+		return
+	}
+
+	self := strings.TrimRight(conf.Self, "/")
+	for _, p := range conf.Peers {
+		p = strings.TrimRight(p, "/")
+		if p == "" || p == self {
+			continue
+		}
+		go func(target string) {
+			if err := send(target+NotifyPath, body, conf); err != nil {
+				logger.GetLogger("quiver").Warnf("notify peer %s env %s failed: %v", target, env, err)
+			}
+		}(p)
+	}
+}
+
+func send(url string, body []byte, conf config.ClusterConfig) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(conf.Secret, ts, body))
+
+	client := *httpClient
+	client.Timeout = conf.Timeout
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("unexpected status " + resp.Status)
+	}
+	return nil
+}
+
+// Sign 计算 hex(HMAC-SHA256(secret, timestamp + "." + body))
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify 校验签名和时间戳
+func Verify(timestamp, signature string, body []byte) error {
+	conf := config.GetClusterConfig()
+	if conf.Secret == "" {
+		return errors.New("peer notify disabled")
+	}
+
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return errors.New("invalid timestamp")
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > maxClockSkew || skew < -maxClockSkew {
+		return errors.New("timestamp expired")
+	}
+
+	expected := Sign(conf.Secret, timestamp, body)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package peer
+
+import (
+	"os"
+	"path/filepath"
+	"quiver/config"
+	"strconv"
+	"testing"
+	"time"
+)
+
+const testSecret = "peer-secret"
+
+// loadConfig 用临时配置文件加载全局配置
+func loadConfig(t *testing.T, yaml string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"env":"dev"}`)
+	sig := Sign(testSecret, "1700000000", body)
+	if sig != Sign(testSecret, "1700000000", body) {
+		t.Fatal("signature is not deterministic")
+	}
+	if len(sig) != 64 {
+		t.Fatalf("signature length = %d, want 64 hex chars", len(sig))
+	}
+
+	// 时间戳、body、密钥任一变化签名都不同
+	for name, other := range map[string]string{
+		"timestamp": Sign(testSecret, "1700000001", body),
+		"body":      Sign(testSecret, "1700000000", []byte(`{"env":"pro"}`)),
+		"secret":    Sign("other", "1700000000", body),
+	} {
+		if other == sig {
+			t.Errorf("changing %s does not change the signature", name)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	loadConfig(t, "cluster:\n  secret: "+testSecret+"\n")
+
+	body := []byte(`{"origin":"http://10.0.0.1:8080","env":"dev","time":1700000000000}`)
+	at := func(offset time.Duration) string {
+		return strconv.FormatInt(time.Now().Add(offset).Unix(), 10)
+	}
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature func(ts string) string
+		body      []byte
+		wantErr   bool
+	}{
+		{"valid", at(0), func(ts string) string { return Sign(testSecret, ts, body) }, body, false},
+		{"small skew in the past", at(-4 * time.Minute), func(ts string) string { return Sign(testSecret, ts, body) }, body, false},
+		{"small skew in the future", at(4 * time.Minute), func(ts string) string { return Sign(testSecret, ts, body) }, body, false},
+		{"expired", at(-6 * time.Minute), func(ts string) string { return Sign(testSecret, ts, body) }, body, true},
+		{"too far in the future", at(6 * time.Minute), func(ts string) string { return Sign(testSecret, ts, body) }, body, true},
+		{"tampered body", at(0), func(ts string) string { return Sign(testSecret, ts, body) }, []byte(`{"env":"pro"}`), true},
+		{"wrong secret", at(0), func(ts string) string { return Sign("other", ts, body) }, body, true},
+		{"signature for another timestamp", at(0), func(string) string { return Sign(testSecret, at(-time.Minute), body) }, body, true},
+		{"invalid timestamp", "yesterday", func(ts string) string { return Sign(testSecret, ts, body) }, body, true},
+		{"empty signature", at(0), func(string) string { return "" }, body, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.timestamp, tt.signature(tt.timestamp), tt.body)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyWithoutSecret(t *testing.T) {
+	loadConfig(t, "cluster:\n  peers: [http://10.0.0.2:8080]\n")
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	body := []byte(`{}`)
+	// 未配置密钥时拒绝所有请求，即使签名用空密钥算出
+	if err := Verify(ts, Sign("", ts, body), body); err == nil {
+		t.Fatal("Verify() accepted a request while peer notify is disabled")
+	}
+}
@@ -0,0 +1,679 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: quiver/v1/config.proto
+
+package quiverv1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Item 配置项
+type Item struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Value         string                 `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Item) Reset() {
+	*x = Item{}
+	mi := &file_quiver_v1_config_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Item) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Item) ProtoMessage() {}
+
+func (x *Item) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Item.ProtoReflect.Descriptor instead.
+func (*Item) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Item) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Item) GetValue() string {
+	if x != nil {
+		return x.Value
+	}
+	return ""
+}
+
+// ChangeSet 最新版本相对客户端当前版本变化的 key
+type ChangeSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Added         []string               `protobuf:"bytes,1,rep,name=added,proto3" json:"added,omitempty"`
+	Updated       []string               `protobuf:"bytes,2,rep,name=updated,proto3" json:"updated,omitempty"`
+	Deleted       []string               `protobuf:"bytes,3,rep,name=deleted,proto3" json:"deleted,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ChangeSet) Reset() {
+	*x = ChangeSet{}
+	mi := &file_quiver_v1_config_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ChangeSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ChangeSet) ProtoMessage() {}
+
+func (x *ChangeSet) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ChangeSet.ProtoReflect.Descriptor instead.
+func (*ChangeSet) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ChangeSet) GetAdded() []string {
+	if x != nil {
+		return x.Added
+	}
+	return nil
+}
+
+func (x *ChangeSet) GetUpdated() []string {
+	if x != nil {
+		return x.Updated
+	}
+	return nil
+}
+
+func (x *ChangeSet) GetDeleted() []string {
+	if x != nil {
+		return x.Deleted
+	}
+	return nil
+}
+
+// Config 命名空间的最新版本，items 只包含新增和修改的配置项
+type Config struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Env           string                 `protobuf:"bytes,1,opt,name=env,proto3" json:"env,omitempty"`
+	AppName       string                 `protobuf:"bytes,2,opt,name=app_name,json=appName,proto3" json:"app_name,omitempty"`
+	ClusterName   string                 `protobuf:"bytes,3,opt,name=cluster_name,json=clusterName,proto3" json:"cluster_name,omitempty"`
+	NamespaceName string                 `protobuf:"bytes,4,opt,name=namespace_name,json=namespaceName,proto3" json:"namespace_name,omitempty"`
+	ReleaseId     string                 `protobuf:"bytes,5,opt,name=release_id,json=releaseId,proto3" json:"release_id,omitempty"`
+	ReleaseName   string                 `protobuf:"bytes,6,opt,name=release_name,json=releaseName,proto3" json:"release_name,omitempty"`
+	ReleaseTime   *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=release_time,json=releaseTime,proto3" json:"release_time,omitempty"`
+	Operator      string                 `protobuf:"bytes,8,opt,name=operator,proto3" json:"operator,omitempty"`
+	Comment       string                 `protobuf:"bytes,9,opt,name=comment,proto3" json:"comment,omitempty"`
+	Items         []*Item                `protobuf:"bytes,10,rep,name=items,proto3" json:"items,omitempty"`
+	// 请求中带有 release_id 时返回
+	Changed *ChangeSet `protobuf:"bytes,11,opt,name=changed,proto3" json:"changed,omitempty"`
+	// 由其他集群的哪个版本提升而来
+	SourceReleaseId string `protobuf:"bytes,12,opt,name=source_release_id,json=sourceReleaseId,proto3" json:"source_release_id,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *Config) Reset() {
+	*x = Config{}
+	mi := &file_quiver_v1_config_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Config) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Config) ProtoMessage() {}
+
+func (x *Config) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Config.ProtoReflect.Descriptor instead.
+func (*Config) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Config) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+func (x *Config) GetAppName() string {
+	if x != nil {
+		return x.AppName
+	}
+	return ""
+}
+
+func (x *Config) GetClusterName() string {
+	if x != nil {
+		return x.ClusterName
+	}
+	return ""
+}
+
+func (x *Config) GetNamespaceName() string {
+	if x != nil {
+		return x.NamespaceName
+	}
+	return ""
+}
+
+func (x *Config) GetReleaseId() string {
+	if x != nil {
+		return x.ReleaseId
+	}
+	return ""
+}
+
+func (x *Config) GetReleaseName() string {
+	if x != nil {
+		return x.ReleaseName
+	}
+	return ""
+}
+
+func (x *Config) GetReleaseTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReleaseTime
+	}
+	return nil
+}
+
+func (x *Config) GetOperator() string {
+	if x != nil {
+		return x.Operator
+	}
+	return ""
+}
+
+func (x *Config) GetComment() string {
+	if x != nil {
+		return x.Comment
+	}
+	return ""
+}
+
+func (x *Config) GetItems() []*Item {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+func (x *Config) GetChanged() *ChangeSet {
+	if x != nil {
+		return x.Changed
+	}
+	return nil
+}
+
+func (x *Config) GetSourceReleaseId() string {
+	if x != nil {
+		return x.SourceReleaseId
+	}
+	return ""
+}
+
+type GetConfigRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Env           string                 `protobuf:"bytes,1,opt,name=env,proto3" json:"env,omitempty"`
+	AppName       string                 `protobuf:"bytes,2,opt,name=app_name,json=appName,proto3" json:"app_name,omitempty"`
+	ClusterName   string                 `protobuf:"bytes,3,opt,name=cluster_name,json=clusterName,proto3" json:"cluster_name,omitempty"`
+	NamespaceName string                 `protobuf:"bytes,4,opt,name=namespace_name,json=namespaceName,proto3" json:"namespace_name,omitempty"`
+	// 客户端当前的版本，为空时返回完整配置
+	ReleaseId     string `protobuf:"bytes,5,opt,name=release_id,json=releaseId,proto3" json:"release_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigRequest) Reset() {
+	*x = GetConfigRequest{}
+	mi := &file_quiver_v1_config_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigRequest) ProtoMessage() {}
+
+func (x *GetConfigRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigRequest.ProtoReflect.Descriptor instead.
+func (*GetConfigRequest) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetConfigRequest) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+func (x *GetConfigRequest) GetAppName() string {
+	if x != nil {
+		return x.AppName
+	}
+	return ""
+}
+
+func (x *GetConfigRequest) GetClusterName() string {
+	if x != nil {
+		return x.ClusterName
+	}
+	return ""
+}
+
+func (x *GetConfigRequest) GetNamespaceName() string {
+	if x != nil {
+		return x.NamespaceName
+	}
+	return ""
+}
+
+func (x *GetConfigRequest) GetReleaseId() string {
+	if x != nil {
+		return x.ReleaseId
+	}
+	return ""
+}
+
+type GetConfigResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Config        *Config                `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetConfigResponse) Reset() {
+	*x = GetConfigResponse{}
+	mi := &file_quiver_v1_config_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetConfigResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetConfigResponse) ProtoMessage() {}
+
+func (x *GetConfigResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetConfigResponse.ProtoReflect.Descriptor instead.
+func (*GetConfigResponse) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *GetConfigResponse) GetConfig() *Config {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+// WatchNamespace 订阅的命名空间，release_id 为客户端当前的版本
+type WatchNamespace struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ClusterName   string                 `protobuf:"bytes,1,opt,name=cluster_name,json=clusterName,proto3" json:"cluster_name,omitempty"`
+	NamespaceName string                 `protobuf:"bytes,2,opt,name=namespace_name,json=namespaceName,proto3" json:"namespace_name,omitempty"`
+	ReleaseId     string                 `protobuf:"bytes,3,opt,name=release_id,json=releaseId,proto3" json:"release_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchNamespace) Reset() {
+	*x = WatchNamespace{}
+	mi := &file_quiver_v1_config_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchNamespace) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchNamespace) ProtoMessage() {}
+
+func (x *WatchNamespace) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchNamespace.ProtoReflect.Descriptor instead.
+func (*WatchNamespace) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *WatchNamespace) GetClusterName() string {
+	if x != nil {
+		return x.ClusterName
+	}
+	return ""
+}
+
+func (x *WatchNamespace) GetNamespaceName() string {
+	if x != nil {
+		return x.NamespaceName
+	}
+	return ""
+}
+
+func (x *WatchNamespace) GetReleaseId() string {
+	if x != nil {
+		return x.ReleaseId
+	}
+	return ""
+}
+
+type WatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Env           string                 `protobuf:"bytes,1,opt,name=env,proto3" json:"env,omitempty"`
+	AppName       string                 `protobuf:"bytes,2,opt,name=app_name,json=appName,proto3" json:"app_name,omitempty"`
+	Namespaces    []*WatchNamespace      `protobuf:"bytes,3,rep,name=namespaces,proto3" json:"namespaces,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_quiver_v1_config_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchRequest) GetEnv() string {
+	if x != nil {
+		return x.Env
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetAppName() string {
+	if x != nil {
+		return x.AppName
+	}
+	return ""
+}
+
+func (x *WatchRequest) GetNamespaces() []*WatchNamespace {
+	if x != nil {
+		return x.Namespaces
+	}
+	return nil
+}
+
+// WatchEvent 某个命名空间有新的版本，config 相对该命名空间上一次推送（或订阅时）的版本
+type WatchEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Config        *Config                `protobuf:"bytes,1,opt,name=config,proto3" json:"config,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchEvent) Reset() {
+	*x = WatchEvent{}
+	mi := &file_quiver_v1_config_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchEvent) ProtoMessage() {}
+
+func (x *WatchEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_quiver_v1_config_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchEvent.ProtoReflect.Descriptor instead.
+func (*WatchEvent) Descriptor() ([]byte, []int) {
+	return file_quiver_v1_config_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *WatchEvent) GetConfig() *Config {
+	if x != nil {
+		return x.Config
+	}
+	return nil
+}
+
+var File_quiver_v1_config_proto protoreflect.FileDescriptor
+
+const file_quiver_v1_config_proto_rawDesc = "" +
+	"\n" +
+	"\x16quiver/v1/config.proto\x12\tquiver.v1\x1a\x1fgoogle/protobuf/timestamp.proto\".\n" +
+	"\x04Item\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value\"U\n" +
+	"\tChangeSet\x12\x14\n" +
+	"\x05added\x18\x01 \x03(\tR\x05added\x12\x18\n" +
+	"\aupdated\x18\x02 \x03(\tR\aupdated\x12\x18\n" +
+	"\adeleted\x18\x03 \x03(\tR\adeleted\"\xb9\x03\n" +
+	"\x06Config\x12\x10\n" +
+	"\x03env\x18\x01 \x01(\tR\x03env\x12\x19\n" +
+	"\bapp_name\x18\x02 \x01(\tR\aappName\x12!\n" +
+	"\fcluster_name\x18\x03 \x01(\tR\vclusterName\x12%\n" +
+	"\x0enamespace_name\x18\x04 \x01(\tR\rnamespaceName\x12\x1d\n" +
+	"\n" +
+	"release_id\x18\x05 \x01(\tR\treleaseId\x12!\n" +
+	"\frelease_name\x18\x06 \x01(\tR\vreleaseName\x12=\n" +
+	"\frelease_time\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\vreleaseTime\x12\x1a\n" +
+	"\boperator\x18\b \x01(\tR\boperator\x12\x18\n" +
+	"\acomment\x18\t \x01(\tR\acomment\x12%\n" +
+	"\x05items\x18\n" +
+	" \x03(\v2\x0f.quiver.v1.ItemR\x05items\x12.\n" +
+	"\achanged\x18\v \x01(\v2\x14.quiver.v1.ChangeSetR\achanged\x12*\n" +
+	"\x11source_release_id\x18\f \x01(\tR\x0fsourceReleaseId\"\xa8\x01\n" +
+	"\x10GetConfigRequest\x12\x10\n" +
+	"\x03env\x18\x01 \x01(\tR\x03env\x12\x19\n" +
+	"\bapp_name\x18\x02 \x01(\tR\aappName\x12!\n" +
+	"\fcluster_name\x18\x03 \x01(\tR\vclusterName\x12%\n" +
+	"\x0enamespace_name\x18\x04 \x01(\tR\rnamespaceName\x12\x1d\n" +
+	"\n" +
+	"release_id\x18\x05 \x01(\tR\treleaseId\">\n" +
+	"\x11GetConfigResponse\x12)\n" +
+	"\x06config\x18\x01 \x01(\v2\x11.quiver.v1.ConfigR\x06config\"y\n" +
+	"\x0eWatchNamespace\x12!\n" +
+	"\fcluster_name\x18\x01 \x01(\tR\vclusterName\x12%\n" +
+	"\x0enamespace_name\x18\x02 \x01(\tR\rnamespaceName\x12\x1d\n" +
+	"\n" +
+	"release_id\x18\x03 \x01(\tR\treleaseId\"v\n" +
+	"\fWatchRequest\x12\x10\n" +
+	"\x03env\x18\x01 \x01(\tR\x03env\x12\x19\n" +
+	"\bapp_name\x18\x02 \x01(\tR\aappName\x129\n" +
+	"\n" +
+	"namespaces\x18\x03 \x03(\v2\x19.quiver.v1.WatchNamespaceR\n" +
+	"namespaces\"7\n" +
+	"\n" +
+	"WatchEvent\x12)\n" +
+	"\x06config\x18\x01 \x01(\v2\x11.quiver.v1.ConfigR\x06config2\x92\x01\n" +
+	"\rConfigService\x12F\n" +
+	"\tGetConfig\x12\x1b.quiver.v1.GetConfigRequest\x1a\x1c.quiver.v1.GetConfigResponse\x129\n" +
+	"\x05Watch\x12\x17.quiver.v1.WatchRequest\x1a\x15.quiver.v1.WatchEvent0\x01B!Z\x1fquiver/proto/quiver/v1;quiverv1b\x06proto3"
+
+var (
+	file_quiver_v1_config_proto_rawDescOnce sync.Once
+	file_quiver_v1_config_proto_rawDescData []byte
+)
+
+func file_quiver_v1_config_proto_rawDescGZIP() []byte {
+	file_quiver_v1_config_proto_rawDescOnce.Do(func() {
+		file_quiver_v1_config_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_quiver_v1_config_proto_rawDesc), len(file_quiver_v1_config_proto_rawDesc)))
+	})
+	return file_quiver_v1_config_proto_rawDescData
+}
+
+var file_quiver_v1_config_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
+var file_quiver_v1_config_proto_goTypes = []any{
+	(*Item)(nil),                  // 0: quiver.v1.Item
+	(*ChangeSet)(nil),             // 1: quiver.v1.ChangeSet
+	(*Config)(nil),                // 2: quiver.v1.Config
+	(*GetConfigRequest)(nil),      // 3: quiver.v1.GetConfigRequest
+	(*GetConfigResponse)(nil),     // 4: quiver.v1.GetConfigResponse
+	(*WatchNamespace)(nil),        // 5: quiver.v1.WatchNamespace
+	(*WatchRequest)(nil),          // 6: quiver.v1.WatchRequest
+	(*WatchEvent)(nil),            // 7: quiver.v1.WatchEvent
+	(*timestamppb.Timestamp)(nil), // 8: google.protobuf.Timestamp
+}
+var file_quiver_v1_config_proto_depIdxs = []int32{
+	8, // 0: quiver.v1.Config.release_time:type_name -> google.protobuf.Timestamp
+	0, // 1: quiver.v1.Config.items:type_name -> quiver.v1.Item
+	1, // 2: quiver.v1.Config.changed:type_name -> quiver.v1.ChangeSet
+	2, // 3: quiver.v1.GetConfigResponse.config:type_name -> quiver.v1.Config
+	5, // 4: quiver.v1.WatchRequest.namespaces:type_name -> quiver.v1.WatchNamespace
+	2, // 5: quiver.v1.WatchEvent.config:type_name -> quiver.v1.Config
+	3, // 6: quiver.v1.ConfigService.GetConfig:input_type -> quiver.v1.GetConfigRequest
+	6, // 7: quiver.v1.ConfigService.Watch:input_type -> quiver.v1.WatchRequest
+	4, // 8: quiver.v1.ConfigService.GetConfig:output_type -> quiver.v1.GetConfigResponse
+	7, // 9: quiver.v1.ConfigService.Watch:output_type -> quiver.v1.WatchEvent
+	8, // [8:10] is the sub-list for method output_type
+	6, // [6:8] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_quiver_v1_config_proto_init() }
+func file_quiver_v1_config_proto_init() {
+	if File_quiver_v1_config_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_quiver_v1_config_proto_rawDesc), len(file_quiver_v1_config_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   8,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_quiver_v1_config_proto_goTypes,
+		DependencyIndexes: file_quiver_v1_config_proto_depIdxs,
+		MessageInfos:      file_quiver_v1_config_proto_msgTypes,
+	}.Build()
+	File_quiver_v1_config_proto = out.File
+	file_quiver_v1_config_proto_goTypes = nil
+	file_quiver_v1_config_proto_depIdxs = nil
+}
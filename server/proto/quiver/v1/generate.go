@@ -0,0 +1,4 @@
+package quiverv1
+
+// 修改 config.proto 后在本目录执行 go generate 重新生成：
+//go:generate protoc -I ../.. --go_out=../../.. --go_opt=module=quiver --go-grpc_out=../../.. --go-grpc_opt=module=quiver ../../quiver/v1/config.proto
@@ -0,0 +1,199 @@
+package render
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Parse 把配置文件解析为配置项，是 Render 的逆过程，支持 properties、yaml 和 json。
+// yaml、json 的嵌套映射按 . 展开为 key，标量按原文作为字符串值；列表、null 和重复的 key 返回错误
+func Parse(f Format, data []byte) ([]Item, error) {
+	if !utf8.Valid(data) {
+		return nil, errors.New("content is not valid UTF-8")
+	}
+
+	var items []Item
+	var err error
+	switch f {
+	case FormatProperties:
+		items, err = parseProperties(string(data))
+	case FormatYAML, FormatJSON:
+		items, err = parseYAML(data)
+	default:
+		return nil, fmt.Errorf("format %s cannot be parsed", f)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Key < items[j].Key })
+	for i := 1; i < len(items); i++ {
+		if items[i].Key == items[i-1].Key {
+			return nil, fmt.Errorf("duplicate key %s", items[i].Key)
+		}
+	}
+	return items, nil
+}
+
+// parseProperties 按 java.util.Properties 的规则解析：# 和 ! 开头为注释，行尾奇数个 \ 续行，
+// key 以第一个未转义的 =、: 或空白结束，支持 \t \n \r \f \uXXXX 转义
+func parseProperties(s string) ([]Item, error) {
+	var items []Item
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNo := i + 1
+		line := strings.TrimLeft(lines[i], " \t\f")
+		if line == "" || line[0] == '#' || line[0] == '!' {
+			continue
+		}
+		for continues(line) && i+1 < len(lines) {
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(lines[i], " \t\f")
+		}
+		if continues(line) {
+			line = line[:len(line)-1]
+		}
+
+		end := 0
+		for end < len(line) {
+			c := line[end]
+			if c == '\\' {
+				end += 2
+				continue
+			}
+			if c == '=' || c == ':' || c == ' ' || c == '\t' || c == '\f' {
+				break
+			}
+			end++
+		}
+		end = min(end, len(line))
+		rawKey, rest := line[:end], strings.TrimLeft(line[end:], " \t\f")
+		if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+			rest = strings.TrimLeft(rest[1:], " \t\f")
+		}
+
+		key, err := unescapeProperties(rawKey)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		value, err := unescapeProperties(rest)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		items = append(items, Item{Key: key, Value: value})
+	}
+	return items, nil
+}
+
+// continues 行尾是否有奇数个反斜杠
+func continues(line string) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func unescapeProperties(s string) (string, error) {
+	if !strings.Contains(s, `\`) {
+		return s, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 'f':
+			b.WriteByte('\f')
+		case 'u':
+			if i+5 > len(s) {
+				return "", errors.New(`malformed \uXXXX escape`)
+			}
+			r, err := strconv.ParseUint(s[i+1:i+5], 16, 16)
+			if err != nil {
+				return "", errors.New(`malformed \uXXXX escape`)
+			}
+			b.WriteRune(rune(r))
+			i += 4
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// yamlNode 映射或标量，标量保留原文，3306、true、0x1F 都不会被转换；set 为 false 时为 null
+type yamlNode struct {
+	children map[string]yamlNode
+	value    string
+	set      bool
+}
+
+func (n *yamlNode) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var children map[string]yamlNode
+	if err := unmarshal(&children); err == nil {
+		n.children, n.set = children, true
+		if n.children == nil {
+			n.children = map[string]yamlNode{}
+		}
+		return nil
+	}
+	if err := unmarshal(&n.value); err != nil {
+		return errors.New("lists are not supported, values must be mappings or scalars")
+	}
+	n.set = true
+	return nil
+}
+
+// UnmarshalText yaml.v2 对 "null"、"~" 这样的字符串不调用 UnmarshalYAML，带引号时按字符串在这里接收
+func (n *yamlNode) UnmarshalText(text []byte) error {
+	n.value, n.set = string(text), true
+	return nil
+}
+
+func parseYAML(data []byte) ([]Item, error) {
+	var root map[string]yamlNode
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, err
+	}
+	var items []Item
+	if err := flatten("", root, &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+func flatten(prefix string, children map[string]yamlNode, items *[]Item) error {
+	for k, child := range children {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch {
+		case !child.set:
+			return fmt.Errorf("key %s has no value", key)
+		case child.children != nil:
+			if err := flatten(key, child.children, items); err != nil {
+				return err
+			}
+		default:
+			*items = append(*items, Item{Key: key, Value: child.value})
+		}
+	}
+	return nil
+}
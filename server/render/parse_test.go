@@ -0,0 +1,72 @@
+package render
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Item
+		wantErr string
+	}{
+		{
+			name: "nested mappings and raw scalars",
+			data: "app:\n  port: 3306\n  debug: true\n  mask: 0x1F\n  name: demo\n",
+			want: []Item{{"app.debug", "true"}, {"app.mask", "0x1F"}, {"app.name", "demo"}, {"app.port", "3306"}},
+		},
+		{
+			name: "quoted null-like strings",
+			data: "a: \"null\"\nb: '~'\nc: \"\"\nd: 'Null'\n",
+			want: []Item{{"a", "null"}, {"b", "~"}, {"c", ""}, {"d", "Null"}},
+		},
+		{
+			name: "json",
+			data: `{"a": {"b": "1"}, "c": "null"}`,
+			want: []Item{{"a.b", "1"}, {"c", "null"}},
+		},
+		{name: "null value", data: "a: null\n", wantErr: "key a has no value"},
+		{name: "tilde value", data: "a:\n  b: ~\n", wantErr: "key a.b has no value"},
+		{name: "empty value", data: "a:\n", wantErr: "key a has no value"},
+		{name: "list", data: "a: [1, 2]\n", wantErr: "lists are not supported"},
+		{name: "duplicate flattened key", data: "a.b: 1\na:\n  b: 2\n", wantErr: "duplicate key a.b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(FormatYAML, []byte(tt.data))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Parse() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Parse() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 值为 "null"、"~" 的配置项渲染为 yaml、json 后可以解析回来
+func TestRenderParseNullStrings(t *testing.T) {
+	items := []Item{{"a", "null"}, {"b", "~"}, {"c.d", "NULL"}}
+	for _, f := range []Format{FormatYAML, FormatJSON} {
+		out, err := Render(f, items, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Parse(f, out)
+		if err != nil {
+			t.Fatalf("Parse(%s) error = %v\n%s", f, err, out)
+		}
+		if !reflect.DeepEqual(got, items) {
+			t.Fatalf("Parse(%s) = %q, want %q", f, got, items)
+		}
+	}
+}
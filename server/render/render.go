@@ -0,0 +1,337 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Format 渲染的文件格式
+type Format string
+
+const (
+	FormatProperties Format = "properties"
+	FormatEnv        Format = "env"
+	FormatYAML       Format = "yaml"
+	FormatJSON       Format = "json"
+	FormatTOML       Format = "toml"
+	FormatINI        Format = "ini"
+)
+
+var contentTypes = map[Format]string{
+	FormatProperties: "text/x-java-properties; charset=utf-8",
+	FormatEnv:        "text/plain; charset=utf-8",
+	FormatYAML:       "application/yaml; charset=utf-8",
+	FormatJSON:       "application/json; charset=utf-8",
+	FormatTOML:       "application/toml; charset=utf-8",
+	FormatINI:        "text/plain; charset=utf-8",
+}
+
+// Lookup 按名称查找格式，yml 等同于 yaml
+func Lookup(name string) (Format, bool) {
+	f := Format(strings.ToLower(name))
+	if f == "yml" {
+		f = FormatYAML
+	}
+	_, ok := contentTypes[f]
+	return f, ok
+}
+
+// ContentType 响应的 Content-Type
+func (f Format) ContentType() string {
+	return contentTypes[f]
+}
+
+// Item 待渲染的配置项
+type Item struct {
+	Key   string
+	Value string
+}
+
+// Render 把配置项渲染为文件内容，配置项按 key 排序，同样的输入总是得到完全相同的输出。
+// section 只用于 ini 格式
+func Render(f Format, items []Item, section string) ([]byte, error) {
+	sorted := make([]Item, len(items))
+	copy(sorted, items)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Key < sorted[j].Key })
+
+	switch f {
+	case FormatProperties:
+		return renderProperties(sorted), nil
+	case FormatEnv:
+		return renderEnv(sorted)
+	case FormatYAML:
+		return renderYAML(sorted)
+	case FormatJSON:
+		return renderJSON(sorted)
+	case FormatTOML:
+		return renderTOML(sorted)
+	case FormatINI:
+		return renderINI(sorted, section), nil
+	}
+	return nil, fmt.Errorf("unsupported format %s", f)
+}
+
+// renderProperties Java properties，key 中的分隔符、值开头的空白以及换行等控制字符都转义
+func renderProperties(items []Item) []byte {
+	var buf bytes.Buffer
+	for _, item := range items {
+		buf.WriteString(escapeProperties(item.Key, true))
+		buf.WriteByte('=')
+		buf.WriteString(escapeProperties(item.Value, false))
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes()
+}
+
+func escapeProperties(s string, key bool) string {
+	var b strings.Builder
+	for i, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\f':
+			b.WriteString(`\f`)
+		case ' ':
+			if key || i == 0 {
+				b.WriteString(`\ `)
+			} else {
+				b.WriteRune(r)
+			}
+		case '=', ':', '#', '!':
+			if key || i == 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+var envSafeValue = regexp.MustCompile(`^[A-Za-z0-9_./:@%+,=-]*$`)
+
+// renderEnv KEY=value，key 转为大写并把非字母数字替换为下划线；值需要时用单引号，可以直接被 shell source
+func renderEnv(items []Item) ([]byte, error) {
+	var buf bytes.Buffer
+	seen := make(map[string]string, len(items))
+	for _, item := range items {
+		name := envName(item.Key)
+		if other, ok := seen[name]; ok {
+			return nil, fmt.Errorf("keys %s and %s both map to %s", other, item.Key, name)
+		}
+		seen[name] = item.Key
+
+		buf.WriteString(name)
+		buf.WriteByte('=')
+		if envSafeValue.MatchString(item.Value) {
+			buf.WriteString(item.Value)
+		} else {
+			buf.WriteString("'" + strings.ReplaceAll(item.Value, "'", `'\''`) + "'")
+		}
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func envName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_') {
+			b[i] = '_'
+		}
+	}
+	if len(b) > 0 && b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+// node 按 . 展开后的树，叶子节点 leaf 为 true
+type node struct {
+	leaf     bool
+	value    string
+	children map[string]*node
+}
+
+// buildTree 按 . 把 key 展开为嵌套结构，a 与 a.b 同时存在时返回错误
+func buildTree(items []Item) (*node, error) {
+	root := &node{children: map[string]*node{}}
+	owner := map[*node]string{}
+	for _, item := range items {
+		n := root
+		parts := strings.Split(item.Key, ".")
+		for i, part := range parts {
+			if part == "" {
+				return nil, fmt.Errorf("key %s has an empty segment", item.Key)
+			}
+			child, ok := n.children[part]
+			if !ok {
+				child = &node{children: map[string]*node{}}
+				n.children[part] = child
+				owner[child] = item.Key
+			}
+			last := i == len(parts)-1
+			if child.leaf || (last && len(child.children) > 0) {
+				return nil, fmt.Errorf("key %s conflicts with %s", item.Key, owner[child])
+			}
+			if last {
+				child.leaf, child.value = true, item.Value
+			}
+			n = child
+		}
+	}
+	return root, nil
+}
+
+func sortedKeys(m map[string]*node) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var plainKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// renderYAML 按 . 展开为嵌套映射，值都输出为双引号字符串，不会被解析为数字、布尔等类型
+func renderYAML(items []Item) ([]byte, error) {
+	root, err := buildTree(items)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if len(root.children) == 0 {
+		buf.WriteString("{}\n")
+		return buf.Bytes(), nil
+	}
+	writeYAML(&buf, root, 0)
+	return buf.Bytes(), nil
+}
+
+func writeYAML(buf *bytes.Buffer, n *node, depth int) {
+	for _, k := range sortedKeys(n.children) {
+		child := n.children[k]
+		buf.WriteString(strings.Repeat("  ", depth))
+		if plainKey.MatchString(k) && !yamlReserved(k) {
+			buf.WriteString(k)
+		} else {
+			buf.WriteString(quote(k))
+		}
+		if child.leaf {
+			buf.WriteString(": " + quote(child.value) + "\n")
+			continue
+		}
+		buf.WriteString(":\n")
+		writeYAML(buf, child, depth+1)
+	}
+}
+
+// yamlReserved 作为 key 时会被 YAML 1.1 解析为布尔、null 或数字的写法
+func yamlReserved(k string) bool {
+	switch strings.ToLower(k) {
+	case "y", "n", "yes", "no", "on", "off", "true", "false", "null", "~":
+		return true
+	}
+	return k[0] == '-' || (k[0] >= '0' && k[0] <= '9')
+}
+
+// renderJSON 平铺的 JSON 对象，key 按字典序
+func renderJSON(items []Item) ([]byte, error) {
+	m := make(map[string]string, len(items))
+	for _, item := range items {
+		m[item.Key] = item.Value
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(m); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// renderTOML 使用 dotted key 表示嵌套，值都是字符串
+func renderTOML(items []Item) ([]byte, error) {
+	if _, err := buildTree(items); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, item := range items {
+		parts := strings.Split(item.Key, ".")
+		for i, part := range parts {
+			if !plainKey.MatchString(part) {
+				parts[i] = quote(part)
+			}
+		}
+		buf.WriteString(strings.Join(parts, ".") + " = " + quote(item.Value) + "\n")
+	}
+	return buf.Bytes(), nil
+}
+
+var iniKey = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// renderINI 所有配置项放在一个 section 中；key 含字母数字和 _ . - 以外的字符时加双引号，
+// 值包含换行、引号、注释符或首尾空白时加双引号
+func renderINI(items []Item, section string) []byte {
+	var buf bytes.Buffer
+	if section != "" {
+		buf.WriteString("[" + section + "]\n")
+	}
+	for _, item := range items {
+		key := item.Key
+		if !iniKey.MatchString(key) {
+			key = quote(key)
+		}
+		value := item.Value
+		if value != strings.TrimSpace(value) || strings.ContainsAny(value, "\"'\n\r;#\\") {
+			value = quote(value)
+		}
+		buf.WriteString(key + " = " + value + "\n")
+	}
+	return buf.Bytes()
+}
+
+// quote 输出双引号字符串，同时是合法的 JSON、YAML 和 TOML 字符串：
+// 转义反斜杠、双引号和不可打印字符，其他 UTF-8 字符原样输出
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		i += size
+		switch {
+		case r == '\\':
+			b.WriteString(`\\`)
+		case r == '"':
+			b.WriteString(`\"`)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r == utf8.RuneError && size == 1:
+			b.WriteString(`\ufffd`)
+		case r < 0x20 || (r >= 0x7f && r <= 0x9f) || r == 0xfeff:
+			fmt.Fprintf(&b, `\u%04x`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,217 @@
+package render
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	for name, want := range map[string]Format{
+		"properties": FormatProperties,
+		"YAML":       FormatYAML,
+		"yml":        FormatYAML,
+		"ini":        FormatINI,
+	} {
+		if got, ok := Lookup(name); !ok || got != want {
+			t.Errorf("Lookup(%q) = %s, %v, want %s", name, got, ok, want)
+		}
+	}
+	if _, ok := Lookup("xml"); ok {
+		t.Error("Lookup(xml) found an unsupported format")
+	}
+}
+
+// 每种格式都按 key 排序输出，与输入顺序无关
+func TestRenderSorted(t *testing.T) {
+	items := []Item{
+		{Key: "server.port", Value: "8080"},
+		{Key: "app.name", Value: "demo"},
+		{Key: "db.url", Value: "mysql://db:3306/demo"},
+		{Key: "app.debug", Value: "true"},
+	}
+	reversed := make([]Item, len(items))
+	for i, item := range items {
+		reversed[len(items)-1-i] = item
+	}
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{FormatProperties, "app.debug=true\napp.name=demo\ndb.url=mysql://db:3306/demo\nserver.port=8080\n"},
+		{FormatEnv, "APP_DEBUG=true\nAPP_NAME=demo\nDB_URL=mysql://db:3306/demo\nSERVER_PORT=8080\n"},
+		{FormatYAML, "app:\n  debug: \"true\"\n  name: \"demo\"\ndb:\n  url: \"mysql://db:3306/demo\"\nserver:\n  port: \"8080\"\n"},
+		{FormatJSON, "{\n  \"app.debug\": \"true\",\n  \"app.name\": \"demo\",\n  \"db.url\": \"mysql://db:3306/demo\",\n  \"server.port\": \"8080\"\n}\n"},
+		{FormatTOML, "app.debug = \"true\"\napp.name = \"demo\"\ndb.url = \"mysql://db:3306/demo\"\nserver.port = \"8080\"\n"},
+		{FormatINI, "[demo]\napp.debug = true\napp.name = demo\ndb.url = mysql://db:3306/demo\nserver.port = 8080\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			got, err := Render(tt.format, items, "demo")
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Render() =\n%s\nwant\n%s", got, tt.want)
+			}
+			again, err := Render(tt.format, reversed, "demo")
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			if !bytes.Equal(got, again) {
+				t.Fatalf("Render() depends on input order:\n%s\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestRenderDoesNotModifyInput(t *testing.T) {
+	items := []Item{{Key: "b", Value: "2"}, {Key: "a", Value: "1"}}
+	if _, err := Render(FormatProperties, items, ""); err != nil {
+		t.Fatal(err)
+	}
+	if items[0].Key != "b" {
+		t.Fatalf("Render() sorted the caller's slice: %v", items)
+	}
+}
+
+func TestRenderPropertiesEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		want string
+	}{
+		{"plain", Item{"a.b", "value"}, "a.b=value\n"},
+		{"separators in key", Item{"a=b:c", "v"}, "a\\=b\\:c=v\n"},
+		{"space in key", Item{"a b", "v"}, "a\\ b=v\n"},
+		{"comment chars in key", Item{"#a!b", "v"}, "\\#a\\!b=v\n"},
+		{"backslash in key", Item{`a\b`, "v"}, "a\\\\b=v\n"},
+		{"newline in key", Item{"a\nb", "v"}, "a\\nb=v\n"},
+		{"separators inside value kept", Item{"k", "x=1 y:2"}, "k=x=1 y:2\n"},
+		{"leading space in value", Item{"k", "  v"}, "k=\\  v\n"},
+		{"leading comment char in value", Item{"k", "#v"}, "k=\\#v\n"},
+		{"control chars in value", Item{"k", "a\tb\r\nc\fd"}, "k=a\\tb\\r\\nc\\fd\n"},
+		{"unicode", Item{"名称", "值"}, "名称=值\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(FormatProperties, []Item{tt.item}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+			// 转义后的内容解析回来与原来相同
+			parsed, err := Parse(FormatProperties, got)
+			if err != nil {
+				t.Fatalf("Parse() error = %v", err)
+			}
+			if want := []Item{tt.item}; !reflect.DeepEqual(parsed, want) {
+				t.Fatalf("Parse() = %q, want %q", parsed, want)
+			}
+		})
+	}
+}
+
+func TestRenderINIEscaping(t *testing.T) {
+	tests := []struct {
+		name string
+		item Item
+		want string
+	}{
+		{"plain", Item{"a.b-c_d", "value"}, "a.b-c_d = value\n"},
+		{"separator in key", Item{"a=b", "v"}, "\"a=b\" = v\n"},
+		{"space in key", Item{"a b", "v"}, "\"a b\" = v\n"},
+		{"comment chars in key", Item{";a#b", "v"}, "\";a#b\" = v\n"},
+		{"section brackets in key", Item{"[a]", "v"}, "\"[a]\" = v\n"},
+		{"newline in key", Item{"a\nb", "v"}, "\"a\\nb\" = v\n"},
+		{"empty value", Item{"k", ""}, "k = \n"},
+		{"surrounding space in value", Item{"k", " v "}, "k = \" v \"\n"},
+		{"comment chars in value", Item{"k", "a;b#c"}, "k = \"a;b#c\"\n"},
+		{"quotes in value", Item{"k", `say "hi"`}, "k = \"say \\\"hi\\\"\"\n"},
+		{"newline in value", Item{"k", "a\nb"}, "k = \"a\\nb\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Render(FormatINI, []Item{tt.item}, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Fatalf("Render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 渲染的 properties、yaml、json 解析回来与原来的配置项相同，值不会被转换为数字、布尔等类型
+func TestRenderRoundTrip(t *testing.T) {
+	items := []Item{
+		{Key: "app.enabled", Value: "yes"},
+		{Key: "app.name", Value: "demo"},
+		{Key: "app.port", Value: "3306"},
+		{Key: "db.password", Value: "p@ss: \"w#rd\"\n"},
+		{Key: "empty", Value: ""},
+		{Key: "true", Value: "0x1F"},
+	}
+	for _, f := range []Format{FormatProperties, FormatYAML, FormatJSON} {
+		t.Run(string(f), func(t *testing.T) {
+			out, err := Render(f, items, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := Parse(f, out)
+			if err != nil {
+				t.Fatalf("Parse() error = %v\n%s", err, out)
+			}
+			if !reflect.DeepEqual(parsed, items) {
+				t.Fatalf("Parse(Render()) = %q, want %q", parsed, items)
+			}
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  Format
+		items   []Item
+		wantErr string
+	}{
+		{"yaml key conflicts with prefix", FormatYAML, []Item{{"a", "1"}, {"a.b", "2"}}, "conflicts with"},
+		{"toml key conflicts with prefix", FormatTOML, []Item{{"a.b", "1"}, {"a", "2"}}, "conflicts with"},
+		{"yaml empty segment", FormatYAML, []Item{{"a..b", "1"}}, "empty segment"},
+		{"env names collide", FormatEnv, []Item{{"a.b", "1"}, {"a-b", "2"}}, "both map to A_B"},
+		{"unsupported format", Format("xml"), nil, "unsupported format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Render(tt.format, tt.items, "")
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Render() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRenderEmpty(t *testing.T) {
+	for f, want := range map[Format]string{
+		FormatProperties: "",
+		FormatEnv:        "",
+		FormatYAML:       "{}\n",
+		FormatJSON:       "{}\n",
+		FormatTOML:       "",
+		FormatINI:        "[demo]\n",
+	} {
+		got, err := Render(f, nil, "demo")
+		if err != nil {
+			t.Fatalf("Render(%s) error = %v", f, err)
+		}
+		if string(got) != want {
+			t.Errorf("Render(%s) = %q, want %q", f, got, want)
+		}
+	}
+}
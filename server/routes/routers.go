@@ -1,14 +1,19 @@
 package routes
 
 import (
+	"quiver/config"
 	"quiver/handler"
 	"quiver/middleware"
+	"quiver/peer"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 // SetupRoutes 设置所有路由
 func SetupRoutes(app *fiber.App) {
+	// 实例间变更广播（HMAC 签名认证，不走限流和登录校验）
+	app.Post(peer.NotifyPath, handler.NewPeerHandler().Notify)
+
 	// API v1 路由组
 	api := app.Group("/api/v1")
 
@@ -21,14 +26,50 @@ func SetupRoutes(app *fiber.App) {
 	//在 envGroup 上应用 EnvMiddleware
 	envGroup.Use(middleware.EnvMiddleware())
 
+	// 不需要登录即可访问的接口，必须在 AuthMiddleware 之前注册，请求在这里处理完不会再经过 AuthMiddleware
+	authHandler := handler.NewAuthHandler()
+	public := envGroup.Group("/auth")
+	{
+		public.Post("/login", authHandler.Login)
+		public.Post("/refresh", authHandler.RefreshToken)
+		public.Get("/oidc/login", authHandler.OIDCLogin)       // 跳转到 OIDC 登录
+		public.Get("/oidc/callback", authHandler.OIDCCallback) // OIDC 回调
+	}
+
+	// 校验登录会话
+	envGroup.Use(middleware.AuthMiddleware(config.GetAuthConfig().Required))
+
 	auth := envGroup.Group("/auth")
 	{
-		authHandler := handler.NewAuthHandler()
-		auth.Post("/login", authHandler.Login)
 		auth.Post("/logout", authHandler.Logout)
-		auth.Post("/refresh", authHandler.RefreshToken)
+		auth.Get("/sessions", authHandler.ListSessions)                 // 查看我的会话
+		auth.Delete("/sessions/:session_id", authHandler.RevokeSession) // 吊销我的某个会话
 	}
 
+	// 运维管理（只允许管理员），作用于处理请求的实例，与 env 无关
+	envAdmin := envGroup.Group("/admin", middleware.AdminOnly())
+	{
+		logHandler := handler.NewLogHandler()
+		envAdmin.Get("/loggers", logHandler.ListLoggers)       // 查看日志级别
+		envAdmin.Put("/loggers/:name", logHandler.SetLogLevel) // 调整日志级别
+
+		cacheHandler := handler.NewCacheHandler()
+		envAdmin.Get("/cache/stats", cacheHandler.Stats)            // 缓存统计
+		envAdmin.Get("/cache/keys", cacheHandler.ListKeys)          // 按前缀列出 key
+		envAdmin.Delete("/cache/keys", cacheHandler.PurgeKeys)      // 按前缀删除
+		envAdmin.Get("/cache/keys/:key", cacheHandler.GetKey)       // 查看 key
+		envAdmin.Delete("/cache/keys/:key", cacheHandler.DeleteKey) // 删除 key
+		envAdmin.Post("/cache/gc", cacheHandler.RunGC)              // 回收 value log
+	}
+
+	// 发布记录保留策略（只允许管理员）
+	envGroup.Post("/retention/gc", middleware.AdminOnly(), handler.NewRetentionHandler().RunGC)
+	// 发布快照迁移到新格式并检查哈希碰撞（只允许管理员）
+	envGroup.Post("/migrations/releases", middleware.AdminOnly(), handler.NewMigrationHandler().MigrateReleases)
+
+	// 按清单声明式调整应用、集群、命名空间和配置项，默认只返回计划
+	envGroup.Post("/apply", handler.NewApplyHandler().Apply)
+
 	// 用户管理 （只允许管理员）
 	users := envGroup.Group("/users")
 	{
@@ -37,6 +78,8 @@ func SetupRoutes(app *fiber.App) {
 		users.Get("/:user_id", handler.NewUserHandler().GetUser)
 		users.Put("/:user_id", handler.NewUserHandler().UpdateUser)
 		users.Delete("/:user_id", handler.NewUserHandler().DeleteUser)
+		users.Post("/:user_id/sessions/revoke", middleware.AdminOnly(), authHandler.ForceLogout) // 强制下线
+		users.Put("/:user_id/identity", middleware.AdminOnly(), authHandler.LinkIdentity)        // 关联 SSO 外部身份
 	}
 
 	// 权限管理
@@ -67,6 +110,22 @@ func SetupRoutes(app *fiber.App) {
 		apps.Get("/:app_name", AppHandler.GetApp)       // 获取单个应用
 		apps.Put("/:app_name", AppHandler.UpdateApp)    // 更新应用
 		apps.Delete("/:app_name", AppHandler.DeleteApp) // 删除应用
+
+		apps.Post("/:app_name/releases/batch", handler.NewReleaseHandler().BatchGetRelease) // 批量拉取多个命名空间的配置
+	}
+
+	// 配置变更 webhook，订阅整个应用或其中的命名空间
+	webhooks := apps.Group("/:app_name/webhooks")
+	{
+		webhookHandler := handler.NewWebhookHandler()
+		webhooks.Post("/", webhookHandler.CreateWebhook)
+		webhooks.Get("/", webhookHandler.ListWebhooks)
+		webhooks.Get("/:webhook_id", webhookHandler.GetWebhook)
+		webhooks.Put("/:webhook_id", webhookHandler.UpdateWebhook)
+		webhooks.Delete("/:webhook_id", webhookHandler.DeleteWebhook)
+		webhooks.Get("/:webhook_id/deliveries", webhookHandler.ListDeliveries)                      // 投递记录
+		webhooks.Get("/:webhook_id/deliveries/:delivery_id", webhookHandler.GetDelivery)            // 单条投递记录
+		webhooks.Post("/:webhook_id/deliveries/:delivery_id/replay", webhookHandler.ReplayDelivery) // 重新投递
 	}
 
 	// 集群管理路由
@@ -104,9 +163,14 @@ func SetupRoutes(app *fiber.App) {
 	releases := namespaces.Group("/:namespace_name/releases")
 	{
 		releaseHandler := handler.NewReleaseHandler()
-		releases.Post("/", releaseHandler.PublishRelease)       // 创建发布
-		releases.Get("/", releaseHandler.ListReleases)          // 获取发布列表
-		releases.Get("/:release_id", releaseHandler.GetRelease) // 获取发布详情
+		releases.Post("/", releaseHandler.PublishRelease)                      // 创建发布
+		releases.Get("/", releaseHandler.ListReleases)                         // 获取发布列表
+		releases.Get("/:release_id", releaseHandler.GetRelease)                // 获取发布详情
+		releases.Post("/:release_id/promote", releaseHandler.PromoteRelease)   // 提升到其他集群
+		releases.Get("/:release_id/promotions", releaseHandler.ListPromotions) // 提升记录
+
+		// 渲染为配置文件下载
+		namespaces.Get("/:namespace_name/render/:format", releaseHandler.RenderRelease)
 	}
 
 	// 回滚
@@ -116,6 +180,27 @@ func SetupRoutes(app *fiber.App) {
 		rollback.Post("/:release_id", rollbackHandler.RollbackRelease) // 回滚发布
 	}
 
+	// 定时发布、回滚
+	schedules := namespaces.Group("/:namespace_name/schedules")
+	{
+		scheduleHandler := handler.NewScheduleHandler()
+		schedules.Post("/", scheduleHandler.CreateSchedule)               // 创建定时任务
+		schedules.Get("/", scheduleHandler.ListSchedules)                 // 获取定时任务列表
+		schedules.Get("/:schedule_id", scheduleHandler.GetSchedule)       // 获取定时任务详情
+		schedules.Delete("/:schedule_id", scheduleHandler.CancelSchedule) // 取消定时任务
+	}
+
+	// 绑定服务器本地的 Git 仓库
+	git := namespaces.Group("/:namespace_name/git")
+	{
+		gitHandler := handler.NewGitHandler()
+		git.Put("/", gitHandler.Bind)       // 绑定或修改仓库
+		git.Get("/", gitHandler.GetBinding) // 查看绑定
+		git.Delete("/", gitHandler.Unbind)  // 解除绑定
+		git.Post("/pull", gitHandler.Pull)  // 导入草稿区或直接发布
+		git.Get("/drift", gitHandler.Drift) // 仓库与已发布配置的差异
+	}
+
 	// 灰度发布
 	//gray := namespaces.Group("/:namespace_name/gray")
 }
@@ -0,0 +1,41 @@
+package routes
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// 运维接口即使 auth.required 为 false 也必须登录
+func TestAdminRoutesRequireLogin(t *testing.T) {
+	app := fiber.New()
+	SetupRoutes(app)
+
+	tests := []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/v1/envs/dev/admin/loggers"},
+		{http.MethodPut, "/api/v1/envs/dev/admin/loggers/quiver"},
+		{http.MethodGet, "/api/v1/envs/dev/admin/cache/stats"},
+		{http.MethodGet, "/api/v1/envs/dev/admin/cache/keys"},
+		{http.MethodDelete, "/api/v1/envs/dev/admin/cache/keys?prefix=release:"},
+		{http.MethodGet, "/api/v1/envs/dev/admin/cache/keys/release:latest:dev:a:c:n"},
+		{http.MethodDelete, "/api/v1/envs/dev/admin/cache/keys/release:latest:dev:a:c:n"},
+		{http.MethodPost, "/api/v1/envs/dev/admin/cache/gc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest(tt.method, tt.path, nil), -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+-- 升级到支持命名空间绑定 Git 仓库的版本，部署新版本前执行
+
+ALTER TABLE namespace_release
+    ADD COLUMN git_commit VARCHAR(64) NULL AFTER source_release_id;
+
+CREATE TABLE IF NOT EXISTS namespace_git_binding (
+    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_id         BIGINT NOT NULL,
+    app_name       VARCHAR(128) NOT NULL,
+    cluster_id     BIGINT NOT NULL,
+    cluster_name   VARCHAR(128) NOT NULL,
+    namespace_id   BIGINT NOT NULL,
+    namespace_name VARCHAR(128) NOT NULL,
+    repo_path      VARCHAR(1024) NOT NULL,
+    ref            VARCHAR(255) NOT NULL,
+    path           VARCHAR(1024) NOT NULL DEFAULT '',
+    format         VARCHAR(16) NOT NULL,
+    operator       VARCHAR(64) NOT NULL,
+    pulled_commit  VARCHAR(64),
+    pulled_hash    CHAR(64),
+    pulled_by      VARCHAR(64),
+    pulled_time    DATETIME NULL,
+    create_time    DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time    DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uk_namespace_id (namespace_id),
+    FOREIGN KEY (namespace_id) REFERENCES namespace (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='命名空间 Git 仓库绑定表';
@@ -0,0 +1,5 @@
+-- 升级到支持配置项引用（${key}、${namespace:key}）的版本，部署新版本前执行
+
+ALTER TABLE namespace_release
+    ADD COLUMN interpolated TINYINT(1) NOT NULL DEFAULT 0 AFTER source_release_id,
+    ADD COLUMN refs MEDIUMTEXT NULL AFTER interpolated;
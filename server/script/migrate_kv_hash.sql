@@ -0,0 +1,10 @@
+-- 升级到按内容哈希（kv_hash）去重的 item_release
+-- 1. 部署新版本前执行本脚本
+-- 2. 部署后调用 POST /api/v1/envs/{env}/migrations/releases?dry_run=false 补齐 kv_hash 并迁移旧格式发布
+-- 旧的 uk_namespace_kv_id 会让 kv_id 碰撞的配置项在发布时被丢弃，必须删除
+
+ALTER TABLE item_release
+    ADD COLUMN kv_hash CHAR(64) NULL AFTER kv_id,
+    DROP INDEX uk_namespace_kv_id,
+    ADD KEY idx_namespace_kv_id (namespace_id, kv_id),
+    ADD UNIQUE KEY uk_namespace_kv_hash (namespace_id, kv_hash);
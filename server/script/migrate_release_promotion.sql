@@ -0,0 +1,24 @@
+-- 升级到支持跨集群提升版本的版本，部署新版本前执行
+
+ALTER TABLE namespace_release
+    ADD COLUMN source_release_id VARCHAR(64) NULL AFTER comment,
+    ADD KEY idx_source_release_id (source_release_id);
+
+CREATE TABLE IF NOT EXISTS release_promotion (
+    id                  BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_name            VARCHAR(128) NOT NULL,
+    source_release_id   VARCHAR(64) NOT NULL,
+    source_cluster_name VARCHAR(128) NOT NULL,
+    source_namespace    VARCHAR(128) NOT NULL,
+    target_cluster_name VARCHAR(128) NOT NULL,
+    target_namespace    VARCHAR(128) NOT NULL,
+    target_namespace_id BIGINT NOT NULL,
+    mode                VARCHAR(16) NOT NULL,
+    result_release_id   VARCHAR(64),
+    operator            VARCHAR(64) NOT NULL,
+    comment             VARCHAR(1024),
+    create_time         DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    KEY idx_source_release_id (source_release_id),
+    KEY idx_target_namespace_id (target_namespace_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='版本提升记录表';
@@ -0,0 +1,10 @@
+-- 升级到支持 LDAP、OIDC 单点登录的版本，部署新版本前执行
+-- SSO 登录按 external_id 查找用户，不会按用户名关联已有的本地用户，需要由管理员通过 PUT /users/{user_id}/identity 关联
+
+ALTER TABLE user
+    ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'local' AFTER phone,
+    ADD COLUMN external_id VARCHAR(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL AFTER source,
+    ADD UNIQUE KEY uk_source_external_id (source, external_id);
+
+ALTER TABLE permission
+    ADD COLUMN source VARCHAR(16) NOT NULL DEFAULT 'manual' AFTER action;
@@ -0,0 +1,39 @@
+-- 升级到支持配置变更 webhook 的版本，部署新版本前执行
+
+CREATE TABLE IF NOT EXISTS webhook (
+    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_name       VARCHAR(128) NOT NULL,
+    cluster_name   VARCHAR(128) NOT NULL DEFAULT '',
+    namespace_name VARCHAR(128) NOT NULL DEFAULT '',
+    url            VARCHAR(1024) NOT NULL,
+    secret         VARCHAR(128) NOT NULL,
+    events         TEXT,
+    enabled        TINYINT(1) NOT NULL DEFAULT 1,
+    description    VARCHAR(1024),
+    operator       VARCHAR(64) NOT NULL,
+    create_time    DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time    DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY idx_app_name (app_name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='webhook 订阅表';
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id                BIGINT AUTO_INCREMENT PRIMARY KEY,
+    webhook_id        BIGINT NOT NULL,
+    event_id          VARCHAR(64) NOT NULL,
+    event             VARCHAR(32) NOT NULL,
+    payload           MEDIUMTEXT NOT NULL,
+    status            VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts          INT NOT NULL DEFAULT 0,
+    next_attempt_time DATETIME NOT NULL,
+    response_code     INT NOT NULL DEFAULT 0,
+    last_error        VARCHAR(1024),
+    replay_of         BIGINT NOT NULL DEFAULT 0,
+    delivered_time    DATETIME NULL,
+    create_time       DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time       DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY idx_status_next_attempt_time (status, next_attempt_time),
+    KEY idx_webhook_id (webhook_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhook (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COMMENT='webhook 投递记录表';
@@ -14,12 +14,15 @@ CREATE TABLE IF NOT EXISTS user (
     password    VARCHAR(256) NOT NULL,
     email       VARCHAR(128),
     phone       VARCHAR(32),
+    source      VARCHAR(16) NOT NULL DEFAULT 'local', -- local、ldap、oidc
+    external_id VARCHAR(512) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NULL, -- LDAP 用户 DN，OIDC 为 iss#sub；本地用户为 NULL
     create_time DATETIME DEFAULT CURRENT_TIMESTAMP,
     update_time DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
     UNIQUE KEY uk_user_name (user_name),
     UNIQUE KEY uk_email (email),
-    UNIQUE KEY uk_phone (phone)
+    UNIQUE KEY uk_phone (phone),
+    UNIQUE KEY uk_source_external_id (source, external_id)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- 权限表
@@ -30,6 +33,7 @@ CREATE TABLE IF NOT EXISTS permission (
     resource_id      BIGINT NOT NULL,           -- 指向 app.id / cluster.id / namespace.id
     resource_name    VARCHAR(128) NOT NULL,     -- 冗余，便于查询
     action           VARCHAR(128) NOT NULL,
+    source           VARCHAR(16) NOT NULL DEFAULT 'manual', -- manual 手工授权，sso 由 IdP 组映射同步
     create_time DATETIME DEFAULT CURRENT_TIMESTAMP,
     update_time DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
@@ -56,6 +60,32 @@ CREATE TABLE IF NOT EXISTS accesskey (
     FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- 登录会话表
+CREATE TABLE IF NOT EXISTS session (
+    id                 BIGINT AUTO_INCREMENT PRIMARY KEY,
+    session_id         VARCHAR(64) NOT NULL,
+    user_id            BIGINT NOT NULL,
+    user_name          VARCHAR(128) NOT NULL,
+    access_token_hash  VARCHAR(64) NOT NULL,      -- sha256(access token)
+    refresh_token_hash VARCHAR(64) NOT NULL,      -- sha256(refresh token)
+    access_expire_at   DATETIME NOT NULL,
+    refresh_expire_at  DATETIME NOT NULL,
+    client_ip          VARCHAR(64),
+    user_agent         VARCHAR(256),
+    revoked            TINYINT NOT NULL DEFAULT 0,
+    revoke_reason      VARCHAR(128),
+    revoked_at         DATETIME NULL,
+    last_seen_at       DATETIME NULL,
+    create_time        DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time        DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uk_session_id (session_id),
+    FOREIGN KEY (user_id) REFERENCES user (id) ON DELETE CASCADE,
+
+    KEY idx_user_revoked (user_id, revoked),
+    KEY idx_update_time (update_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 -- 应用表
 CREATE TABLE IF NOT EXISTS app (
     id          BIGINT AUTO_INCREMENT PRIMARY KEY,
@@ -152,6 +182,10 @@ CREATE TABLE IF NOT EXISTS namespace_release (
     release_time   DATETIME DEFAULT CURRENT_TIMESTAMP,
     operator       VARCHAR(64),
     comment        VARCHAR(1024),
+    source_release_id VARCHAR(64),                   -- 由其他集群的哪个版本提升而来
+    git_commit     VARCHAR(64),                      -- 内容与绑定的 Git 仓库中哪个 commit 一致
+    interpolated   TINYINT(1) NOT NULL DEFAULT 0,    -- 发布时已解析 ${key} 引用
+    refs           MEDIUMTEXT,                       -- 发布时解析的引用及其值（JSON）
     config         BLOB,
     create_time    DATETIME DEFAULT CURRENT_TIMESTAMP,
     update_time    DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
@@ -163,7 +197,8 @@ CREATE TABLE IF NOT EXISTS namespace_release (
     -- 优化：高频查询
     KEY idx_namespace_time (namespace_id, release_time DESC), -- 最近发布
     KEY idx_cluster_namespace (cluster_id, namespace_name),   -- 按集群+命名空间查
-    KEY idx_release_time (release_time DESC)                  -- 全局发布时间
+    KEY idx_release_time (release_time DESC),                 -- 全局发布时间
+    KEY idx_source_release_id (source_release_id)            -- 查询提升出去的版本
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
 -- 发布配置项表
@@ -174,13 +209,15 @@ CREATE TABLE IF NOT EXISTS item_release (
     namespace_id   BIGINT NOT NULL,
     k              VARCHAR(255) NOT NULL,
     v              TEXT,
-    kv_id          BIGINT UNSIGNED NOT NULL,
+    kv_id          BIGINT UNSIGNED NOT NULL,          -- MurmurHash64(k,v)，可能碰撞，仅用于兼容旧格式发布
+    kv_hash        CHAR(64) NULL,                     -- SHA-256(len(k),k,v)，发布快照按内容去重
     is_deleted     TINYINT DEFAULT 0,
 
     create_time    DATETIME DEFAULT CURRENT_TIMESTAMP,
     update_time    DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
 
-    UNIQUE KEY uk_namespace_kv_id (namespace_id, kv_id),
+    UNIQUE KEY uk_namespace_kv_hash (namespace_id, kv_hash),
+    KEY idx_namespace_kv_id (namespace_id, kv_id),
 
     -- 优化：与 item 表保持一致
     KEY idx_namespace_deleted (namespace_id, is_deleted),
@@ -188,12 +225,154 @@ CREATE TABLE IF NOT EXISTS item_release (
     KEY idx_namespace_k (namespace_id, k)
 ) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
 
+-- 变更消息表：发布、回滚、权限、accesskey、会话变更在同一事务中追加，watcher 按 seq 顺序消费
+CREATE TABLE IF NOT EXISTS release_message (
+    seq          BIGINT UNSIGNED PRIMARY KEY,   -- 由 sequence 表在事务内分配，按提交顺序递增且无空洞
+    kind         VARCHAR(32) NOT NULL,          -- namespace_release、permission、accesskey、session
+    message      VARCHAR(512) NOT NULL,         -- 需要失效的缓存 key
+    create_time  DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    KEY idx_create_time (create_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 序列表
+CREATE TABLE IF NOT EXISTS sequence (
+    name   VARCHAR(64) PRIMARY KEY,
+    value  BIGINT UNSIGNED NOT NULL DEFAULT 0
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT IGNORE INTO sequence (name, value) VALUES ('release_message', 0);
+
+-- 定时发布、回滚表
+CREATE TABLE IF NOT EXISTS scheduled_release (
+    id                BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_id            BIGINT NOT NULL,
+    app_name          VARCHAR(128) NOT NULL,
+    cluster_id        BIGINT NOT NULL,
+    cluster_name      VARCHAR(128) NOT NULL,
+    namespace_id      BIGINT NOT NULL,
+    namespace_name    VARCHAR(128) NOT NULL,
+    action            VARCHAR(16) NOT NULL,              -- publish、rollback
+    execute_at        DATETIME NOT NULL,
+    revert_at         DATETIME NULL,                     -- 执行成功后在该时间回滚到执行前的版本
+    release_name      VARCHAR(128),
+    `keys`            TEXT,                              -- JSON 数组，部分发布的 key
+    release_id        VARCHAR(64),                       -- 回滚的目标版本
+    restore_draft     TINYINT(1) NOT NULL DEFAULT 0,
+    `force`           TINYINT(1) NOT NULL DEFAULT 0,
+    operator          VARCHAR(64) NOT NULL,
+    comment           VARCHAR(1024),
+    status            VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending、succeeded、failed、cancelled
+    reason            VARCHAR(1024),                     -- 失败原因
+    result_release_id VARCHAR(64),                       -- 执行生成的版本
+    parent_id         BIGINT NOT NULL DEFAULT 0,         -- 由哪个任务的 revert_at 生成
+    execute_time      DATETIME NULL,                     -- 实际执行或取消的时间
+    cancelled_by      VARCHAR(64),
+    create_time       DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time       DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY idx_status_execute_at (status, execute_at),      -- 扫描到期任务
+    KEY idx_namespace_id (namespace_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 版本提升记录表
+CREATE TABLE IF NOT EXISTS release_promotion (
+    id                  BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_name            VARCHAR(128) NOT NULL,
+    source_release_id   VARCHAR(64) NOT NULL,
+    source_cluster_name VARCHAR(128) NOT NULL,
+    source_namespace    VARCHAR(128) NOT NULL,
+    target_cluster_name VARCHAR(128) NOT NULL,
+    target_namespace    VARCHAR(128) NOT NULL,
+    target_namespace_id BIGINT NOT NULL,
+    mode                VARCHAR(16) NOT NULL,            -- draft 只写入草稿，publish 直接发布
+    result_release_id   VARCHAR(64),                     -- publish 时生成的版本
+    operator            VARCHAR(64) NOT NULL,
+    comment             VARCHAR(1024),
+    create_time         DATETIME DEFAULT CURRENT_TIMESTAMP,
+
+    KEY idx_source_release_id (source_release_id),
+    KEY idx_target_namespace_id (target_namespace_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 命名空间绑定的 Git 仓库表
+CREATE TABLE IF NOT EXISTS namespace_git_binding (
+    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_id         BIGINT NOT NULL,
+    app_name       VARCHAR(128) NOT NULL,
+    cluster_id     BIGINT NOT NULL,
+    cluster_name   VARCHAR(128) NOT NULL,
+    namespace_id   BIGINT NOT NULL,
+    namespace_name VARCHAR(128) NOT NULL,
+    repo_path      VARCHAR(1024) NOT NULL,            -- 服务器上的仓库路径，裸仓库或工作副本
+    ref            VARCHAR(255) NOT NULL,             -- 默认拉取的分支、标签或 commit
+    path           VARCHAR(1024) NOT NULL DEFAULT '', -- 仓库中的目录或文件
+    format         VARCHAR(16) NOT NULL,              -- files、properties、yaml、json
+    operator       VARCHAR(64) NOT NULL,
+    pulled_commit  VARCHAR(64),                       -- 最近一次拉取到草稿区的 commit
+    pulled_hash    CHAR(64),                          -- 拉取内容的摘要
+    pulled_by      VARCHAR(64),
+    pulled_time    DATETIME NULL,
+    create_time    DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time    DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    UNIQUE KEY uk_namespace_id (namespace_id),
+    FOREIGN KEY (namespace_id) REFERENCES namespace (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 配置变更 webhook 订阅和投递记录表
+CREATE TABLE IF NOT EXISTS webhook (
+    id             BIGINT AUTO_INCREMENT PRIMARY KEY,
+    app_name       VARCHAR(128) NOT NULL,
+    cluster_name   VARCHAR(128) NOT NULL DEFAULT '',  -- 与 namespace_name 同时为空时订阅整个应用
+    namespace_name VARCHAR(128) NOT NULL DEFAULT '',
+    url            VARCHAR(1024) NOT NULL,
+    secret         VARCHAR(128) NOT NULL,             -- 签名密钥
+    events         TEXT,                              -- JSON 数组，为空时订阅全部事件
+    enabled        TINYINT(1) NOT NULL DEFAULT 1,
+    description    VARCHAR(1024),
+    operator       VARCHAR(64) NOT NULL,
+    create_time    DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time    DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY idx_app_name (app_name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE IF NOT EXISTS webhook_delivery (
+    id                BIGINT AUTO_INCREMENT PRIMARY KEY,
+    webhook_id        BIGINT NOT NULL,
+    event_id          VARCHAR(64) NOT NULL,              -- 重放时不变
+    event             VARCHAR(32) NOT NULL,              -- publish、rollback、item.set 等
+    payload           MEDIUMTEXT NOT NULL,
+    status            VARCHAR(16) NOT NULL DEFAULT 'pending', -- pending、succeeded、failed
+    attempts          INT NOT NULL DEFAULT 0,
+    next_attempt_time DATETIME NOT NULL,
+    response_code     INT NOT NULL DEFAULT 0,            -- 最近一次投递的 HTTP 状态码
+    last_error        VARCHAR(1024),
+    replay_of         BIGINT NOT NULL DEFAULT 0,         -- 由哪条记录重放而来
+    delivered_time    DATETIME NULL,
+    create_time       DATETIME DEFAULT CURRENT_TIMESTAMP,
+    update_time       DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+
+    KEY idx_status_next_attempt_time (status, next_attempt_time), -- 扫描待投递记录
+    KEY idx_webhook_id (webhook_id),
+    FOREIGN KEY (webhook_id) REFERENCES webhook (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
 
 -- 可选：添加注释说明
 ALTER TABLE user COMMENT '用户表';
+ALTER TABLE session COMMENT '登录会话表';
 ALTER TABLE app COMMENT '应用表';
 ALTER TABLE cluster COMMENT '集群表';
 ALTER TABLE namespace COMMENT '命名空间表';
 ALTER TABLE item COMMENT '配置项表';
 ALTER TABLE item_release COMMENT '配置项发布快照表';
 ALTER TABLE namespace_release COMMENT '命名空间发布记录表';
+ALTER TABLE release_message COMMENT '变更消息表';
+ALTER TABLE sequence COMMENT '序列表';
+ALTER TABLE scheduled_release COMMENT '定时发布表';
+ALTER TABLE release_promotion COMMENT '版本提升记录表';
+ALTER TABLE namespace_git_binding COMMENT '命名空间 Git 仓库绑定表';
+ALTER TABLE webhook COMMENT 'webhook 订阅表';
+ALTER TABLE webhook_delivery COMMENT 'webhook 投递记录表';
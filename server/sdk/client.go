@@ -0,0 +1,199 @@
+// Package sdk 是服务端 gRPC ConfigService 的 Go 客户端，供 quiver-agent、quiver-run 等工具使用
+package sdk
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	quiverv1 "quiver/proto/quiver/v1"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// accesskey 签名使用的 metadata，与服务端 grpcserver 一致
+const (
+	metadataAccessKey = "x-quiver-accesskey"
+	metadataTimestamp = "x-quiver-timestamp"
+	metadataSignature = "x-quiver-signature"
+)
+
+// ErrNoRelease 命名空间还没有任何发布
+var ErrNoRelease = errors.New("no releases found")
+
+// Config 连接服务端的配置
+type Config struct {
+	Server     string // 服务端 gRPC 地址，如 quiver.internal:9090
+	AccessKey  string // gRPC 请求都需要签名，所有者需要有应用、集群或命名空间的权限
+	SecretKey  string
+	TLS        bool   // 经过 TLS 代理访问服务端时开启
+	CAFile     string // 为空时使用系统根证书
+	ServerName string // 为空时使用 Server 中的主机名
+}
+
+// NamespaceRef 集群下的一个命名空间
+type NamespaceRef struct {
+	Cluster   string
+	Namespace string
+}
+
+func (r NamespaceRef) String() string {
+	return r.Cluster + "/" + r.Namespace
+}
+
+// ParseNamespace 解析 "namespace" 或 "cluster/namespace"，省略集群时使用 defaultCluster
+func ParseNamespace(name, defaultCluster string) (NamespaceRef, error) {
+	ref := NamespaceRef{Cluster: defaultCluster, Namespace: name}
+	if cluster, namespace, ok := strings.Cut(name, "/"); ok {
+		ref = NamespaceRef{Cluster: cluster, Namespace: namespace}
+	}
+	if ref.Cluster == "" || ref.Namespace == "" || strings.Contains(ref.Namespace, "/") {
+		return NamespaceRef{}, fmt.Errorf("invalid namespace %q, use \"namespace\" or \"cluster/namespace\"", name)
+	}
+	return ref, nil
+}
+
+// Namespace 命名空间最新版本的完整配置
+type Namespace struct {
+	NamespaceRef
+	ReleaseID string
+	Items     map[string]string
+}
+
+// Client ConfigService 客户端，每个请求都按 accesskey 签名
+type Client struct {
+	conf Config
+	conn *grpc.ClientConn
+	rpc  quiverv1.ConfigServiceClient
+}
+
+// NewClient 创建客户端，连接在第一次请求时建立，断开后自动重连
+func NewClient(conf Config) (*Client, error) {
+	if conf.Server == "" {
+		return nil, errors.New("server is required")
+	}
+	if conf.AccessKey == "" || conf.SecretKey == "" {
+		return nil, errors.New("access key and secret key are required")
+	}
+
+	creds := insecure.NewCredentials()
+	if conf.TLS {
+		tlsConf := &tls.Config{ServerName: conf.ServerName}
+		if conf.CAFile != "" {
+			pem, err := os.ReadFile(conf.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			tlsConf.RootCAs = x509.NewCertPool()
+			if !tlsConf.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", conf.CAFile)
+			}
+		}
+		creds = credentials.NewTLS(tlsConf)
+	}
+
+	c := &Client{conf: conf}
+	conn, err := grpc.NewClient(conf.Server, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return nil, err
+	}
+	c.conn, c.rpc = conn, quiverv1.NewConfigServiceClient(conn)
+	return c, nil
+}
+
+// Close 关闭连接
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// sign 按服务端的要求给请求签名：hex(HMAC-SHA256(secret_key, timestamp + "\n" + payload))，
+// payload 为方法全名、env、应用和以逗号连接的 "cluster/namespace"，每行一项，命名空间的顺序与请求一致
+func (c *Client) sign(ctx context.Context, method, env, appName string, refs []NamespaceRef) context.Context {
+	namespaces := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		namespaces = append(namespaces, ref.String())
+	}
+	payload := strings.Join([]string{method, env, appName, strings.Join(namespaces, ",")}, "\n")
+
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(c.conf.SecretKey))
+	mac.Write([]byte(ts + "\n" + payload))
+	return metadata.AppendToOutgoingContext(ctx,
+		metadataAccessKey, c.conf.AccessKey,
+		metadataTimestamp, ts,
+		metadataSignature, hex.EncodeToString(mac.Sum(nil)),
+	)
+}
+
+// GetNamespace 拉取命名空间最新版本的完整配置，还没有任何发布时返回 ErrNoRelease
+func (c *Client) GetNamespace(ctx context.Context, env, appName string, ref NamespaceRef) (*Namespace, error) {
+	ctx = c.sign(ctx, quiverv1.ConfigService_GetConfig_FullMethodName, env, appName, []NamespaceRef{ref})
+	resp, err := c.rpc.GetConfig(ctx, &quiverv1.GetConfigRequest{
+		Env:           env,
+		AppName:       appName,
+		ClusterName:   ref.Cluster,
+		NamespaceName: ref.Namespace,
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, ErrNoRelease
+		}
+		return nil, fmt.Errorf("get %s: %w", ref, err)
+	}
+
+	cfg := resp.GetConfig()
+	ns := &Namespace{NamespaceRef: ref, ReleaseID: cfg.GetReleaseId(), Items: make(map[string]string, len(cfg.GetItems()))}
+	for _, item := range cfg.GetItems() {
+		ns.Items[item.GetKey()] = item.GetValue()
+	}
+	return ns, nil
+}
+
+// Watch 订阅同一应用下的命名空间，known 为客户端当前的版本（没有时为空字符串）。
+// 订阅后先通知已经落后的命名空间，之后每次发布、回滚都调用一次 fn；
+// 事件中只有增量，需要完整配置时用 GetNamespace 重新拉取。连接断开、ctx 结束或 fn 返回错误时返回
+func (c *Client) Watch(ctx context.Context, env, appName string, known map[NamespaceRef]string, fn func(ref NamespaceRef, releaseID string) error) error {
+	req := &quiverv1.WatchRequest{Env: env, AppName: appName}
+	refs := make([]NamespaceRef, 0, len(known))
+	for ref, releaseID := range known {
+		req.Namespaces = append(req.Namespaces, &quiverv1.WatchNamespace{
+			ClusterName:   ref.Cluster,
+			NamespaceName: ref.Namespace,
+			ReleaseId:     releaseID,
+		})
+		refs = append(refs, ref)
+	}
+
+	ctx = c.sign(ctx, quiverv1.ConfigService_Watch_FullMethodName, env, appName, refs)
+	stream, err := c.rpc.Watch(ctx, req)
+	if err != nil {
+		return err
+	}
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		cfg := event.GetConfig()
+		ref := NamespaceRef{Cluster: cfg.GetClusterName(), Namespace: cfg.GetNamespaceName()}
+		if _, ok := known[ref]; !ok {
+			continue
+		}
+		if err := fn(ref, cfg.GetReleaseId()); err != nil {
+			return err
+		}
+	}
+}
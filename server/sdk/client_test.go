@@ -0,0 +1,69 @@
+package sdk
+
+import (
+	"context"
+	"errors"
+	"net"
+	quiverv1 "quiver/proto/quiver/v1"
+	"quiver/services"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// SDK 中的签名是服务端算法的副本，两边必须一致
+func TestSignMatchesServer(t *testing.T) {
+	c := &Client{conf: Config{AccessKey: "ak-12345678", SecretKey: "sk-secret"}}
+	refs := []NamespaceRef{{Cluster: "default", Namespace: "application"}, {Cluster: "shenzhen", Namespace: "database"}}
+	const method = "/quiver.v1.ConfigService/Watch"
+
+	ctx := c.sign(context.Background(), method, "dev", "demo", refs)
+	md, _ := metadata.FromOutgoingContext(ctx)
+	if got := md.Get(metadataAccessKey); len(got) != 1 || got[0] != "ak-12345678" {
+		t.Fatalf("access key = %v", got)
+	}
+	ts := md.Get(metadataTimestamp)
+	if len(ts) != 1 {
+		t.Fatalf("timestamp = %v", ts)
+	}
+
+	payload := services.AccessKeyPayload(method, "dev", "demo", []string{"default/application", "shenzhen/database"})
+	want := services.SignAccessKey("sk-secret", ts[0], payload)
+	if got := md.Get(metadataSignature); len(got) != 1 || got[0] != want {
+		t.Fatalf("signature = %v, want %s", got, want)
+	}
+}
+
+// notFoundServer GetConfig 总是返回 NotFound
+type notFoundServer struct {
+	quiverv1.UnimplementedConfigServiceServer
+}
+
+func (notFoundServer) GetConfig(context.Context, *quiverv1.GetConfigRequest) (*quiverv1.GetConfigResponse, error) {
+	return nil, status.Error(codes.NotFound, "no releases found")
+}
+
+func TestGetNamespaceNoRelease(t *testing.T) {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	quiverv1.RegisterConfigServiceServer(server, notFoundServer{})
+	go server.Serve(lis)
+	defer server.Stop()
+
+	c, err := NewClient(Config{Server: lis.Addr().String(), AccessKey: "ak-12345678", SecretKey: "sk-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	_, err = c.GetNamespace(context.Background(), "dev", "demo", NamespaceRef{Cluster: "default", Namespace: "application"})
+	if !errors.Is(err, ErrNoRelease) {
+		t.Fatalf("GetNamespace() error = %v, want ErrNoRelease", err)
+	}
+}
@@ -1,7 +1,9 @@
 package services
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"quiver/database"
@@ -9,6 +11,9 @@ import (
 	"quiver/models"
 	"strconv"
 	"strings"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type AccessKeyService struct{}
@@ -92,11 +97,19 @@ func (s *AccessKeyService) CreateAccessKey(env string, userID uint64) (*models.A
 		SecretKey: sk,
 	}
 
-	if err := db.Create(accessKey).Error; err != nil {
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(accessKey).Error; err != nil {
+			return err
+		}
+		return AppendReleaseMessage(tx, models.MessageKindAccessKey, accessKey.CacheKey(env))
+	})
+	if err != nil {
 		logger.GetLogger("quiver").Errorf("error creating access key: %v, userID: %d", err, userID)
 		return nil, err
 	}
 
+	NotifyChanges(env)
+
 	// 注意：返回 secretKey，仅本次可见
 	return accessKey, nil
 }
@@ -206,14 +219,154 @@ func (s *AccessKeyService) DeleteAccessKey(env string, userID uint64, accessKey
 		return errors.New("access key cannot be empty")
 	}
 
-	result := db.Where("access_key = ?", accessKey).Delete(&models.AccessKey{})
-	if result.Error != nil {
-		logger.GetLogger("quiver").Errorf("error deleting accesskey: %v", result.Error)
-		return result.Error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var ak models.AccessKey
+		if err := tx.Where("access_key = ?", accessKey).First(&ak).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.GetLogger("quiver").Warnf("accesskey delete failed %s", "accesskey not found")
+				return errors.New("accesskey not found")
+			}
+			logger.GetLogger("quiver").Errorf("error querying accesskey: %v", err)
+			return err
+		}
+
+		if err := tx.Delete(&ak).Error; err != nil {
+			logger.GetLogger("quiver").Errorf("error deleting accesskey: %v", err)
+			return err
+		}
+		return AppendReleaseMessage(tx, models.MessageKindAccessKey, ak.CacheKey(env))
+	})
+	if err != nil {
+		return err
+	}
+	NotifyChanges(env)
+	return nil
+}
+
+// accessKeyClockSkew accesskey 签名时间戳允许的偏差，防止重放
+const accessKeyClockSkew = 5 * time.Minute
+
+// ErrAccessDenied accesskey 的所有者没有访问应用的权限
+var ErrAccessDenied = errors.New("permission denied")
+
+// ErrInvalidAccessKey accesskey 不存在或已经删除
+var ErrInvalidAccessKey = errors.New("invalid access key")
+
+// ErrAccessKeyExpired accesskey 已过期
+var ErrAccessKeyExpired = errors.New("access key expired")
+
+// AccessKeyPayload gRPC 请求签名的内容：方法全名、env、应用和请求的命名空间（"cluster/namespace"，按请求中的顺序以逗号连接），
+// 签名只对同一个方法的同一组命名空间有效
+func AccessKeyPayload(method, env, appName string, namespaces []string) string {
+	return strings.Join([]string{method, env, appName, strings.Join(namespaces, ",")}, "\n")
+}
+
+// SignAccessKey 计算 accesskey 签名：hex(HMAC-SHA256(secret_key, timestamp + "\n" + payload))
+func SignAccessKey(secretKey, timestamp, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("\n"))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticate 校验 accesskey 签名，signature 见 SignAccessKey，payload 见 AccessKeyPayload；
+// timestamp 为 Unix 秒，与服务端时间偏差超过 5 分钟拒绝
+func (s *AccessKeyService) Authenticate(env, accessKey, timestamp, signature, payload string) (*models.AccessKey, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	if len(accessKey) < 8 || len(accessKey) > 64 {
+		return nil, ErrInvalidAccessKey
+	}
+	sec, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, errors.New("invalid timestamp")
+	}
+	if skew := time.Since(time.Unix(sec, 0)); skew > accessKeyClockSkew || skew < -accessKeyClockSkew {
+		return nil, errors.New("timestamp expired")
+	}
+
+	ak, err := loadAccessKey(db, accessKey)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := SignAccessKey(ak.SecretKey, timestamp, payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, errors.New("invalid signature")
+	}
+	return ak, nil
+}
+
+// Revalidate 重新读取已经通过 Authenticate 的 accesskey，检查它仍然存在并且没有过期，不再校验签名；
+// 用于 Watch 这类长连接在订阅期间重新校验
+func (s *AccessKeyService) Revalidate(env, accessKey string) (*models.AccessKey, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+	return loadAccessKey(db, accessKey)
+}
+
+// loadAccessKey 按 access_key 读取未过期的 accesskey
+func loadAccessKey(db *gorm.DB, accessKey string) (*models.AccessKey, error) {
+	var ak models.AccessKey
+	if err := db.Where("access_key = ?", accessKey).First(&ak).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidAccessKey
+		}
+		logger.GetLogger("quiver").Errorf("error querying access key: %v", err)
+		return nil, err
+	}
+	if ak.ExpireAt != nil && ak.ExpireAt.Before(time.Now()) {
+		return nil, ErrAccessKeyExpired
+	}
+	return &ak, nil
+}
+
+// Authorize 校验 accesskey 的所有者能否读取命名空间：管理员，或者在应用、集群、命名空间任意一级有权限；
+// 命名空间不存在时同样返回 ErrAccessDenied，不暴露资源是否存在
+func (s *AccessKeyService) Authorize(env string, ak *models.AccessKey, appName, clusterName, namespaceName string) error {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return errors.New("db not initialized")
+	}
+
+	var user models.User
+	if err := db.Where("id = ?", ak.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAccessDenied
+		}
+		logger.GetLogger("quiver").Errorf("error querying user %d: %v", ak.UserID, err)
+		return err
+	}
+	if IsAdmin(user.UserName) {
+		return nil
+	}
+
+	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return ErrAccessDenied
+	}
+	var count int64
+	err = db.Model(&models.Permission{}).
+		Where("user_id = ?", ak.UserID).
+		Where(db.Where("resource_type = ? AND resource_id = ?", "APP", ids.AppID).
+			Or("resource_type = ? AND resource_id = ?", "CLUSTER", ids.ClusterID).
+			Or("resource_type = ? AND resource_id = ?", "NAMESPACE", ids.NamespaceID)).
+		Count(&count).Error
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("error querying permission of user %d: %v", ak.UserID, err)
+		return err
 	}
-	if result.RowsAffected == 0 {
-		logger.GetLogger("quiver").Warnf("accesskey delete failed %s", "accesskey not found")
-		return errors.New("accesskey not found")
+	if count == 0 {
+		return ErrAccessDenied
 	}
 	return nil
 }
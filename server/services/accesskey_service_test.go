@@ -0,0 +1,36 @@
+package services
+
+import "testing"
+
+func TestSignAccessKey(t *testing.T) {
+	const method = "/quiver.v1.ConfigService/Watch"
+	payload := AccessKeyPayload(method, "dev", "demo", []string{"default/application", "default/database"})
+	if want := method + "\ndev\ndemo\ndefault/application,default/database"; payload != want {
+		t.Fatalf("AccessKeyPayload() = %q, want %q", payload, want)
+	}
+
+	// HMAC-SHA256("secret", "1754380800\nhello")
+	if got, want := SignAccessKey("secret", "1754380800", "hello"), "b1c9b398fed1747d45ec546fe5a0d7373c744ac12de1961a497918c2b6791158"; got != want {
+		t.Fatalf("SignAccessKey() = %s, want %s", got, want)
+	}
+
+	sig := SignAccessKey("secret", "1754380800", payload)
+	if SignAccessKey("secret", "1754380800", payload) != sig {
+		t.Fatal("SignAccessKey() not stable")
+	}
+	// 签名不能用于其他 env、应用、命名空间或方法
+	for name, other := range map[string]string{
+		"env":       AccessKeyPayload(method, "pro", "demo", []string{"default/application", "default/database"}),
+		"app":       AccessKeyPayload(method, "dev", "other", []string{"default/application", "default/database"}),
+		"namespace": AccessKeyPayload(method, "dev", "demo", []string{"default/application"}),
+		"order":     AccessKeyPayload(method, "dev", "demo", []string{"default/database", "default/application"}),
+		"method":    AccessKeyPayload("/quiver.v1.ConfigService/GetConfig", "dev", "demo", []string{"default/application", "default/database"}),
+	} {
+		if SignAccessKey("secret", "1754380800", other) == sig {
+			t.Errorf("signature reused across %s", name)
+		}
+	}
+	if SignAccessKey("other", "1754380800", payload) == sig || SignAccessKey("secret", "1754380801", payload) == sig {
+		t.Error("signature ignores the secret key or timestamp")
+	}
+}
@@ -1,56 +1,77 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"go.opentelemetry.io/otel/trace"
 	"quiver/database"
 	"quiver/logger"
 	"quiver/models"
+	"quiver/telemetry"
 
 	"gorm.io/gorm"
 )
 
 // AppService 应用服务
-type AppService struct{}
+type AppService struct {
+	ctx context.Context
+}
 
 // NewAppService 创建应用服务实例
 func NewAppService() *AppService {
 	return &AppService{}
 }
 
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *AppService) WithContext(ctx context.Context) *AppService {
+	return &AppService{ctx: ctx}
+}
+
+func (s *AppService) trace(name string) (*AppService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "AppService."+name)
+	return &AppService{ctx: ctx}, span
+}
+
 // CreateApp 创建应用
-func (s *AppService) CreateApp(env string, app *models.App) error {
-	logger.GetLogger("quiver").Infof("app create %+v for env %s", app, env)
-	db := database.GetDB(env)
+func (s *AppService) CreateApp(env string, app *models.App) (err error) {
+	s, span := s.trace("CreateApp")
+	defer func() { telemetry.End(span, err) }()
+
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("app create %+v for env %s", app, env)
+	db := database.GetDBContext(s.ctx, env)
 	if db == nil {
-		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("db is nil for env %s", env)
 		return errors.New("db not initialized")
 	}
 
 	// 检查应用ID是否已存在
 	var existingApp models.App
-	err := db.Where("app_name = ?", app.AppName).First(&existingApp).Error
+	err = db.Where("app_name = ?", app.AppName).First(&existingApp).Error
 	if err == nil {
-		logger.GetLogger("quiver").Errorf("app_name %s already exists", app.AppName)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app_name %s already exists", app.AppName)
 		return errors.New("app_name already exists")
 	}
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		logger.GetLogger("quiver").Errorf("app create %s failed %s", app.AppName, err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app create %s failed %s", app.AppName, err.Error())
 		return err
 	}
 
-	logger.GetLogger("quiver").Infof("begin create  %s in db", app.AppName)
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("begin create  %s in db", app.AppName)
 	// 创建应用
 	return db.Create(app).Error
 }
 
 // GetApp 根据ID获取应用
-func (s *AppService) GetApp(env string, appName string) (*models.App, error) {
-	db := database.GetDB(env)
+func (s *AppService) GetApp(env string, appName string) (_ *models.App, err error) {
+	s, span := s.trace("GetApp")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
 
 	var app models.App
-	err := db.Where("app_name = ?", appName).First(&app).Error
+	err = db.Where("app_name = ?", appName).First(&app).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("app get %s failed %s", appName, err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app get %s failed %s", appName, err.Error())
 		return nil, err
 	}
 
@@ -58,21 +79,24 @@ func (s *AppService) GetApp(env string, appName string) (*models.App, error) {
 }
 
 // ListApp 获取所有应用
-func (s *AppService) ListApp(env string, page, size int) ([]models.App, int64, error) {
-	db := database.GetDB(env)
+func (s *AppService) ListApp(env string, page, size int) (_ []models.App, _ int64, err error) {
+	s, span := s.trace("ListApp")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
 
 	var apps []models.App
 	var total int64
 
 	// 获取总数
 	if err := db.Model(&models.App{}).Count(&total).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("failed to count apps num %s", err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to count apps num %s", err.Error())
 		return nil, 0, err
 	}
 
 	// 分页查询
 	offset := (page - 1) * size
-	err := db.Offset(offset).Limit(size).Find(&apps).Error
+	err = db.Offset(offset).Limit(size).Find(&apps).Error
 	if err != nil {
 		return nil, 0, err
 	}
@@ -81,13 +105,16 @@ func (s *AppService) ListApp(env string, page, size int) ([]models.App, int64, e
 }
 
 // UpdateApp 更新应用
-func (s *AppService) UpdateApp(env string, appName string, updates map[string]interface{}) (*models.App, error) {
-	db := database.GetDB(env)
+func (s *AppService) UpdateApp(env string, appName string, updates map[string]interface{}) (_ *models.App, err error) {
+	s, span := s.trace("UpdateApp")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
 
 	// 获取当前版本号
 	app, err := s.GetApp(env, appName)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("app get %s failed %s", appName, err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app get %s failed %s", appName, err.Error())
 		return nil, errors.New("app_name not exist")
 	}
 
@@ -97,12 +124,12 @@ func (s *AppService) UpdateApp(env string, appName string, updates map[string]in
 	// 使用乐观锁更新
 	result := db.Model(&models.App{}).Where("app_name = ? AND ver = ?", appName, ver).Updates(updates)
 	if result.Error != nil {
-		logger.GetLogger("quiver").Errorf("app update %s failed %s", appName, result.Error)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app update %s failed %s", appName, result.Error)
 		return nil, result.Error
 	}
 
 	if result.RowsAffected == 0 {
-		logger.GetLogger("quiver").Errorf("app update %s failed %s", appName, "app not found or version conflict")
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app update %s failed %s", appName, "app not found or version conflict")
 		return nil, errors.New("app not found or version conflict")
 	}
 
@@ -110,43 +137,52 @@ func (s *AppService) UpdateApp(env string, appName string, updates map[string]in
 }
 
 // DeleteApp 删除应用
-func (s *AppService) DeleteApp(env string, appName string) error {
-	db := database.GetDB(env)
+func (s *AppService) DeleteApp(env string, appName string) (err error) {
+	s, span := s.trace("DeleteApp")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
 
 	// 先查询app 是否存在
 	app, err := s.GetApp(env, appName)
 	if err != nil || app == nil {
-		logger.GetLogger("quiver").Errorf("app get %s failed %s", appName, err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app get %s failed %s", appName, err)
 		return errors.New("app_name not exist")
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
+	err = db.Transaction(func(tx *gorm.DB) error {
 		// 更新  Item 记录的 deleted 字段
 		err := BulkMarkDeleted[*models.Item](tx, map[string]interface{}{"app_id": app.AppID})
 		if err != nil {
-			logger.GetLogger("quiver").Errorf("update items deleted for app %s failed %s", appName, err)
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update items deleted for app %s failed %s", appName, err)
 			return err
 		}
 
 		// 更新与  ItemRelease 记录的 deleted 字段
 		err = BulkMarkDeleted[*models.ItemRelease](tx, map[string]interface{}{"app_id": app.AppID})
 		if err != nil {
-			logger.GetLogger("quiver").Errorf("update items release deleted for app %s failed %s", appName, err)
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update items release deleted for app %s failed %s", appName, err)
 			return err
 		}
 
 		// 删除应用相关的所有数据
 		result := tx.Where("id = ?", app.AppID).Delete(&models.App{})
 		if result.Error != nil {
-			logger.GetLogger("quiver").Errorf("app delete %s failed %s", appName, result.Error)
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app delete %s failed %s", appName, result.Error)
 			return result.Error
 		}
 
 		if result.RowsAffected == 0 {
-			logger.GetLogger("quiver").Errorf("app delete %s failed %s", appName, "app not found")
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("app delete %s failed %s", appName, "app not found")
 			return errors.New("app_name not exist")
 		}
 
-		return nil
+		// webhook 按应用名订阅，删除应用后仍然保留，可以收到本事件
+		return enqueueWebhooks(tx, &WebhookEvent{Event: models.WebhookEventAppDelete, Env: env, AppName: appName})
 	})
+	if err != nil {
+		return err
+	}
+	WakeWebhooks(env)
+	return nil
 }
@@ -0,0 +1,676 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/telemetry"
+	"quiver/utils"
+	"sort"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// ApplyService 按声明式清单调整应用、集群、命名空间和配置项
+type ApplyService struct {
+	ctx context.Context
+}
+
+// Manifest 一个环境下期望的 App -> Cluster -> Namespace -> Item 结构。
+// 只处理清单中列出的应用；description 省略时不修改，clusters、namespaces、items 省略时不管理该层级，
+// 写为空列表或空对象时表示该层级应当为空
+type Manifest struct {
+	Apps []ManifestApp `yaml:"apps" json:"apps"`
+}
+
+// ManifestApp 清单中的应用
+type ManifestApp struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description *string           `yaml:"description" json:"description"`
+	Clusters    []ManifestCluster `yaml:"clusters" json:"clusters"`
+}
+
+// ManifestCluster 清单中的集群
+type ManifestCluster struct {
+	Name        string              `yaml:"name" json:"name"`
+	Description *string             `yaml:"description" json:"description"`
+	Namespaces  []ManifestNamespace `yaml:"namespaces" json:"namespaces"`
+}
+
+// ManifestNamespace 清单中的命名空间，items 为草稿区期望的全部配置项
+type ManifestNamespace struct {
+	Name        string            `yaml:"name" json:"name"`
+	Description *string           `yaml:"description" json:"description"`
+	Items       map[string]string `yaml:"items" json:"items"`
+}
+
+// ApplyOptions 执行清单的选项
+type ApplyOptions struct {
+	DryRun      bool   // 只计算计划，不修改
+	Prune       bool   // 删除清单中没有列出的集群、命名空间和配置项；应用不会被删除
+	Publish     bool   // 发布最新版本与清单不一致的命名空间
+	PlanID      string // 不为空时，执行前重新计算的计划与之不同则放弃执行
+	ReleaseName string
+	Operator    string
+	Comment     string
+}
+
+// ApplyChange 计划中的一项改动。action 为 create、update、delete 或 publish；
+// kind 为 item 时 value、old_value 为配置项的值，为 app、cluster、namespace 时为描述
+type ApplyChange struct {
+	Action        string `json:"action"`
+	Kind          string `json:"kind"`
+	AppName       string `json:"app_name"`
+	ClusterName   string `json:"cluster_name,omitempty"`
+	NamespaceName string `json:"namespace_name,omitempty"`
+	Key           string `json:"key,omitempty"`
+	Value         string `json:"value,omitempty"`
+	OldValue      string `json:"old_value,omitempty"`
+	ReleaseID     string `json:"release_id,omitempty"` // publish 执行后生成的版本
+}
+
+// ApplySummary 各类改动的数量
+type ApplySummary struct {
+	Create  int `json:"create"`
+	Update  int `json:"update"`
+	Delete  int `json:"delete"`
+	Publish int `json:"publish"`
+}
+
+// ApplyPlan 计划及执行结果，plan_id 为计划内容的摘要，执行时带上可以保证执行的就是审阅过的计划
+type ApplyPlan struct {
+	Env     string        `json:"env"`
+	DryRun  bool          `json:"dry_run"`
+	Applied bool          `json:"applied"`
+	PlanID  string        `json:"plan_id"`
+	Summary ApplySummary  `json:"summary"`
+	Changes []ApplyChange `json:"changes"`
+}
+
+// ErrPlanChanged 执行前重新计算的计划与 plan_id 不一致，通常是审阅之后有人修改了配置
+var ErrPlanChanged = errors.New("plan changed since it was reviewed, review the new plan and apply again")
+
+// applyStep 计划中的一步，run 在事务中执行 changes 描述的改动
+type applyStep struct {
+	changes []ApplyChange
+	run     func(tx *gorm.DB) error
+}
+
+// planner 对比清单与数据库生成计划，db 为 dry_run 时的连接或执行时的事务
+type planner struct {
+	s     *ApplyService
+	db    *gorm.DB
+	env   string
+	opts  ApplyOptions
+	steps []*applyStep
+}
+
+// NewApplyService 创建声明式配置服务实例
+func NewApplyService() *ApplyService {
+	return &ApplyService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *ApplyService) WithContext(ctx context.Context) *ApplyService {
+	return &ApplyService{ctx: ctx}
+}
+
+func (s *ApplyService) trace(name string) (*ApplyService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "ApplyService."+name)
+	return &ApplyService{ctx: ctx}, span
+}
+
+// Apply 计算清单与当前数据的差异；dry_run 时只返回计划，否则在一个事务中重新计算并执行计划，
+// 任何一步失败都整体回滚。执行时对涉及的命名空间加行锁，与发布、回滚串行
+func (s *ApplyService) Apply(env string, manifest *Manifest, opts ApplyOptions) (_ *ApplyPlan, err error) {
+	s, span := s.trace("Apply")
+	defer func() { telemetry.End(span, err) }()
+
+	if err := validateManifest(manifest); err != nil {
+		return nil, err
+	}
+	if opts.Publish && opts.Operator == "" {
+		return nil, errors.New("operator is required to publish")
+	}
+	if opts.ReleaseName == "" {
+		opts.ReleaseName = time.Now().Format("20060102150405") + "-apply"
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+	if db == nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	if opts.DryRun {
+		p := &planner{s: s, db: db, env: env, opts: opts}
+		if err := p.plan(manifest); err != nil {
+			return nil, err
+		}
+		return p.result(false), nil
+	}
+
+	var plan *ApplyPlan
+	published := false
+	err = db.Transaction(func(tx *gorm.DB) error {
+		p := &planner{s: s, db: tx, env: env, opts: opts}
+		if err := p.plan(manifest); err != nil {
+			return err
+		}
+		plan = p.result(false)
+		if opts.PlanID != "" && opts.PlanID != plan.PlanID {
+			return ErrPlanChanged
+		}
+
+		for _, step := range p.steps {
+			if err := step.run(tx); err != nil {
+				change := step.changes[0]
+				logger.GetLogger("quiver").WithContext(s.ctx).Errorf("apply %s %s %s/%s/%s failed: %v",
+					change.Action, change.Kind, change.AppName, change.ClusterName, change.NamespaceName, err)
+				return fmt.Errorf("%s %s %s: %w", change.Action, change.Kind, changePath(change), err)
+			}
+		}
+		published = plan.Summary.Publish > 0
+		plan = p.result(true)
+		// 发布事件由 publishTx 写入，这里补充配置项修改和删除的事件
+		return enqueueWebhooks(tx, applyEvents(env, opts.Operator, plan.Changes)...)
+	})
+	if err != nil {
+		if errors.Is(err, ErrPlanChanged) {
+			return plan, err
+		}
+		return nil, err
+	}
+
+	if published {
+		NotifyChanges(env)
+	} else if len(plan.Changes) > 0 {
+		WakeWebhooks(env)
+	}
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("applied manifest for env %s: %d create, %d update, %d delete, %d publish",
+		env, plan.Summary.Create, plan.Summary.Update, plan.Summary.Delete, plan.Summary.Publish)
+	return plan, nil
+}
+
+// applyEvents 把执行的改动转换为 webhook 事件：配置项的新增、修改和删除，以及集群、命名空间的删除
+func applyEvents(env, operator string, changes []ApplyChange) []*WebhookEvent {
+	var events []*WebhookEvent
+	for _, change := range changes {
+		event := &WebhookEvent{Env: env, AppName: change.AppName, ClusterName: change.ClusterName,
+			NamespaceName: change.NamespaceName, Operator: operator}
+		switch {
+		case change.Kind == "item" && change.Action == "delete":
+			event.Event, event.Key = models.WebhookEventItemDelete, change.Key
+		case change.Kind == "item":
+			event.Event, event.Key = models.WebhookEventItemSet, change.Key
+		case change.Kind == "namespace" && change.Action == "delete":
+			event.Event = models.WebhookEventNamespaceDelete
+		case change.Kind == "cluster" && change.Action == "delete":
+			event.Event = models.WebhookEventClusterDelete
+		default:
+			continue
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// validateManifest 校验名称、配置项和重复项
+func validateManifest(m *Manifest) error {
+	if len(m.Apps) == 0 {
+		return errors.New("manifest has no apps")
+	}
+	apps := make(map[string]bool, len(m.Apps))
+	for _, app := range m.Apps {
+		if !utils.ValidateAppName(app.Name) {
+			return fmt.Errorf("invalid app_name %q", app.Name)
+		}
+		if apps[app.Name] {
+			return fmt.Errorf("duplicate app %s", app.Name)
+		}
+		apps[app.Name] = true
+		if err := validateDescription(app.Description, app.Name); err != nil {
+			return err
+		}
+
+		clusters := make(map[string]bool, len(app.Clusters))
+		for _, cluster := range app.Clusters {
+			path := app.Name + "/" + cluster.Name
+			if !utils.ValidateClusterName(cluster.Name) {
+				return fmt.Errorf("invalid cluster_name %q in app %s", cluster.Name, app.Name)
+			}
+			if clusters[cluster.Name] {
+				return fmt.Errorf("duplicate cluster %s", path)
+			}
+			clusters[cluster.Name] = true
+			if err := validateDescription(cluster.Description, path); err != nil {
+				return err
+			}
+
+			namespaces := make(map[string]bool, len(cluster.Namespaces))
+			for _, namespace := range cluster.Namespaces {
+				if !utils.ValidateNamespaceName(namespace.Name) {
+					return fmt.Errorf("invalid namespace_name %q in %s", namespace.Name, path)
+				}
+				if namespaces[namespace.Name] {
+					return fmt.Errorf("duplicate namespace %s/%s", path, namespace.Name)
+				}
+				namespaces[namespace.Name] = true
+				if err := validateDescription(namespace.Description, path+"/"+namespace.Name); err != nil {
+					return err
+				}
+				for k, v := range namespace.Items {
+					if !utils.ValidateItemKey(k) {
+						return fmt.Errorf("invalid key %q in %s/%s", k, path, namespace.Name)
+					}
+					if v == "" {
+						return fmt.Errorf("empty value for key %s in %s/%s", k, path, namespace.Name)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func validateDescription(description *string, path string) error {
+	if description != nil && len(*description) > 1024 {
+		return fmt.Errorf("description of %s is longer than 1024 bytes", path)
+	}
+	return nil
+}
+
+func changePath(c ApplyChange) string {
+	path := c.AppName
+	for _, part := range []string{c.ClusterName, c.NamespaceName, c.Key} {
+		if part != "" {
+			path += "/" + part
+		}
+	}
+	return path
+}
+
+// result 汇总计划，applied 为 true 时表示已经执行
+func (p *planner) result(applied bool) *ApplyPlan {
+	plan := &ApplyPlan{Env: p.env, DryRun: p.opts.DryRun, Applied: applied, Changes: []ApplyChange{}}
+	for _, step := range p.steps {
+		for _, change := range step.changes {
+			switch change.Action {
+			case "create":
+				plan.Summary.Create++
+			case "update":
+				plan.Summary.Update++
+			case "delete":
+				plan.Summary.Delete++
+			case "publish":
+				plan.Summary.Publish++
+			}
+			plan.Changes = append(plan.Changes, change)
+		}
+	}
+
+	// plan_id 只由改动内容和选项决定，不包含执行结果
+	digest := struct {
+		Prune   bool          `json:"prune"`
+		Publish bool          `json:"publish"`
+		Changes []ApplyChange `json:"changes"`
+	}{Prune: p.opts.Prune, Publish: p.opts.Publish, Changes: make([]ApplyChange, len(plan.Changes))}
+	for i, change := range plan.Changes {
+		change.ReleaseID = ""
+		digest.Changes[i] = change
+	}
+	data, _ := json.Marshal(digest)
+	sum := sha256.Sum256(data)
+	plan.PlanID = hex.EncodeToString(sum[:16])
+	return plan
+}
+
+func (p *planner) add(run func(tx *gorm.DB) error, changes ...ApplyChange) *applyStep {
+	step := &applyStep{changes: changes, run: run}
+	p.steps = append(p.steps, step)
+	return step
+}
+
+func (p *planner) plan(m *Manifest) error {
+	for _, app := range m.Apps {
+		if err := p.planApp(app); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// planApp 应用不存在时创建；存在时按清单更新描述，并对比集群
+func (p *planner) planApp(m ManifestApp) error {
+	ids := &models.IDs{}
+	change := ApplyChange{Kind: "app", AppName: m.Name}
+
+	var app models.App
+	err := p.db.Where("app_name = ?", m.Name).First(&app).Error
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		change.Action, change.Value = "create", deref(m.Description)
+		p.add(func(tx *gorm.DB) error {
+			created := models.App{AppName: m.Name, Description: deref(m.Description)}
+			if err := tx.Create(&created).Error; err != nil {
+				return err
+			}
+			ids.AppID = created.AppID
+			return nil
+		}, change)
+		for _, cluster := range m.Clusters {
+			if err := p.planCluster(ids, m.Name, cluster, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	case err != nil:
+		logger.GetLogger("quiver").WithContext(p.s.ctx).Errorf("app get %s failed %s", m.Name, err.Error())
+		return err
+	}
+
+	ids.AppID = app.AppID
+	if m.Description != nil && *m.Description != app.Description {
+		change.Action, change.Value, change.OldValue = "update", *m.Description, app.Description
+		p.add(func(tx *gorm.DB) error {
+			return tx.Model(&models.App{}).Where("id = ?", app.AppID).
+				Updates(map[string]interface{}{"description": *m.Description, "ver": gorm.Expr("ver + 1")}).Error
+		}, change)
+	}
+	if m.Clusters == nil {
+		return nil
+	}
+
+	var existing []models.Cluster
+	if err := p.db.Where("app_id = ?", app.AppID).Order("cluster_name ASC").Find(&existing).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(p.s.ctx).Errorf("failed to list clusters for app %s: %v", m.Name, err)
+		return err
+	}
+	byName := make(map[string]*models.Cluster, len(existing))
+	for i := range existing {
+		byName[existing[i].ClusterName] = &existing[i]
+	}
+
+	listed := make(map[string]bool, len(m.Clusters))
+	for _, cluster := range m.Clusters {
+		listed[cluster.Name] = true
+		if err := p.planCluster(ids, m.Name, cluster, byName[cluster.Name]); err != nil {
+			return err
+		}
+	}
+	if !p.opts.Prune {
+		return nil
+	}
+	for _, cluster := range existing {
+		if listed[cluster.ClusterName] {
+			continue
+		}
+		clusterID, clusterName := cluster.ClusterID, cluster.ClusterName
+		p.add(func(tx *gorm.DB) error {
+			if err := NewClusterService().WithContext(p.s.ctx).deleteCluster(tx, clusterID, m.Name, clusterName); err != nil {
+				return err
+			}
+			// 集群下的配置项已经标记为删除，命名空间一起删除
+			return tx.Where("cluster_id = ?", clusterID).Delete(&models.Namespace{}).Error
+		}, ApplyChange{Action: "delete", Kind: "cluster", AppName: m.Name, ClusterName: clusterName})
+	}
+	return nil
+}
+
+// planCluster existing 为 nil 时创建集群及其下的全部命名空间
+func (p *planner) planCluster(appIDs *models.IDs, appName string, m ManifestCluster, existing *models.Cluster) error {
+	ids := &models.IDs{}
+	change := ApplyChange{Kind: "cluster", AppName: appName, ClusterName: m.Name}
+
+	if existing == nil {
+		change.Action, change.Value = "create", deref(m.Description)
+		p.add(func(tx *gorm.DB) error {
+			created := models.Cluster{AppID: appIDs.AppID, AppName: appName, ClusterName: m.Name, Description: deref(m.Description)}
+			if err := tx.Create(&created).Error; err != nil {
+				return err
+			}
+			ids.AppID, ids.ClusterID = appIDs.AppID, created.ClusterID
+			return nil
+		}, change)
+		for _, namespace := range m.Namespaces {
+			if err := p.planNamespace(ids, appName, m.Name, namespace, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	ids.AppID, ids.ClusterID = existing.AppID, existing.ClusterID
+	if m.Description != nil && *m.Description != existing.Description {
+		change.Action, change.Value, change.OldValue = "update", *m.Description, existing.Description
+		p.add(func(tx *gorm.DB) error {
+			return tx.Model(&models.Cluster{}).Where("id = ?", existing.ClusterID).
+				Updates(map[string]interface{}{"description": *m.Description, "ver": gorm.Expr("ver + 1")}).Error
+		}, change)
+	}
+	if m.Namespaces == nil {
+		return nil
+	}
+
+	var namespaces []models.Namespace
+	if err := p.db.Where("cluster_id = ?", existing.ClusterID).Order("namespace_name ASC").Find(&namespaces).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(p.s.ctx).Errorf("failed to query namespaces of %s/%s: %v", appName, m.Name, err)
+		return err
+	}
+	byName := make(map[string]*models.Namespace, len(namespaces))
+	for i := range namespaces {
+		byName[namespaces[i].NamespaceName] = &namespaces[i]
+	}
+
+	listed := make(map[string]bool, len(m.Namespaces))
+	for _, namespace := range m.Namespaces {
+		listed[namespace.Name] = true
+		if err := p.planNamespace(ids, appName, m.Name, namespace, byName[namespace.Name]); err != nil {
+			return err
+		}
+	}
+	if !p.opts.Prune {
+		return nil
+	}
+	for _, namespace := range namespaces {
+		if listed[namespace.NamespaceName] {
+			continue
+		}
+		namespaceID, namespaceName := namespace.NamespaceID, namespace.NamespaceName
+		p.add(func(tx *gorm.DB) error {
+			return NewNamespaceService().WithContext(p.s.ctx).deleteNamespace(tx, namespaceID, namespaceName)
+		}, ApplyChange{Action: "delete", Kind: "namespace", AppName: appName, ClusterName: m.Name, NamespaceName: namespaceName})
+	}
+	return nil
+}
+
+// planNamespace existing 为 nil 时创建命名空间；items 不为 nil 时把草稿区调整为 items，
+// 开启 publish 时最新版本与 items 不一致的命名空间在同一事务中发布
+func (p *planner) planNamespace(clusterIDs *models.IDs, appName, clusterName string, m ManifestNamespace, existing *models.Namespace) error {
+	ids := &models.IDs{}
+	change := ApplyChange{Kind: "namespace", AppName: appName, ClusterName: clusterName, NamespaceName: m.Name}
+	drafts := map[string]string{}
+	released := map[string]string{}
+	hasRelease := false
+
+	if existing == nil {
+		change.Action, change.Value = "create", deref(m.Description)
+		p.add(func(tx *gorm.DB) error {
+			created := models.Namespace{
+				AppID:         clusterIDs.AppID,
+				AppName:       appName,
+				ClusterID:     clusterIDs.ClusterID,
+				ClusterName:   clusterName,
+				NamespaceName: m.Name,
+				Description:   deref(m.Description),
+			}
+			if err := tx.Create(&created).Error; err != nil {
+				return err
+			}
+			ids.AppID, ids.ClusterID, ids.NamespaceID = clusterIDs.AppID, clusterIDs.ClusterID, created.NamespaceID
+			return nil
+		}, change)
+	} else {
+		ids.AppID, ids.ClusterID, ids.NamespaceID = existing.AppID, existing.ClusterID, existing.NamespaceID
+		if m.Description != nil && *m.Description != existing.Description {
+			change.Action, change.Value, change.OldValue = "update", *m.Description, existing.Description
+			p.add(func(tx *gorm.DB) error {
+				return tx.Model(&models.Namespace{}).Where("id = ?", existing.NamespaceID).
+					Updates(map[string]interface{}{"description": *m.Description, "ver": gorm.Expr("ver + 1")}).Error
+			}, change)
+		}
+		if m.Items == nil {
+			return nil
+		}
+
+		// 执行时先锁住命名空间，计划基于的草稿和最新版本在提交前不会被其他发布修改
+		if !p.opts.DryRun {
+			if err := lockNamespace(p.db, existing.NamespaceID); err != nil {
+				return fmt.Errorf("failed to lock namespace: %w", err)
+			}
+		}
+		var items []models.Item
+		if err := p.db.Select("k, v").Where("namespace_id = ? AND is_deleted = 0", existing.NamespaceID).Find(&items).Error; err != nil {
+			logger.GetLogger("quiver").WithContext(p.s.ctx).Errorf("query items of namespace %s failed: %v", m.Name, err)
+			return err
+		}
+		for _, item := range items {
+			drafts[item.K] = item.V
+		}
+
+		var latest models.NamespaceRelease
+		err := p.db.Where("namespace_id = ?", existing.NamespaceID).Order("id DESC").First(&latest).Error
+		switch {
+		case err == nil:
+			if released, err = releaseKV(p.db, existing.NamespaceID, &latest); err != nil {
+				logger.GetLogger("quiver").WithContext(p.s.ctx).Errorf("failed to query items of %s: %v", latest.ReleaseID, err)
+				return errors.New("failed to get release data")
+			}
+			hasRelease = true
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			logger.GetLogger("quiver").WithContext(p.s.ctx).Errorf("query latest release failed: %v", err)
+			return errors.New("failed to get release data")
+		}
+	}
+	if m.Items == nil {
+		return nil
+	}
+
+	// 草稿区改回与最新版本一致时，这些配置项仍然标记为已发布
+	var matchesRelease map[string]string
+	if hasRelease && equalKV(released, m.Items) {
+		matchesRelease = released
+	}
+	p.planItems(ids, change, drafts, m.Items, matchesRelease)
+
+	if p.opts.Publish && len(m.Items) > 0 && (!hasRelease || !equalKV(released, m.Items)) {
+		step := p.add(nil, ApplyChange{Action: "publish", Kind: "namespace", AppName: appName, ClusterName: clusterName, NamespaceName: m.Name})
+		step.run = func(tx *gorm.DB) error {
+			release, err := NewReleaseService().WithContext(p.s.ctx).publishTx(tx, p.env, ids, appName, clusterName, m.Name,
+				p.opts.ReleaseName, p.opts.Operator, p.opts.Comment, nil, nil, nil)
+			if err != nil {
+				return err
+			}
+			step.changes[0].ReleaseID = release.ReleaseID
+			return nil
+		}
+	}
+	return nil
+}
+
+// planItems 按 key 对比草稿区与清单，一个命名空间的配置项改动合并为一步执行；
+// released 不为 nil 时草稿区改完后与该版本一致，重新标记已发布的配置项
+func (p *planner) planItems(ids *models.IDs, ns ApplyChange, drafts, desired, released map[string]string) {
+	base := ApplyChange{Kind: "item", AppName: ns.AppName, ClusterName: ns.ClusterName, NamespaceName: ns.NamespaceName}
+	var changes []ApplyChange
+	var deleted []string
+
+	keys := make([]string, 0, len(desired)+len(drafts))
+	for k := range desired {
+		keys = append(keys, k)
+	}
+	for k := range drafts {
+		if _, ok := desired[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		change := base
+		change.Key = k
+		want, inManifest := desired[k]
+		have, inDraft := drafts[k]
+		switch {
+		case !inDraft:
+			change.Action, change.Value = "create", want
+		case !inManifest:
+			if !p.opts.Prune {
+				continue
+			}
+			change.Action, change.OldValue = "delete", have
+			deleted = append(deleted, k)
+		case want != have:
+			change.Action, change.Value, change.OldValue = "update", want, have
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+	if len(changes) == 0 {
+		return
+	}
+
+	p.add(func(tx *gorm.DB) error {
+		itemService := NewItemService().WithContext(p.s.ctx)
+		for _, change := range changes {
+			if change.Action == "delete" {
+				continue
+			}
+			if err := itemService.setItem(tx, ids, change.Key, change.Value); err != nil {
+				return fmt.Errorf("set %s: %w", change.Key, err)
+			}
+		}
+		for i := 0; i < len(deleted); i += 1000 {
+			chunk := deleted[i:min(i+1000, len(deleted))]
+			if err := tx.Where("namespace_id = ? AND k IN ?", ids.NamespaceID, chunk).Delete(&models.Item{}).Error; err != nil {
+				return err
+			}
+		}
+		if released == nil {
+			return nil
+		}
+		var items []models.Item
+		if err := tx.Select("id, k, v").Where("namespace_id = ? AND is_deleted = 0", ids.NamespaceID).Find(&items).Error; err != nil {
+			return err
+		}
+		return markReleasedItems(tx, ids.NamespaceID, items, released)
+	}, changes...)
+}
+
+func equalKV(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
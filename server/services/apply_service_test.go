@@ -0,0 +1,282 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sort"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// memTable 只读的内存表，查询按 WHERE 的第一个参数匹配 filter 列
+type memTable struct {
+	filter string
+	rows   []map[string]driver.Value
+}
+
+// memDB 按 FROM 后的表名分发查询，用于 dry_run 的计划
+type memDB map[string]*memTable
+
+var fromTable = regexp.MustCompile("FROM `(\\w+)`")
+
+func (db memDB) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	m := fromTable.FindStringSubmatch(query)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	rows := &memRows{columns: []string{"id"}}
+	table, ok := db[m[1]]
+	if !ok || len(args) == 0 {
+		return rows, nil
+	}
+	for _, row := range table.rows {
+		if fmt.Sprint(row[table.filter]) == fmt.Sprint(args[0].Value) {
+			rows.rows = append(rows.rows, row)
+		}
+	}
+	if len(rows.rows) > 0 {
+		rows.columns = rows.columns[:0]
+		for column := range rows.rows[0] {
+			rows.columns = append(rows.columns, column)
+		}
+		sort.Strings(rows.columns)
+	}
+	return rows, nil
+}
+
+type memRows struct {
+	columns []string
+	rows    []map[string]driver.Value
+}
+
+func (r *memRows) Columns() []string { return r.columns }
+func (r *memRows) Close() error      { return nil }
+func (r *memRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	for i, column := range r.columns {
+		dest[i] = r.rows[0][column]
+	}
+	r.rows = r.rows[1:]
+	return nil
+}
+
+type memConnector struct{ db memDB }
+
+func (c memConnector) Connect(context.Context) (driver.Conn, error) { return memConn(c), nil }
+func (c memConnector) Driver() driver.Driver                        { return nil }
+
+type memConn struct{ db memDB }
+
+func (c memConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c memConn) Close() error              { return nil }
+func (c memConn) Begin() (driver.Tx, error) { return nil, errors.New("read only") }
+func (c memConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.db.query(query, args)
+}
+
+// applyFixture 应用 demo 下有集群 default、old；default 下有命名空间 application（a=1 b=2）和 legacy，都没有发布
+func applyFixture(t *testing.T) *gorm.DB {
+	t.Helper()
+	tables := memDB{
+		"app": {filter: "app_name", rows: []map[string]driver.Value{
+			{"id": int64(1), "app_name": "demo", "description": "demo app"},
+		}},
+		"cluster": {filter: "app_id", rows: []map[string]driver.Value{
+			{"id": int64(1), "app_id": int64(1), "app_name": "demo", "cluster_name": "default", "description": ""},
+			{"id": int64(2), "app_id": int64(1), "app_name": "demo", "cluster_name": "old", "description": ""},
+		}},
+		"namespace": {filter: "cluster_id", rows: []map[string]driver.Value{
+			{"id": int64(10), "app_id": int64(1), "cluster_id": int64(1), "namespace_name": "application", "description": ""},
+			{"id": int64(11), "app_id": int64(1), "cluster_id": int64(1), "namespace_name": "legacy", "description": ""},
+		}},
+		"item": {filter: "namespace_id", rows: []map[string]driver.Value{
+			{"k": "a", "v": "1", "namespace_id": int64(10)},
+			{"k": "b", "v": "2", "namespace_id": int64(10)},
+		}},
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(memConnector{db: tables}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestPlanPruneAndEmpty(t *testing.T) {
+	str := func(s string) *string { return &s }
+	app := func(clusters []ManifestCluster) *Manifest {
+		return &Manifest{Apps: []ManifestApp{{Name: "demo", Clusters: clusters}}}
+	}
+	defaultCluster := func(namespaces []ManifestNamespace) []ManifestCluster {
+		return []ManifestCluster{{Name: "default", Namespaces: namespaces}}
+	}
+	application := func(items map[string]string) []ManifestNamespace {
+		return []ManifestNamespace{{Name: "application", Items: items}, {Name: "legacy"}}
+	}
+
+	tests := []struct {
+		name     string
+		manifest *Manifest
+		opts     ApplyOptions
+		want     []string
+	}{
+		{
+			name:     "clusters omitted are not managed",
+			manifest: app(nil),
+			opts:     ApplyOptions{Prune: true},
+			want:     nil,
+		},
+		{
+			name:     "empty clusters without prune",
+			manifest: app([]ManifestCluster{}),
+			want:     nil,
+		},
+		{
+			name:     "empty clusters with prune deletes every cluster",
+			manifest: app([]ManifestCluster{}),
+			opts:     ApplyOptions{Prune: true},
+			want:     []string{"delete cluster demo/default", "delete cluster demo/old"},
+		},
+		{
+			name:     "namespaces omitted are not managed",
+			manifest: app(defaultCluster(nil)),
+			opts:     ApplyOptions{Prune: true},
+			want:     []string{"delete cluster demo/old"},
+		},
+		{
+			name:     "empty namespaces with prune",
+			manifest: app(defaultCluster([]ManifestNamespace{})),
+			opts:     ApplyOptions{Prune: true},
+			want: []string{
+				"delete namespace demo/default/application",
+				"delete namespace demo/default/legacy",
+				"delete cluster demo/old",
+			},
+		},
+		{
+			name:     "items omitted are not managed",
+			manifest: app(defaultCluster(application(nil))),
+			opts:     ApplyOptions{Prune: true},
+			want:     []string{"delete cluster demo/old"},
+		},
+		{
+			name:     "empty items without prune",
+			manifest: app(defaultCluster(application(map[string]string{}))),
+			want:     nil,
+		},
+		{
+			name:     "empty items with prune deletes every item",
+			manifest: app(defaultCluster(application(map[string]string{}))),
+			opts:     ApplyOptions{Prune: true},
+			want: []string{
+				"delete item demo/default/application/a",
+				"delete item demo/default/application/b",
+				"delete cluster demo/old",
+			},
+		},
+		{
+			name:     "unlisted items kept without prune",
+			manifest: app(defaultCluster(application(map[string]string{"a": "10", "c": "3"}))),
+			want: []string{
+				"update item demo/default/application/a",
+				"create item demo/default/application/c",
+			},
+		},
+		{
+			name:     "unlisted items deleted with prune",
+			manifest: app(defaultCluster(application(map[string]string{"a": "1", "c": "3"}))),
+			opts:     ApplyOptions{Prune: true},
+			want: []string{
+				"delete item demo/default/application/b",
+				"create item demo/default/application/c",
+				"delete cluster demo/old",
+			},
+		},
+		{
+			name:     "publish only namespaces with items",
+			manifest: app(defaultCluster(application(map[string]string{"a": "1", "b": "2"}))),
+			opts:     ApplyOptions{Publish: true},
+			want:     []string{"publish namespace demo/default/application"},
+		},
+		{
+			name: "new namespace and description",
+			manifest: app([]ManifestCluster{{Name: "default", Description: str("main"), Namespaces: []ManifestNamespace{
+				{Name: "extra", Items: map[string]string{"x": "1"}},
+				{Name: "empty", Items: map[string]string{}},
+			}}}),
+			opts: ApplyOptions{Publish: true},
+			want: []string{
+				"update cluster demo/default",
+				"create namespace demo/default/extra",
+				"create item demo/default/extra/x",
+				"publish namespace demo/default/extra",
+				"create namespace demo/default/empty",
+			},
+		},
+		{
+			name:     "new app creates everything",
+			manifest: &Manifest{Apps: []ManifestApp{{Name: "other", Clusters: defaultCluster([]ManifestNamespace{{Name: "application"}})}}},
+			opts:     ApplyOptions{Prune: true},
+			want: []string{
+				"create app other",
+				"create cluster other/default",
+				"create namespace other/default/application",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.DryRun = true
+			p := &planner{s: NewApplyService(), db: applyFixture(t), env: "dev", opts: tt.opts}
+			if err := p.plan(tt.manifest); err != nil {
+				t.Fatalf("plan() error = %v", err)
+			}
+			var got []string
+			for _, change := range p.result(false).Changes {
+				got = append(got, change.Action+" "+change.Kind+" "+changePath(change))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("changes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanID(t *testing.T) {
+	manifest := &Manifest{Apps: []ManifestApp{{Name: "demo", Clusters: []ManifestCluster{}}}}
+	planID := func(opts ApplyOptions) string {
+		opts.DryRun = true
+		p := &planner{s: NewApplyService(), db: applyFixture(t), env: "dev", opts: opts}
+		if err := p.plan(manifest); err != nil {
+			t.Fatal(err)
+		}
+		return p.result(false).PlanID
+	}
+
+	pruned := planID(ApplyOptions{Prune: true})
+	if planID(ApplyOptions{Prune: true}) != pruned {
+		t.Fatal("plan_id is not stable")
+	}
+	// 没有改动的计划，选项不同 plan_id 也不同
+	if planID(ApplyOptions{}) == planID(ApplyOptions{Publish: true}) {
+		t.Fatal("plan_id ignores the publish option")
+	}
+	if planID(ApplyOptions{}) == pruned {
+		t.Fatal("plan_id ignores the changes")
+	}
+}
@@ -1,31 +1,49 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"go.opentelemetry.io/otel/trace"
 	"quiver/database"
 	"quiver/logger"
 	"quiver/models"
+	"quiver/telemetry"
 
 	"gorm.io/gorm"
 )
 
 // ClusterService 集群服务
-type ClusterService struct{}
+type ClusterService struct {
+	ctx context.Context
+}
 
 // NewClusterService 创建集群服务实例
 func NewClusterService() *ClusterService {
 	return &ClusterService{}
 }
 
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *ClusterService) WithContext(ctx context.Context) *ClusterService {
+	return &ClusterService{ctx: ctx}
+}
+
+func (s *ClusterService) trace(name string) (*ClusterService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "ClusterService."+name)
+	return &ClusterService{ctx: ctx}, span
+}
+
 // CreateCluster 创建集群
-func (s *ClusterService) CreateCluster(env string, cluster *models.Cluster) error {
-	ids, err := CheckACNKinDB(&env, &cluster.AppName, nil, nil, nil)
+func (s *ClusterService) CreateCluster(env string, cluster *models.Cluster) (err error) {
+	s, span := s.trace("CreateCluster")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &cluster.AppName, nil, nil, nil)
 	if err != nil {
 		return err
 	}
 
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
 	cluster.AppID = ids.AppID
 
@@ -33,12 +51,12 @@ func (s *ClusterService) CreateCluster(env string, cluster *models.Cluster) erro
 	var existingCluster models.Cluster
 	err = db.Where("app_name = ? AND cluster_name = ?", cluster.AppName, cluster.ClusterName).First(&existingCluster).Error
 	if err == nil {
-		logger.GetLogger("quiver").Errorf("cluster %s already exists in this app", cluster.ClusterName)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("cluster %s already exists in this app", cluster.ClusterName)
 		return errors.New("cluster already exists in this app")
 	}
 
 	if !errors.Is(err, gorm.ErrRecordNotFound) {
-		logger.GetLogger("quiver").Errorf("cluster create %s failed %s", cluster.AppName, err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("cluster create %s failed %s", cluster.AppName, err.Error())
 		return err
 	}
 
@@ -47,14 +65,17 @@ func (s *ClusterService) CreateCluster(env string, cluster *models.Cluster) erro
 }
 
 // ListCluster 获取应用下的所有集群
-func (s *ClusterService) ListCluster(env string, appName string, page, size int) ([]models.Cluster, int64, error) {
-	ids, err := CheckACNKinDB(&env, &appName, nil, nil, nil)
+func (s *ClusterService) ListCluster(env string, appName string, page, size int) (_ []models.Cluster, _ int64, err error) {
+	s, span := s.trace("ListCluster")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, nil, nil, nil)
 	if err != nil {
 		return nil, 0, err
 	}
 
 	// 获取对应环境的 DB 实例
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
 	var clusters []models.Cluster
 	var total int64
@@ -64,7 +85,7 @@ func (s *ClusterService) ListCluster(env string, appName string, page, size int)
 
 	// 获取总数（用于分页）
 	if err := query.Model(&models.Cluster{}).Count(&total).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("failed to count clusters for app %s: %v", appName, err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to count clusters for app %s: %v", appName, err)
 		return nil, 0, fmt.Errorf("failed to count clusters: %w", err)
 	}
 
@@ -77,7 +98,7 @@ func (s *ClusterService) ListCluster(env string, appName string, page, size int)
 		Find(&clusters).Error
 
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("failed to list clusters for app %s: %v", appName, err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to list clusters for app %s: %v", appName, err)
 		return nil, 0, err
 	}
 
@@ -85,18 +106,21 @@ func (s *ClusterService) ListCluster(env string, appName string, page, size int)
 }
 
 // GetCluster 获取特定集群
-func (s *ClusterService) GetCluster(env string, appName, clusterName string) (*models.Cluster, error) {
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, nil, nil)
+func (s *ClusterService) GetCluster(env string, appName, clusterName string) (_ *models.Cluster, err error) {
+	s, span := s.trace("GetCluster")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, nil, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
 	var cluster models.Cluster
 	err = db.Where("id = ?", ids.ClusterID).First(&cluster).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("cluster get %s failed %s", appName, err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("cluster get %s failed %s", appName, err.Error())
 		return nil, err
 	}
 
@@ -104,40 +128,57 @@ func (s *ClusterService) GetCluster(env string, appName, clusterName string) (*m
 }
 
 // DeleteCluster 删除集群
-func (s *ClusterService) DeleteCluster(env string, appName, clusterName string) error {
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, nil, nil)
+func (s *ClusterService) DeleteCluster(env string, appName, clusterName string) (err error) {
+	s, span := s.trace("DeleteCluster")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, nil, nil)
 	if err != nil {
 		return err
 	}
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
-	return db.Transaction(func(tx *gorm.DB) error {
-		// 更新  Item 记录的 deleted 字段
-		err := BulkMarkDeleted[*models.Item](tx, map[string]interface{}{"cluster_id": ids.ClusterID})
-		if err != nil {
-			logger.GetLogger("quiver").Errorf("update items deleted for cluster %s failed %s", clusterName, err)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := s.deleteCluster(tx, ids.ClusterID, appName, clusterName); err != nil {
 			return err
 		}
+		return enqueueWebhooks(tx, &WebhookEvent{Event: models.WebhookEventClusterDelete, Env: env,
+			AppName: appName, ClusterName: clusterName})
+	})
+	if err != nil {
+		return err
+	}
+	WakeWebhooks(env)
+	return nil
+}
 
-		// 更新与  ItemRelease 记录的 deleted 字段
-		err = BulkMarkDeleted[*models.ItemRelease](tx, map[string]interface{}{"cluster_id": ids.ClusterID})
-		if err != nil {
-			logger.GetLogger("quiver").Errorf("update items release deleted for cluster %s failed %s", clusterName, err)
-			return err
-		}
+// deleteCluster 在事务中删除集群，集群下草稿和发布的配置项标记为已删除
+func (s *ClusterService) deleteCluster(tx *gorm.DB, clusterID uint64, appName, clusterName string) error {
+	// 更新  Item 记录的 deleted 字段
+	err := BulkMarkDeleted[*models.Item](tx, map[string]interface{}{"cluster_id": clusterID})
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update items deleted for cluster %s failed %s", clusterName, err)
+		return err
+	}
 
-		// 删除集群
-		result := tx.Where("id = ?", ids.ClusterID).Delete(&models.Cluster{})
-		if result.Error != nil {
-			logger.GetLogger("quiver").Errorf("cluster delete %s failed %s", appName, result.Error.Error())
-			return result.Error
-		}
+	// 更新与  ItemRelease 记录的 deleted 字段
+	err = BulkMarkDeleted[*models.ItemRelease](tx, map[string]interface{}{"cluster_id": clusterID})
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update items release deleted for cluster %s failed %s", clusterName, err)
+		return err
+	}
 
-		if result.RowsAffected == 0 {
-			logger.GetLogger("quiver").Errorf("cluster delete %s failed %s", appName, "cluster not found")
-			return errors.New("cluster not found")
-		}
+	// 删除集群
+	result := tx.Where("id = ?", clusterID).Delete(&models.Cluster{})
+	if result.Error != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("cluster delete %s failed %s", appName, result.Error.Error())
+		return result.Error
+	}
 
-		return nil
-	})
+	if result.RowsAffected == 0 {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("cluster delete %s failed %s", appName, "cluster not found")
+		return errors.New("cluster not found")
+	}
+
+	return nil
 }
@@ -0,0 +1,533 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"quiver/config"
+	"quiver/database"
+	"quiver/gitrepo"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/render"
+	"quiver/telemetry"
+	"quiver/utils"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// GitService 命名空间绑定服务器本地的 Git 仓库：把某个 commit 中的文件导入草稿区或直接发布，并检查仓库与已发布配置的差异
+type GitService struct {
+	ctx context.Context
+}
+
+// ErrGitBindingNotFound 命名空间没有绑定仓库
+var ErrGitBindingNotFound = errors.New("namespace is not bound to a git repository")
+
+// GitPullOptions 拉取参数，ref 为空时使用绑定的 ref
+type GitPullOptions struct {
+	Ref         string
+	Mode        string // draft 只写入草稿区，publish 写入后直接发布
+	ReleaseName string
+	Operator    string
+	Comment     string
+	Force       bool // 覆盖草稿区未发布的改动
+}
+
+// GitPullResult 拉取结果
+type GitPullResult struct {
+	Ref              string        `json:"ref"`
+	Commit           string        `json:"commit"`
+	Mode             string        `json:"mode"`
+	UpToDate         bool          `json:"up_to_date"`                  // publish 时最新版本已经是该 commit 的内容，没有生成新版本
+	ReleaseID        string        `json:"release_id,omitempty"`        // publish 时生成的版本
+	Changes          []DraftChange `json:"changes"`                     // 草稿区相对该 commit 的差异，即本次写入的改动
+	UnpublishedEdits []DraftChange `json:"unpublished_edits,omitempty"` // 草稿区未发布的改动，未指定 force 时因此失败
+}
+
+// GitDriftChange 仓库与已发布配置的一处差异，type 为 added（仓库中新增）、updated（值不同）、deleted（仓库中已删除）
+type GitDriftChange struct {
+	Key          string `json:"key"`
+	Type         string `json:"type"`
+	RepoValue    string `json:"repo_value"`
+	ReleaseValue string `json:"release_value"`
+}
+
+// GitDriftReport 仓库中某个 commit 与命名空间最新版本的差异
+type GitDriftReport struct {
+	Ref           string           `json:"ref"`
+	Commit        string           `json:"commit"`
+	ReleaseID     string           `json:"release_id"`               // 最新版本，还没有发布时为空
+	ReleaseCommit string           `json:"release_commit,omitempty"` // 最新版本记录的 commit
+	InSync        bool             `json:"in_sync"`                  // 内容完全一致
+	Changes       []GitDriftChange `json:"changes"`
+}
+
+// NewGitService 创建 Git 仓库同步服务实例
+func NewGitService() *GitService {
+	return &GitService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *GitService) WithContext(ctx context.Context) *GitService {
+	return &GitService{ctx: ctx}
+}
+
+func (s *GitService) trace(name string) (*GitService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "GitService."+name)
+	return &GitService{ctx: ctx}, span
+}
+
+// Bind 绑定或修改命名空间的仓库。仓库必须位于 git.roots 配置的目录中，绑定时校验 ref 和 path 可以读取；
+// 修改绑定会清除最近一次拉取的记录
+func (s *GitService) Bind(env, appName, clusterName, namespaceName string, binding *models.NamespaceGitBinding) (err error) {
+	s, span := s.trace("Bind")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return err
+	}
+
+	if binding.Ref == "" {
+		binding.Ref = "HEAD"
+	}
+	if binding.Format == "" {
+		binding.Format = models.GitFormatFiles
+	}
+	binding.Path = strings.Trim(path.Clean("/"+binding.Path), "/")
+	switch binding.Format {
+	case models.GitFormatFiles, models.GitFormatProperties, models.GitFormatYAML, models.GitFormatJSON:
+	default:
+		return errors.New("format must be files, properties, yaml or json")
+	}
+	if binding.Format != models.GitFormatFiles && binding.Path == "" {
+		return fmt.Errorf("path of the %s file is required", binding.Format)
+	}
+	if binding.RepoPath, err = allowedRepoPath(binding.RepoPath); err != nil {
+		return err
+	}
+
+	// 绑定前确认能读出配置项，避免保存一个拉取时才会失败的绑定
+	if _, _, err := s.readRepo(binding, binding.Ref); err != nil {
+		return err
+	}
+
+	binding.AppID, binding.AppName = ids.AppID, appName
+	binding.ClusterID, binding.ClusterName = ids.ClusterID, clusterName
+	binding.NamespaceID, binding.NamespaceName = ids.NamespaceID, namespaceName
+	binding.PulledCommit, binding.PulledHash, binding.PulledBy, binding.PulledTime = "", "", "", nil
+
+	db := database.GetDBContext(s.ctx, env)
+	return db.Transaction(func(tx *gorm.DB) error {
+		var existing models.NamespaceGitBinding
+		err := tx.Where("namespace_id = ?", ids.NamespaceID).Take(&existing).Error
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(binding).Error; err != nil {
+				logger.GetLogger("quiver").WithContext(s.ctx).Errorf("create git binding for %s/%s/%s failed: %v", appName, clusterName, namespaceName, err)
+				return err
+			}
+		case err != nil:
+			return err
+		default:
+			binding.ID, binding.CreateTime = existing.ID, existing.CreateTime
+			if err := tx.Select("repo_path", "ref", "path", "format", "operator", "pulled_commit", "pulled_hash", "pulled_by", "pulled_time").
+				Updates(binding).Error; err != nil {
+				logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update git binding for %s/%s/%s failed: %v", appName, clusterName, namespaceName, err)
+				return err
+			}
+		}
+		logger.GetLogger("quiver").WithContext(s.ctx).Infof("bound %s/%s/%s to %s (%s, path %q, %s)",
+			appName, clusterName, namespaceName, binding.RepoPath, binding.Ref, binding.Path, binding.Format)
+		return nil
+	})
+}
+
+// GetBinding 查询命名空间绑定的仓库
+func (s *GitService) GetBinding(env, appName, clusterName, namespaceName string) (_ *models.NamespaceGitBinding, err error) {
+	s, span := s.trace("GetBinding")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.binding(database.GetDBContext(s.ctx, env), ids.NamespaceID)
+}
+
+// Unbind 解除绑定，已经导入的配置项和版本上记录的 commit 不受影响
+func (s *GitService) Unbind(env, appName, clusterName, namespaceName string) (err error) {
+	s, span := s.trace("Unbind")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+	result := db.Where("namespace_id = ?", ids.NamespaceID).Delete(&models.NamespaceGitBinding{})
+	if result.Error != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("delete git binding for %s/%s/%s failed: %v", appName, clusterName, namespaceName, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrGitBindingNotFound
+	}
+	return nil
+}
+
+// Pull 把仓库中某个 commit 的内容写入草稿区，草稿区与该 commit 完全一致；
+// mode 为 publish 时在同一事务中发布，新版本记录 git_commit。draft 时记录拉取的 commit，
+// 之后发布的草稿与拉取内容完全一致时，新版本同样记录该 commit。
+// 草稿区有未发布的改动时需要 force，否则返回 ErrUnpublishedEdits 和这些改动
+func (s *GitService) Pull(env, appName, clusterName, namespaceName string, opts GitPullOptions) (_ *GitPullResult, err error) {
+	s, span := s.trace("Pull")
+	defer func() { telemetry.End(span, err) }()
+
+	if opts.Mode == "" {
+		opts.Mode = models.PromoteModeDraft
+	}
+	if opts.Mode != models.PromoteModeDraft && opts.Mode != models.PromoteModePublish {
+		return nil, errors.New("mode must be draft or publish")
+	}
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	db := database.GetDBContext(s.ctx, env)
+	binding, err := s.binding(db, ids.NamespaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Ref == "" {
+		opts.Ref = binding.Ref
+	}
+
+	// 在事务外读取仓库，不在持有行锁时执行 git
+	result := &GitPullResult{Ref: opts.Ref, Mode: opts.Mode, Changes: []DraftChange{}}
+	repoKV, commit, err := s.readRepo(binding, opts.Ref)
+	if err != nil {
+		return nil, err
+	}
+	result.Commit = commit
+	digest := kvDigest(repoKV)
+
+	prepare := func(tx *gorm.DB, ids *models.IDs) error {
+		var drafts []models.Item
+		if err := tx.Select("id, k, v").Where("namespace_id = ? AND is_deleted = 0", ids.NamespaceID).Find(&drafts).Error; err != nil {
+			return fmt.Errorf("failed to query items: %w", err)
+		}
+
+		latestKV := map[string]string{}
+		var latest models.NamespaceRelease
+		err := tx.Where("namespace_id = ?", ids.NamespaceID).Order("id DESC").First(&latest).Error
+		switch {
+		case err == nil:
+			if latestKV, err = releaseKV(tx, ids.NamespaceID, &latest); err != nil {
+				return errors.New("failed to get release data")
+			}
+			// 最新版本已经是该 commit 的内容，不需要再发布
+			if opts.Mode == models.PromoteModePublish && latest.GitCommit == commit && equalKV(latestKV, repoKV) {
+				result.UpToDate, result.ReleaseID = true, latest.ReleaseID
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return errors.New("failed to get release data")
+		}
+
+		if unpublished := diffDraft(drafts, latestKV); len(unpublished) > 0 && !opts.Force && !result.UpToDate {
+			result.UnpublishedEdits = unpublished
+			return ErrUnpublishedEdits
+		}
+		if result.UpToDate {
+			return nil
+		}
+		result.Changes = diffDraft(drafts, repoKV)
+		// 草稿模式下按最新版本标记已发布，发布模式下草稿区就是新版本的内容
+		released := repoKV
+		if opts.Mode == models.PromoteModeDraft {
+			released = latestKV
+		}
+		return restoreDraftItems(tx, ids, drafts, repoKV, released)
+	}
+
+	record := func(tx *gorm.DB) error {
+		now := time.Now()
+		return tx.Model(&models.NamespaceGitBinding{}).Where("id = ?", binding.ID).Updates(map[string]interface{}{
+			"pulled_commit": commit,
+			"pulled_hash":   digest,
+			"pulled_by":     opts.Operator,
+			"pulled_time":   now,
+		}).Error
+	}
+
+	if opts.Mode == models.PromoteModeDraft {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := lockNamespace(tx, ids.NamespaceID); err != nil {
+				return fmt.Errorf("failed to lock namespace: %w", err)
+			}
+			if err := prepare(tx, ids); err != nil {
+				return err
+			}
+			return record(tx)
+		})
+	} else {
+		err = s.pullAndPublish(env, binding, commit, opts, prepare, record, result)
+	}
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("pull %s@%s into %s/%s/%s failed: %v",
+			binding.RepoPath, result.Ref, appName, clusterName, namespaceName, err)
+		return result, err
+	}
+
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("pulled %s@%s (%s) into %s/%s/%s (%s), %d changes",
+		binding.RepoPath, result.Ref, commit, appName, clusterName, namespaceName, opts.Mode, len(result.Changes))
+	return result, nil
+}
+
+// errUpToDate 最新版本已经是拉取的内容，用于在发布事务中放弃生成新版本
+var errUpToDate = errors.New("release is up to date")
+
+// pullAndPublish 通过发布流程写入草稿并发布，最新版本已经是该 commit 的内容时不生成新版本
+func (s *GitService) pullAndPublish(env string, binding *models.NamespaceGitBinding, commit string, opts GitPullOptions,
+	prepare prepareHook, record func(tx *gorm.DB) error, result *GitPullResult) error {
+	if opts.ReleaseName == "" {
+		opts.ReleaseName = "git-" + commit[:min(12, len(commit))]
+	}
+
+	prepareOrSkip := func(tx *gorm.DB, ids *models.IDs) error {
+		if err := prepare(tx, ids); err != nil {
+			return err
+		}
+		if result.UpToDate {
+			return errUpToDate
+		}
+		return nil
+	}
+	hook := func(tx *gorm.DB, release *models.NamespaceRelease) error {
+		release.GitCommit = commit
+		if err := tx.Model(release).UpdateColumn("git_commit", commit).Error; err != nil {
+			return err
+		}
+		return record(tx)
+	}
+
+	release, err := NewReleaseService().WithContext(s.ctx).publish(env, binding.AppName, binding.ClusterName, binding.NamespaceName,
+		opts.ReleaseName, opts.Operator, opts.Comment, nil, prepareOrSkip, hook)
+	if errors.Is(err, errUpToDate) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	result.ReleaseID = release.ReleaseID
+	return nil
+}
+
+// Drift 对比仓库中 ref 指向的 commit 与命名空间的最新版本，ref 为空时使用绑定的 ref
+func (s *GitService) Drift(env, appName, clusterName, namespaceName, ref string) (_ *GitDriftReport, err error) {
+	s, span := s.trace("Drift")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return nil, err
+	}
+	db := database.GetDBContext(s.ctx, env)
+	binding, err := s.binding(db, ids.NamespaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref == "" {
+		ref = binding.Ref
+	}
+	repoKV, commit, err := s.readRepo(binding, ref)
+	if err != nil {
+		return nil, err
+	}
+	report := &GitDriftReport{Ref: ref, Commit: commit, Changes: []GitDriftChange{}}
+
+	releasedKV := map[string]string{}
+	var latest models.NamespaceRelease
+	err = db.Where("namespace_id = ?", ids.NamespaceID).Order("id DESC").First(&latest).Error
+	switch {
+	case err == nil:
+		if releasedKV, err = releaseKV(db, ids.NamespaceID, &latest); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items of %s: %v", latest.ReleaseID, err)
+			return nil, errors.New("failed to get release data")
+		}
+		report.ReleaseID, report.ReleaseCommit = latest.ReleaseID, latest.GitCommit
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		return nil, errors.New("failed to get release data")
+	}
+
+	for k, v := range repoKV {
+		old, ok := releasedKV[k]
+		switch {
+		case !ok:
+			report.Changes = append(report.Changes, GitDriftChange{Key: k, Type: "added", RepoValue: v})
+		case old != v:
+			report.Changes = append(report.Changes, GitDriftChange{Key: k, Type: "updated", RepoValue: v, ReleaseValue: old})
+		}
+	}
+	for k, v := range releasedKV {
+		if _, ok := repoKV[k]; !ok {
+			report.Changes = append(report.Changes, GitDriftChange{Key: k, Type: "deleted", ReleaseValue: v})
+		}
+	}
+	sort.Slice(report.Changes, func(i, j int) bool { return report.Changes[i].Key < report.Changes[j].Key })
+	report.InSync = len(report.Changes) == 0 && report.ReleaseID != ""
+	return report, nil
+}
+
+func (s *GitService) binding(db *gorm.DB, namespaceID uint64) (*models.NamespaceGitBinding, error) {
+	var binding models.NamespaceGitBinding
+	err := db.Where("namespace_id = ?", namespaceID).Take(&binding).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrGitBindingNotFound
+	}
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query git binding of namespace %d failed: %v", namespaceID, err)
+		return nil, err
+	}
+	return &binding, nil
+}
+
+// readRepo 读取 ref 指向的 commit 中绑定的文件并转换为配置项，返回配置项和 commit
+func (s *GitService) readRepo(binding *models.NamespaceGitBinding, ref string) (map[string]string, string, error) {
+	conf := config.GetGitConfig()
+	if !conf.Enabled {
+		return nil, "", errors.New("git sync is disabled, set git.enabled in the server config")
+	}
+
+	repo, err := gitrepo.Open(s.ctx, binding.RepoPath, conf.Binary, conf.Timeout)
+	if err != nil {
+		return nil, "", err
+	}
+	commit, err := repo.Resolve(s.ctx, ref)
+	if err != nil {
+		return nil, "", err
+	}
+	files, err := repo.ReadFiles(s.ctx, commit, binding.Path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kv := map[string]string{}
+	if binding.Format == models.GitFormatFiles {
+		// 目录中每个文件是一个配置项，key 为相对路径，/ 替换为 .
+		for _, f := range files {
+			rel := strings.TrimPrefix(strings.TrimPrefix(f.Path, binding.Path), "/")
+			if rel == "" {
+				rel = path.Base(f.Path)
+			}
+			key := strings.ReplaceAll(rel, "/", ".")
+			if !utils.ValidateItemKey(key) {
+				return nil, "", fmt.Errorf("file %s: invalid key %q", f.Path, key)
+			}
+			if _, ok := kv[key]; ok {
+				return nil, "", fmt.Errorf("file %s: duplicate key %s", f.Path, key)
+			}
+			if len(f.Data) == 0 {
+				return nil, "", fmt.Errorf("file %s is empty", f.Path)
+			}
+			if !utf8.Valid(f.Data) {
+				return nil, "", fmt.Errorf("file %s is not valid UTF-8", f.Path)
+			}
+			kv[key] = string(f.Data)
+		}
+		return kv, commit, nil
+	}
+
+	if len(files) != 1 || files[0].Path != binding.Path {
+		return nil, "", fmt.Errorf("path %s is a directory, the %s format needs a file", binding.Path, binding.Format)
+	}
+	items, err := render.Parse(render.Format(binding.Format), files[0].Data)
+	if err != nil {
+		return nil, "", fmt.Errorf("file %s: %w", binding.Path, err)
+	}
+	for _, item := range items {
+		if !utils.ValidateItemKey(item.Key) {
+			return nil, "", fmt.Errorf("file %s: invalid key %q", binding.Path, item.Key)
+		}
+		if item.Value == "" {
+			return nil, "", fmt.Errorf("file %s: empty value for key %s", binding.Path, item.Key)
+		}
+		kv[item.Key] = item.Value
+	}
+	return kv, commit, nil
+}
+
+// allowedRepoPath 仓库路径必须是绝对路径，解析符号链接后位于 git.roots 中的某个目录下
+func allowedRepoPath(repoPath string) (string, error) {
+	conf := config.GetGitConfig()
+	if !conf.Enabled {
+		return "", errors.New("git sync is disabled, set git.enabled in the server config")
+	}
+	if !filepath.IsAbs(repoPath) {
+		return "", errors.New("repo_path must be an absolute path")
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(repoPath))
+	if err != nil {
+		return "", fmt.Errorf("repo_path %s: %w", repoPath, err)
+	}
+	for _, root := range conf.Roots {
+		root, err := filepath.EvalSymlinks(filepath.Clean(root))
+		if err != nil {
+			continue
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return resolved, nil
+		}
+	}
+	return "", fmt.Errorf("repo_path %s is not under any of git.roots", repoPath)
+}
+
+// kvDigest 配置项集合的摘要，与顺序无关
+func kvDigest(kv map[string]string) string {
+	hashes := make([]string, 0, len(kv))
+	for k, v := range kv {
+		hashes = append(hashes, utils.ContentHash(k, v))
+	}
+	sort.Strings(hashes)
+	sum := sha256.Sum256([]byte(strings.Join(hashes, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// draftGitCommit 草稿区与绑定仓库最近一次拉取的内容完全一致时返回该 commit，发布时记录在新版本上
+func draftGitCommit(tx *gorm.DB, namespaceID uint64) (string, error) {
+	var binding models.NamespaceGitBinding
+	err := tx.Select("pulled_commit, pulled_hash").Where("namespace_id = ?", namespaceID).Take(&binding).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil || binding.PulledCommit == "" {
+		return "", err
+	}
+
+	var drafts []models.Item
+	if err := tx.Select("k, v").Where("namespace_id = ? AND is_deleted = 0", namespaceID).Find(&drafts).Error; err != nil {
+		return "", err
+	}
+	kv := make(map[string]string, len(drafts))
+	for _, item := range drafts {
+		kv[item.K] = item.V
+	}
+	if kvDigest(kv) != binding.PulledHash {
+		return "", nil
+	}
+	return binding.PulledCommit, nil
+}
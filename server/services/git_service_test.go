@@ -0,0 +1,81 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"quiver/config"
+	"testing"
+)
+
+// loadTestConfig 用临时配置文件加载全局配置
+func loadTestConfig(t *testing.T, yaml string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := config.LoadConfig(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAllowedRepoPath(t *testing.T) {
+	base, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := filepath.Join(base, "repos")
+	outside := filepath.Join(base, "outside")
+	for _, dir := range []string{
+		filepath.Join(root, "app"),
+		filepath.Join(root, "nested", "app"),
+		outside,
+		filepath.Join(base, "repos-evil"),
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 根目录下指向外部的符号链接，以及外部指向根目录的符号链接
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(root, "app"), filepath.Join(base, "link-in")); err != nil {
+		t.Fatal(err)
+	}
+
+	loadTestConfig(t, "git:\n  enabled: true\n  roots: ["+root+"]\n")
+
+	tests := []struct {
+		name    string
+		path    string
+		want    string
+		wantErr bool
+	}{
+		{"repo under root", filepath.Join(root, "app"), filepath.Join(root, "app"), false},
+		{"nested repo", filepath.Join(root, "nested", "app"), filepath.Join(root, "nested", "app"), false},
+		{"unclean path", root + "/nested/../app/", filepath.Join(root, "app"), false},
+		{"symlink into root", filepath.Join(base, "link-in"), filepath.Join(root, "app"), false},
+		{"symlink escaping root", filepath.Join(root, "escape"), "", true},
+		{"dot-dot escaping root", filepath.Join(root, "..", "outside"), "", true},
+		{"sibling with root as prefix", filepath.Join(base, "repos-evil"), "", true},
+		{"relative path", "repos/app", "", true},
+		{"missing path", filepath.Join(root, "missing"), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := allowedRepoPath(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("allowedRepoPath(%s) error = %v, wantErr %v", tt.path, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("allowedRepoPath(%s) = %s, want %s", tt.path, got, tt.want)
+			}
+		})
+	}
+
+	loadTestConfig(t, "git:\n  roots: ["+root+"]\n")
+	if _, err := allowedRepoPath(filepath.Join(root, "app")); err == nil {
+		t.Fatal("allowedRepoPath() accepted a path while git sync is disabled")
+	}
+}
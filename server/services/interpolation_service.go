@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"quiver/config"
+	"quiver/interp"
+	"quiver/logger"
+	"quiver/models"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+// interpolateRelease 解析版本中配置项的引用，引用不存在或有循环时返回错误；
+// publish 模式下把版本标记为已解析并记录解析到的引用，fetch 模式只做校验
+func (s *ReleaseService) interpolateRelease(tx *gorm.DB, env string, release *models.NamespaceRelease) error {
+	conf := config.GetInterpolationConfig()
+	if !conf.Enabled {
+		return nil
+	}
+
+	kv, err := releaseKV(tx, release.NamespaceID, release)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items of %s: %v", release.ReleaseID, err)
+		return errors.New("failed to get release data")
+	}
+	_, refs, err := s.resolveReferences(env, release.AppName, release.ClusterName, release.NamespaceName, release.ReleaseID, kv)
+	if err != nil {
+		return err
+	}
+
+	if conf.Mode == config.InterpolationModePublish {
+		release.Interpolated = true
+		release.Refs = refs
+	}
+	return nil
+}
+
+// resolveReferences 在同一应用、集群内解析 kv 中的引用，返回解析后的值和直接引用的目标。
+// 本命名空间的引用使用 kv 中的值，其他命名空间的引用使用其当前最新版本对外提供的值
+func (s *ReleaseService) resolveReferences(env, appName, clusterName, namespaceName, releaseID string,
+	kv map[string]string) (map[string]string, []models.ReleaseRef, error) {
+	latest := map[string]*models.NamespaceRelease{}
+	latestKV := map[string]map[string]string{}
+	lookup := func(namespace string) (*models.NamespaceRelease, map[string]string) {
+		if release, ok := latest[namespace]; ok {
+			return release, latestKV[namespace]
+		}
+		release, err := s.GetLatestReleaseAll(env, appName, clusterName, namespace)
+		if err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Warnf("referenced namespace %s/%s/%s has no release: %v", appName, clusterName, namespace, err)
+			release = nil
+		}
+		values := map[string]string{}
+		if release != nil {
+			for _, item := range release.Items {
+				values[item.K] = item.V
+			}
+		}
+		latest[namespace], latestKV[namespace] = release, values
+		return release, values
+	}
+
+	resolver := interp.NewResolver(func(namespace, key string) (interp.Value, bool, error) {
+		if namespace == namespaceName {
+			v, ok := kv[key]
+			return interp.Value{Text: v}, ok, nil
+		}
+		release, values := lookup(namespace)
+		v, ok := values[key]
+		// 已解析的版本对外提供的就是最终值，不再展开
+		return interp.Value{Text: v, Resolved: release != nil && release.Interpolated}, ok, nil
+	})
+
+	keys := make([]string, 0, len(kv))
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	resolved := make(map[string]string, len(kv))
+	refs := []models.ReleaseRef{}
+	seen := map[interp.Ref]bool{}
+	for _, k := range keys {
+		v := kv[k]
+		if !interp.HasRefs(v) {
+			resolved[k] = v
+			continue
+		}
+		value, err := resolver.Resolve(namespaceName, k)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve %s: %w", k, err)
+		}
+		resolved[k] = value
+
+		direct, err := interp.Refs(v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to resolve %s: %w", k, err)
+		}
+		for _, ref := range direct {
+			if ref.Namespace == "" {
+				ref.Namespace = namespaceName
+			}
+			if seen[ref] {
+				continue
+			}
+			seen[ref] = true
+
+			refValue, err := resolver.Resolve(ref.Namespace, ref.Key)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to resolve %s: %w", k, err)
+			}
+			refReleaseID := releaseID
+			if ref.Namespace != namespaceName {
+				release, _ := lookup(ref.Namespace)
+				refReleaseID = release.ReleaseID
+			}
+			refs = append(refs, models.ReleaseRef{Namespace: ref.Namespace, Key: ref.Key, ReleaseID: refReleaseID, Value: refValue})
+		}
+	}
+	return resolved, refs, nil
+}
+
+// expandReleaseItems 按发布时记录的引用展开已解析版本的配置项，结果只与版本本身有关，可以长期缓存
+func expandReleaseItems(release *models.NamespaceRelease) error {
+	raw := make(map[string]string, len(release.Items))
+	for _, item := range release.Items {
+		raw[item.K] = item.V
+	}
+
+	resolver := interp.NewResolver(func(namespace, key string) (interp.Value, bool, error) {
+		if namespace != release.NamespaceName {
+			return interp.Value{}, false, nil
+		}
+		v, ok := raw[key]
+		return interp.Value{Text: v}, ok, nil
+	})
+	for _, ref := range release.Refs {
+		if ref.Namespace != release.NamespaceName {
+			resolver.Pin(ref.Namespace, ref.Key, ref.Value)
+		}
+	}
+
+	items := make([]models.ItemRelease, len(release.Items))
+	for i, item := range release.Items {
+		if interp.HasRefs(item.V) {
+			value, err := resolver.Resolve(release.NamespaceName, item.K)
+			if err != nil {
+				return fmt.Errorf("failed to resolve %s: %w", item.K, err)
+			}
+			item.V = value
+		}
+		items[i] = item
+	}
+	release.Items = items
+	return nil
+}
@@ -1,25 +1,40 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/gorm"
 	"quiver/database"
 	"quiver/logger"
 	"quiver/models"
+	"quiver/telemetry"
 	"quiver/utils"
 	"strings"
 )
 
 // ItemService 命名空间服务
-type ItemService struct{}
+type ItemService struct {
+	ctx context.Context
+}
 
 // NewItemService 创建命名空间服务实例
 func NewItemService() *ItemService {
 	return &ItemService{}
 }
 
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *ItemService) WithContext(ctx context.Context) *ItemService {
+	return &ItemService{ctx: ctx}
+}
+
+func (s *ItemService) trace(name string) (*ItemService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "ItemService."+name)
+	return &ItemService{ctx: ctx}, span
+}
+
 func BulkDeleted[T models.HasID](db *gorm.DB, conditions map[string]interface{}) error {
 	var lastID uint64 = 0
 	// 合并条件，确保只处理未删除的
@@ -156,13 +171,25 @@ func CheckACNKFormat(c *fiber.Ctx, env, appName, clusterName, namespaceName, ite
 
 // CheckACNKinDB 检查 app, cluster, namespace,itemKey 是否在数据库中存在
 func CheckACNKinDB(env, appName, clusterName, namespaceName, itemKey *string) (*models.IDs, error) {
+	return CheckACNKinDBContext(context.Background(), env, appName, clusterName, namespaceName, itemKey)
+}
+
+// CheckACNKinDBContext 同 CheckACNKinDB，每一级的查询都作为 ctx 中 span 的子 span
+func CheckACNKinDBContext(ctx context.Context, env, appName, clusterName, namespaceName, itemKey *string) (_ *models.IDs, err error) {
+	ctx, span := telemetry.Start(ctx, "CheckACNKinDB")
+	defer func() { telemetry.End(span, err) }()
+
 	if env == nil || *env == "" {
-		logger.GetLogger("quiver").Errorf("env not found")
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("env not found")
 		return nil, errors.New("env not found")
 	}
 
 	var ids models.IDs
-	db := database.GetDB(*env)
+	db := database.GetDBContext(ctx, *env)
+	if db == nil {
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("db is nil for env %s", *env)
+		return nil, errors.New("db not initialized")
+	}
 
 	if appName == nil {
 		return &ids, nil
@@ -170,9 +197,9 @@ func CheckACNKinDB(env, appName, clusterName, namespaceName, itemKey *string) (*
 
 	// 检查应用是否存在
 	var app models.App
-	err := db.Where("app_name = ?", *appName).First(&app).Error
+	err = db.Where("app_name = ?", *appName).First(&app).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("app %s not found", *appName)
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("app %s not found", *appName)
 		return nil, errors.New("app not found")
 	}
 	ids.AppID = app.AppID
@@ -185,7 +212,7 @@ func CheckACNKinDB(env, appName, clusterName, namespaceName, itemKey *string) (*
 	var cluster models.Cluster
 	err = db.Where("app_id = ? AND cluster_name = ?", app.AppID, *clusterName).First(&cluster).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("cluster %s not found", *clusterName)
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("cluster %s not found", *clusterName)
 		return nil, errors.New("cluster not found")
 	}
 	ids.ClusterID = cluster.ClusterID
@@ -199,7 +226,7 @@ func CheckACNKinDB(env, appName, clusterName, namespaceName, itemKey *string) (*
 	err = db.Where("cluster_id = ? AND namespace_name = ?", cluster.ClusterID, *namespaceName).First(&namespace).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			logger.GetLogger("quiver").Errorf("namespace %s not found in ClusterID %d", *namespaceName, cluster.ClusterID)
+			logger.GetLogger("quiver").WithContext(ctx).Errorf("namespace %s not found in ClusterID %d", *namespaceName, cluster.ClusterID)
 			return nil, errors.New("namespace not found")
 		}
 		return nil, errors.New("namespace not found")
@@ -214,7 +241,7 @@ func CheckACNKinDB(env, appName, clusterName, namespaceName, itemKey *string) (*
 	var item models.Item
 	err = db.Where("namespace_id = ? AND k = ?", namespace.NamespaceID, *itemKey).First(&item).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("item %s not found", *itemKey)
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("item %s not found", *itemKey)
 		return nil, errors.New("item not found")
 	}
 	ids.ItemID = item.ID
@@ -223,17 +250,36 @@ func CheckACNKinDB(env, appName, clusterName, namespaceName, itemKey *string) (*
 }
 
 // SetItem 设置配置项
-func (s *ItemService) SetItem(env string, appName, clusterName, namespaceName, key, value string) error {
+func (s *ItemService) SetItem(env string, appName, clusterName, namespaceName, key, value string) (err error) {
+	s, span := s.trace("SetItem")
+	defer func() { telemetry.End(span, err) }()
+
 	// 检查 app, cluster, namespace
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := s.setItem(tx, ids, key, value); err != nil {
+			return err
+		}
+		return enqueueWebhooks(tx, &WebhookEvent{Event: models.WebhookEventItemSet, Env: env,
+			AppName: appName, ClusterName: clusterName, NamespaceName: namespaceName, Key: key})
+	})
 	if err != nil {
 		return err
 	}
+	WakeWebhooks(env)
+	return nil
+}
 
-	db := database.GetDB(env)
+// setItem 更新或创建草稿配置项，db 可以是调用方的事务
+func (s *ItemService) setItem(db *gorm.DB, ids *models.IDs, key, value string) error {
 	// 更新或创建配置项
 	var item models.Item
-	err = db.Where("namespace_id = ? AND k = ?", ids.NamespaceID, key).First(&item).Error
+	err := db.Where("namespace_id = ? AND k = ?", ids.NamespaceID, key).First(&item).Error
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			kvID := utils.MurmurHash64(key, value)
@@ -249,7 +295,7 @@ func (s *ItemService) SetItem(env string, appName, clusterName, namespaceName, k
 			}
 			err = db.Create(&item).Error
 		} else {
-			logger.GetLogger("quiver").Errorf("failed to create item")
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to create item")
 			return err
 		}
 	} else {
@@ -266,7 +312,7 @@ func (s *ItemService) SetItem(env string, appName, clusterName, namespaceName, k
 	}
 
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("failed to update item")
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to update item")
 		return err
 	}
 
@@ -274,32 +320,38 @@ func (s *ItemService) SetItem(env string, appName, clusterName, namespaceName, k
 }
 
 // GetItem 获取单个配置项
-func (s *ItemService) GetItem(env, appName, clusterName, namespaceName, key string) (*models.Item, error) {
+func (s *ItemService) GetItem(env, appName, clusterName, namespaceName, key string) (_ *models.Item, err error) {
+	s, span := s.trace("GetItem")
+	defer func() { telemetry.End(span, err) }()
+
 	// 检查 app, cluster, namespace
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, &key)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, &key)
 	if err != nil {
 		return nil, err
 	}
 
 	// 检查配置项是否存在
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 	var item models.Item
 	err = db.Where("namespace_id = ? AND k = ?", ids.NamespaceID, key).First(&item).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("item %s not found", key)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("item %s not found", key)
 		return nil, errors.New("item not found")
 	}
 
 	return &item, nil
 }
 
-func (s *ItemService) ListItem(env, appName, clusterName, namespaceName, search string, page, size int) ([]models.Item, int64, error) {
+func (s *ItemService) ListItem(env, appName, clusterName, namespaceName, search string, page, size int) (_ []models.Item, _ int64, err error) {
+	s, span := s.trace("ListItem")
+	defer func() { telemetry.End(span, err) }()
+
 	// 检查 app, cluster, namespace
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
 	if err != nil {
 		return nil, 0, err
 	}
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
 	var items []models.Item
 	var total int64
@@ -316,7 +368,7 @@ func (s *ItemService) ListItem(env, appName, clusterName, namespaceName, search
 
 	// 获取总数（用于分页）
 	if err := query.Model(&models.Item{}).Count(&total).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("failed to count items num: %s for namespace :%s", err.Error(), namespaceName)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to count items num: %s for namespace :%s", err.Error(), namespaceName)
 		return nil, 0, fmt.Errorf("failed to count items: %w", err)
 	}
 
@@ -325,7 +377,7 @@ func (s *ItemService) ListItem(env, appName, clusterName, namespaceName, search
 
 	// 执行分页查询
 	if err := query.Find(&items).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("failed to query items: %s", err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items: %s", err.Error())
 		return nil, 0, fmt.Errorf("failed to query items: %w", err)
 	}
 
@@ -333,13 +385,27 @@ func (s *ItemService) ListItem(env, appName, clusterName, namespaceName, search
 }
 
 // DeleteItem 删除配置项
-func (s *ItemService) DeleteItem(env, appName, clusterName, namespaceName, key string) error {
+func (s *ItemService) DeleteItem(env, appName, clusterName, namespaceName, key string) (err error) {
+	s, span := s.trace("DeleteItem")
+	defer func() { telemetry.End(span, err) }()
+
 	// 检查 app, cluster, namespace
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, &key)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, &key)
 	if err != nil {
 		return err
 	}
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
-	return db.Where("namespace_id = ? AND k = ?", ids.NamespaceID, key).Delete(&models.Item{}).Error
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("namespace_id = ? AND k = ?", ids.NamespaceID, key).Delete(&models.Item{}).Error; err != nil {
+			return err
+		}
+		return enqueueWebhooks(tx, &WebhookEvent{Event: models.WebhookEventItemDelete, Env: env,
+			AppName: appName, ClusterName: clusterName, NamespaceName: namespaceName, Key: key})
+	})
+	if err != nil {
+		return err
+	}
+	WakeWebhooks(env)
+	return nil
 }
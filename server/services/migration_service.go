@@ -0,0 +1,276 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/telemetry"
+	"quiver/utils"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 碰撞明细最多返回的条数
+const maxCollisionReport = 1000
+
+// MigrationService 把旧格式（kv_id）的发布迁移为按 item_release.id 引用的新格式，并检查历史数据中的哈希碰撞
+type MigrationService struct {
+	ctx context.Context
+}
+
+// MigrationReport 迁移结果，dry_run 时为预计迁移的数量
+type MigrationReport struct {
+	Env               string             `json:"env"`
+	DryRun            bool               `json:"dry_run"`
+	HashesFilled      int64              `json:"hashes_filled"`      // 补齐 kv_hash 的 item_release 行数
+	ReleasesMigrated  int64              `json:"releases_migrated"`  // 改写为新格式的发布数
+	ReleasesSkipped   []MissingItems     `json:"releases_skipped"`   // 引用的 item_release 已经缺失，无法迁移
+	KeyCollisions     []KeyCollision     `json:"key_collisions"`     // 同一命名空间内 key 的 32 位哈希相同，旧版本增量接口会把它们当成同一个 key
+	ContentCollisions []ContentCollision `json:"content_collisions"` // 草稿与已有 item_release 的 kv_id 相同但内容不同，旧版本发布时被丢弃
+	Duration          string             `json:"duration"`
+}
+
+// MissingItems 旧格式发布中找不到对应 item_release 的 kv_id
+type MissingItems struct {
+	ReleaseID   string   `json:"release_id"`
+	NamespaceID uint64   `json:"namespace_id"`
+	KvIDs       []uint64 `json:"kv_ids"`
+}
+
+// KeyCollision 同一命名空间内低 32 位相同的不同 key
+type KeyCollision struct {
+	Table       string   `json:"table"`
+	NamespaceID uint64   `json:"namespace_id"`
+	KeyHash     uint32   `json:"key_hash"`
+	Keys        []string `json:"keys"`
+}
+
+// ContentCollision 草稿配置项与 item_release 中 kv_id 相同但 key 或 value 不同的记录
+type ContentCollision struct {
+	NamespaceID uint64 `json:"namespace_id"`
+	KvID        uint64 `json:"kv_id"`
+	Key         string `json:"key"`
+	ReleasedKey string `json:"released_key"`
+}
+
+// NewMigrationService 创建迁移服务实例
+func NewMigrationService() *MigrationService {
+	return &MigrationService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *MigrationService) WithContext(ctx context.Context) *MigrationService {
+	return &MigrationService{ctx: ctx}
+}
+
+func (s *MigrationService) trace(name string) (*MigrationService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "MigrationService."+name)
+	return &MigrationService{ctx: ctx}, span
+}
+
+// MigrateReleases 需要先执行 script/migrate_kv_hash.sql：
+//  1. 为 item_release 补齐 kv_hash
+//  2. 把旧格式的发布快照改写为 item_release.id 列表
+//  3. 报告历史数据中的 key 哈希碰撞和内容碰撞
+//
+// 可以重复执行，已经是新格式的发布会跳过
+func (s *MigrationService) MigrateReleases(env string, dryRun bool) (_ *MigrationReport, err error) {
+	s, span := s.trace("MigrateReleases")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+	if db == nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	start := time.Now()
+	report := &MigrationReport{
+		Env:               env,
+		DryRun:            dryRun,
+		ReleasesSkipped:   []MissingItems{},
+		KeyCollisions:     []KeyCollision{},
+		ContentCollisions: []ContentCollision{},
+	}
+
+	if err := s.fillHashes(db, dryRun, report); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("fill kv_hash for env %s failed: %v", env, err)
+		return nil, err
+	}
+	if err := s.migrateConfigs(db, dryRun, report); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("migrate release config for env %s failed: %v", env, err)
+		return nil, err
+	}
+	if err := s.detectCollisions(db, report); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("detect collisions for env %s failed: %v", env, err)
+		return nil, err
+	}
+
+	report.Duration = time.Since(start).String()
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("migrate releases env %s dry_run %v: %d hashes, %d releases, %d skipped, %d key collisions, %d content collisions",
+		env, dryRun, report.HashesFilled, report.ReleasesMigrated, len(report.ReleasesSkipped), len(report.KeyCollisions), len(report.ContentCollisions))
+	return report, nil
+}
+
+// fillHashes 为旧数据计算 kv_hash。旧的唯一索引保证旧数据中相同内容只有一行，
+// 但执行迁移脚本后、补齐之前的发布可能已经插入了相同内容的新行，这时旧行保持为空，只供旧格式发布按 kv_id 查询
+func (s *MigrationService) fillHashes(db *gorm.DB, dryRun bool, report *MigrationReport) error {
+	if dryRun {
+		return db.Model(&models.ItemRelease{}).Where("kv_hash IS NULL OR kv_hash = ''").Count(&report.HashesFilled).Error
+	}
+
+	var lastID uint64
+	for {
+		var rows []models.ItemRelease
+		if err := db.Select("id, `k`, `v`").
+			Where("id > ? AND (kv_hash IS NULL OR kv_hash = '')", lastID).
+			Order("id ASC").Limit(retentionBatchSize).Find(&rows).Error; err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, row := range rows {
+				result := tx.Exec("UPDATE IGNORE item_release SET kv_hash = ? WHERE id = ?", utils.ContentHash(row.K, row.V), row.ID)
+				if result.Error != nil {
+					return result.Error
+				}
+				report.HashesFilled += result.RowsAffected
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		lastID = rows[len(rows)-1].ID
+	}
+}
+
+// migrateConfigs 把旧格式快照中的 kv_id 解析为 item_release.id 后改写，
+// 解析时持有命名空间行锁，避免与发布、回滚以及保留策略清理交叉
+func (s *MigrationService) migrateConfigs(db *gorm.DB, dryRun bool, report *MigrationReport) error {
+	var lastID uint64
+	for {
+		var releases []models.NamespaceRelease
+		if err := db.Select("id, namespace_id, release_id, config").
+			Where("id > ?", lastID).Order("id ASC").Limit(100).Find(&releases).Error; err != nil {
+			return err
+		}
+		if len(releases) == 0 {
+			return nil
+		}
+		lastID = releases[len(releases)-1].ID
+
+		for _, release := range releases {
+			cfg, err := models.DecodeReleaseConfig(release.Config)
+			if err != nil {
+				logger.GetLogger("quiver").WithContext(s.ctx).Warnf("skip release %s with invalid config: %v", release.ReleaseID, err)
+				continue
+			}
+			if cfg.Version != models.ReleaseConfigLegacy {
+				continue
+			}
+
+			migrate := func(tx *gorm.DB) error {
+				items, err := loadReleaseItems(tx, release.NamespaceID, cfg)
+				if err != nil {
+					return err
+				}
+				idByKvID := make(map[uint64]uint64, len(items))
+				for _, item := range items {
+					idByKvID[item.KvID] = item.ID
+				}
+
+				itemIDs := make([]uint64, 0, len(cfg.KvIDs))
+				var missing []uint64
+				for _, kvID := range cfg.KvIDs {
+					id, ok := idByKvID[kvID]
+					if !ok {
+						missing = append(missing, kvID)
+						continue
+					}
+					itemIDs = append(itemIDs, id)
+				}
+				if len(missing) > 0 {
+					report.ReleasesSkipped = append(report.ReleasesSkipped, MissingItems{
+						ReleaseID:   release.ReleaseID,
+						NamespaceID: release.NamespaceID,
+						KvIDs:       missing,
+					})
+					return nil
+				}
+
+				report.ReleasesMigrated++
+				if dryRun {
+					return nil
+				}
+				data, err := models.EncodeReleaseConfig(itemIDs)
+				if err != nil {
+					return err
+				}
+				return tx.Model(&models.NamespaceRelease{}).Where("id = ?", release.ID).UpdateColumn("config", data).Error
+			}
+
+			if dryRun {
+				err = migrate(db)
+			} else {
+				err = db.Transaction(func(tx *gorm.DB) error {
+					if err := lockNamespace(tx, release.NamespaceID); err != nil {
+						if errors.Is(err, gorm.ErrRecordNotFound) {
+							// 命名空间已删除，交给保留策略清理
+							return nil
+						}
+						return err
+					}
+					return migrate(tx)
+				})
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// detectCollisions 检查历史数据中的 32 位哈希碰撞
+func (s *MigrationService) detectCollisions(db *gorm.DB, report *MigrationReport) error {
+	for _, table := range []string{"item", "item_release"} {
+		var rows []struct {
+			NamespaceID uint64
+			KeyHash     uint32
+			Keys        string
+		}
+		if err := db.Raw(`SELECT namespace_id, kv_id & 0xFFFFFFFF AS key_hash,
+				GROUP_CONCAT(DISTINCT k ORDER BY k SEPARATOR '\n') AS `+"`keys`"+`
+			FROM `+table+`
+			GROUP BY namespace_id, key_hash
+			HAVING COUNT(DISTINCT BINARY k) > 1
+			LIMIT ?`, maxCollisionReport).Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			report.KeyCollisions = append(report.KeyCollisions, KeyCollision{
+				Table:       table,
+				NamespaceID: row.NamespaceID,
+				KeyHash:     row.KeyHash,
+				Keys:        strings.Split(row.Keys, "\n"),
+			})
+		}
+	}
+
+	// 草稿的 kv_id 在 item_release 中只对应到内容不同的行，说明发布时该配置项被 INSERT IGNORE 丢掉了
+	return db.Raw(`SELECT i.namespace_id, i.kv_id, i.k AS `+"`key`"+`, r.k AS released_key
+		FROM item i
+		JOIN item_release r ON r.namespace_id = i.namespace_id AND r.kv_id = i.kv_id
+		WHERE i.is_deleted = 0 AND (BINARY r.k <> BINARY i.k OR BINARY r.v <> BINARY i.v)
+			AND NOT EXISTS (SELECT 1 FROM item_release r2
+				WHERE r2.namespace_id = i.namespace_id AND r2.kv_id = i.kv_id
+					AND BINARY r2.k = BINARY i.k AND BINARY r2.v = BINARY i.v)
+		LIMIT ?`, maxCollisionReport).Scan(&report.ContentCollisions).Error
+}
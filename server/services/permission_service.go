@@ -111,7 +111,18 @@ func (s *PermissionService) CreatePermission(env string, perm *models.Permission
 		return fmt.Errorf("%s with id %d does not exist", perm.ResourceType, perm.ResourceID)
 	}
 
-	return db.Create(perm).Error
+	perm.Source = PermissionSourceManual
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(perm).Error; err != nil {
+			return err
+		}
+		return AppendReleaseMessage(tx, models.MessageKindPermission, perm.CacheKey(env))
+	})
+	if err != nil {
+		return err
+	}
+	NotifyChanges(env)
+	return nil
 }
 
 func (s *PermissionService) GetPermission(env string, userID uint64, permissionId uint64) (*models.Permission, error) {
@@ -252,10 +263,19 @@ func (s *PermissionService) UpdatePermission(env string, update *models.Permissi
 		return nil, fmt.Errorf("%s with id %d does not exist", update.ResourceType, update.ResourceID)
 	}
 
-	if err := db.Model(&update).Updates(update).Error; err != nil {
+	// 权限来源不允许通过接口修改
+	update.Source = ""
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&update).Updates(update).Error; err != nil {
+			return err
+		}
+		return AppendReleaseMessage(tx, models.MessageKindPermission, perm.CacheKey(env))
+	})
+	if err != nil {
 		logger.GetLogger("quiver").Errorf("error updating permission for id %d: %v", update.ID, err)
 		return nil, errors.New("update failed")
 	}
+	NotifyChanges(env)
 
 	return s.GetPermission(env, update.UserID, update.ID)
 }
@@ -280,15 +300,26 @@ func (s *PermissionService) DeletePermission(env string, userID uint64, resource
 		return errors.New("user does not exist")
 	}
 
-	result := db.Where("id = ?", resourceId).Delete(&models.Permission{})
-	if result.Error != nil {
-		logger.GetLogger("quiver").Errorf("error deleting permission for id %d: %v", resourceId, result.Error)
-		return result.Error
-	}
-	if result.RowsAffected == 0 {
-		logger.GetLogger("quiver").Warnf("permission delete failed %s", "permission not found")
-		return errors.New("permission not found")
-	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var perm models.Permission
+		if err := tx.Where("id = ?", resourceId).First(&perm).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logger.GetLogger("quiver").Warnf("permission delete failed %s", "permission not found")
+				return errors.New("permission not found")
+			}
+			logger.GetLogger("quiver").Errorf("error querying permission for id %d: %v", resourceId, err)
+			return err
+		}
 
+		if err := tx.Delete(&perm).Error; err != nil {
+			logger.GetLogger("quiver").Errorf("error deleting permission for id %d: %v", resourceId, err)
+			return err
+		}
+		return AppendReleaseMessage(tx, models.MessageKindPermission, perm.CacheKey(env))
+	})
+	if err != nil {
+		return err
+	}
+	NotifyChanges(env)
 	return nil
 }
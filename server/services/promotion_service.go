@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/telemetry"
+	"quiver/utils"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// PromotionService 把某个版本的配置提升到同一应用的其他集群
+type PromotionService struct {
+	ctx context.Context
+}
+
+// PromoteTarget 提升的目标，namespace_name 为空时与源命名空间同名
+type PromoteTarget struct {
+	ClusterName   string `json:"cluster_name"`
+	NamespaceName string `json:"namespace_name"`
+}
+
+// PromoteResult 单个目标的提升结果，目标之间互不影响
+type PromoteResult struct {
+	ClusterName      string        `json:"cluster_name"`
+	NamespaceName    string        `json:"namespace_name"`
+	Status           string        `json:"status"` // succeeded 或 failed
+	Reason           string        `json:"reason,omitempty"`
+	ReleaseID        string        `json:"release_id,omitempty"`        // publish 时生成的版本
+	Changes          []DraftChange `json:"changes"`                     // 目标草稿区相对源版本的差异，即本次写入的改动
+	UnpublishedEdits []DraftChange `json:"unpublished_edits,omitempty"` // 目标草稿区未发布的改动，未指定 force 时因此失败
+}
+
+// NewPromotionService 创建版本提升服务实例
+func NewPromotionService() *PromotionService {
+	return &PromotionService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *PromotionService) WithContext(ctx context.Context) *PromotionService {
+	return &PromotionService{ctx: ctx}
+}
+
+func (s *PromotionService) trace(name string) (*PromotionService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "PromotionService."+name)
+	return &PromotionService{ctx: ctx}, span
+}
+
+// Promote 把源版本的全部配置项写入各目标命名空间的草稿区，草稿区与源版本完全一致；
+// mode 为 publish 时在同一事务中直接发布，新版本记录 source_release_id。
+// 目标草稿区有未发布的改动时需要 force，否则该目标失败并返回这些改动
+func (s *PromotionService) Promote(env, appName, clusterName, namespaceName, releaseID string, targets []PromoteTarget,
+	mode, releaseName, operator, comment string, force bool) (_ []PromoteResult, err error) {
+	s, span := s.trace("Promote")
+	defer func() { telemetry.End(span, err) }()
+
+	if mode != models.PromoteModeDraft && mode != models.PromoteModePublish {
+		return nil, errors.New("mode must be draft or publish")
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("targets is required")
+	}
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+
+	var source models.NamespaceRelease
+	if err := db.Where("release_id = ? AND namespace_id = ?", releaseID, ids.NamespaceID).First(&source).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("promote release_id %s not found", releaseID)
+		return nil, errors.New("release_id not found")
+	}
+	sourceKV, err := releaseKV(db, ids.NamespaceID, &source)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items of %s: %v", releaseID, err)
+		return nil, errors.New("failed to get release data")
+	}
+	if releaseName == "" {
+		releaseName = "promote-" + source.ReleaseName
+	}
+
+	results := make([]PromoteResult, 0, len(targets))
+	seen := make(map[PromoteTarget]bool, len(targets))
+	for _, target := range targets {
+		if target.NamespaceName == "" {
+			target.NamespaceName = namespaceName
+		}
+		if seen[target] {
+			continue
+		}
+		seen[target] = true
+
+		result := PromoteResult{ClusterName: target.ClusterName, NamespaceName: target.NamespaceName, Changes: []DraftChange{}}
+		if err := s.promoteOne(env, appName, &source, sourceKV, target, mode, releaseName, operator, comment, force, &result); err != nil {
+			// 事务已回滚，草稿区没有改动
+			result.Status, result.Reason, result.Changes = "failed", err.Error(), []DraftChange{}
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("promote %s to %s/%s/%s failed: %v",
+				releaseID, appName, target.ClusterName, target.NamespaceName, err)
+		} else {
+			result.Status = "succeeded"
+			logger.GetLogger("quiver").WithContext(s.ctx).Infof("promoted %s to %s/%s/%s (%s), %d changes",
+				releaseID, appName, target.ClusterName, target.NamespaceName, mode, len(result.Changes))
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func (s *PromotionService) promoteOne(env, appName string, source *models.NamespaceRelease, sourceKV map[string]string, target PromoteTarget,
+	mode, releaseName, operator, comment string, force bool, result *PromoteResult) error {
+	if !utils.ValidateClusterName(target.ClusterName) || !utils.ValidateNamespaceName(target.NamespaceName) {
+		return errors.New("invalid cluster_name or namespace_name")
+	}
+	if target.ClusterName == source.ClusterName && target.NamespaceName == source.NamespaceName {
+		return errors.New("target is the source namespace")
+	}
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &target.ClusterName, &target.NamespaceName, nil)
+	if err != nil {
+		return err
+	}
+
+	record := func(tx *gorm.DB, resultReleaseID string) error {
+		return tx.Create(&models.ReleasePromotion{
+			AppName:           appName,
+			SourceReleaseID:   source.ReleaseID,
+			SourceClusterName: source.ClusterName,
+			SourceNamespace:   source.NamespaceName,
+			TargetClusterName: target.ClusterName,
+			TargetNamespace:   target.NamespaceName,
+			TargetNamespaceID: ids.NamespaceID,
+			Mode:              mode,
+			ResultReleaseID:   resultReleaseID,
+			Operator:          operator,
+			Comment:           comment,
+		}).Error
+	}
+
+	// 在目标命名空间的行锁内检查并改写草稿区
+	prepare := func(tx *gorm.DB, ids *models.IDs) error {
+		var drafts []models.Item
+		if err := tx.Select("id, k, v").Where("namespace_id = ? AND is_deleted = 0", ids.NamespaceID).Find(&drafts).Error; err != nil {
+			return fmt.Errorf("failed to query items: %w", err)
+		}
+
+		latestKV := map[string]string{}
+		var latest models.NamespaceRelease
+		err := tx.Where("namespace_id = ?", ids.NamespaceID).Order("id DESC").First(&latest).Error
+		switch {
+		case err == nil:
+			if latestKV, err = releaseKV(tx, ids.NamespaceID, &latest); err != nil {
+				return errors.New("failed to get release data")
+			}
+		case !errors.Is(err, gorm.ErrRecordNotFound):
+			return errors.New("failed to get release data")
+		}
+
+		if unpublished := diffDraft(drafts, latestKV); len(unpublished) > 0 && !force {
+			result.UnpublishedEdits = unpublished
+			return ErrUnpublishedEdits
+		}
+		result.Changes = diffDraft(drafts, sourceKV)
+		// 只写草稿时按最新版本标记已发布，发布时草稿区就是新版本的内容
+		released := sourceKV
+		if mode == models.PromoteModeDraft {
+			released = latestKV
+		}
+		return restoreDraftItems(tx, ids, drafts, sourceKV, released)
+	}
+
+	if mode == models.PromoteModeDraft {
+		db := database.GetDBContext(s.ctx, env)
+		return db.Transaction(func(tx *gorm.DB) error {
+			if err := lockNamespace(tx, ids.NamespaceID); err != nil {
+				return fmt.Errorf("failed to lock namespace: %w", err)
+			}
+			if err := prepare(tx, ids); err != nil {
+				return err
+			}
+			return record(tx, "")
+		})
+	}
+
+	hook := func(tx *gorm.DB, release *models.NamespaceRelease) error {
+		release.SourceRelease = source.ReleaseID
+		if err := tx.Model(release).UpdateColumn("source_release_id", source.ReleaseID).Error; err != nil {
+			return err
+		}
+		return record(tx, release.ReleaseID)
+	}
+	release, err := NewReleaseService().WithContext(s.ctx).publish(env, appName, target.ClusterName, target.NamespaceName,
+		releaseName, operator, comment, nil, prepare, hook)
+	if err != nil {
+		return err
+	}
+	result.ReleaseID = release.ReleaseID
+	return nil
+}
+
+// ListPromotions 查询某个版本被提升到了哪些集群
+func (s *PromotionService) ListPromotions(env, appName, clusterName, namespaceName, releaseID string) (_ []models.ReleasePromotion, err error) {
+	s, span := s.trace("ListPromotions")
+	defer func() { telemetry.End(span, err) }()
+
+	if _, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil); err != nil {
+		return nil, err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+
+	promotions := []models.ReleasePromotion{}
+	if err := db.Where("app_name = ? AND source_cluster_name = ? AND source_namespace = ? AND source_release_id = ?",
+		appName, clusterName, namespaceName, releaseID).
+		Order("id DESC").Find(&promotions).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query promotions of %s failed: %v", releaseID, err)
+		return nil, err
+	}
+	return promotions, nil
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"errors"
+	"quiver/cache"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/peer"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const releaseMessageSequence = "release_message"
+
+// AppendReleaseMessage 在业务事务 tx 中追加一条变更消息，必须在事务内调用
+// sequence 行锁一直持有到事务提交，因此 seq 的顺序就是提交顺序，回滚的事务也不会留下空洞
+func AppendReleaseMessage(tx *gorm.DB, kind, message string) error {
+	seq := models.Sequence{Name: releaseMessageSequence}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", seq.Name).First(&seq).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 建表脚本会初始化序列，这里兜底
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Sequence{Name: seq.Name}).Error; err != nil {
+			return err
+		}
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", seq.Name).First(&seq).Error
+	}
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("lock release_message sequence failed: %v", err)
+		return err
+	}
+
+	seq.Value++
+	if err := tx.Model(&models.Sequence{}).Where("name = ?", seq.Name).Update("value", seq.Value).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("update release_message sequence failed: %v", err)
+		return err
+	}
+
+	msg := &models.ReleaseMessage{Seq: seq.Value, Kind: kind, Message: message}
+	if err := tx.Create(msg).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("append release_message %s %s failed: %v", kind, message, err)
+		return err
+	}
+	return nil
+}
+
+// NotifyChanges 事务提交后调用：本实例立即消费变更日志并投递 webhook，并广播给其他实例
+func NotifyChanges(env string) {
+	cache.Notify(env)
+	WakeWebhooks(env)
+	peer.Broadcast(env)
+}
@@ -1,26 +1,47 @@
 package services
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"quiver/cache"
+	"quiver/config"
 	"quiver/database"
+	"quiver/interp"
 	"quiver/logger"
 	"quiver/models"
+	"quiver/telemetry"
 	"quiver/utils"
+	"sort"
 	"strings"
 	"time"
 )
 
 // ReleaseService 命名空间服务
-type ReleaseService struct{}
+type ReleaseService struct {
+	ctx context.Context
+}
 
 // NewReleaseService 创建命名空间服务实例
 func NewReleaseService() *ReleaseService {
 	return &ReleaseService{}
 }
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *ReleaseService) WithContext(ctx context.Context) *ReleaseService {
+	return &ReleaseService{ctx: ctx}
+}
+
+func (s *ReleaseService) trace(name string) (*ReleaseService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "ReleaseService."+name)
+	return &ReleaseService{ctx: ctx}, span
+}
 func OnKeyUpdate4Release(env, key string) {
 	if len(env) == 0 || len(key) == 0 {
 		logger.GetLogger("quiver").Errorf("env %s or key %s is empty", env, key)
@@ -44,31 +65,42 @@ func OnKeyUpdate4Release(env, key string) {
 	_, err := s.GetLatestReleaseAll(env, appName, clusterName, namespaceName)
 	logger.GetLogger("quiver").Infof("refresh release cache: %s %s %s %s for key %s, %v",
 		env, appName, clusterName, namespaceName, key, err)
+	publishReleaseEvent(ReleaseEvent{Env: env, AppName: appName, ClusterName: clusterName, NamespaceName: namespaceName})
 	return
 }
 
-// PublishRelease 发布指定命名空间的配置
+// PublishRelease 发布指定命名空间的配置。
+// keys 为空时发布草稿区的全部配置项；否则只发布这些 key 的草稿改动（新增、修改或删除），
+// 新版本由最新版本加上这些改动组成，其余草稿改动保持未发布
 func (s *ReleaseService) PublishRelease(env, appName, clusterName, namespaceName,
-	releaseName, operator, comment string) (*models.NamespaceRelease, error) {
+	releaseName, operator, comment string, keys []string) (_ *models.NamespaceRelease, err error) {
+	s, span := s.trace("PublishRelease")
+	defer func() { telemetry.End(span, err) }()
+
+	return s.publish(env, appName, clusterName, namespaceName, releaseName, operator, comment, keys, nil, nil)
+}
+
+// releaseHook 在发布、回滚的事务中、写入新版本之后执行，返回错误时整个发布回滚
+type releaseHook func(tx *gorm.DB, release *models.NamespaceRelease) error
+
+// prepareHook 在发布的事务中、加锁之后读取草稿之前执行，用于在同一事务中改写草稿
+type prepareHook func(tx *gorm.DB, ids *models.IDs) error
+
+func (s *ReleaseService) publish(env, appName, clusterName, namespaceName,
+	releaseName, operator, comment string, keys []string, prepare prepareHook, hook releaseHook) (*models.NamespaceRelease, error) {
+
 	// 1. 校验 App/Cluster/Namespace 是否存在，并获取 IDs
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
-	// 2. 生成 release_id
-	releaseID, err := utils.GenerateReleaseID()
-	if err != nil {
-		logger.GetLogger("quiver").Errorf("generate release id error: %v", err)
-		return nil, fmt.Errorf("failed to generate release ID: %w", err)
-	}
-
-	// 3. 开启事务
+	// 2. 开启事务
 	tx := db.Begin()
 	if tx.Error != nil {
-		logger.GetLogger("quiver").Errorf("begin transaction failed: %v", tx.Error)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("begin transaction failed: %v", tx.Error)
 		return nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
 	}
 	defer func() {
@@ -77,69 +109,75 @@ func (s *ReleaseService) PublishRelease(env, appName, clusterName, namespaceName
 		}
 	}()
 
-	// 4. 分页查询未发布的 items，并收集 kv_id
-	const batchSize = 1000
-	var allKvIDs []uint64
-	offset := 0
-	totalProcessed := 0
+	namespaceRelease, err := s.publishTx(tx, env, ids, appName, clusterName, namespaceName, releaseName, operator, comment, keys, prepare, hook)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		var items []models.Item
-		if err := tx.
-			Where("namespace_id = ? AND is_deleted = ?", ids.NamespaceID, 0).
-			Order("id ASC").Offset(offset).Limit(batchSize).Find(&items).Error; err != nil {
-			logger.GetLogger("quiver").Errorf("query items failed: %v", err)
-			return nil, fmt.Errorf("failed to query items: %w", err)
-		}
+	// 3. 提交事务
+	if err := tx.Commit().Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("transaction commit failed: %v", err)
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
 
-		if len(items) == 0 {
-			break // 查询完成
-		}
+	tx = nil
+	NotifyChanges(env)
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("Successfully published release %s", namespaceRelease.ReleaseID)
 
-		// 提取 kv_id
-		for _, item := range items {
-			allKvIDs = append(allKvIDs, item.KVId)
-		}
+	// 4. 返回发布结果
+	return namespaceRelease, nil
+}
 
-		// 转换为 item_release 数据
-		var itemReleases []models.ItemRelease
-		for _, item := range items {
-			itemReleases = append(itemReleases, models.ItemRelease{
-				AppID:       item.AppID,
-				ClusterID:   item.ClusterID,
-				NamespaceID: item.NamespaceID,
-				K:           item.K,
-				V:           item.V,
-				KvID:        item.KVId,
-			})
-		}
+// publishTx 在调用方的事务中发布，调用方提交事务后需要调用 NotifyChanges
+func (s *ReleaseService) publishTx(tx *gorm.DB, env string, ids *models.IDs, appName, clusterName, namespaceName,
+	releaseName, operator, comment string, keys []string, prepare prepareHook, hook releaseHook) (*models.NamespaceRelease, error) {
+
+	// 1. 生成 release_id
+	releaseID, err := utils.GenerateReleaseID()
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("generate release id error: %v", err)
+		return nil, fmt.Errorf("failed to generate release ID: %w", err)
+	}
 
-		// 批量插入到 item_release 表，跳过重复（基于 uk_namespace_kv_id）
-		if err := tx.Clauses(clause.Insert{Modifier: "IGNORE"}).
-			Create(&itemReleases).Error; err != nil {
-			logger.GetLogger("quiver").Errorf("batch insert item_release failed: %v", err)
-			return nil, fmt.Errorf("failed to insert into item_release: %w", err)
+	// 锁住命名空间，与其他发布、回滚以及保留策略清理串行执行
+	if err := lockNamespace(tx, ids.NamespaceID); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("lock namespace %d failed: %v", ids.NamespaceID, err)
+		return nil, fmt.Errorf("failed to lock namespace: %w", err)
+	}
+
+	if prepare != nil {
+		if err := prepare(tx, ids); err != nil {
+			return nil, err
 		}
+	}
 
-		totalProcessed += len(items)
-		offset += len(items)
+	// 2. 收集新版本的 item_release.id
+	var itemIDs []uint64
+	var releasedItemIDs []uint64
+	if len(keys) == 0 {
+		itemIDs, err = s.storeDraft(tx, ids.NamespaceID)
+	} else {
+		itemIDs, releasedItemIDs, err = s.storeSelectedKeys(tx, ids.NamespaceID, keys)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// 5. 如果没有要发布的配置项
-	if len(allKvIDs) == 0 {
+	// 3. 如果没有要发布的配置项
+	if len(itemIDs) == 0 {
 		return nil, fmt.Errorf("no unreleased items found for namespace %s", namespaceName)
 	}
 
-	logger.GetLogger("quiver").Infof("Publishing %d items for namespace %s", totalProcessed, namespaceName)
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("Publishing %d items for namespace %s", len(itemIDs), namespaceName)
 
-	// 6. 序列化 kv_id 列表为 MessagePack
-	configData, err := msgpack.Marshal(allKvIDs)
+	// 4. 序列化发布快照
+	configData, err := models.EncodeReleaseConfig(itemIDs)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("msgpack marshal kv_ids failed: %v", err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("msgpack marshal release config failed: %v", err)
 		return nil, fmt.Errorf("failed to serialize config: %w", err)
 	}
 
-	// 7. 写入 namespace_release 表（核心发布记录）
+	// 5. 写入 namespace_release 表（核心发布记录）
 	namespaceRelease := &models.NamespaceRelease{
 		AppID:         ids.AppID,
 		AppName:       appName,
@@ -154,40 +192,257 @@ func (s *ReleaseService) PublishRelease(env, appName, clusterName, namespaceName
 		Comment:       comment,
 	}
 
+	// 解析配置项中的引用，引用不存在或有循环时发布失败
+	if err := s.interpolateRelease(tx, env, namespaceRelease); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("interpolate release %s failed: %v", releaseID, err)
+		return nil, err
+	}
+
+	// 发布全部草稿且草稿与绑定的 Git 仓库最近一次拉取的内容一致时，记录该 commit
+	if len(keys) == 0 {
+		if namespaceRelease.GitCommit, err = draftGitCommit(tx, ids.NamespaceID); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query git binding of namespace %d failed: %v", ids.NamespaceID, err)
+			return nil, fmt.Errorf("failed to query git binding: %w", err)
+		}
+	}
+
 	if err := tx.Create(namespaceRelease).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("create namespace_release failed: %v", err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("create namespace_release failed: %v", err)
 		return nil, fmt.Errorf("failed to create namespace release: %w", err)
 	}
 
-	// 8. 更新 item 表：标记为已发布
-	if err := tx.Model(&models.Item{}).
-		Where("namespace_id = ? AND is_released = ?", ids.NamespaceID, 0).
-		Update("is_released", 1).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("update is_released flag failed: %v", err)
+	// 6. 更新 item 表：标记为已发布，部分发布时只标记选中的配置项
+	markReleased := tx.Model(&models.Item{}).Where("namespace_id = ? AND is_released = ?", ids.NamespaceID, 0)
+	if len(keys) > 0 {
+		markReleased = markReleased.Where("id IN ?", append(releasedItemIDs, 0))
+	}
+	if err := markReleased.Update("is_released", 1).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update is_released flag failed: %v", err)
 		return nil, fmt.Errorf("failed to mark items as released: %w", err)
 	}
 
-	// 9. 提交事务
-	if err := tx.Commit().Error; err != nil {
-		logger.GetLogger("quiver").Errorf("transaction commit failed: %v", err)
-		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	if hook != nil {
+		if err := hook(tx, namespaceRelease); err != nil {
+			return nil, err
+		}
 	}
 
-	tx = nil
-	logger.GetLogger("quiver").Infof("Successfully published release %s with %d items", releaseID, len(allKvIDs))
+	// 订阅了发布事件的 webhook，投递记录与发布记录一起提交
+	if err := enqueueWebhooks(tx, releaseEvent(models.WebhookEventPublish, env, namespaceRelease)); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("enqueue webhooks for release %s failed: %v", releaseID, err)
+		return nil, err
+	}
+
+	// 7. 追加变更消息，与发布记录一起提交
+	if err := AppendReleaseMessage(tx, models.MessageKindRelease, namespaceRelease.CacheKey(env)); err != nil {
+		return nil, fmt.Errorf("failed to append release message: %w", err)
+	}
 
-	// 10. 返回发布结果
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("release %s with %d items written", releaseID, len(itemIDs))
 	return namespaceRelease, nil
 }
 
+// storeDraft 分页读取草稿区全部配置项写入 item_release，返回新版本的 item_release.id
+func (s *ReleaseService) storeDraft(tx *gorm.DB, namespaceID uint64) ([]uint64, error) {
+	const batchSize = 1000
+	var itemIDs []uint64
+	offset := 0
+
+	for {
+		var items []models.Item
+		if err := tx.
+			Where("namespace_id = ? AND is_deleted = ?", namespaceID, 0).
+			Order("id ASC").Offset(offset).Limit(batchSize).Find(&items).Error; err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query items failed: %v", err)
+			return nil, fmt.Errorf("failed to query items: %w", err)
+		}
+
+		if len(items) == 0 {
+			return itemIDs, nil // 查询完成
+		}
+
+		batchIDs, err := s.storeItems(tx, namespaceID, items)
+		if err != nil {
+			return nil, err
+		}
+		itemIDs = append(itemIDs, batchIDs...)
+		offset += len(items)
+	}
+}
+
+// storeSelectedKeys 在最新版本的基础上应用选中 key 的草稿改动：草稿中有的按草稿写入，草稿中已删除的从版本中去掉。
+// 返回新版本的 item_release.id，以及需要标记为已发布的草稿 item.id
+func (s *ReleaseService) storeSelectedKeys(tx *gorm.DB, namespaceID uint64, keys []string) ([]uint64, []uint64, error) {
+	// 最新版本的配置项，没有发布过时为空
+	var latestItems []models.ItemRelease
+	var latest models.NamespaceRelease
+	err := tx.Where("namespace_id = ?", namespaceID).Order("id DESC").First(&latest).Error
+	switch {
+	case err == nil:
+		cfg, err := models.DecodeReleaseConfig(latest.Config)
+		if err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to unmarshal %s config", latest.ReleaseID)
+			return nil, nil, errors.New("failed to get release data")
+		}
+		if latestItems, err = loadReleaseItems(tx, namespaceID, cfg); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query release items failed: %v", err)
+			return nil, nil, errors.New("failed to get release data")
+		}
+	case !errors.Is(err, gorm.ErrRecordNotFound):
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query latest release failed: %v", err)
+		return nil, nil, errors.New("failed to get release data")
+	}
+
+	var drafts []models.Item
+	for i := 0; i < len(keys); i += 1000 {
+		var batch []models.Item
+		if err := tx.Where("namespace_id = ? AND is_deleted = 0 AND k IN ?", namespaceID, keys[i:min(i+1000, len(keys))]).
+			Find(&batch).Error; err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query items failed: %v", err)
+			return nil, nil, fmt.Errorf("failed to query items: %w", err)
+		}
+		drafts = append(drafts, batch...)
+	}
+
+	selection, err := selectKeys(keys, latestItems, drafts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	changedIDs, err := s.storeItems(tx, namespaceID, selection.changed)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(selection.kept, changedIDs...), selection.releasedItemIDs, nil
+}
+
+// keySelection 部分发布的计划
+type keySelection struct {
+	changed         []models.Item // 需要写入 item_release 的草稿配置项
+	kept            []uint64      // 沿用最新版本的 item_release.id
+	releasedItemIDs []uint64      // 发布后标记为已发布的草稿 item.id
+}
+
+// selectKeys 在最新版本 latestItems 的基础上应用选中 key 的草稿改动，drafts 为选中 key 的草稿：
+// 草稿中有且内容有变化的重新写入，草稿中已删除的从版本中去掉，未选中的和选中但没有改动的沿用最新版本。
+// 选中的 key 在草稿和最新版本中都不存在，或者选中的 key 都没有改动时报错
+func selectKeys(keys []string, latestItems []models.ItemRelease, drafts []models.Item) (*keySelection, error) {
+	latestKV := make(map[string]models.ItemRelease, len(latestItems))
+	for _, item := range latestItems {
+		latestKV[item.K] = item
+	}
+	draftKV := make(map[string]models.Item, len(drafts))
+	for _, item := range drafts {
+		draftKV[item.K] = item
+	}
+
+	selection := &keySelection{}
+	selected := make(map[string]bool, len(keys))
+	unchanged := make(map[string]bool)
+	changes := 0
+	for _, k := range keys {
+		if selected[k] {
+			continue
+		}
+		selected[k] = true
+		draft, inDraft := draftKV[k]
+		released, inLatest := latestKV[k]
+		switch {
+		case !inDraft && !inLatest:
+			return nil, fmt.Errorf("item %s not found", k)
+		case !inDraft:
+			changes++
+		case !inLatest || released.V != draft.V:
+			changes++
+			selection.changed = append(selection.changed, draft)
+			selection.releasedItemIDs = append(selection.releasedItemIDs, draft.ID)
+		default:
+			unchanged[k] = true
+			selection.releasedItemIDs = append(selection.releasedItemIDs, draft.ID)
+		}
+	}
+	if changes == 0 {
+		return nil, errors.New("no unreleased changes in selected keys")
+	}
+
+	selection.kept = make([]uint64, 0, len(latestItems)+len(selection.changed))
+	for _, item := range latestItems {
+		if !selected[item.K] || unchanged[item.K] {
+			selection.kept = append(selection.kept, item.ID)
+		}
+	}
+	return selection, nil
+}
+
+// storeItems 把草稿配置项写入 item_release，按内容哈希去重，返回与 items 一一对应的 item_release.id
+func (s *ReleaseService) storeItems(tx *gorm.DB, namespaceID uint64, items []models.Item) ([]uint64, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	// 转换为 item_release 数据
+	var itemReleases []models.ItemRelease
+	hashes := make([]string, 0, len(items))
+	for _, item := range items {
+		hash := utils.ContentHash(item.K, item.V)
+		hashes = append(hashes, hash)
+		itemReleases = append(itemReleases, models.ItemRelease{
+			AppID:       item.AppID,
+			ClusterID:   item.ClusterID,
+			NamespaceID: item.NamespaceID,
+			K:           item.K,
+			V:           item.V,
+			KvID:        item.KVId,
+			KvHash:      hash,
+		})
+	}
+
+	// 批量插入到 item_release 表，跳过重复（基于 uk_namespace_kv_hash）
+	if err := tx.Clauses(clause.Insert{Modifier: "IGNORE"}).
+		CreateInBatches(&itemReleases, 1000).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("batch insert item_release failed: %v", err)
+		return nil, fmt.Errorf("failed to insert into item_release: %w", err)
+	}
+
+	// 取回 id，内容相同的行可能在之前的发布中已经存在
+	idByHash := make(map[string]uint64, len(hashes))
+	for i := 0; i < len(hashes); i += 1000 {
+		var rows []models.ItemRelease
+		if err := tx.Select("id, kv_hash").
+			Where("namespace_id = ? AND kv_hash IN ?", namespaceID, hashes[i:min(i+1000, len(hashes))]).
+			Find(&rows).Error; err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query item_release ids failed: %v", err)
+			return nil, fmt.Errorf("failed to query item_release: %w", err)
+		}
+		for _, row := range rows {
+			idByHash[row.KvHash] = row.ID
+		}
+	}
+
+	itemIDs := make([]uint64, 0, len(items))
+	for i, hash := range hashes {
+		id, ok := idByHash[hash]
+		if !ok {
+			// 旧的 uk_namespace_kv_id 唯一索引还在时，kv_id 碰撞的行会被 INSERT IGNORE 丢掉
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("item_release for key %s not stored, kv_id %d may collide", items[i].K, items[i].KVId)
+			return nil, fmt.Errorf("failed to store item %s, please apply script/migrate_kv_hash.sql", items[i].K)
+		}
+		itemIDs = append(itemIDs, id)
+	}
+	return itemIDs, nil
+}
+
 // ListRelease 获取集群下的所有命名空间
-func (s *ReleaseService) ListRelease(env, appName, clusterName, namespaceName string, page, size int) ([]models.NamespaceRelease, int64, error) {
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+func (s *ReleaseService) ListRelease(env, appName, clusterName, namespaceName string, page, size int) (_ []models.NamespaceRelease, _ int64, err error) {
+	s, span := s.trace("ListRelease")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
 	if err != nil {
 		return nil, 0, err
 	}
 
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
 	var releases []models.NamespaceRelease
 	var total int64
@@ -197,7 +452,7 @@ func (s *ReleaseService) ListRelease(env, appName, clusterName, namespaceName st
 
 	// 获取总数（用于分页）
 	if err := query.Model(&models.NamespaceRelease{}).Count(&total).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("failed to count release num : %s for cluster: %s", err.Error(), clusterName)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to count release num : %s for cluster: %s", err.Error(), clusterName)
 		return nil, 0, fmt.Errorf("failed to count release: %w", err)
 	}
 
@@ -205,125 +460,129 @@ func (s *ReleaseService) ListRelease(env, appName, clusterName, namespaceName st
 
 	// 执行分页查询
 	if err := query.Offset(offset).Limit(size).Find(&releases).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("failed to query release: %s", err.Error())
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query release: %s", err.Error())
 		return nil, 0, fmt.Errorf("failed to query release: %w", err)
 	}
 
 	return releases, total, nil
 }
 
-func (s *ReleaseService) GetFixedReleaseAll(env, releaseID string) (*models.NamespaceRelease, error) {
+func (s *ReleaseService) GetFixedReleaseAll(env, releaseID string) (_ *models.NamespaceRelease, err error) {
+	s, span := s.trace("GetFixedReleaseAll")
+	defer func() { telemetry.End(span, err) }()
+
 	if releaseID == "" {
 		return nil, fmt.Errorf("releaseID is empty")
 	}
 
 	// 1、先检查缓存里面有没有
-	releaseKey := fmt.Sprintf("release:%s:%s", env, releaseID)
-	data, ok, _ := cache.Get(releaseKey)
+	releaseKey := models.ReleaseCacheKey(env, releaseID)
+	data, ok, _ := cache.GetContext(s.ctx, releaseKey)
 	if ok {
 		if len(data) > 0 {
 			nr := &models.NamespaceRelease{}
 			if err := msgpack.Unmarshal(data, nr); err != nil {
-				// 数据错误，删除缓存
-				_ = cache.Delete(releaseKey)
-				logger.GetLogger("quiver").Warnf("error unmarshaling release: %s, %v", releaseKey, err)
+				// 数据错误（例如持久化缓存里的旧格式），删除缓存后从数据库重新加载
+				_ = cache.DeleteContext(s.ctx, releaseKey)
+				logger.GetLogger("quiver").WithContext(s.ctx).Warnf("error unmarshaling release: %s, %v", releaseKey, err)
+			} else {
+				logger.GetLogger("quiver").WithContext(s.ctx).Infof("get release %s from cache success", releaseKey)
+				return nr, nil
 			}
-
-			logger.GetLogger("quiver").Infof("get release %s from cache success", releaseKey)
-			return nr, nil
 		} else {
 			// 数据已经破坏
-			_ = cache.Delete(releaseKey)
+			_ = cache.DeleteContext(s.ctx, releaseKey)
 		}
 	}
 
 	// 2、从数据库中获取
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 	var baseRelease models.NamespaceRelease
 	if err := db.Where("release_id = ?", releaseID).First(&baseRelease).Error; err != nil {
-		logger.GetLogger("quiver").Warnf("Release %s not found", releaseID)
+		logger.GetLogger("quiver").WithContext(s.ctx).Warnf("Release %s not found", releaseID)
 		return nil, errors.New("no releases found")
 	}
 
-	var allKvIDs []uint64
-	if len(baseRelease.Config) > 0 {
-		if err := msgpack.Unmarshal(baseRelease.Config, &allKvIDs); err != nil {
-			logger.GetLogger("quiver").Errorf("failed to unmarshal %s config", releaseID)
-			return nil, errors.New("failed to get release data")
-		}
+	cfg, err := models.DecodeReleaseConfig(baseRelease.Config)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to unmarshal %s config", releaseID)
+		return nil, errors.New("failed to get release data")
 	}
-	if len(allKvIDs) == 0 {
+	if cfg.Len() == 0 {
 		return nil, errors.New("no kv found")
 	}
 
-	// 3、 根据kv_id 取 item_release 表获取数据
-	var allItems []models.ItemRelease
-	for i := 0; i < len(allKvIDs); i += 1000 {
-		end := i + 1000
-		if end > len(allKvIDs) {
-			end = len(allKvIDs)
-		}
-		chunk := allKvIDs[i:end]
-		var batch []models.ItemRelease
-		if err := db.Table("item_release").
-			Select("kv_id, `k`, `v`"). // 可优化为只查 key，但通常一起查
-			Where("namespace_id =? AND kv_id IN (?)", baseRelease.NamespaceID, chunk).
-			Scan(&batch).Error; err != nil {
-			logger.GetLogger("quiver").Errorf("failed to query items: %s", err.Error())
-			return nil, err
-		}
-		// 追加到结果
-		allItems = append(allItems, batch...)
+	// 3、 根据快照取 item_release 表获取数据
+	allItems, err := loadReleaseItems(db, baseRelease.NamespaceID, cfg)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items: %s", err.Error())
+		return nil, err
 	}
 
 	baseRelease.Items = allItems
-	baseRelease.KvIDs = allKvIDs
+
+	// 已解析的版本按发布时记录的引用展开，失败时按原值提供
+	if baseRelease.Interpolated {
+		if err := expandReleaseItems(&baseRelease); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to expand release %s: %v", releaseID, err)
+		}
+	}
 
 	// 4、写入cache 缓存
 	if data, err := msgpack.Marshal(&baseRelease); err == nil {
 		if len(data) > 0 && len(baseRelease.ReleaseID) > 0 {
-			releaseKey := fmt.Sprintf("release:%s:%s", env, baseRelease.ReleaseID)
-			_ = cache.Set(releaseKey, data, 24*30*time.Hour)
+			releaseKey := models.ReleaseCacheKey(env, baseRelease.ReleaseID)
+			_ = cache.SetContext(s.ctx, releaseKey, data, 24*30*time.Hour)
 		}
 	}
 	return &baseRelease, nil
 }
 
-func (s *ReleaseService) GetLatestReleaseAll(env, appName, clusterName, namespaceName string) (*models.NamespaceRelease, error) {
+// ErrNoRelease 命名空间还没有任何发布
+var ErrNoRelease = errors.New("no releases found")
+
+func (s *ReleaseService) GetLatestReleaseAll(env, appName, clusterName, namespaceName string) (_ *models.NamespaceRelease, err error) {
+	s, span := s.trace("GetLatestReleaseAll")
+	defer func() { telemetry.End(span, err) }()
+
 	// 1、先检查缓存里面有没有
 	r := models.NamespaceRelease{AppName: appName, ClusterName: clusterName, NamespaceName: namespaceName}
-	data, ok, err := cache.Get(r.CacheKey(env))
-	logger.GetLogger("quiver").Infof("cache.Get %s %v %v %v", r.CacheKey(env), data, ok, err)
+	data, ok, err := cache.GetContext(s.ctx, r.CacheKey(env))
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("cache.Get %s %v %v %v", r.CacheKey(env), data, ok, err)
 	if ok {
 		if len(data) > 0 {
 			// 把data 转成 string
 			releaseID := string(data)
 			nr, err := s.GetFixedReleaseAll(env, releaseID)
-			logger.GetLogger("quiver").Warnf("get fixed release %s, err: %v", releaseID, err)
+			logger.GetLogger("quiver").WithContext(s.ctx).Warnf("get fixed release %s, err: %v", releaseID, err)
 			if err == nil && nr != nil {
 				return nr, nil
 			}
 		} else {
 			// 数据已经破坏
-			_ = cache.Delete(r.CacheKey(env))
+			_ = cache.DeleteContext(s.ctx, r.CacheKey(env))
 		}
 	}
 
 	// 2. 校验并获取 namespace ID
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
 	// 3. 获取最近一次的 release kv_id
 	var latestRelease models.NamespaceRelease
 	if err := db.Where("namespace_id = ?", ids.NamespaceID).
 		Order("id DESC").
 		First(&latestRelease).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("no releases found for %s/%s/%s/%s", env, appName, clusterName, namespaceName)
-		return nil, errors.New("no releases found")
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.GetLogger("quiver").WithContext(s.ctx).Warnf("no releases found for %s/%s/%s/%s", env, appName, clusterName, namespaceName)
+			return nil, ErrNoRelease
+		}
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("error querying latest release of %s/%s/%s/%s: %v", env, appName, clusterName, namespaceName, err)
+		return nil, err
 	}
 	if latestRelease.ReleaseID == "" || len(latestRelease.Config) == 0 {
 		return nil, errors.New("release not found")
@@ -332,133 +591,120 @@ func (s *ReleaseService) GetLatestReleaseAll(env, appName, clusterName, namespac
 	// 尝试从缓存中获取
 	nr, err := s.GetFixedReleaseAll(env, latestRelease.ReleaseID)
 	if err != nil || nr == nil {
-		logger.GetLogger("quiver").Errorf("failed to get latest release %s %s data", env, latestRelease.ReleaseID)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to get latest release %s %s data", env, latestRelease.ReleaseID)
 		return nil, fmt.Errorf("failed to get latest release data: %w", err)
 	}
 
 	latestRelease.Items = nr.Items
-	latestRelease.KvIDs = nr.KvIDs
 
 	// 写入cache 缓存
 	if data, err := msgpack.Marshal(&latestRelease); err == nil {
 		if len(data) > 0 && len(latestRelease.ReleaseID) > 0 {
-			_ = cache.Set(latestRelease.CacheKey(env), []byte(latestRelease.ReleaseID), 300*time.Second)
-			releaseKey := fmt.Sprintf("release:%s:%s", env, latestRelease.ReleaseID)
-			_ = cache.Set(releaseKey, data, 24*30*time.Hour)
-			logger.GetLogger("quiver").Infof("write %s %s to cache", latestRelease.CacheKey(env), releaseKey)
+			_ = cache.SetContext(s.ctx, latestRelease.CacheKey(env), []byte(latestRelease.ReleaseID), 300*time.Second)
+			releaseKey := models.ReleaseCacheKey(env, latestRelease.ReleaseID)
+			_ = cache.SetContext(s.ctx, releaseKey, data, 24*30*time.Hour)
+			logger.GetLogger("quiver").WithContext(s.ctx).Infof("write %s %s to cache", latestRelease.CacheKey(env), releaseKey)
 		}
 	}
 	return &latestRelease, nil
 }
 
-// GetRelease 获取特定命名空间
-func (s *ReleaseService) GetRelease(env, appName, clusterName, namespaceName, releaseId string) (map[string]interface{}, error) {
-	// 1、从缓存中获取最近一次发布的release内容
+// ReleaseETag 计算客户端拉取配置时响应的 ETag，由最新版本的 release_id、客户端当前的 release_id 和引用的解析结果决定。
+// 通常只需要读取缓存中最新版本的 release_id；fetch 模式下未解析的版本还需要按被引用命名空间当前的值解析
+func (s *ReleaseService) ReleaseETag(env, appName, clusterName, namespaceName, releaseId string) (_ string, err error) {
+	s, span := s.trace("ReleaseETag")
+	defer func() { telemetry.End(span, err) }()
+
+	conf := config.GetInterpolationConfig()
+	if !conf.Enabled || conf.Mode != config.InterpolationModeFetch {
+		latestID, err := s.latestReleaseID(env, appName, clusterName, namespaceName)
+		if err != nil {
+			return "", err
+		}
+		return releaseETag(latestID, releaseId, nil, nil), nil
+	}
+
 	latestRelease, err := s.GetLatestReleaseAll(env, appName, clusterName, namespaceName)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("get latest release all error: %v", err)
-		return nil, err
+		return "", err
 	}
-	//logger.GetLogger("quiver").Infof("get latest release all: %v", latestRelease)
-
-	latestKvIDs := latestRelease.KvIDs
-	// 2、建立映射表，方便后续查找
-	latestKH := make(map[uint32]uint64, len(latestKvIDs))
-	latestK := make([]uint32, 0, len(latestKvIDs)) // 预分配容量，提升性能
-	for _, h := range latestKvIDs {
-		k := uint32(h & 0xFFFFFFFF) // 取低 32 位
-		latestK = append(latestK, k)
-		latestKH[k] = h
+	if latestRelease.Interpolated {
+		return releaseETag(latestRelease.ReleaseID, releaseId, nil, nil), nil
 	}
-
-	//logger.GetLogger("quiver").Infof("latestKvIDs: %v", latestKvIDs)
-
-	// 3. 获取 base release（客户端传来的release版本）
-	var baseKvIDs []uint64
-	baseRelease, err := s.GetFixedReleaseAll(env, releaseId)
-	if err == nil && baseRelease != nil {
-		baseKvIDs = baseRelease.KvIDs
+	kv := make(map[string]string, len(latestRelease.Items))
+	for _, item := range latestRelease.Items {
+		kv[item.K] = item.V
 	}
-
-	// 4、同样建立映射表，方便后续查找
-	baseKH := make(map[uint32]uint64, len(baseKvIDs))
-	baseK := make([]uint32, 0, len(baseKvIDs)) // 预分配容量，提升性能
-	for _, h := range baseKvIDs {
-		k := uint32(h & 0xFFFFFFFF) // 取低 32 位
-		baseK = append(baseK, k)
-		baseKH[k] = h
+	resolved, _, err := s.resolveReferences(env, appName, clusterName, namespaceName, latestRelease.ReleaseID, kv)
+	if err != nil {
+		return "", err
 	}
+	return releaseETag(latestRelease.ReleaseID, releaseId, kv, resolved), nil
+}
 
-	// 5、得到增删改三部分
-	addK, bothK, deleteK := utils.Diff32(latestK, baseK)
-	// 计算新增，update, 和 delete 的 kvid中有变化的部分
-	var allKvIDs, addKvIDs, updateKvIDs, deleteKvIDs []uint64
-	for _, k := range bothK {
-		if latestKH[k] != baseKH[k] {
-			updateKvIDs = append(updateKvIDs, latestKH[k])
+// releaseETag 由最新版本的 release_id 和客户端当前的 release_id 计算 ETag；
+// kv 为未解析的版本内容时，含引用的 key 解析后的值（resolved）也参与计算
+func releaseETag(latestID, releaseID string, kv, resolved map[string]string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", latestID, releaseID)
+	keys := make([]string, 0, len(kv))
+	for k, v := range kv {
+		if interp.HasRefs(v) {
+			keys = append(keys, k)
 		}
 	}
-	allKvIDs = append(allKvIDs, updateKvIDs...)
-	for _, k := range addK {
-		addKvIDs = append(addKvIDs, latestKH[k])
-	}
-	allKvIDs = append(allKvIDs, addKvIDs...)
-	for _, k := range deleteK {
-		deleteKvIDs = append(deleteKvIDs, baseKvIDs[k])
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(h, "%s\n", utils.ContentHash(k, resolved[k]))
 	}
-	allKvIDs = append(allKvIDs, deleteKvIDs...)
-
-	logger.GetLogger("quiver").Infof("item del %d , updated %d, add %d",
-		len(deleteKvIDs), len(updateKvIDs), len(addKvIDs))
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
 
-	// 6. 构建 kvid -> {k,v} 映射
-	type KvPair struct {
-		Key   string
-		Value string
+// latestReleaseID 返回命名空间最新版本的 release_id，优先读取缓存中的指针，不加载配置项
+func (s *ReleaseService) latestReleaseID(env, appName, clusterName, namespaceName string) (string, error) {
+	r := models.NamespaceRelease{AppName: appName, ClusterName: clusterName, NamespaceName: namespaceName}
+	if data, ok, _ := cache.GetContext(s.ctx, r.CacheKey(env)); ok && len(data) > 0 {
+		return string(data), nil
 	}
 
-	idToKv := make(map[uint64]KvPair, len(allKvIDs))
-
-	for _, item := range latestRelease.Items {
-		idToKv[item.KvID] = KvPair{
-			Key:   item.K,
-			Value: item.V,
-		}
-	}
-	if baseRelease != nil {
-		for _, item := range baseRelease.Items {
-			idToKv[item.KvID] = KvPair{
-				Key:   item.K,
-				Value: item.V,
-			}
-		}
+	latestRelease, err := s.GetLatestReleaseAll(env, appName, clusterName, namespaceName)
+	if err != nil {
+		return "", err
 	}
+	return latestRelease.ReleaseID, nil
+}
 
-	// 6. 构造 changed 差异（只返回 key 列表）
-	addKeys, updateKeys, deleteKeys := []string{}, []string{}, []string{}
-	kvList := make([]KvPair, 0, len(addKvIDs)+len(updateKvIDs))
+// KvPair 客户端拉取到的配置项
+type KvPair struct {
+	Key   string
+	Value string
+}
 
-	for _, id := range addKvIDs {
-		if item, exists := idToKv[id]; exists {
-			addKeys = append(addKeys, item.Key)
-			kvList = append(kvList, KvPair{Key: item.Key, Value: item.Value})
-		}
-	}
+// ReleaseDelta 最新版本相对客户端当前版本的增量，Items 只包含新增和修改的配置项
+type ReleaseDelta struct {
+	Release *models.NamespaceRelease
+	Items   []KvPair
+	Added   []string
+	Updated []string
+	Deleted []string
+	ETag    string // 与 Release 和解析结果对应的 ETag，引用解析失败时为空
+}
 
-	for _, id := range updateKvIDs {
-		if item, exists := idToKv[id]; exists {
-			updateKeys = append(updateKeys, item.Key)
-			kvList = append(kvList, KvPair{Key: item.Key, Value: item.Value})
-		}
-	}
+// GetRelease 获取特定命名空间
+func (s *ReleaseService) GetRelease(env, appName, clusterName, namespaceName, releaseId string) (_ map[string]interface{}, err error) {
+	s, span := s.trace("GetRelease")
+	defer func() { telemetry.End(span, err) }()
 
-	for _, id := range deleteKvIDs {
-		if item, exists := idToKv[id]; exists {
-			deleteKeys = append(deleteKeys, item.Key)
-		}
+	delta, err := s.GetReleaseDelta(env, appName, clusterName, namespaceName, releaseId)
+	if err != nil {
+		return nil, err
 	}
+	return delta.Response(env, appName, clusterName, namespaceName, releaseId), nil
+}
 
-	// 7、回idToKv 和 addKeys, updateKeys, deleteKeys
+// Response 客户端拉取接口返回的 data：最新版本的变化内容，releaseId 不为空时还包括新增、修改、删除的 key
+func (d *ReleaseDelta) Response(env, appName, clusterName, namespaceName, releaseId string) map[string]interface{} {
+	latestRelease := d.Release
 	ret := map[string]interface{}{
 		"env":            env,
 		"app_name":       appName,
@@ -469,63 +715,224 @@ func (s *ReleaseService) GetRelease(env, appName, clusterName, namespaceName, re
 		"release_time":   latestRelease.ReleaseTime,
 		"operator":       latestRelease.Operator,
 		"comment":        latestRelease.Comment,
-		"items":          kvList,
+		"items":          d.Items,
+	}
+	if latestRelease.SourceRelease != "" {
+		ret["source_release_id"] = latestRelease.SourceRelease
 	}
 
 	if releaseId != "" {
 		ret["changed"] = map[string]interface{}{
-			"added":   addKeys,
-			"updated": updateKeys,
-			"deleted": deleteKeys,
+			"added":   d.Added,
+			"updated": d.Updated,
+			"deleted": d.Deleted,
 		}
 	}
+	return ret
+}
 
-	//logger.GetLogger("quiver").Infof("GetRelease: %+v", ret)
-	return ret, nil
+// GetReleaseDelta 计算命名空间最新版本相对 releaseId 的增量，releaseId 为空或无效时返回完整配置
+func (s *ReleaseService) GetReleaseDelta(env, appName, clusterName, namespaceName, releaseId string) (_ *ReleaseDelta, err error) {
+	s, span := s.trace("GetReleaseDelta")
+	defer func() { telemetry.End(span, err) }()
+
+	// 1、从缓存中获取最近一次发布的release内容
+	latestRelease, err := s.GetLatestReleaseAll(env, appName, clusterName, namespaceName)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("get latest release all error: %v", err)
+		return nil, err
+	}
+
+	// 2、按 key 建立映射，key 在命名空间内唯一，直接比较 key 和 value，不依赖哈希
+	latestKV := make(map[string]string, len(latestRelease.Items))
+	for _, item := range latestRelease.Items {
+		latestKV[item.K] = item.V
+	}
+
+	// 3. 获取 base release（客户端传来的release版本）
+	var baseItems []models.ItemRelease
+	baseRelease, err := s.GetFixedReleaseAll(env, releaseId)
+	if err == nil && baseRelease != nil {
+		baseItems = baseRelease.Items
+	}
+	baseKV := make(map[string]string, len(baseItems))
+	for _, item := range baseItems {
+		baseKV[item.K] = item.V
+	}
+
+	// 4、得到增删改三部分，只返回新增和修改的内容
+	// fetch 模式下未解析的版本按被引用命名空间当前的值解析，含引用的 key 每次都返回
+	var resolvedKV map[string]string
+	etag := releaseETag(latestRelease.ReleaseID, releaseId, nil, nil)
+	if conf := config.GetInterpolationConfig(); conf.Enabled && conf.Mode == config.InterpolationModeFetch && !latestRelease.Interpolated {
+		resolvedKV, _, err = s.resolveReferences(env, appName, clusterName, namespaceName, latestRelease.ReleaseID, latestKV)
+		if err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to resolve release %s: %v", latestRelease.ReleaseID, err)
+			resolvedKV = nil
+			etag = ""
+		} else {
+			etag = releaseETag(latestRelease.ReleaseID, releaseId, latestKV, resolvedKV)
+		}
+	}
+
+	delta := &ReleaseDelta{Release: latestRelease, Items: []KvPair{}, Added: []string{}, Updated: []string{}, Deleted: []string{}, ETag: etag}
+	for _, item := range latestRelease.Items {
+		old, ok := baseKV[item.K]
+		switch {
+		case !ok:
+			delta.Added = append(delta.Added, item.K)
+		case old != item.V:
+			delta.Updated = append(delta.Updated, item.K)
+		case resolvedKV != nil && interp.HasRefs(item.V):
+			// 原值未变，但被引用的值可能已经变化，仍然返回
+		default:
+			continue
+		}
+		value := item.V
+		if resolvedKV != nil {
+			value = resolvedKV[item.K]
+		}
+		delta.Items = append(delta.Items, KvPair{Key: item.K, Value: value})
+	}
+	for _, item := range baseItems {
+		if _, ok := latestKV[item.K]; !ok {
+			delta.Deleted = append(delta.Deleted, item.K)
+		}
+	}
+
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("item del %d , updated %d, add %d",
+		len(delta.Deleted), len(delta.Updated), len(delta.Added))
+	return delta, nil
+}
+
+// MaxBatchReleaseQueries 一次批量拉取最多包含的命名空间数
+const MaxBatchReleaseQueries = 100
+
+// ReleaseQuery 批量拉取中的一个命名空间，release_id 为客户端当前的版本，可以为空
+type ReleaseQuery struct {
+	ClusterName   string `json:"cluster_name"`
+	NamespaceName string `json:"namespace_name"`
+	ReleaseID     string `json:"release_id"`
+}
+
+// BatchReleaseResult 批量拉取中单个命名空间的结果，status 为 ok、not_modified 或 failed
+type BatchReleaseResult struct {
+	ClusterName   string                 `json:"cluster_name"`
+	NamespaceName string                 `json:"namespace_name"`
+	Status        string                 `json:"status"`
+	Reason        string                 `json:"reason,omitempty"`
+	ReleaseID     string                 `json:"release_id,omitempty"` // not_modified 时为客户端当前的版本
+	Release       map[string]interface{} `json:"release,omitempty"`    // 与单个命名空间拉取接口的 data 相同
+}
+
+// BatchGetRelease 一次拉取同一应用下多个命名空间的最新版本或增量，单个命名空间失败不影响其他命名空间。
+// 客户端已经是最新版本时返回 not_modified，只读取缓存中最新版本的 release_id
+func (s *ReleaseService) BatchGetRelease(env, appName string, queries []ReleaseQuery) (_ []BatchReleaseResult, err error) {
+	s, span := s.trace("BatchGetRelease")
+	defer func() { telemetry.End(span, err) }()
+
+	if len(queries) == 0 {
+		return nil, errors.New("namespaces is required")
+	}
+	if len(queries) > MaxBatchReleaseQueries {
+		return nil, fmt.Errorf("too many namespaces, at most %d", MaxBatchReleaseQueries)
+	}
+
+	// fetch 模式下同一版本的值也可能随被引用的命名空间变化，不能按 release_id 判断没有变化
+	conf := config.GetInterpolationConfig()
+	checkLatest := !conf.Enabled || conf.Mode != config.InterpolationModeFetch
+
+	results := make([]BatchReleaseResult, 0, len(queries))
+	for _, q := range queries {
+		result := BatchReleaseResult{ClusterName: q.ClusterName, NamespaceName: q.NamespaceName}
+		switch {
+		case !utils.ValidateClusterName(q.ClusterName):
+			result.Status, result.Reason = "failed", "invalid cluster_name"
+		case !utils.ValidateNamespaceName(q.NamespaceName):
+			result.Status, result.Reason = "failed", "invalid namespace_name"
+		default:
+			if checkLatest && q.ReleaseID != "" {
+				if latestID, err := s.latestReleaseID(env, appName, q.ClusterName, q.NamespaceName); err == nil && latestID == q.ReleaseID {
+					result.Status, result.ReleaseID = "not_modified", q.ReleaseID
+					break
+				}
+			}
+			release, err := s.GetRelease(env, appName, q.ClusterName, q.NamespaceName, q.ReleaseID)
+			if err != nil {
+				result.Status, result.Reason = "failed", err.Error()
+				break
+			}
+			result.Status, result.Release = "ok", release
+		}
+		results = append(results, result)
+	}
+	return results, nil
 }
 
-func (s *ReleaseService) RollbackRelease(env, appName, clusterName, namespaceName, releaseId, operator, comment string) (*models.NamespaceRelease, error) {
+// ErrUnpublishedEdits 恢复草稿时草稿区还有未发布的改动，需要 force 才能覆盖
+var ErrUnpublishedEdits = errors.New("draft has unpublished edits, publish or discard them first, or use force=true to overwrite")
+
+// DraftChange 草稿区相对某个发布版本的差异，type 为 added（草稿新增）、updated（值不同）、deleted（草稿中已删除）
+type DraftChange struct {
+	Key          string `json:"key"`
+	Type         string `json:"type"`
+	Value        string `json:"value"`
+	ReleaseValue string `json:"release_value"`
+}
+
+// RollbackResult 回滚结果
+type RollbackResult struct {
+	Release          *models.NamespaceRelease `json:"-"`
+	DraftRestored    bool                     `json:"draft_restored"`
+	UnpublishedEdits []DraftChange            `json:"unpublished_edits"` // 回滚前草稿区相对当前版本未发布的改动
+	DiscardedEdits   []DraftChange            `json:"discarded_edits"`   // 恢复草稿时被覆盖的草稿内容
+	PendingChanges   []DraftChange            `json:"pending_changes"`   // 未恢复草稿时，草稿区相对回滚版本的差异，下次发布会重新发布这些改动
+}
+
+// RollbackRelease 以目标版本的内容生成一个新版本。
+// restoreDraft 为 true 时同时把草稿区改写为目标版本的内容，否则草稿区保持不变，下次发布会把草稿中的改动重新发布出去；
+// 草稿区有未发布的改动时，恢复草稿需要 force，否则返回 ErrUnpublishedEdits 和这些改动
+func (s *ReleaseService) RollbackRelease(env, appName, clusterName, namespaceName, releaseId, operator, comment string, restoreDraft, force bool) (_ *RollbackResult, err error) {
+	s, span := s.trace("RollbackRelease")
+	defer func() { telemetry.End(span, err) }()
+
+	return s.rollback(env, appName, clusterName, namespaceName, releaseId, operator, comment, restoreDraft, force, nil)
+}
+
+func (s *ReleaseService) rollback(env, appName, clusterName, namespaceName, releaseId, operator, comment string, restoreDraft, force bool, hook releaseHook) (*RollbackResult, error) {
+
 	// 1. 校验并获取 namespace ID
-	ids, err := CheckACNKinDB(&env, &appName, &clusterName, &namespaceName, nil)
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	db := database.GetDB(env)
+	db := database.GetDBContext(s.ctx, env)
 
-	// 2、判断待回滚的 releaseId 是否存在
+	// 2、判断待回滚的 releaseId 是否存在，且属于该命名空间
 	if releaseId == "" {
-		logger.GetLogger("quiver").Errorf("rollback release_id is empty")
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("rollback release_id is empty")
 		return nil, errors.New("release_id not found")
 	}
 
 	var release models.NamespaceRelease
-	err = db.Where("release_id = ?", releaseId).First(&release).Error
+	err = db.Where("release_id = ? AND namespace_id = ?", releaseId, ids.NamespaceID).First(&release).Error
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("rollback release_id %s not found", releaseId)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("rollback release_id %s not found", releaseId)
 		return nil, errors.New("release_id not found")
 	}
 
-	// 3. 获取 base release（客户端传来的release版本）
-	var baseKvIDs []uint64
-	if len(release.Config) > 0 {
-		if err := msgpack.Unmarshal(release.Config, &baseKvIDs); err != nil {
-			logger.GetLogger("quiver").Errorf("failed to unmarshal %s config", releaseId)
-			return nil, errors.New("failed to get release data")
-		}
-	}
-
-	// 4. 生成 release_id
+	// 3. 生成 release_id
 	newReleaseID, err := utils.GenerateReleaseID()
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("generate release id error: %v", err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("generate release id error: %v", err)
 		return nil, fmt.Errorf("failed to generate release ID: %w", err)
 	}
 
-	// 5. 开启事务
+	// 4. 开启事务
 	tx := db.Begin()
 	if tx.Error != nil {
-		logger.GetLogger("quiver").Errorf("begin transaction failed: %v", tx.Error)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("begin transaction failed: %v", tx.Error)
 		return nil, fmt.Errorf("failed to begin transaction: %w", tx.Error)
 	}
 
@@ -535,47 +942,272 @@ func (s *ReleaseService) RollbackRelease(env, appName, clusterName, namespaceNam
 		}
 	}()
 
-	//6、设置item 表(草稿区) 的 namespace_id 为 ids 的 namespaceId 的 is_released 字段为 0
-	err = tx.Model(&models.Item{}).Where("namespace_id = ? AND is_deleted = 0", ids.NamespaceID).
-		Update("is_released", 0).Error
+	// 锁住命名空间后再确认目标版本没有被保留策略清理掉，之后读取的草稿和当前版本不会再被并发修改
+	if err := lockNamespace(tx, ids.NamespaceID); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("lock namespace %d failed: %v", ids.NamespaceID, err)
+		return nil, fmt.Errorf("failed to lock namespace: %w", err)
+	}
+	var exists int64
+	if err := tx.Model(&models.NamespaceRelease{}).Where("release_id = ?", releaseId).Count(&exists).Error; err != nil || exists == 0 {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("rollback release_id %s not found", releaseId)
+		return nil, errors.New("release_id not found")
+	}
+
+	// 5. 读取目标版本、当前版本和草稿区的配置项
+	targetKV, err := releaseKV(tx, ids.NamespaceID, &release)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items of %s: %v", releaseId, err)
+		return nil, errors.New("failed to get release data")
+	}
 
+	var latest models.NamespaceRelease
+	if err := tx.Where("namespace_id = ?", ids.NamespaceID).Order("id DESC").First(&latest).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query latest release of namespace %d failed: %v", ids.NamespaceID, err)
+		return nil, errors.New("failed to get release data")
+	}
+	latestKV, err := releaseKV(tx, ids.NamespaceID, &latest)
 	if err != nil {
-		logger.GetLogger("quiver").Errorf("update items failed: %v", err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query items of %s: %v", latest.ReleaseID, err)
+		return nil, errors.New("failed to get release data")
+	}
+
+	var drafts []models.Item
+	if err := tx.Select("id, k, v").Where("namespace_id = ? AND is_deleted = 0", ids.NamespaceID).Find(&drafts).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query items failed: %v", err)
 		return nil, fmt.Errorf("failed to query items: %w", err)
 	}
 
-	//7、重新发布该版本
-	release.ID = 0
-	release.ReleaseID = newReleaseID
-	release.ReleaseName = "rollback-" + release.ReleaseName
-	release.Operator = operator
-	release.Comment = comment
-	logger.GetLogger("quiver").Errorf("step 7 release :%v", release)
+	result := &RollbackResult{
+		DraftRestored:    restoreDraft,
+		UnpublishedEdits: diffDraft(drafts, latestKV),
+		DiscardedEdits:   []DraftChange{},
+		PendingChanges:   []DraftChange{},
+	}
+	if restoreDraft && len(result.UnpublishedEdits) > 0 && !force {
+		logger.GetLogger("quiver").WithContext(s.ctx).Warnf("refuse to rollback %s: %d unpublished edits in draft", releaseId, len(result.UnpublishedEdits))
+		return result, ErrUnpublishedEdits
+	}
+
+	// 6. 更新草稿区
+	if restoreDraft {
+		result.DiscardedEdits = diffDraft(drafts, targetKV)
+		if err := restoreDraftItems(tx, ids, drafts, targetKV, targetKV); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("restore draft of namespace %d failed: %v", ids.NamespaceID, err)
+			return nil, fmt.Errorf("failed to restore draft: %w", err)
+		}
+	} else {
+		result.PendingChanges = diffDraft(drafts, targetKV)
+		if err := markReleasedItems(tx, ids.NamespaceID, drafts, targetKV); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update items failed: %v", err)
+			return nil, fmt.Errorf("failed to update items: %w", err)
+		}
+	}
+
+	// 7、重新发布该版本
+	release = rollbackRelease(release, newReleaseID, operator, comment)
+	// 目标版本已解析时沿用其记录的引用，回滚后对外提供的值与目标版本一致
+	if !release.Interpolated {
+		if err := s.interpolateRelease(tx, env, &release); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("interpolate release %s failed: %v", newReleaseID, err)
+			return nil, err
+		}
+	}
 	if err := tx.Create(&release).Error; err != nil {
-		logger.GetLogger("quiver").Errorf("create namespace_release failed: %v", err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("create namespace_release failed: %v", err)
 		return nil, fmt.Errorf("failed to create namespace release: %w", err)
 	}
 
-	// 8、 恢复item表中没有变化的 的is_release marked
-	const batchSize = 1000
-	for i := 0; i < len(baseKvIDs); i += batchSize {
-		chunk := baseKvIDs[i:min(i+batchSize, len(baseKvIDs))]
+	if hook != nil {
+		if err := hook(tx, &release); err != nil {
+			return nil, err
+		}
+	}
 
-		err = tx.Model(&models.Item{}).
-			Where("namespace_id = ? AND kv_id IN (?)", ids.NamespaceID, chunk).
-			Update("is_released", 1).Error
+	event := releaseEvent(models.WebhookEventRollback, env, &release)
+	event.Release.TargetReleaseID = releaseId
+	if err := enqueueWebhooks(tx, event); err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("enqueue webhooks for release %s failed: %v", newReleaseID, err)
+		return nil, err
+	}
 
-		if err != nil {
-			logger.GetLogger("quiver").Errorf("update batch failed: %v", err)
-			return nil, fmt.Errorf("failed to update batch: %w", err)
-		}
+	// 8. 追加变更消息，与回滚记录一起提交
+	if err := AppendReleaseMessage(tx, models.MessageKindRelease, release.CacheKey(env)); err != nil {
+		return nil, fmt.Errorf("failed to append release message: %w", err)
 	}
 
 	// 9. 提交事务
 	if err := tx.Commit().Error; err != nil {
-		logger.GetLogger("quiver").Errorf("transaction commit failed: %v", err)
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("transaction commit failed: %v", err)
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 	tx = nil
-	return &release, nil
+	NotifyChanges(env)
+
+	if restoreDraft {
+		logger.GetLogger("quiver").WithContext(s.ctx).Infof("rollback %s to %s, draft restored, %d edits discarded",
+			namespaceName, releaseId, len(result.DiscardedEdits))
+	} else if len(result.PendingChanges) > 0 {
+		logger.GetLogger("quiver").WithContext(s.ctx).Warnf("rollback %s to %s without restoring draft, %d draft changes will be published again on next publish",
+			namespaceName, releaseId, len(result.PendingChanges))
+	}
+	result.Release = &release
+	return result, nil
+}
+
+// rollbackRelease 回滚生成的新版本，内容与目标版本相同。
+// source_release_id 表示版本由提升而来，回滚版本不是提升的结果，不沿用；
+// git_commit 表示内容与仓库中哪个 commit 一致，内容没有变化，沿用目标版本的记录；
+// 发布时间清零，由创建时重新生成
+func rollbackRelease(target models.NamespaceRelease, releaseID, operator, comment string) models.NamespaceRelease {
+	release := target
+	release.ID = 0
+	release.ReleaseTime, release.CreateTime, release.UpdateTime = time.Time{}, time.Time{}, time.Time{}
+	release.ReleaseID = releaseID
+	release.ReleaseName = "rollback-" + target.ReleaseName
+	release.Operator = operator
+	release.Comment = comment
+	release.SourceRelease = ""
+	return release
+}
+
+// releaseKV 读取发布版本的全部配置项，返回 key -> value
+func releaseKV(db *gorm.DB, namespaceID uint64, release *models.NamespaceRelease) (map[string]string, error) {
+	cfg, err := models.DecodeReleaseConfig(release.Config)
+	if err != nil {
+		return nil, err
+	}
+	items, err := loadReleaseItems(db, namespaceID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	kv := make(map[string]string, len(items))
+	for _, item := range items {
+		kv[item.K] = item.V
+	}
+	return kv, nil
+}
+
+// diffDraft 按 key 和 value 比较草稿区与发布版本，结果按 key 排序
+func diffDraft(drafts []models.Item, released map[string]string) []DraftChange {
+	changes := []DraftChange{}
+	inDraft := make(map[string]bool, len(drafts))
+	for _, item := range drafts {
+		inDraft[item.K] = true
+		v, ok := released[item.K]
+		switch {
+		case !ok:
+			changes = append(changes, DraftChange{Key: item.K, Type: "added", Value: item.V})
+		case v != item.V:
+			changes = append(changes, DraftChange{Key: item.K, Type: "updated", Value: item.V, ReleaseValue: v})
+		}
+	}
+	for k, v := range released {
+		if !inDraft[k] {
+			changes = append(changes, DraftChange{Key: k, Type: "deleted", ReleaseValue: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+// markReleasedItems 只把草稿区中与发布版本 key、value 都相同的配置项标记为已发布
+func markReleasedItems(tx *gorm.DB, namespaceID uint64, drafts []models.Item, released map[string]string) error {
+	if err := tx.Model(&models.Item{}).Where("namespace_id = ? AND is_deleted = 0", namespaceID).
+		Update("is_released", 0).Error; err != nil {
+		return err
+	}
+
+	var releasedIDs []uint64
+	for _, item := range drafts {
+		if v, ok := released[item.K]; ok && v == item.V {
+			releasedIDs = append(releasedIDs, item.ID)
+		}
+	}
+	for i := 0; i < len(releasedIDs); i += 1000 {
+		chunk := releasedIDs[i:min(i+1000, len(releasedIDs))]
+		if err := tx.Model(&models.Item{}).Where("id IN (?)", chunk).Update("is_released", 1).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreDraftItems 把草稿区改写为 target 的内容：删除多出来的 key，缺少或不同的按 target 写入；
+// 与 released（最新版本）key、value 都相同的配置项标记为已发布，其余标记为未发布
+func restoreDraftItems(tx *gorm.DB, ids *models.IDs, drafts []models.Item, target, released map[string]string) error {
+	var delIDs []uint64
+	for _, item := range drafts {
+		if _, ok := target[item.K]; !ok {
+			delIDs = append(delIDs, item.ID)
+		}
+	}
+	for i := 0; i < len(delIDs); i += 1000 {
+		chunk := delIDs[i:min(i+1000, len(delIDs))]
+		if err := tx.Where("namespace_id = ? AND id IN ?", ids.NamespaceID, chunk).Delete(&models.Item{}).Error; err != nil {
+			return err
+		}
+	}
+
+	items := make([]models.Item, 0, len(target))
+	for k, v := range target {
+		item := models.Item{
+			AppID:       ids.AppID,
+			ClusterID:   ids.ClusterID,
+			NamespaceID: ids.NamespaceID,
+			K:           k,
+			V:           v,
+			KVId:        utils.MurmurHash64(k, v),
+		}
+		if rv, ok := released[k]; ok && rv == v {
+			item.IsReleased = 1
+		}
+		items = append(items, item)
+	}
+	if len(items) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "namespace_id"}, {Name: "k"}},
+		DoUpdates: clause.AssignmentColumns([]string{"v", "kv_id", "is_released", "is_deleted"}),
+	}).CreateInBatches(&items, 1000).Error
+}
+
+// lockNamespace 在事务中对命名空间加行锁，发布、回滚、保留策略清理都要先获取，
+// 避免清理程序删除正在被新版本引用的 item_release
+func lockNamespace(tx *gorm.DB, namespaceID uint64) error {
+	var ns models.Namespace
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id = ?", namespaceID).First(&ns).Error
+}
+
+// loadReleaseItems 按发布快照读取配置项：新格式按 item_release 主键查询；
+// 旧格式按 (namespace_id, kv_id) 查询，同一个 kv_id 有多行时（碰撞）取最早的一行，即发布时存在的那一行
+func loadReleaseItems(db *gorm.DB, namespaceID uint64, cfg *models.ReleaseConfig) ([]models.ItemRelease, error) {
+	column, ids := "id", cfg.ItemIDs
+	if cfg.Version == models.ReleaseConfigLegacy {
+		column, ids = "kv_id", cfg.KvIDs
+	}
+
+	var allItems []models.ItemRelease
+	seen := make(map[uint64]bool, len(ids))
+	for i := 0; i < len(ids); i += 1000 {
+		chunk := ids[i:min(i+1000, len(ids))]
+		var batch []models.ItemRelease
+		if err := db.Table("item_release").
+			Select("id, kv_id, kv_hash, `k`, `v`").
+			Where("namespace_id = ? AND "+column+" IN (?)", namespaceID, chunk).
+			Order("id ASC").
+			Scan(&batch).Error; err != nil {
+			return nil, err
+		}
+		for _, item := range batch {
+			if cfg.Version == models.ReleaseConfigLegacy {
+				if seen[item.KvID] {
+					continue
+				}
+				seen[item.KvID] = true
+			}
+			allItems = append(allItems, item)
+		}
+	}
+	return allItems, nil
 }
@@ -0,0 +1,366 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"quiver/models"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestDiffDraft(t *testing.T) {
+	tests := []struct {
+		name     string
+		drafts   []models.Item
+		released map[string]string
+		want     []DraftChange
+	}{
+		{
+			name:     "no changes",
+			drafts:   []models.Item{{K: "a", V: "1"}, {K: "b", V: "2"}},
+			released: map[string]string{"a": "1", "b": "2"},
+			want:     []DraftChange{},
+		},
+		{
+			name:     "added, updated and deleted sorted by key",
+			drafts:   []models.Item{{K: "c", V: "new"}, {K: "a", V: "2"}, {K: "d", V: "4"}},
+			released: map[string]string{"a": "1", "b": "gone", "d": "4"},
+			want: []DraftChange{
+				{Key: "a", Type: "updated", Value: "2", ReleaseValue: "1"},
+				{Key: "b", Type: "deleted", ReleaseValue: "gone"},
+				{Key: "c", Type: "added", Value: "new"},
+			},
+		},
+		{
+			name:     "never released",
+			drafts:   []models.Item{{K: "b", V: "2"}, {K: "a", V: "1"}},
+			released: nil,
+			want: []DraftChange{
+				{Key: "a", Type: "added", Value: "1"},
+				{Key: "b", Type: "added", Value: "2"},
+			},
+		},
+		{
+			name:     "empty value differs from missing key",
+			drafts:   []models.Item{{K: "a", V: ""}},
+			released: map[string]string{},
+			want:     []DraftChange{{Key: "a", Type: "added", Value: ""}},
+		},
+		{
+			name:     "draft emptied",
+			drafts:   nil,
+			released: map[string]string{"a": "1"},
+			want:     []DraftChange{{Key: "a", Type: "deleted", ReleaseValue: "1"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffDraft(tt.drafts, tt.released); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("diffDraft() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectKeys(t *testing.T) {
+	// 最新版本：a=1 b=2 c=3，对应 item_release.id 11 12 13
+	latest := []models.ItemRelease{
+		{ID: 11, K: "a", V: "1"},
+		{ID: 12, K: "b", V: "2"},
+		{ID: 13, K: "c", V: "3"},
+	}
+	// 草稿：a 未改动，b 改为 20，c 已删除，d 新增
+	drafts := map[string]models.Item{
+		"a": {ID: 1, K: "a", V: "1"},
+		"b": {ID: 2, K: "b", V: "20"},
+		"d": {ID: 4, K: "d", V: "4"},
+	}
+	draftsOf := func(keys ...string) []models.Item {
+		var items []models.Item
+		for _, k := range keys {
+			if item, ok := drafts[k]; ok {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	changedKeys := func(items []models.Item) []string {
+		var keys []string
+		for _, item := range items {
+			keys = append(keys, item.K)
+		}
+		return keys
+	}
+
+	tests := []struct {
+		name         string
+		keys         []string
+		latest       []models.ItemRelease
+		wantChanged  []string
+		wantKept     []uint64
+		wantReleased []uint64
+		wantErr      bool
+	}{
+		{
+			name:         "update one key keeps the others",
+			keys:         []string{"b"},
+			latest:       latest,
+			wantChanged:  []string{"b"},
+			wantKept:     []uint64{11, 13},
+			wantReleased: []uint64{2},
+		},
+		{
+			name:         "delete one key",
+			keys:         []string{"c"},
+			latest:       latest,
+			wantKept:     []uint64{11, 12},
+			wantReleased: nil,
+		},
+		{
+			name:         "add one key",
+			keys:         []string{"d"},
+			latest:       latest,
+			wantChanged:  []string{"d"},
+			wantKept:     []uint64{11, 12, 13},
+			wantReleased: []uint64{4},
+		},
+		{
+			name:         "unchanged key selected with a change",
+			keys:         []string{"a", "b"},
+			latest:       latest,
+			wantChanged:  []string{"b"},
+			wantKept:     []uint64{11, 13},
+			wantReleased: []uint64{1, 2},
+		},
+		{
+			name:         "duplicated keys counted once",
+			keys:         []string{"b", "b", "c"},
+			latest:       latest,
+			wantChanged:  []string{"b"},
+			wantKept:     []uint64{11},
+			wantReleased: []uint64{2},
+		},
+		{
+			name:         "first release",
+			keys:         []string{"a", "d"},
+			latest:       nil,
+			wantChanged:  []string{"a", "d"},
+			wantKept:     []uint64{},
+			wantReleased: []uint64{1, 4},
+		},
+		{
+			name:    "only unchanged keys",
+			keys:    []string{"a"},
+			latest:  latest,
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			keys:    []string{"b", "missing"},
+			latest:  latest,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := selectKeys(tt.keys, tt.latest, draftsOf(tt.keys...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("selectKeys() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if keys := changedKeys(got.changed); !reflect.DeepEqual(keys, tt.wantChanged) {
+				t.Errorf("changed = %v, want %v", keys, tt.wantChanged)
+			}
+			if !reflect.DeepEqual(got.kept, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", got.kept, tt.wantKept)
+			}
+			if !reflect.DeepEqual(got.releasedItemIDs, tt.wantReleased) {
+				t.Errorf("releasedItemIDs = %v, want %v", got.releasedItemIDs, tt.wantReleased)
+			}
+		})
+	}
+}
+
+func TestReleaseETag(t *testing.T) {
+	base := releaseETag("r2", "r1", nil, nil)
+	if len(base) != 34 || base[0] != '"' || base[33] != '"' {
+		t.Fatalf("releaseETag() = %s, want a quoted 32-char hex", base)
+	}
+	if got := releaseETag("r2", "r1", nil, nil); got != base {
+		t.Fatalf("releaseETag() not stable: %s != %s", got, base)
+	}
+	// 最新版本或客户端版本不同，ETag 不同
+	if releaseETag("r3", "r1", nil, nil) == base || releaseETag("r2", "", nil, nil) == base {
+		t.Fatal("releaseETag() ignores the release ids")
+	}
+
+	// 只有含引用的 key 的解析结果参与计算
+	kv := map[string]string{"url": "${host}:3306", "host": "db"}
+	resolved := map[string]string{"url": "db:3306", "host": "db"}
+	withRefs := releaseETag("r2", "r1", kv, resolved)
+	if withRefs == base {
+		t.Fatal("releaseETag() ignores resolved references")
+	}
+	if got := releaseETag("r2", "r1", kv, map[string]string{"url": "db:3306", "host": "other"}); got != withRefs {
+		t.Fatal("releaseETag() depends on keys without references")
+	}
+	if got := releaseETag("r2", "r1", kv, map[string]string{"url": "db2:3306", "host": "db"}); got == withRefs {
+		t.Fatal("releaseETag() unchanged after a referenced value changed")
+	}
+	if got := releaseETag("r2", "r1", map[string]string{"host": "db"}, resolved); got != base {
+		t.Fatal("releaseETag() changed for a release without references")
+	}
+}
+
+// execRecorder 记录执行的语句，用于检查只写入、不查询的 helper
+type execRecorder struct {
+	statements []recordedExec
+}
+
+type recordedExec struct {
+	query string
+	args  []driver.Value
+}
+
+type recorderResult struct{}
+
+func (recorderResult) LastInsertId() (int64, error) { return 1, nil }
+func (recorderResult) RowsAffected() (int64, error) { return 1, nil }
+
+type recorderConnector struct{ r *execRecorder }
+
+func (c recorderConnector) Connect(context.Context) (driver.Conn, error) { return recorderConn(c), nil }
+func (c recorderConnector) Driver() driver.Driver                        { return nil }
+
+type recorderConn struct{ r *execRecorder }
+
+func (c recorderConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c recorderConn) Close() error              { return nil }
+func (c recorderConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+// CheckNamedValue 与 MySQL 驱动一样接受 uint64 等类型的参数
+func (c recorderConn) CheckNamedValue(*driver.NamedValue) error { return nil }
+func (c recorderConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.r.statements = append(c.r.statements, recordedExec{query: query, args: values})
+	return recorderResult{}, nil
+}
+
+var insertColumns = regexp.MustCompile("^INSERT INTO `item` \\(([^)]*)\\)")
+
+// TestRestoreDraftItems 草稿区改写为 target，只有与最新版本一致的配置项标记为已发布，不需要再次查询和更新
+func TestRestoreDraftItems(t *testing.T) {
+	r := &execRecorder{}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(recorderConnector{r: r}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids := &models.IDs{AppID: 1, ClusterID: 2, NamespaceID: 3}
+	drafts := []models.Item{{ID: 11, K: "a", V: "1"}, {ID: 12, K: "b", V: "draft"}, {ID: 13, K: "gone", V: "x"}}
+	target := map[string]string{"a": "1", "b": "2", "c": "3"}
+	released := map[string]string{"a": "1", "b": "old", "gone": "x"}
+	if err := restoreDraftItems(db, ids, drafts, target, released); err != nil {
+		t.Fatalf("restoreDraftItems() error = %v", err)
+	}
+
+	var deleted []driver.Value
+	isReleased := map[string]string{}
+	for _, st := range r.statements {
+		switch {
+		case strings.HasPrefix(st.query, "DELETE FROM `item`"):
+			deleted = append(deleted, st.args[1:]...)
+		case strings.HasPrefix(st.query, "INSERT INTO `item`"):
+			m := insertColumns.FindStringSubmatch(st.query)
+			if m == nil {
+				t.Fatalf("unexpected insert %q", st.query)
+			}
+			columns := strings.Split(strings.ReplaceAll(m[1], "`", ""), ",")
+			kIdx, releasedIdx := -1, -1
+			for i, column := range columns {
+				switch column {
+				case "k":
+					kIdx = i
+				case "is_released":
+					releasedIdx = i
+				}
+			}
+			if kIdx < 0 || releasedIdx < 0 || len(st.args)%len(columns) != 0 {
+				t.Fatalf("unexpected insert columns %v with %d args", columns, len(st.args))
+			}
+			for i := 0; i < len(st.args); i += len(columns) {
+				isReleased[fmt.Sprint(st.args[i+kIdx])] = fmt.Sprint(st.args[i+releasedIdx])
+			}
+		default:
+			t.Fatalf("unexpected statement %q", st.query)
+		}
+	}
+
+	if want := []driver.Value{uint64(13)}; !reflect.DeepEqual(deleted, want) {
+		t.Errorf("deleted ids = %v, want %v", deleted, want)
+	}
+	if want := map[string]string{"a": "1", "b": "0", "c": "0"}; !reflect.DeepEqual(isReleased, want) {
+		t.Errorf("is_released = %v, want %v", isReleased, want)
+	}
+}
+
+func TestRollbackRelease(t *testing.T) {
+	released := time.Date(2025, 1, 1, 10, 0, 0, 0, time.Local)
+	target := models.NamespaceRelease{
+		ID:            9,
+		NamespaceID:   3,
+		ReleaseID:     "target",
+		ReleaseName:   "v1.2.0",
+		ReleaseTime:   released,
+		CreateTime:    released,
+		UpdateTime:    released,
+		Operator:      "alice",
+		Comment:       "promote from default",
+		SourceRelease: "source-in-default",
+		GitCommit:     "9fceb02d0ae598e95dc970b74767f19372d61af8",
+		Interpolated:  true,
+		Refs:          []models.ReleaseRef{{Key: "db.url"}},
+		Config:        []byte("config"),
+	}
+
+	got := rollbackRelease(target, "rollback", "bob", "revert")
+	want := target
+	want.ID = 0
+	want.ReleaseID = "rollback"
+	want.ReleaseName = "rollback-v1.2.0"
+	want.ReleaseTime, want.CreateTime, want.UpdateTime = time.Time{}, time.Time{}, time.Time{}
+	want.Operator = "bob"
+	want.Comment = "revert"
+	want.SourceRelease = "" // 回滚不是提升
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("rollbackRelease() = %+v, want %+v", got, want)
+	}
+	if target.SourceRelease != "source-in-default" {
+		t.Fatal("rollbackRelease() modified the target release")
+	}
+
+	// webhook 的 rollback 事件不会带上目标版本的 source_release_id
+	if event := releaseEvent(models.WebhookEventRollback, "dev", &got); event.Release.SourceReleaseID != "" || event.Release.GitCommit != target.GitCommit {
+		t.Fatalf("releaseEvent() release = %+v", event.Release)
+	}
+}
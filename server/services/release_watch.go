@@ -0,0 +1,78 @@
+package services
+
+import (
+	"sync"
+)
+
+// ReleaseEvent 命名空间有新的版本（发布、回滚等），由变更日志消费后触发，各实例都会收到
+type ReleaseEvent struct {
+	Env           string
+	AppName       string
+	ClusterName   string
+	NamespaceName string
+}
+
+// ReleaseSubscription 发布事件的订阅，订阅者处理不过来时同一个命名空间的事件会合并，不会丢失
+type ReleaseSubscription struct {
+	mu      sync.Mutex
+	pending map[ReleaseEvent]struct{}
+	notify  chan struct{}
+}
+
+var releaseSubscribers = struct {
+	sync.Mutex
+	subs map[*ReleaseSubscription]struct{}
+}{subs: make(map[*ReleaseSubscription]struct{})}
+
+// SubscribeReleases 订阅所有命名空间的发布事件，不再使用时必须调用 Close
+func SubscribeReleases() *ReleaseSubscription {
+	sub := &ReleaseSubscription{
+		pending: make(map[ReleaseEvent]struct{}),
+		notify:  make(chan struct{}, 1),
+	}
+	releaseSubscribers.Lock()
+	releaseSubscribers.subs[sub] = struct{}{}
+	releaseSubscribers.Unlock()
+	return sub
+}
+
+// Notify 有新事件时可读
+func (sub *ReleaseSubscription) Notify() <-chan struct{} {
+	return sub.notify
+}
+
+// Drain 取出当前积累的事件
+func (sub *ReleaseSubscription) Drain() []ReleaseEvent {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	events := make([]ReleaseEvent, 0, len(sub.pending))
+	for ev := range sub.pending {
+		events = append(events, ev)
+	}
+	sub.pending = make(map[ReleaseEvent]struct{})
+	return events
+}
+
+// Close 取消订阅
+func (sub *ReleaseSubscription) Close() {
+	releaseSubscribers.Lock()
+	delete(releaseSubscribers.subs, sub)
+	releaseSubscribers.Unlock()
+}
+
+// publishReleaseEvent 通知所有订阅者，不会阻塞变更日志的消费
+func publishReleaseEvent(ev ReleaseEvent) {
+	releaseSubscribers.Lock()
+	defer releaseSubscribers.Unlock()
+
+	for sub := range releaseSubscribers.subs {
+		sub.mu.Lock()
+		sub.pending[ev] = struct{}{}
+		sub.mu.Unlock()
+		select {
+		case sub.notify <- struct{}{}:
+		default:
+		}
+	}
+}
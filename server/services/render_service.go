@@ -0,0 +1,64 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"quiver/config"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/render"
+	"quiver/telemetry"
+)
+
+// RenderedFile 渲染后的配置文件，Checksum 为内容的 SHA-256（十六进制）
+type RenderedFile struct {
+	Release  *models.NamespaceRelease
+	Content  []byte
+	Checksum string
+}
+
+// RenderRelease 把命名空间的最新版本（releaseID 为空时）或指定版本渲染为 format 格式的文件，
+// 同一个版本总是得到完全相同的内容；fetch 模式下未解析的版本按被引用命名空间当前的值解析
+func (s *ReleaseService) RenderRelease(env, appName, clusterName, namespaceName, releaseID string, format render.Format, section string) (_ *RenderedFile, err error) {
+	s, span := s.trace("RenderRelease")
+	defer func() { telemetry.End(span, err) }()
+
+	var release *models.NamespaceRelease
+	if releaseID == "" {
+		release, err = s.GetLatestReleaseAll(env, appName, clusterName, namespaceName)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		release, err = s.GetFixedReleaseAll(env, releaseID)
+		if err != nil || release.AppName != appName || release.ClusterName != clusterName || release.NamespaceName != namespaceName {
+			return nil, errors.New("release_id not found")
+		}
+	}
+
+	kv := make(map[string]string, len(release.Items))
+	for _, item := range release.Items {
+		kv[item.K] = item.V
+	}
+	if conf := config.GetInterpolationConfig(); conf.Enabled && conf.Mode == config.InterpolationModeFetch && !release.Interpolated {
+		resolved, _, err := s.resolveReferences(env, appName, clusterName, namespaceName, release.ReleaseID, kv)
+		if err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to resolve release %s: %v", release.ReleaseID, err)
+		} else {
+			kv = resolved
+		}
+	}
+
+	items := make([]render.Item, 0, len(kv))
+	for k, v := range kv {
+		items = append(items, render.Item{Key: k, Value: v})
+	}
+	content, err := render.Render(format, items, section)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(content)
+	return &RenderedFile{Release: release, Content: content, Checksum: hex.EncodeToString(sum[:])}, nil
+}
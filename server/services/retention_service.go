@@ -0,0 +1,399 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"quiver/config"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/telemetry"
+	"quiver/utils"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 每批删除的行数，避免单条 SQL 锁住太多行
+const retentionBatchSize = 1000
+
+// RetentionService 按保留策略清理历史发布和不再被引用的 item_release
+type RetentionService struct {
+	ctx context.Context
+}
+
+// RetentionReport 一次清理的结果，dry_run 时为预计删除的数量
+type RetentionReport struct {
+	Env                 string               `json:"env"`
+	DryRun              bool                 `json:"dry_run"`
+	KeepReleases        int                  `json:"keep_releases"`
+	KeepDays            int                  `json:"keep_days"`
+	Namespaces          []NamespaceRetention `json:"namespaces"`
+	ReleasesDeleted     int64                `json:"releases_deleted"`
+	ItemReleasesDeleted int64                `json:"item_releases_deleted"`
+	ItemsDeleted        int64                `json:"items_deleted"`
+	Duration            string               `json:"duration"`
+}
+
+// NamespaceRetention 单个命名空间的清理结果，只列出有数据需要删除的命名空间
+type NamespaceRetention struct {
+	NamespaceID   uint64   `json:"namespace_id"`
+	AppName       string   `json:"app_name,omitempty"`
+	ClusterName   string   `json:"cluster_name,omitempty"`
+	NamespaceName string   `json:"namespace_name,omitempty"`
+	Deleted       bool     `json:"deleted"` // 命名空间已被删除，剩余数据全部清理
+	ReleasesKept  int      `json:"releases_kept"`
+	Releases      []string `json:"releases"` // 删除的 release_id
+	ItemReleases  int64    `json:"item_releases"`
+	Items         int64    `json:"items"`
+}
+
+// releaseMeta 不含 config 的发布记录
+type releaseMeta struct {
+	ID            uint64
+	NamespaceID   uint64
+	AppName       string
+	ClusterName   string
+	NamespaceName string
+	ReleaseID     string
+	CreateTime    time.Time
+}
+
+// NewRetentionService 创建保留策略服务实例
+func NewRetentionService() *RetentionService {
+	return &RetentionService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *RetentionService) WithContext(ctx context.Context) *RetentionService {
+	return &RetentionService{ctx: ctx}
+}
+
+func (s *RetentionService) trace(name string) (*RetentionService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "RetentionService."+name)
+	return &RetentionService{ctx: ctx}, span
+}
+
+// StartRetentionGC 按配置的间隔在后台定期清理配置了保留策略的环境
+func StartRetentionGC() {
+	conf := config.GetRetentionConfig()
+	if !conf.Enabled {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(conf.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for env := range conf.Envs {
+				if !utils.ValidateEnv(env) {
+					continue
+				}
+				report, err := NewRetentionService().Run(env, false)
+				if err != nil {
+					logger.GetLogger("quiver").Errorf("retention gc for env %s failed: %v", env, err)
+					continue
+				}
+				logger.GetLogger("quiver").Infof("retention gc for env %s: deleted %d releases, %d item_release, %d items in %s",
+					env, report.ReleasesDeleted, report.ItemReleasesDeleted, report.ItemsDeleted, report.Duration)
+			}
+		}
+	}()
+}
+
+// Run 按环境的保留策略清理：
+//  1. 已删除命名空间残留的发布记录、item_release 和 item 全部删除
+//  2. 其余命名空间保留当前版本、最近 keep_releases 个以及 keep_days 天内的发布，删除其余发布
+//  3. 删除不再被任何保留下来的发布引用的 item_release
+//
+// dryRun 为 true 时只统计不删除
+func (s *RetentionService) Run(env string, dryRun bool) (_ *RetentionReport, err error) {
+	s, span := s.trace("Run")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+	if db == nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	start := time.Now()
+	policy := config.GetRetentionConfig().Envs[env]
+	report := &RetentionReport{
+		Env:          env,
+		DryRun:       dryRun,
+		KeepReleases: policy.KeepReleases,
+		KeepDays:     policy.KeepDays,
+		Namespaces:   []NamespaceRetention{},
+	}
+
+	// 1. 已删除的命名空间
+	var orphans []uint64
+	if err := db.Raw(`SELECT namespace_id FROM namespace_release WHERE namespace_id NOT IN (SELECT id FROM namespace)
+		UNION SELECT namespace_id FROM item_release WHERE namespace_id NOT IN (SELECT id FROM namespace)
+		UNION SELECT namespace_id FROM item WHERE namespace_id NOT IN (SELECT id FROM namespace)`).
+		Scan(&orphans).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query deleted namespaces failed: %v", err)
+		return nil, err
+	}
+	for _, namespaceID := range orphans {
+		nr, err := s.purgeNamespace(db, namespaceID, dryRun)
+		if err != nil {
+			return nil, err
+		}
+		report.add(nr)
+	}
+
+	// 2. 按策略筛选需要清理的命名空间：发布数超过 keep_releases 且最早的发布超过 keep_days
+	if !policy.Unlimited() {
+		var candidates []struct {
+			NamespaceID uint64
+			Cnt         int
+			Oldest      time.Time
+		}
+		if err := db.Raw(`SELECT namespace_id, COUNT(*) AS cnt, MIN(create_time) AS oldest FROM namespace_release
+			WHERE namespace_id IN (SELECT id FROM namespace) GROUP BY namespace_id HAVING COUNT(*) > 1`).
+			Scan(&candidates).Error; err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query release counts failed: %v", err)
+			return nil, err
+		}
+
+		cutoff := retentionCutoff(policy)
+		for _, c := range candidates {
+			if policy.KeepReleases > 0 && c.Cnt <= policy.KeepReleases {
+				continue
+			}
+			if policy.KeepDays > 0 && c.Oldest.After(cutoff) {
+				continue
+			}
+			nr, err := s.pruneNamespace(db, c.NamespaceID, policy, dryRun)
+			if err != nil {
+				return nil, err
+			}
+			report.add(nr)
+		}
+	}
+
+	report.Duration = time.Since(start).String()
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("retention env %s dry_run %v: %d releases, %d item_release, %d items",
+		env, dryRun, report.ReleasesDeleted, report.ItemReleasesDeleted, report.ItemsDeleted)
+	return report, nil
+}
+
+// purgeNamespace 删除已删除命名空间的所有残留数据
+func (s *RetentionService) purgeNamespace(db *gorm.DB, namespaceID uint64, dryRun bool) (*NamespaceRetention, error) {
+	nr := &NamespaceRetention{NamespaceID: namespaceID, Deleted: true}
+
+	var releases []releaseMeta
+	if err := db.Table("namespace_release").
+		Select("id, namespace_id, app_name, cluster_name, namespace_name, release_id, create_time").
+		Where("namespace_id = ?", namespaceID).Order("id DESC").Scan(&releases).Error; err != nil {
+		return nil, err
+	}
+	if len(releases) > 0 {
+		nr.AppName, nr.ClusterName, nr.NamespaceName = releases[0].AppName, releases[0].ClusterName, releases[0].NamespaceName
+	}
+	for _, r := range releases {
+		nr.Releases = append(nr.Releases, r.ReleaseID)
+	}
+
+	if dryRun {
+		if err := db.Table("item_release").Where("namespace_id = ?", namespaceID).Count(&nr.ItemReleases).Error; err != nil {
+			return nil, err
+		}
+		if err := db.Table("item").Where("namespace_id = ?", namespaceID).Count(&nr.Items).Error; err != nil {
+			return nil, err
+		}
+		return nr, nil
+	}
+
+	var err error
+	if _, err = deleteInBatches(db, "namespace_release", namespaceID); err != nil {
+		return nil, err
+	}
+	if nr.ItemReleases, err = deleteInBatches(db, "item_release", namespaceID); err != nil {
+		return nil, err
+	}
+	if nr.Items, err = deleteInBatches(db, "item", namespaceID); err != nil {
+		return nil, err
+	}
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("purged deleted namespace %d: %d releases, %d item_release, %d items",
+		namespaceID, len(nr.Releases), nr.ItemReleases, nr.Items)
+	return nr, nil
+}
+
+// pruneNamespace 按策略删除命名空间的历史发布以及不再被引用的 item_release
+// 实际删除时持有命名空间行锁，期间不会有新的发布或回滚引用将要删除的数据
+func (s *RetentionService) pruneNamespace(db *gorm.DB, namespaceID uint64, policy config.RetentionPolicy, dryRun bool) (*NamespaceRetention, error) {
+	nr := &NamespaceRetention{NamespaceID: namespaceID}
+
+	prune := func(tx *gorm.DB) error {
+		var releases []releaseMeta
+		if err := tx.Table("namespace_release").
+			Select("id, namespace_id, app_name, cluster_name, namespace_name, release_id, create_time").
+			Where("namespace_id = ?", namespaceID).Order("id DESC").Scan(&releases).Error; err != nil {
+			return err
+		}
+		if len(releases) == 0 {
+			return nil
+		}
+		nr.AppName, nr.ClusterName, nr.NamespaceName = releases[0].AppName, releases[0].ClusterName, releases[0].NamespaceName
+
+		kept, deleted := splitReleases(releases, policy, retentionCutoff(policy))
+		var keptIDs, deleteIDs []uint64
+		for _, r := range kept {
+			keptIDs = append(keptIDs, r.ID)
+		}
+		for _, r := range deleted {
+			deleteIDs = append(deleteIDs, r.ID)
+			nr.Releases = append(nr.Releases, r.ReleaseID)
+		}
+		nr.ReleasesKept = len(keptIDs)
+		if len(deleteIDs) == 0 {
+			return nil
+		}
+
+		// 保留下来的发布引用的 item_release.id，以及旧格式发布引用的 kv_id
+		referenced := make(map[uint64]struct{})
+		legacyReferenced := make(map[uint64]struct{})
+		for i := 0; i < len(keptIDs); i += retentionBatchSize {
+			var configs [][]byte
+			if err := tx.Table("namespace_release").Where("id IN ?", keptIDs[i:min(i+retentionBatchSize, len(keptIDs))]).
+				Pluck("config", &configs).Error; err != nil {
+				return err
+			}
+			if err := collectReferences(configs, referenced, legacyReferenced); err != nil {
+				return err
+			}
+		}
+
+		// 没有被引用的 item_release
+		var unreferenced []uint64
+		var lastID uint64
+		for {
+			var rows []struct {
+				ID   uint64
+				KvID uint64
+			}
+			if err := tx.Table("item_release").Select("id, kv_id").
+				Where("namespace_id = ? AND id > ?", namespaceID, lastID).
+				Order("id ASC").Limit(retentionBatchSize).Scan(&rows).Error; err != nil {
+				return err
+			}
+			for _, row := range rows {
+				_, byID := referenced[row.ID]
+				_, byKvID := legacyReferenced[row.KvID]
+				if !byID && !byKvID {
+					unreferenced = append(unreferenced, row.ID)
+				}
+				lastID = row.ID
+			}
+			if len(rows) < retentionBatchSize {
+				break
+			}
+		}
+		nr.ItemReleases = int64(len(unreferenced))
+
+		if dryRun {
+			return nil
+		}
+		for i := 0; i < len(deleteIDs); i += retentionBatchSize {
+			if err := tx.Exec("DELETE FROM namespace_release WHERE id IN ?", deleteIDs[i:min(i+retentionBatchSize, len(deleteIDs))]).Error; err != nil {
+				return err
+			}
+		}
+		for i := 0; i < len(unreferenced); i += retentionBatchSize {
+			if err := tx.Exec("DELETE FROM item_release WHERE id IN ?", unreferenced[i:min(i+retentionBatchSize, len(unreferenced))]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var err error
+	if dryRun {
+		err = prune(db)
+	} else {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := lockNamespace(tx, namespaceID); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					// 命名空间刚被删除，下一轮按已删除处理
+					return nil
+				}
+				return err
+			}
+			return prune(tx)
+		})
+	}
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("prune namespace %d failed: %v", namespaceID, err)
+		return nil, err
+	}
+	return nr, nil
+}
+
+func (r *RetentionReport) add(nr *NamespaceRetention) {
+	if len(nr.Releases) == 0 && nr.ItemReleases == 0 && nr.Items == 0 {
+		return
+	}
+	r.Namespaces = append(r.Namespaces, *nr)
+	r.ReleasesDeleted += int64(len(nr.Releases))
+	r.ItemReleasesDeleted += nr.ItemReleases
+	r.ItemsDeleted += nr.Items
+}
+
+// deleteInBatches 分批删除 table 中 namespace_id 的所有行，返回删除的行数
+func deleteInBatches(db *gorm.DB, table string, namespaceID uint64) (int64, error) {
+	var total int64
+	for {
+		result := db.Exec("DELETE FROM "+table+" WHERE namespace_id = ? LIMIT ?", namespaceID, retentionBatchSize)
+		if result.Error != nil {
+			logger.GetLogger("quiver").Errorf("delete %s of namespace %d failed: %v", table, namespaceID, result.Error)
+			return total, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < retentionBatchSize {
+			return total, nil
+		}
+	}
+}
+
+// splitReleases 把按 id 倒序排列的发布分成保留和删除两部分：
+// 第一个即当前版本始终保留，其余的在最近 keep_releases 个或 keep_days 天内（create_time 晚于 cutoff）时保留
+func splitReleases(releases []releaseMeta, policy config.RetentionPolicy, cutoff time.Time) (kept, deleted []releaseMeta) {
+	for i, r := range releases {
+		keep := i == 0 || policy.Unlimited() ||
+			(policy.KeepReleases > 0 && i < policy.KeepReleases) ||
+			(policy.KeepDays > 0 && r.CreateTime.After(cutoff))
+		if keep {
+			kept = append(kept, r)
+			continue
+		}
+		deleted = append(deleted, r)
+	}
+	return kept, deleted
+}
+
+// collectReferences 把发布快照引用的 item_release.id 加入 referenced，旧格式引用的 kv_id 加入 legacyReferenced
+func collectReferences(configs [][]byte, referenced, legacyReferenced map[uint64]struct{}) error {
+	for _, data := range configs {
+		cfg, err := models.DecodeReleaseConfig(data)
+		if err != nil {
+			// 无法确认引用关系时宁可不删
+			return errors.New("failed to unmarshal release config")
+		}
+		for _, id := range cfg.ItemIDs {
+			referenced[id] = struct{}{}
+		}
+		for _, id := range cfg.KvIDs {
+			legacyReferenced[id] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// retentionCutoff keep_days 对应的时间点，早于该时间的发布才可能被删除
+func retentionCutoff(policy config.RetentionPolicy) time.Time {
+	return time.Now().AddDate(0, 0, -policy.KeepDays)
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"quiver/config"
+	"quiver/models"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestSplitReleases(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	// 按 id 倒序，release 5 为当前版本，每 10 天发布一次
+	var releases []releaseMeta
+	for id := uint64(5); id >= 1; id-- {
+		releases = append(releases, releaseMeta{
+			ID:         id,
+			ReleaseID:  string(rune('a' + id - 1)),
+			CreateTime: now.AddDate(0, 0, -int(5-id)*10),
+		})
+	}
+	ids := func(rs []releaseMeta) []uint64 {
+		var out []uint64
+		for _, r := range rs {
+			out = append(out, r.ID)
+		}
+		return out
+	}
+
+	tests := []struct {
+		name        string
+		releases    []releaseMeta
+		policy      config.RetentionPolicy
+		wantKept    []uint64
+		wantDeleted []uint64
+	}{
+		{
+			name:        "keep releases only",
+			releases:    releases,
+			policy:      config.RetentionPolicy{KeepReleases: 2},
+			wantKept:    []uint64{5, 4},
+			wantDeleted: []uint64{3, 2, 1},
+		},
+		{
+			name:        "keep days only",
+			releases:    releases,
+			policy:      config.RetentionPolicy{KeepDays: 25}, // 0、10、20 天前的发布
+			wantKept:    []uint64{5, 4, 3},
+			wantDeleted: []uint64{2, 1},
+		},
+		{
+			name:        "union of keep releases and keep days",
+			releases:    releases,
+			policy:      config.RetentionPolicy{KeepReleases: 4, KeepDays: 15},
+			wantKept:    []uint64{5, 4, 3, 2},
+			wantDeleted: []uint64{1},
+		},
+		{
+			name:        "current release always kept",
+			releases:    []releaseMeta{{ID: 9, CreateTime: now.AddDate(-1, 0, 0)}, {ID: 8, CreateTime: now.AddDate(-1, 0, 0)}},
+			policy:      config.RetentionPolicy{KeepDays: 1},
+			wantKept:    []uint64{9},
+			wantDeleted: []uint64{8},
+		},
+		{
+			name:        "current release kept with keep releases 1",
+			releases:    releases,
+			policy:      config.RetentionPolicy{KeepReleases: 1},
+			wantKept:    []uint64{5},
+			wantDeleted: []uint64{4, 3, 2, 1},
+		},
+		{
+			name:     "unlimited keeps everything",
+			releases: releases,
+			policy:   config.RetentionPolicy{},
+			wantKept: []uint64{5, 4, 3, 2, 1},
+		},
+		{
+			name:     "no releases",
+			releases: nil,
+			policy:   config.RetentionPolicy{KeepReleases: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cutoff := now.AddDate(0, 0, -tt.policy.KeepDays)
+			kept, deleted := splitReleases(tt.releases, tt.policy, cutoff)
+			if got := ids(kept); !reflect.DeepEqual(got, tt.wantKept) {
+				t.Errorf("kept = %v, want %v", got, tt.wantKept)
+			}
+			if got := ids(deleted); !reflect.DeepEqual(got, tt.wantDeleted) {
+				t.Errorf("deleted = %v, want %v", got, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestCollectReferences(t *testing.T) {
+	v2, err := models.EncodeReleaseConfig([]uint64{10, 11})
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy, err := msgpack.Marshal([]uint64{1001, 1002})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	referenced := make(map[uint64]struct{})
+	legacyReferenced := make(map[uint64]struct{})
+	if err := collectReferences([][]byte{v2, legacy, nil}, referenced, legacyReferenced); err != nil {
+		t.Fatalf("collectReferences() error = %v", err)
+	}
+	if want := map[uint64]struct{}{10: {}, 11: {}}; !reflect.DeepEqual(referenced, want) {
+		t.Errorf("referenced = %v, want %v", referenced, want)
+	}
+	if want := map[uint64]struct{}{1001: {}, 1002: {}}; !reflect.DeepEqual(legacyReferenced, want) {
+		t.Errorf("legacyReferenced = %v, want %v", legacyReferenced, want)
+	}
+
+	// 无法解析的快照导致整个命名空间不清理
+	if err := collectReferences([][]byte{v2, []byte("not msgpack")}, referenced, legacyReferenced); err == nil {
+		t.Fatal("collectReferences() accepted a corrupted release config")
+	}
+}
@@ -0,0 +1,330 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quiver/config"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/telemetry"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// errScheduleTaken 任务已经被其他实例执行或被取消，本次执行的事务回滚
+var errScheduleTaken = errors.New("schedule is no longer pending")
+
+// ErrScheduleNotPending 只有未执行的任务可以取消
+var ErrScheduleNotPending = errors.New("only pending schedule can be cancelled")
+
+// ScheduleService 定时发布、回滚
+type ScheduleService struct {
+	ctx context.Context
+}
+
+// NewScheduleService 创建定时发布服务实例
+func NewScheduleService() *ScheduleService {
+	return &ScheduleService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *ScheduleService) WithContext(ctx context.Context) *ScheduleService {
+	return &ScheduleService{ctx: ctx}
+}
+
+func (s *ScheduleService) trace(name string) (*ScheduleService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "ScheduleService."+name)
+	return &ScheduleService{ctx: ctx}, span
+}
+
+// StartScheduler 按配置的间隔在后台扫描并执行到期的定时任务
+// 每个实例都会扫描，同一任务只有一个实例能提交成功
+func StartScheduler(envs []string) {
+	conf := config.GetScheduleConfig()
+
+	go func() {
+		ticker := time.NewTicker(conf.Interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, env := range envs {
+				NewScheduleService().RunDue(env, conf.BatchSize)
+			}
+		}
+	}()
+}
+
+// CreateSchedule 创建定时任务，publish 需要 release_name，rollback 需要属于该命名空间的 release_id
+func (s *ScheduleService) CreateSchedule(env, appName, clusterName, namespaceName string, schedule *models.ScheduledRelease) (err error) {
+	s, span := s.trace("CreateSchedule")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+
+	switch schedule.Action {
+	case models.ScheduleActionPublish:
+		if schedule.ReleaseName == "" {
+			return errors.New("release_name is required")
+		}
+		schedule.ReleaseID, schedule.RestoreDraft, schedule.Force = "", false, false
+	case models.ScheduleActionRollback:
+		if schedule.ReleaseID == "" {
+			return errors.New("release_id is required")
+		}
+		var count int64
+		if err := db.Model(&models.NamespaceRelease{}).
+			Where("release_id = ? AND namespace_id = ?", schedule.ReleaseID, ids.NamespaceID).
+			Count(&count).Error; err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query release %s failed: %v", schedule.ReleaseID, err)
+			return err
+		}
+		if count == 0 {
+			return errors.New("release_id not found")
+		}
+		schedule.ReleaseName, schedule.Keys = "", nil
+	default:
+		return errors.New("action must be publish or rollback")
+	}
+
+	if !schedule.ExecuteAt.After(time.Now()) {
+		return errors.New("execute_at must be in the future")
+	}
+	if schedule.RevertAt != nil && !schedule.RevertAt.After(schedule.ExecuteAt) {
+		return errors.New("revert_at must be after execute_at")
+	}
+
+	schedule.ID = 0
+	schedule.AppID, schedule.AppName = ids.AppID, appName
+	schedule.ClusterID, schedule.ClusterName = ids.ClusterID, clusterName
+	schedule.NamespaceID, schedule.NamespaceName = ids.NamespaceID, namespaceName
+	schedule.Status = models.ScheduleStatusPending
+	schedule.Reason, schedule.ResultID, schedule.ParentID = "", "", 0
+	schedule.ExecuteTime, schedule.CancelledBy = nil, ""
+
+	if err := db.Create(schedule).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("create schedule failed: %v", err)
+		return err
+	}
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("scheduled %s for %s/%s/%s at %s by %s",
+		schedule.Action, appName, clusterName, namespaceName, schedule.ExecuteAt.Format(time.RFC3339), schedule.Operator)
+	return nil
+}
+
+// ListSchedules 获取命名空间的定时任务，按执行时间倒序，status 为空时返回全部
+func (s *ScheduleService) ListSchedules(env, appName, clusterName, namespaceName, status string, page, size int) (_ []models.ScheduledRelease, _ int64, err error) {
+	s, span := s.trace("ListSchedules")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+
+	query := db.Model(&models.ScheduledRelease{}).Where("namespace_id = ?", ids.NamespaceID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to count schedules: %v", err)
+		return nil, 0, fmt.Errorf("failed to count schedules: %w", err)
+	}
+
+	var schedules []models.ScheduledRelease
+	if err := query.Order("execute_at DESC, id DESC").Offset((page - 1) * size).Limit(size).Find(&schedules).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query schedules: %v", err)
+		return nil, 0, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	return schedules, total, nil
+}
+
+// GetSchedule 获取命名空间下的单个定时任务
+func (s *ScheduleService) GetSchedule(env, appName, clusterName, namespaceName string, scheduleID uint64) (_ *models.ScheduledRelease, err error) {
+	s, span := s.trace("GetSchedule")
+	defer func() { telemetry.End(span, err) }()
+
+	ids, err := CheckACNKinDBContext(s.ctx, &env, &appName, &clusterName, &namespaceName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+
+	var schedule models.ScheduledRelease
+	if err := db.Where("id = ? AND namespace_id = ?", scheduleID, ids.NamespaceID).First(&schedule).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("schedule not found")
+		}
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query schedule %d failed: %v", scheduleID, err)
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// CancelSchedule 取消尚未执行的定时任务，已执行、已失败或已取消的任务不能取消
+func (s *ScheduleService) CancelSchedule(env, appName, clusterName, namespaceName string, scheduleID uint64, operator string) (_ *models.ScheduledRelease, err error) {
+	s, span := s.trace("CancelSchedule")
+	defer func() { telemetry.End(span, err) }()
+
+	schedule, err := s.GetSchedule(env, appName, clusterName, namespaceName, scheduleID)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+
+	now := time.Now()
+	result := db.Model(&models.ScheduledRelease{}).
+		Where("id = ? AND status = ?", scheduleID, models.ScheduleStatusPending).
+		Updates(map[string]interface{}{
+			"status":       models.ScheduleStatusCancelled,
+			"cancelled_by": operator,
+			"execute_time": now,
+		})
+	if result.Error != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("cancel schedule %d failed: %v", scheduleID, result.Error)
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("schedule is %s: %w", schedule.Status, ErrScheduleNotPending)
+	}
+
+	schedule.Status, schedule.CancelledBy, schedule.ExecuteTime = models.ScheduleStatusCancelled, operator, &now
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("schedule %d cancelled by %s", scheduleID, operator)
+	return schedule, nil
+}
+
+// RunDue 执行环境中已经到期的定时任务
+func (s *ScheduleService) RunDue(env string, limit int) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return
+	}
+
+	var schedules []models.ScheduledRelease
+	if err := db.Where("status = ? AND execute_at <= ?", models.ScheduleStatusPending, time.Now()).
+		Order("execute_at ASC, id ASC").Limit(limit).Find(&schedules).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("query due schedules for env %s failed: %v", env, err)
+		return
+	}
+	for i := range schedules {
+		s.execute(env, &schedules[i])
+	}
+}
+
+// claimSchedule 把仍为 pending 的任务标记为成功，与新版本在同一事务中提交；
+// 影响 0 行说明已经被其他实例执行或被取消，返回 errScheduleTaken 让整个事务回滚
+func claimSchedule(tx *gorm.DB, scheduleID uint64, releaseID string) error {
+	result := tx.Model(&models.ScheduledRelease{}).
+		Where("id = ? AND status = ?", scheduleID, models.ScheduleStatusPending).
+		Updates(map[string]interface{}{
+			"status":            models.ScheduleStatusSucceeded,
+			"result_release_id": releaseID,
+			"execute_time":      time.Now(),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errScheduleTaken
+	}
+	return nil
+}
+
+// execute 执行单个任务。成功时任务状态与新版本在同一事务中提交；
+// 另一个实例先提交了的话，本实例的条件更新影响 0 行，整个发布回滚
+func (s *ScheduleService) execute(env string, schedule *models.ScheduledRelease) {
+	s, span := s.trace("execute")
+	defer span.End()
+
+	db := database.GetDBContext(s.ctx, env)
+
+	hook := func(tx *gorm.DB, release *models.NamespaceRelease) error {
+		if err := claimSchedule(tx, schedule.ID, release.ReleaseID); err != nil {
+			return err
+		}
+		if schedule.RevertAt == nil {
+			return nil
+		}
+
+		// 执行前的版本，作为 revert_at 时回滚的目标
+		var previous models.NamespaceRelease
+		if err := tx.Select("release_id").
+			Where("namespace_id = ? AND id < ?", schedule.NamespaceID, release.ID).
+			Order("id DESC").First(&previous).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("no previous release to revert to")
+			}
+			return err
+		}
+		revert := models.ScheduledRelease{
+			AppID:         schedule.AppID,
+			AppName:       schedule.AppName,
+			ClusterID:     schedule.ClusterID,
+			ClusterName:   schedule.ClusterName,
+			NamespaceID:   schedule.NamespaceID,
+			NamespaceName: schedule.NamespaceName,
+			Action:        models.ScheduleActionRollback,
+			ExecuteAt:     *schedule.RevertAt,
+			ReleaseID:     previous.ReleaseID,
+			RestoreDraft:  schedule.RestoreDraft,
+			Force:         schedule.Force,
+			Operator:      schedule.Operator,
+			Comment:       fmt.Sprintf("revert of schedule %d", schedule.ID),
+			Status:        models.ScheduleStatusPending,
+			ParentID:      schedule.ID,
+		}
+		return tx.Create(&revert).Error
+	}
+
+	releaseService := NewReleaseService().WithContext(s.ctx)
+	var err error
+	switch schedule.Action {
+	case models.ScheduleActionPublish:
+		_, err = releaseService.publish(env, schedule.AppName, schedule.ClusterName, schedule.NamespaceName,
+			schedule.ReleaseName, schedule.Operator, schedule.Comment, schedule.Keys, nil, hook)
+	case models.ScheduleActionRollback:
+		_, err = releaseService.rollback(env, schedule.AppName, schedule.ClusterName, schedule.NamespaceName,
+			schedule.ReleaseID, schedule.Operator, schedule.Comment, schedule.RestoreDraft, schedule.Force, hook)
+	default:
+		err = fmt.Errorf("unknown action %s", schedule.Action)
+	}
+
+	switch {
+	case err == nil:
+		logger.GetLogger("quiver").WithContext(s.ctx).Infof("schedule %d %s %s/%s/%s/%s executed",
+			schedule.ID, schedule.Action, env, schedule.AppName, schedule.ClusterName, schedule.NamespaceName)
+	case errors.Is(err, errScheduleTaken):
+		logger.GetLogger("quiver").WithContext(s.ctx).Debugf("schedule %d already taken", schedule.ID)
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+
+		// 只记录仍为 pending 的任务，其他实例已经成功执行时不覆盖
+		reason := truncateError(err.Error(), 1024)
+		if updateErr := db.Model(&models.ScheduledRelease{}).
+			Where("id = ? AND status = ?", schedule.ID, models.ScheduleStatusPending).
+			Updates(map[string]interface{}{
+				"status":       models.ScheduleStatusFailed,
+				"reason":       reason,
+				"execute_time": time.Now(),
+			}).Error; updateErr != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("record schedule %d failure failed: %v", schedule.ID, updateErr)
+		}
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("schedule %d %s %s/%s/%s/%s failed: %v",
+			schedule.ID, schedule.Action, env, schedule.AppName, schedule.ClusterName, schedule.NamespaceName, err)
+	}
+}
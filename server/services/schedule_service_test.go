@@ -0,0 +1,112 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"quiver/models"
+	"regexp"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// claimStore 记录 claimSchedule 执行的语句，按 rowsAffected 或 err 返回结果，
+// 条件更新的原子性由数据库保证，这里只检查语句和对影响行数的处理
+type claimStore struct {
+	rowsAffected int64
+	err          error
+	statements   []recordedExec
+}
+
+type fakeConnector struct{ s *claimStore }
+
+func (c fakeConnector) Connect(context.Context) (driver.Conn, error) { return fakeConn(c), nil }
+func (c fakeConnector) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ s *claimStore }
+
+func (c fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c fakeConn) Close() error              { return nil }
+func (c fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c fakeConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.s.statements = append(c.s.statements, recordedExec{query: query, args: values})
+	if c.s.err != nil {
+		return nil, c.s.err
+	}
+	return driver.RowsAffected(c.s.rowsAffected), nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+var claimUpdate = regexp.MustCompile("^UPDATE `scheduled_release` SET (.*) WHERE id = \\? AND status = \\?$")
+
+// TestClaimSchedule 任务只有仍为 pending 时才能被领取：条件更新影响 0 行（已被其他实例执行或已取消）时返回 errScheduleTaken
+func TestClaimSchedule(t *testing.T) {
+	dbErr := errors.New("lock wait timeout")
+	tests := []struct {
+		name         string
+		rowsAffected int64
+		err          error
+		wantErr      error
+	}{
+		{name: "claimed", rowsAffected: 1},
+		{name: "no longer pending", rowsAffected: 0, wantErr: errScheduleTaken},
+		{name: "database error", err: dbErr, wantErr: dbErr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &claimStore{rowsAffected: tt.rowsAffected, err: tt.err}
+			db, err := gorm.Open(mysql.New(mysql.Config{
+				Conn:                      sql.OpenDB(fakeConnector{s: store}),
+				SkipInitializeWithVersion: true,
+			}), &gorm.Config{Logger: logger.Discard})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = claimSchedule(db, 42, "release-1")
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Fatalf("claimSchedule() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(store.statements) != 1 {
+				t.Fatalf("claimSchedule() executed %d statements, want 1", len(store.statements))
+			}
+
+			// 一条以 pending 为条件的 UPDATE，而不是先查询再更新
+			st := store.statements[0]
+			m := claimUpdate.FindStringSubmatch(st.query)
+			if m == nil {
+				t.Fatalf("claimSchedule() query = %q, want a conditional update", st.query)
+			}
+			set := strings.Split(m[1], ",")
+			if len(st.args) != len(set)+2 {
+				t.Fatalf("claimSchedule() args = %v", st.args)
+			}
+			where := st.args[len(set):]
+			if where[0] != int64(42) || where[1] != models.ScheduleStatusPending {
+				t.Fatalf("claimSchedule() where args = %v, want [42 %s]", where, models.ScheduleStatusPending)
+			}
+			values := map[string]driver.Value{}
+			for i, assignment := range set {
+				values[strings.Trim(strings.TrimSuffix(assignment, "=?"), "`")] = st.args[i]
+			}
+			if values["status"] != models.ScheduleStatusSucceeded || values["result_release_id"] != "release-1" {
+				t.Fatalf("claimSchedule() set %v", values)
+			}
+		})
+	}
+}
@@ -0,0 +1,400 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"quiver/cache"
+	"quiver/config"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vmihailenco/msgpack/v5"
+	"gorm.io/gorm"
+)
+
+// 会话缓存时间，吊销通过 release_message 在各实例间传播
+const sessionCacheTTL = 60 * time.Second
+
+// SessionTokens 登录或刷新后下发给客户端的令牌
+type SessionTokens struct {
+	SessionID        string    `json:"session_id"`
+	AccessToken      string    `json:"access_token"`
+	RefreshToken     string    `json:"refresh_token"`
+	AccessExpiresAt  time.Time `json:"expires_at"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+}
+
+type SessionService struct{}
+
+func NewSessionService() *SessionService {
+	return &SessionService{}
+}
+
+// OnKeyUpdate4Session 会话刷新、吊销时缓存已被 watcher 删除，这里只记录日志
+func OnKeyUpdate4Session(env, key string) {
+	logger.GetLogger("quiver").Infof("session cache invalidated: %s %s", env, key)
+}
+
+// newToken 生成 "{session_id}.{随机串}" 格式的令牌，前缀用于定位会话
+func newToken(sessionID string) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return sessionID + "." + hex.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseToken 从令牌中解析出 session_id
+func parseToken(token string) (string, error) {
+	sessionID, secret, ok := strings.Cut(token, ".")
+	if !ok || len(sessionID) == 0 || len(secret) != 64 {
+		return "", errors.New("invalid token")
+	}
+	return sessionID, nil
+}
+
+func tokenMatches(token, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(hash)) == 1
+}
+
+// CreateSession 为登录成功的用户创建会话
+func (s *SessionService) CreateSession(env string, user *models.User, clientIP, userAgent string) (*SessionTokens, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	sessionID := strings.ReplaceAll(uuid.NewString(), "-", "")
+	accessToken, err := newToken(sessionID)
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("generate access token failed: %v", err)
+		return nil, err
+	}
+	refreshToken, err := newToken(sessionID)
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("generate refresh token failed: %v", err)
+		return nil, err
+	}
+
+	authConf := config.GetAuthConfig()
+	now := time.Now()
+	session := &models.Session{
+		SessionID:        sessionID,
+		UserID:           user.UserID,
+		UserName:         user.UserName,
+		AccessTokenHash:  hashToken(accessToken),
+		RefreshTokenHash: hashToken(refreshToken),
+		AccessExpireAt:   now.Add(authConf.AccessTokenTTL),
+		RefreshExpireAt:  now.Add(authConf.RefreshTokenTTL),
+		ClientIP:         clientIP,
+		UserAgent:        truncate(userAgent, 256),
+		LastSeenAt:       now,
+	}
+
+	if err := db.Create(session).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("create session for user %s failed: %v", user.UserName, err)
+		return nil, err
+	}
+	s.cacheSession(env, session)
+
+	return &SessionTokens{
+		SessionID:        sessionID,
+		AccessToken:      accessToken,
+		RefreshToken:     refreshToken,
+		AccessExpiresAt:  session.AccessExpireAt,
+		RefreshExpiresAt: session.RefreshExpireAt,
+	}, nil
+}
+
+// getSession 先查缓存，再查数据库
+func (s *SessionService) getSession(env, sessionID string) (*models.Session, error) {
+	key := (&models.Session{SessionID: sessionID}).CacheKey(env)
+	if data, ok, _ := cache.Get(key); ok && len(data) > 0 {
+		session := &models.Session{}
+		if err := msgpack.Unmarshal(data, session); err == nil {
+			return session, nil
+		}
+		// 数据已经破坏
+		_ = cache.Delete(key)
+	}
+
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	var session models.Session
+	if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("session not found")
+		}
+		logger.GetLogger("quiver").Errorf("query session %s failed: %v", sessionID, err)
+		return nil, err
+	}
+	s.cacheSession(env, &session)
+	return &session, nil
+}
+
+func (s *SessionService) cacheSession(env string, session *models.Session) {
+	if data, err := msgpack.Marshal(session); err == nil && len(data) > 0 {
+		_ = cache.Set(session.CacheKey(env), data, sessionCacheTTL)
+	}
+}
+
+// Authenticate 校验 access token，返回对应的有效会话
+func (s *SessionService) Authenticate(env, accessToken string) (*models.Session, error) {
+	sessionID, err := parseToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.getSession(env, sessionID)
+	if err != nil {
+		return nil, errors.New("invalid token")
+	}
+	if err := checkAccessToken(session, accessToken, time.Now()); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// checkAccessToken 检查会话未被吊销、access token 与会话匹配且未过期
+func checkAccessToken(session *models.Session, accessToken string, now time.Time) error {
+	if session.Revoked != 0 {
+		return errors.New("session revoked")
+	}
+	if !tokenMatches(accessToken, session.AccessTokenHash) {
+		return errors.New("invalid token")
+	}
+	if now.After(session.AccessExpireAt) {
+		return errors.New("token expired")
+	}
+	return nil
+}
+
+// checkRefreshToken 检查 refresh token 能否用于轮换，reused 表示令牌属于该会话但已经被轮换掉
+func checkRefreshToken(session *models.Session, refreshToken string, now time.Time) (reused bool, err error) {
+	if !session.IsActive(now) {
+		return false, errors.New("session expired or revoked")
+	}
+	if !tokenMatches(refreshToken, session.RefreshTokenHash) {
+		return true, errors.New("invalid token")
+	}
+	return false, nil
+}
+
+// Refresh 用 refresh token 换取新的 access token 和 refresh token（轮换）
+// 已经轮换掉的 refresh token 再次出现说明令牌泄露，直接吊销整个会话
+func (s *SessionService) Refresh(env, refreshToken string) (*SessionTokens, error) {
+	sessionID, err := parseToken(refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	var session models.Session
+	if err := db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("invalid token")
+		}
+		logger.GetLogger("quiver").Errorf("query session %s failed: %v", sessionID, err)
+		return nil, err
+	}
+
+	now := time.Now()
+	if reused, err := checkRefreshToken(&session, refreshToken, now); err != nil {
+		if reused {
+			logger.GetLogger("quiver").Warnf("refresh token reuse detected for session %s user %s", session.SessionID, session.UserName)
+			_ = s.RevokeSession(env, session.UserID, session.SessionID, "refresh token reuse")
+		}
+		return nil, err
+	}
+
+	accessToken, err := newToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	newRefreshToken, err := newToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	authConf := config.GetAuthConfig()
+	updates := map[string]interface{}{
+		"access_token_hash":  hashToken(accessToken),
+		"refresh_token_hash": hashToken(newRefreshToken),
+		"access_expire_at":   now.Add(authConf.AccessTokenTTL),
+		"refresh_expire_at":  now.Add(authConf.RefreshTokenTTL),
+		"last_seen_at":       now,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 以旧的 refresh_token_hash 作为 CAS 条件，避免并发刷新同时成功
+		result := tx.Model(&models.Session{}).
+			Where("id = ? AND refresh_token_hash = ? AND revoked = 0", session.ID, session.RefreshTokenHash).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("invalid token")
+		}
+		return AppendReleaseMessage(tx, models.MessageKindSession, session.CacheKey(env))
+	})
+	if err != nil {
+		if err.Error() != "invalid token" {
+			logger.GetLogger("quiver").Errorf("refresh session %s failed: %v", sessionID, err)
+		}
+		return nil, err
+	}
+	_ = cache.Delete(session.CacheKey(env))
+	NotifyChanges(env)
+
+	return &SessionTokens{
+		SessionID:        sessionID,
+		AccessToken:      accessToken,
+		RefreshToken:     newRefreshToken,
+		AccessExpiresAt:  updates["access_expire_at"].(time.Time),
+		RefreshExpiresAt: updates["refresh_expire_at"].(time.Time),
+	}, nil
+}
+
+// ListSessions 列出用户仍然有效的会话
+func (s *SessionService) ListSessions(env string, userID uint64) ([]models.Session, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+
+	var sessions []models.Session
+	if err := db.Where("user_id = ? AND revoked = 0 AND refresh_expire_at > ?", userID, time.Now()).
+		Order("id DESC").Find(&sessions).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("list sessions for user %d failed: %v", userID, err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession 吊销用户的某个会话
+func (s *SessionService) RevokeSession(env string, userID uint64, sessionID, reason string) error {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return errors.New("db not initialized")
+	}
+
+	key := (&models.Session{SessionID: sessionID}).CacheKey(env)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Session{}).
+			Where("session_id = ? AND user_id = ? AND revoked = 0", sessionID, userID).
+			Updates(map[string]interface{}{
+				"revoked":       1,
+				"revoke_reason": truncate(reason, 128),
+				"revoked_at":    time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("session not found")
+		}
+		return AppendReleaseMessage(tx, models.MessageKindSession, key)
+	})
+	if err != nil {
+		if err.Error() != "session not found" {
+			logger.GetLogger("quiver").Errorf("revoke session %s failed: %v", sessionID, err)
+		}
+		return err
+	}
+
+	_ = cache.Delete(key)
+	NotifyChanges(env)
+	logger.GetLogger("quiver").Infof("session %s of user %d revoked: %s", sessionID, userID, reason)
+	return nil
+}
+
+// RevokeUserSessions 吊销用户的全部会话，其他实例通过 release_message 在一个轮询周期内感知
+func (s *SessionService) RevokeUserSessions(env string, userID uint64, reason string) (int64, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return 0, errors.New("db not initialized")
+	}
+
+	var sessionIDs []string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND revoked = 0", userID).
+			Pluck("session_id", &sessionIDs).Error; err != nil {
+			return err
+		}
+		if len(sessionIDs) == 0 {
+			return nil
+		}
+
+		if err := tx.Model(&models.Session{}).
+			Where("user_id = ? AND session_id IN (?)", userID, sessionIDs).
+			Updates(map[string]interface{}{
+				"revoked":       1,
+				"revoke_reason": truncate(reason, 128),
+				"revoked_at":    time.Now(),
+			}).Error; err != nil {
+			return err
+		}
+		for _, sessionID := range sessionIDs {
+			key := (&models.Session{SessionID: sessionID}).CacheKey(env)
+			if err := AppendReleaseMessage(tx, models.MessageKindSession, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("revoke sessions for user %d failed: %v", userID, err)
+		return 0, err
+	}
+
+	for _, sessionID := range sessionIDs {
+		_ = cache.Delete((&models.Session{SessionID: sessionID}).CacheKey(env))
+	}
+	NotifyChanges(env)
+	logger.GetLogger("quiver").Infof("revoked %d sessions of user %d: %s", len(sessionIDs), userID, reason)
+	return int64(len(sessionIDs)), nil
+}
+
+// IsAdmin 判断用户是否为管理员
+func IsAdmin(userName string) bool {
+	for _, name := range config.GetAuthConfig().AdminUsers {
+		if name == userName {
+			return true
+		}
+	}
+	return false
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return fmt.Sprintf("%.*s", n, s)
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"quiver/database"
+	"quiver/models"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestParseToken(t *testing.T) {
+	secret := strings.Repeat("ab", 32)
+	tests := []struct {
+		name    string
+		token   string
+		want    string
+		wantErr bool
+	}{
+		{"valid", "s1." + secret, "s1", false},
+		{"no separator", "s1" + secret, "", true},
+		{"empty session id", "." + secret, "", true},
+		{"short secret", "s1." + secret[:63], "", true},
+		{"long secret", "s1." + secret + "0", "", true},
+		{"empty", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseToken(tt.token)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Fatalf("parseToken() = %q, %v, want %q, wantErr %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	token, err := newToken("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := parseToken(token); err != nil || got != "s1" {
+		t.Fatalf("parseToken(newToken()) = %q, %v", got, err)
+	}
+}
+
+// testSession 返回一个有效会话及其 access token、refresh token
+func testSession(t *testing.T, now time.Time) (*models.Session, string, string) {
+	t.Helper()
+	access, err := newToken("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := newToken("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &models.Session{
+		ID:               1,
+		SessionID:        "s1",
+		UserID:           7,
+		UserName:         "alice",
+		AccessTokenHash:  hashToken(access),
+		RefreshTokenHash: hashToken(refresh),
+		AccessExpireAt:   now.Add(15 * time.Minute),
+		RefreshExpireAt:  now.Add(time.Hour),
+	}, access, refresh
+}
+
+func TestCheckAccessToken(t *testing.T) {
+	now := time.Now()
+	session, access, refresh := testSession(t, now)
+	revoked := *session
+	revoked.Revoked = 1
+
+	tests := []struct {
+		name    string
+		session *models.Session
+		token   string
+		now     time.Time
+		wantErr string
+	}{
+		{"valid", session, access, now, ""},
+		{"refresh token is not an access token", session, refresh, now, "invalid token"},
+		{"rotated access token", session, "s1." + strings.Repeat("0", 64), now, "invalid token"},
+		{"expired", session, access, now.Add(16 * time.Minute), "token expired"},
+		{"revoked", &revoked, access, now, "session revoked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAccessToken(tt.session, tt.token, tt.now)
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("checkAccessToken() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCheckRefreshToken(t *testing.T) {
+	now := time.Now()
+	session, access, refresh := testSession(t, now)
+	revoked := *session
+	revoked.Revoked = 1
+
+	tests := []struct {
+		name       string
+		session    *models.Session
+		token      string
+		now        time.Time
+		wantReused bool
+		wantErr    bool
+	}{
+		{"current token", session, refresh, now, false, false},
+		{"access token expired does not matter", session, refresh, now.Add(30 * time.Minute), false, false},
+		{"rotated token is reuse", session, "s1." + strings.Repeat("0", 64), now, true, true},
+		{"access token is reuse", session, access, now, true, true},
+		{"refresh expired", session, refresh, now.Add(2 * time.Hour), false, true},
+		// 已经吊销的会话不再重复吊销
+		{"revoked", &revoked, refresh, now, false, true},
+		{"old token of revoked session", &revoked, access, now, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reused, err := checkRefreshToken(tt.session, tt.token, tt.now)
+			if reused != tt.wantReused || (err != nil) != tt.wantErr {
+				t.Fatalf("checkRefreshToken() = %v, %v, want %v, wantErr %v", reused, err, tt.wantReused, tt.wantErr)
+			}
+		})
+	}
+}
+
+// sessionStore 只有一个会话的内存库：查询按 session_id 返回该行，
+// UPDATE `session` 把 SET 的列写回该行（不判断 WHERE 条件），其余写入只记录下来
+type sessionStore struct {
+	row        map[string]driver.Value
+	statements []string
+}
+
+var setColumns = regexp.MustCompile("SET (.*) WHERE")
+
+func (s *sessionStore) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	m := fromTable.FindStringSubmatch(query)
+	if m == nil || len(args) == 0 {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	rows := &memRows{columns: []string{"id"}}
+	switch m[1] {
+	case "session":
+		if args[0].Value == s.row["session_id"] {
+			rows.columns = rows.columns[:0]
+			for column := range s.row {
+				rows.columns = append(rows.columns, column)
+			}
+			rows.rows = []map[string]driver.Value{s.row}
+		}
+	case "sequence":
+		rows.columns = []string{"name", "value"}
+		rows.rows = []map[string]driver.Value{{"name": args[0].Value, "value": int64(len(s.statements))}}
+	default:
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	return rows, nil
+}
+
+func (s *sessionStore) exec(query string, args []driver.NamedValue) (driver.Result, error) {
+	s.statements = append(s.statements, query)
+	if strings.HasPrefix(query, "UPDATE `session`") {
+		m := setColumns.FindStringSubmatch(query)
+		if m == nil {
+			return nil, fmt.Errorf("unexpected update %q", query)
+		}
+		for i, assignment := range strings.Split(m[1], ",") {
+			column := strings.Trim(strings.TrimSuffix(assignment, "=?"), "`")
+			s.row[column] = args[i].Value
+		}
+	}
+	return driver.RowsAffected(1), nil
+}
+
+type sessionConnector struct{ s *sessionStore }
+
+func (c sessionConnector) Connect(context.Context) (driver.Conn, error) { return sessionConn(c), nil }
+func (c sessionConnector) Driver() driver.Driver                        { return nil }
+
+type sessionConn struct{ s *sessionStore }
+
+func (c sessionConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c sessionConn) Close() error              { return nil }
+func (c sessionConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c sessionConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.s.query(query, args)
+}
+func (c sessionConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.s.exec(query, args)
+}
+
+// useSessionStore 把会话写入内存库，并注册为 env 的数据库
+func useSessionStore(t *testing.T, env string, session *models.Session) *sessionStore {
+	t.Helper()
+	store := &sessionStore{row: map[string]driver.Value{
+		"id":                 int64(session.ID),
+		"session_id":         session.SessionID,
+		"user_id":            int64(session.UserID),
+		"user_name":          session.UserName,
+		"access_token_hash":  session.AccessTokenHash,
+		"refresh_token_hash": session.RefreshTokenHash,
+		"access_expire_at":   session.AccessExpireAt,
+		"refresh_expire_at":  session.RefreshExpireAt,
+		"revoked":            int64(session.Revoked),
+		"revoke_reason":      "",
+		"revoked_at":         nil,
+	}}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(sessionConnector{s: store}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DBMap[env] = db
+	t.Cleanup(func() { delete(database.DBMap, env) })
+	return store
+}
+
+// TestRefreshRotation 每次刷新都轮换两个令牌，旧的 refresh token 再次出现时吊销整个会话
+func TestRefreshRotation(t *testing.T) {
+	const env = "session-test"
+	session, access, refresh := testSession(t, time.Now())
+	store := useSessionStore(t, env, session)
+	s := NewSessionService()
+
+	if _, err := s.Authenticate(env, access); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	rotated, err := s.Refresh(env, refresh)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if rotated.SessionID != "s1" || !strings.HasPrefix(rotated.RefreshToken, "s1.") || !strings.HasPrefix(rotated.AccessToken, "s1.") {
+		t.Fatalf("Refresh() = %+v, want tokens of session s1", rotated)
+	}
+	if rotated.RefreshToken == refresh || rotated.AccessToken == access {
+		t.Fatal("Refresh() did not rotate the tokens")
+	}
+	if store.row["refresh_token_hash"] != hashToken(rotated.RefreshToken) || store.row["access_token_hash"] != hashToken(rotated.AccessToken) {
+		t.Fatal("Refresh() stored hashes of other tokens")
+	}
+	if !hasStatement(store.statements, "INSERT INTO `release_message`") {
+		t.Fatal("Refresh() did not notify other instances")
+	}
+
+	// 轮换后旧的 access token 失效，新的可用
+	if _, err := s.Authenticate(env, access); err == nil {
+		t.Fatal("Authenticate() accepted the rotated access token")
+	}
+	if _, err := s.Authenticate(env, rotated.AccessToken); err != nil {
+		t.Fatalf("Authenticate() error = %v", err)
+	}
+
+	// 旧的 refresh token 被重放，整个会话吊销，新的令牌也一并失效
+	if _, err := s.Refresh(env, refresh); err == nil {
+		t.Fatal("Refresh() accepted a reused refresh token")
+	}
+	if store.row["revoked"] != int64(1) || store.row["revoke_reason"] != "refresh token reuse" {
+		t.Fatalf("session revoked = %v, reason = %v, want revoked for reuse", store.row["revoked"], store.row["revoke_reason"])
+	}
+	if _, err := s.Refresh(env, rotated.RefreshToken); err == nil {
+		t.Fatal("Refresh() accepted a token of a revoked session")
+	}
+	if _, err := s.Authenticate(env, rotated.AccessToken); err == nil || err.Error() != "session revoked" {
+		t.Fatalf("Authenticate() error = %v, want session revoked", err)
+	}
+}
+
+func TestRefreshRejected(t *testing.T) {
+	const env = "session-test"
+	now := time.Now()
+	tests := []struct {
+		name  string
+		setup func(session *models.Session)
+		token func(refresh string) string
+	}{
+		{"malformed", nil, func(string) string { return "not-a-token" }},
+		{"unknown session", nil, func(refresh string) string { return "s2" + strings.TrimPrefix(refresh, "s1") }},
+		{"expired", func(session *models.Session) { session.RefreshExpireAt = now.Add(-time.Second) }, nil},
+		{"revoked", func(session *models.Session) { session.Revoked = 1 }, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _, refresh := testSession(t, now)
+			if tt.setup != nil {
+				tt.setup(session)
+			}
+			if tt.token != nil {
+				refresh = tt.token(refresh)
+			}
+			store := useSessionStore(t, env, session)
+			if _, err := NewSessionService().Refresh(env, refresh); err == nil {
+				t.Fatal("Refresh() error = nil")
+			}
+			// 无效的令牌既不轮换也不吊销
+			if len(store.statements) != 0 {
+				t.Fatalf("Refresh() wrote %q", store.statements)
+			}
+		})
+	}
+}
+
+func hasStatement(statements []string, prefix string) bool {
+	for _, st := range statements {
+		if strings.HasPrefix(st, prefix) {
+			return true
+		}
+	}
+	return false
+}
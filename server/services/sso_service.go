@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"quiver/cache"
+	"quiver/config"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/sso"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+// OIDC 登录发起到回调之间 state 的有效期
+const oidcStateTTL = 10 * time.Minute
+
+var (
+	// ErrIdentityNotLinked 外部身份没有关联用户，且用户名已被占用
+	ErrIdentityNotLinked = errors.New("identity not linked")
+	// ErrIdentityLinked 外部身份已经关联到其他用户
+	ErrIdentityLinked = errors.New("identity already linked")
+)
+
+// 权限来源
+const (
+	PermissionSourceManual = "manual"
+	PermissionSourceSSO    = "sso"
+)
+
+// oidcState 发起 OIDC 登录时写入缓存，回调时取出校验
+type oidcState struct {
+	Env      string `msgpack:"env"`
+	Nonce    string `msgpack:"nonce"`
+	Verifier string `msgpack:"verifier"`
+}
+
+var (
+	ssoOnce      sync.Once
+	ldapProvider *sso.LDAPProvider
+	oidcProvider *sso.OIDCProvider
+)
+
+// providers 身份提供方全局只创建一次，OIDC 的 discovery 结果可以复用
+func providers() (*sso.LDAPProvider, *sso.OIDCProvider) {
+	ssoOnce.Do(func() {
+		authConf := config.GetAuthConfig()
+		ldapProvider = sso.NewLDAPProvider(authConf.LDAP)
+		oidcProvider = sso.NewOIDCProvider(authConf.OIDC)
+	})
+	return ldapProvider, oidcProvider
+}
+
+type SSOService struct{}
+
+func NewSSOService() *SSOService {
+	return &SSOService{}
+}
+
+// LoginLDAP LDAP 校验通过后按需创建用户并同步组权限
+func (s *SSOService) LoginLDAP(env, userName, password string) (*models.User, error) {
+	ldap, _ := providers()
+	identity, err := ldap.Authenticate(userName, password)
+	if err != nil {
+		return nil, err
+	}
+	return s.ProvisionUser(env, identity)
+}
+
+// OIDCLoginURL 生成 state、nonce 和 PKCE verifier，返回 IdP 的登录地址
+func (s *SSOService) OIDCLoginURL(ctx context.Context, env string) (string, error) {
+	_, oidc := providers()
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(buf[:16])
+	st := oidcState{
+		Env:      env,
+		Nonce:    hex.EncodeToString(buf[16:]),
+		Verifier: oauth2.GenerateVerifier(),
+	}
+
+	url, err := oidc.AuthCodeURL(ctx, state, st.Nonce, st.Verifier)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := msgpack.Marshal(&st)
+	if err != nil {
+		return "", err
+	}
+	if err := cache.SetContext(ctx, "oidc_state:"+state, data, oidcStateTTL); err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("save oidc state failed: %v", err)
+		return "", err
+	}
+	return url, nil
+}
+
+// OIDCCallback 校验 state 后用授权码换取身份，state 只能使用一次
+func (s *SSOService) OIDCCallback(ctx context.Context, env, code, state string) (*models.User, error) {
+	if code == "" || state == "" {
+		return nil, errors.New("invalid state")
+	}
+
+	key := "oidc_state:" + state
+	data, ok, _ := cache.GetContext(ctx, key)
+	if !ok || len(data) == 0 {
+		return nil, errors.New("invalid state")
+	}
+	_ = cache.DeleteContext(ctx, key)
+
+	var st oidcState
+	if err := msgpack.Unmarshal(data, &st); err != nil || st.Env != env {
+		return nil, errors.New("invalid state")
+	}
+
+	_, oidc := providers()
+	identity, err := oidc.Exchange(ctx, code, st.Nonce, st.Verifier)
+	if err != nil {
+		return nil, err
+	}
+	return s.ProvisionUser(env, identity)
+}
+
+// ProvisionUser 按外部身份查找用户，首次登录时创建用户（JIT），之后登录同步邮箱、手机和组权限
+// 不按用户名关联已有用户，同名用户需要管理员通过 LinkIdentity 显式关联
+func (s *SSOService) ProvisionUser(env string, identity *sso.Identity) (*models.User, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+	if identity == nil || identity.UserName == "" || identity.ExternalID == "" {
+		return nil, errors.New("user_name and external_id are required")
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var candidates []models.User
+		if err := tx.Where("(source = ? AND external_id = ?) OR user_name = ?",
+			identity.Source, identity.ExternalID, identity.UserName).Find(&candidates).Error; err != nil {
+			return err
+		}
+		found, err := matchSSOUser(candidates, identity)
+		if err != nil {
+			return err
+		}
+
+		if found == nil {
+			user = models.User{
+				UserName:   identity.UserName,
+				Email:      identity.Email,
+				Phone:      identity.Phone,
+				Source:     identity.Source,
+				ExternalID: &identity.ExternalID,
+			}
+			// 外部用户没有本地密码，写入一个不可猜测的随机值
+			random := make([]byte, 32)
+			if _, err := rand.Read(random); err != nil {
+				return err
+			}
+			if err := user.SetPassword(hex.EncodeToString(random)); err != nil {
+				return err
+			}
+			// email、phone 有唯一索引，为空时写 NULL
+			var omit []string
+			if user.Email == "" {
+				omit = append(omit, "email")
+			}
+			if user.Phone == "" {
+				omit = append(omit, "phone")
+			}
+			if err := tx.Omit(omit...).Create(&user).Error; err != nil {
+				return err
+			}
+			logger.GetLogger("quiver").Infof("provisioned %s user %s for %s", identity.Source, identity.UserName, identity.ExternalID)
+		} else {
+			user = *found
+			updates := map[string]interface{}{}
+			if identity.Email != "" {
+				updates["email"] = identity.Email
+			}
+			if identity.Phone != "" {
+				updates["phone"] = identity.Phone
+			}
+			if len(updates) > 0 {
+				if err := tx.Model(&user).Updates(updates).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return s.syncGroupPermissions(tx, env, user.UserID, identity.Groups)
+	})
+	if err != nil {
+		if errors.Is(err, ErrIdentityNotLinked) {
+			logger.GetLogger("quiver").Warnf("refuse %s login: %v", identity.Source, err)
+		} else {
+			logger.GetLogger("quiver").Errorf("provision user %s failed: %v", identity.UserName, err)
+		}
+		return nil, err
+	}
+	NotifyChanges(env)
+	return &user, nil
+}
+
+// matchSSOUser 从按外部身份或用户名查到的用户中找出已关联该身份的用户，返回 nil 表示需要创建
+// 同名用户（不区分大小写）或与 admin_users 同名时拒绝登录，避免 IdP 中的同名用户接管本地用户或获得管理员权限
+func matchSSOUser(candidates []models.User, identity *sso.Identity) (*models.User, error) {
+	for i := range candidates {
+		u := &candidates[i]
+		if u.Source == identity.Source && u.ExternalID != nil && *u.ExternalID == identity.ExternalID {
+			return u, nil
+		}
+	}
+	for _, u := range candidates {
+		if strings.EqualFold(u.UserName, identity.UserName) {
+			return nil, fmt.Errorf("%w: user %s already exists, an administrator must link it to %s identity %s",
+				ErrIdentityNotLinked, u.UserName, identity.Source, identity.ExternalID)
+		}
+	}
+	for _, name := range config.GetAuthConfig().AdminUsers {
+		if strings.EqualFold(name, identity.UserName) {
+			return nil, fmt.Errorf("%w: user_name %s is reserved for administrators, an administrator must link it to %s identity %s",
+				ErrIdentityNotLinked, identity.UserName, identity.Source, identity.ExternalID)
+		}
+	}
+	return nil, nil
+}
+
+// LinkIdentity 管理员把用户关联到外部身份，之后该用户只能通过对应的身份提供方登录
+func (s *SSOService) LinkIdentity(env string, userID uint64, source, externalID string) (*models.User, error) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return nil, errors.New("db not initialized")
+	}
+	if source != sso.SourceLDAP && source != sso.SourceOIDC {
+		return nil, errors.New("invalid source")
+	}
+	if externalID == "" || len(externalID) > 512 {
+		return nil, errors.New("invalid external_id")
+	}
+
+	var user models.User
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", userID).First(&user).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return err
+		}
+
+		var other models.User
+		err := tx.Where("source = ? AND external_id = ? AND id <> ?", source, externalID, userID).First(&other).Error
+		if err == nil {
+			return fmt.Errorf("%w: already linked to user %s", ErrIdentityLinked, other.UserName)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		user.Source = source
+		user.ExternalID = &externalID
+		return tx.Model(&user).Updates(map[string]interface{}{"source": source, "external_id": externalID}).Error
+	})
+	if err != nil {
+		if !errors.Is(err, ErrIdentityLinked) && err.Error() != "user not found" {
+			logger.GetLogger("quiver").Errorf("link user %d to %s identity %s failed: %v", userID, source, externalID, err)
+		}
+		return nil, err
+	}
+	logger.GetLogger("quiver").Infof("user %s linked to %s identity %s", user.UserName, source, externalID)
+	return &user, nil
+}
+
+// permKey 用户对一个资源的一个操作
+type permKey struct {
+	ResourceType string
+	ResourceID   uint64
+	Action       string
+}
+
+// syncGroupPermissions 按 group_mappings 计算用户应有的权限，补齐缺少的，删除不再属于的组带来的权限
+// 只处理 source=sso 的权限，手工授予的权限不受影响
+func (s *SSOService) syncGroupPermissions(tx *gorm.DB, env string, userID uint64, groups []string) error {
+	mappings := config.GetAuthConfig().GroupMappings
+	if len(mappings) == 0 {
+		return nil
+	}
+
+	desired := groupPermissions(userID, groups, mappings, func(resourceType, resourceName string) (uint64, error) {
+		return resolveResource(env, resourceType, resourceName)
+	})
+
+	var existing []models.Permission
+	if err := tx.Where("user_id = ?", userID).Find(&existing).Error; err != nil {
+		return err
+	}
+	create, remove := diffPermissions(existing, desired)
+	for _, perm := range remove {
+		if err := tx.Delete(&models.Permission{}, perm.ID).Error; err != nil {
+			return err
+		}
+		if err := AppendReleaseMessage(tx, models.MessageKindPermission, perm.CacheKey(env)); err != nil {
+			return err
+		}
+	}
+	for _, perm := range create {
+		if err := tx.Create(&perm).Error; err != nil {
+			return err
+		}
+		if err := AppendReleaseMessage(tx, models.MessageKindPermission, perm.CacheKey(env)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupPermissions 用户所在的组映射出的权限，资源不存在的映射跳过
+func groupPermissions(userID uint64, groups []string, mappings []config.GroupMapping,
+	resolve func(resourceType, resourceName string) (uint64, error)) map[permKey]models.Permission {
+	inGroup := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		inGroup[g] = true
+	}
+
+	desired := make(map[permKey]models.Permission)
+	for _, m := range mappings {
+		if !inGroup[m.Group] {
+			continue
+		}
+		resourceID, err := resolve(m.ResourceType, m.ResourceName)
+		if err != nil {
+			logger.GetLogger("quiver").Warnf("skip group mapping %s -> %s %s: %v", m.Group, m.ResourceType, m.ResourceName, err)
+			continue
+		}
+		key := permKey{ResourceType: m.ResourceType, ResourceID: resourceID, Action: m.Action}
+		desired[key] = models.Permission{
+			UserID:       userID,
+			ResourceType: m.ResourceType,
+			ResourceID:   resourceID,
+			ResourceName: m.ResourceName,
+			Action:       m.Action,
+			Source:       PermissionSourceSSO,
+		}
+	}
+	return desired
+}
+
+// diffPermissions 比较已有权限与组映射出的权限：已有的（手工授予的也算）不再创建，
+// 不再映射出的 source=sso 权限删除，手工授予的权限保留
+func diffPermissions(existing []models.Permission, desired map[permKey]models.Permission) (create, remove []models.Permission) {
+	have := make(map[permKey]bool, len(existing))
+	for _, perm := range existing {
+		key := permKey{ResourceType: perm.ResourceType, ResourceID: perm.ResourceID, Action: perm.Action}
+		have[key] = true
+		if _, ok := desired[key]; !ok && perm.Source == PermissionSourceSSO {
+			remove = append(remove, perm)
+		}
+	}
+	for key, perm := range desired {
+		if !have[key] {
+			create = append(create, perm)
+		}
+	}
+	sort.Slice(create, func(i, j int) bool {
+		a, b := create[i], create[j]
+		if a.ResourceType != b.ResourceType {
+			return a.ResourceType < b.ResourceType
+		}
+		if a.ResourceID != b.ResourceID {
+			return a.ResourceID < b.ResourceID
+		}
+		return a.Action < b.Action
+	})
+	return create, remove
+}
+
+// resolveResource 把 app、app/cluster、app/cluster/namespace 形式的资源名解析为资源 ID
+func resolveResource(env, resourceType, resourceName string) (uint64, error) {
+	parts := strings.Split(resourceName, "/")
+	switch {
+	case resourceType == "APP" && len(parts) == 1:
+		ids, err := CheckACNKinDB(&env, &parts[0], nil, nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		return ids.AppID, nil
+	case resourceType == "CLUSTER" && len(parts) == 2:
+		ids, err := CheckACNKinDB(&env, &parts[0], &parts[1], nil, nil)
+		if err != nil {
+			return 0, err
+		}
+		return ids.ClusterID, nil
+	case resourceType == "NAMESPACE" && len(parts) == 3:
+		ids, err := CheckACNKinDB(&env, &parts[0], &parts[1], &parts[2], nil)
+		if err != nil {
+			return 0, err
+		}
+		return ids.NamespaceID, nil
+	}
+	return 0, fmt.Errorf("invalid resource %s %s", resourceType, resourceName)
+}
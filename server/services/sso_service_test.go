@@ -0,0 +1,416 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"quiver/config"
+	"quiver/database"
+	"quiver/models"
+	"quiver/sso"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMatchSSOUser(t *testing.T) {
+	loadTestConfig(t, "auth:\n  admin_users: [admin, root]\n")
+	str := func(s string) *string { return &s }
+	identity := &sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#sub-1", UserName: "alice"}
+
+	tests := []struct {
+		name       string
+		identity   *sso.Identity
+		candidates []models.User
+		wantID     uint64
+		wantErr    bool
+	}{
+		{
+			name:     "new identity",
+			identity: identity,
+		},
+		{
+			name:       "linked user",
+			identity:   identity,
+			candidates: []models.User{{UserID: 3, UserName: "alice", Source: sso.SourceOIDC, ExternalID: str("https://idp#sub-1")}},
+			wantID:     3,
+		},
+		{
+			// 用户名在 IdP 中改过，仍然按外部身份找到原来的用户
+			name:       "linked user renamed in the idp",
+			identity:   &sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#sub-1", UserName: "alice2"},
+			candidates: []models.User{{UserID: 3, UserName: "alice", Source: sso.SourceOIDC, ExternalID: str("https://idp#sub-1")}},
+			wantID:     3,
+		},
+		{
+			name:       "linked user and another user with the same name",
+			identity:   identity,
+			candidates: []models.User{{UserID: 4, UserName: "alice", Source: sso.SourceLocal}, {UserID: 3, UserName: "alice2", Source: sso.SourceOIDC, ExternalID: str("https://idp#sub-1")}},
+			wantID:     3,
+		},
+		{
+			name:       "local user with the same name",
+			identity:   identity,
+			candidates: []models.User{{UserID: 4, UserName: "alice", Source: sso.SourceLocal}},
+			wantErr:    true,
+		},
+		{
+			name:       "local user with the same name in another case",
+			identity:   identity,
+			candidates: []models.User{{UserID: 4, UserName: "Alice", Source: sso.SourceLocal}},
+			wantErr:    true,
+		},
+		{
+			name:       "user of another subject with the same name",
+			identity:   identity,
+			candidates: []models.User{{UserID: 5, UserName: "alice", Source: sso.SourceOIDC, ExternalID: str("https://idp#sub-2")}},
+			wantErr:    true,
+		},
+		{
+			name:       "same external id from another source",
+			identity:   &sso.Identity{Source: sso.SourceLDAP, ExternalID: "https://idp#sub-1", UserName: "bob"},
+			candidates: []models.User{{UserID: 3, UserName: "bob", Source: sso.SourceOIDC, ExternalID: str("https://idp#sub-1")}},
+			wantErr:    true,
+		},
+		{
+			// 没有本地 admin 用户时，IdP 中的 admin 也不能自动创建
+			name:     "admin user name is reserved",
+			identity: &sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#sub-9", UserName: "Root"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := matchSSOUser(tt.candidates, tt.identity)
+			if tt.wantErr {
+				if !errors.Is(err, ErrIdentityNotLinked) {
+					t.Fatalf("matchSSOUser() error = %v, want ErrIdentityNotLinked", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("matchSSOUser() error = %v", err)
+			}
+			var gotID uint64
+			if got != nil {
+				gotID = got.UserID
+			}
+			if gotID != tt.wantID {
+				t.Fatalf("matchSSOUser() = user %d, want %d", gotID, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestGroupPermissions(t *testing.T) {
+	mappings := []config.GroupMapping{
+		{Group: "payment-dev", ResourceType: "APP", ResourceName: "payment", Action: "write"},
+		{Group: "payment-dev", ResourceType: "NAMESPACE", ResourceName: "payment/default/application", Action: "release"},
+		{Group: "ops", ResourceType: "APP", ResourceName: "payment", Action: "write"},
+		{Group: "ops", ResourceType: "APP", ResourceName: "missing", Action: "read"},
+		{Group: "audit", ResourceType: "APP", ResourceName: "payment", Action: "read"},
+	}
+	resolve := func(resourceType, resourceName string) (uint64, error) {
+		switch resourceType + " " + resourceName {
+		case "APP payment":
+			return 1, nil
+		case "NAMESPACE payment/default/application":
+			return 10, nil
+		}
+		return 0, errors.New("app not found")
+	}
+
+	got := groupPermissions(7, []string{"ops", "payment-dev"}, mappings, resolve)
+	want := map[permKey]models.Permission{
+		{"APP", 1, "write"}: {UserID: 7, ResourceType: "APP", ResourceID: 1, ResourceName: "payment", Action: "write", Source: PermissionSourceSSO},
+		{"NAMESPACE", 10, "release"}: {UserID: 7, ResourceType: "NAMESPACE", ResourceID: 10,
+			ResourceName: "payment/default/application", Action: "release", Source: PermissionSourceSSO},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("groupPermissions() = %+v, want %+v", got, want)
+	}
+
+	if got := groupPermissions(7, nil, mappings, resolve); len(got) != 0 {
+		t.Fatalf("groupPermissions() without groups = %+v", got)
+	}
+}
+
+func TestDiffPermissions(t *testing.T) {
+	sso := func(id uint64, resourceID uint64, action string) models.Permission {
+		return models.Permission{ID: id, UserID: 7, ResourceType: "APP", ResourceID: resourceID, Action: action, Source: PermissionSourceSSO}
+	}
+	manual := func(id uint64, resourceID uint64, action string) models.Permission {
+		p := sso(id, resourceID, action)
+		p.Source = PermissionSourceManual
+		return p
+	}
+	desired := func(perms ...models.Permission) map[permKey]models.Permission {
+		m := make(map[permKey]models.Permission)
+		for _, p := range perms {
+			p.ID = 0
+			m[permKey{p.ResourceType, p.ResourceID, p.Action}] = p
+		}
+		return m
+	}
+	ids := func(perms []models.Permission) []string {
+		var result []string
+		for _, p := range perms {
+			result = append(result, fmt.Sprintf("%d/%d/%s", p.ID, p.ResourceID, p.Action))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name       string
+		existing   []models.Permission
+		desired    map[permKey]models.Permission
+		wantCreate []string
+		wantRemove []string
+	}{
+		{
+			name:       "first login",
+			desired:    desired(sso(0, 1, "write"), sso(0, 2, "read")),
+			wantCreate: []string{"0/1/write", "0/2/read"},
+		},
+		{
+			name:     "already in sync",
+			existing: []models.Permission{sso(11, 1, "write")},
+			desired:  desired(sso(0, 1, "write")),
+		},
+		{
+			name:       "left a group",
+			existing:   []models.Permission{sso(11, 1, "write"), sso(12, 2, "read")},
+			desired:    desired(sso(0, 1, "write")),
+			wantRemove: []string{"12/2/read"},
+		},
+		{
+			name:     "manual permission kept after leaving the group",
+			existing: []models.Permission{manual(21, 1, "write"), manual(22, 3, "release")},
+			desired:  desired(),
+		},
+		{
+			// 手工授予的权限已经覆盖组映射，不再重复创建（唯一索引也不允许）
+			name:     "manual permission covers the mapping",
+			existing: []models.Permission{manual(21, 1, "write")},
+			desired:  desired(sso(0, 1, "write")),
+		},
+		{
+			name:       "no mapping left",
+			existing:   []models.Permission{manual(21, 1, "write"), sso(11, 2, "read"), sso(12, 3, "read")},
+			desired:    desired(),
+			wantRemove: []string{"11/2/read", "12/3/read"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			create, remove := diffPermissions(tt.existing, tt.desired)
+			if got := ids(create); !reflect.DeepEqual(got, tt.wantCreate) {
+				t.Fatalf("create = %q, want %q", got, tt.wantCreate)
+			}
+			if got := ids(remove); !reflect.DeepEqual(got, tt.wantRemove) {
+				t.Fatalf("remove = %q, want %q", got, tt.wantRemove)
+			}
+		})
+	}
+}
+
+// ssoStore 用户按登录时的查询条件匹配，其他表按 memDB 查询，写入只记录下来
+type ssoStore struct {
+	users      []models.User
+	tables     memDB
+	statements []recordedExec
+}
+
+func (s *ssoStore) query(query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "FROM `user`") {
+		return s.tables.query(query, args)
+	}
+	if len(args) != 3 {
+		return nil, fmt.Errorf("unexpected query %q", query)
+	}
+	rows := &memRows{columns: []string{"id", "user_name", "source", "external_id"}}
+	for _, u := range s.users {
+		linked := u.Source == args[0].Value && u.ExternalID != nil && *u.ExternalID == args[1].Value
+		if linked || strings.EqualFold(u.UserName, args[2].Value.(string)) {
+			var externalID driver.Value
+			if u.ExternalID != nil {
+				externalID = *u.ExternalID
+			}
+			rows.rows = append(rows.rows, map[string]driver.Value{
+				"id": int64(u.UserID), "user_name": u.UserName, "source": u.Source, "external_id": externalID,
+			})
+		}
+	}
+	return rows, nil
+}
+
+type ssoConnector struct{ s *ssoStore }
+
+func (c ssoConnector) Connect(context.Context) (driver.Conn, error) { return ssoConn(c), nil }
+func (c ssoConnector) Driver() driver.Driver                        { return nil }
+
+type ssoConn struct{ s *ssoStore }
+
+func (c ssoConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c ssoConn) Close() error              { return nil }
+func (c ssoConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+func (c ssoConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.s.query(query, args)
+}
+func (c ssoConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	c.s.statements = append(c.s.statements, recordedExec{query: query, args: values})
+	return recorderResult{}, nil
+}
+
+// ssoFixture 应用 payment 下有命名空间 default/application；用户 7 是已关联 OIDC 身份 sub-alice 的 alice，
+// 有手工授予的 payment read 和组映射来的 payment write；本地用户 admin、bob 没有关联外部身份
+func ssoFixture(t *testing.T, env string) *ssoStore {
+	t.Helper()
+	loadTestConfig(t, `auth:
+  admin_users: [admin]
+  group_mappings:
+    - {group: payment-dev, resource_type: APP, resource_name: payment, action: write}
+    - {group: payment-ops, resource_type: NAMESPACE, resource_name: payment/default/application, action: release}
+`)
+	aliceID := "https://idp#sub-alice"
+	store := &ssoStore{
+		users: []models.User{
+			{UserID: 1, UserName: "admin", Source: sso.SourceLocal},
+			{UserID: 2, UserName: "bob", Source: sso.SourceLocal},
+			{UserID: 7, UserName: "alice", Source: sso.SourceOIDC, ExternalID: &aliceID},
+		},
+		tables: memDB{
+			"app": {filter: "app_name", rows: []map[string]driver.Value{
+				{"id": int64(1), "app_name": "payment"},
+			}},
+			"cluster": {filter: "app_id", rows: []map[string]driver.Value{
+				{"id": int64(2), "app_id": int64(1), "cluster_name": "default"},
+			}},
+			"namespace": {filter: "cluster_id", rows: []map[string]driver.Value{
+				{"id": int64(10), "app_id": int64(1), "cluster_id": int64(2), "namespace_name": "application"},
+			}},
+			"permission": {filter: "user_id", rows: []map[string]driver.Value{
+				{"id": int64(21), "user_id": int64(7), "resource_type": "APP", "resource_id": int64(1), "resource_name": "payment", "action": "read", "source": PermissionSourceManual},
+				{"id": int64(22), "user_id": int64(7), "resource_type": "APP", "resource_id": int64(1), "resource_name": "payment", "action": "write", "source": PermissionSourceSSO},
+			}},
+			"sequence": {filter: "name", rows: []map[string]driver.Value{
+				{"name": releaseMessageSequence, "value": int64(1)},
+			}},
+		},
+	}
+	db, err := gorm.Open(mysql.New(mysql.Config{
+		Conn:                      sql.OpenDB(ssoConnector{s: store}),
+		SkipInitializeWithVersion: true,
+	}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	database.DBMap[env] = db
+	t.Cleanup(func() { delete(database.DBMap, env) })
+	return store
+}
+
+// writes 按语句类型和表汇总写入，如 "INSERT user"、"DELETE permission 22"
+func (s *ssoStore) writes() []string {
+	var result []string
+	for _, st := range s.statements {
+		fields := strings.Fields(st.query)
+		switch fields[0] {
+		case "INSERT":
+			result = append(result, "INSERT "+strings.Trim(fields[2], "`"))
+		case "UPDATE":
+			result = append(result, "UPDATE "+strings.Trim(fields[1], "`"))
+		case "DELETE":
+			result = append(result, fmt.Sprintf("DELETE %s %v", strings.Trim(fields[2], "`"), st.args[len(st.args)-1]))
+		}
+	}
+	return result
+}
+
+// TestProvisionUser 身份提供方返回的身份按 external_id 关联用户，组映射的权限同步时保留手工授予的权限
+func TestProvisionUser(t *testing.T) {
+	const env = "sso-test"
+	tests := []struct {
+		name       string
+		identity   sso.Identity
+		wantUserID uint64
+		wantWrites []string
+		wantErr    bool
+	}{
+		{
+			name:       "linked user left payment-dev and joined payment-ops",
+			identity:   sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#sub-alice", UserName: "alice", Groups: []string{"payment-ops"}},
+			wantUserID: 7,
+			wantWrites: []string{
+				"DELETE permission 22", "UPDATE sequence", "INSERT release_message",
+				"INSERT permission", "UPDATE sequence", "INSERT release_message",
+			},
+		},
+		{
+			name:       "linked user still in payment-dev",
+			identity:   sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#sub-alice", UserName: "alice", Email: "alice@example.com", Groups: []string{"payment-dev"}},
+			wantUserID: 7,
+			wantWrites: []string{"UPDATE user"},
+		},
+		{
+			name:       "new identity is provisioned with its mapped permissions",
+			identity:   sso.Identity{Source: sso.SourceLDAP, ExternalID: "uid=carol,ou=people,dc=example,dc=com", UserName: "carol", Groups: []string{"payment-dev"}},
+			wantUserID: 1, // 由假驱动的 LastInsertId 返回
+			wantWrites: []string{"INSERT user", "INSERT permission", "UPDATE sequence", "INSERT release_message"},
+		},
+		{
+			name:     "local user with the same name is not linked",
+			identity: sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#sub-bob", UserName: "bob", Groups: []string{"payment-dev"}},
+			wantErr:  true,
+		},
+		{
+			name:     "idp user named admin does not take over the local admin",
+			identity: sso.Identity{Source: sso.SourceOIDC, ExternalID: "https://idp#attacker", UserName: "admin"},
+			wantErr:  true,
+		},
+		{
+			name:     "identity without external id",
+			identity: sso.Identity{Source: sso.SourceOIDC, UserName: "dave"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := ssoFixture(t, env)
+			user, err := NewSSOService().ProvisionUser(env, &tt.identity)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ProvisionUser() = user %d, want error", user.UserID)
+				}
+				if writes := store.writes(); len(writes) != 0 {
+					t.Fatalf("ProvisionUser() wrote %q after refusing the login", writes)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ProvisionUser() error = %v", err)
+			}
+			if user.UserID != tt.wantUserID {
+				t.Fatalf("ProvisionUser() = user %d, want %d", user.UserID, tt.wantUserID)
+			}
+			if user.ExternalID == nil || *user.ExternalID != tt.identity.ExternalID || user.Source != tt.identity.Source {
+				t.Fatalf("ProvisionUser() = %s %v, want %s %s", user.Source, user.ExternalID, tt.identity.Source, tt.identity.ExternalID)
+			}
+			if got := store.writes(); !reflect.DeepEqual(got, tt.wantWrites) {
+				t.Fatalf("writes = %q, want %q", got, tt.wantWrites)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"quiver/database"
 	"quiver/logger"
 	"quiver/models"
+	"quiver/sso"
 	"quiver/utils"
 	"strconv"
 
@@ -77,6 +78,8 @@ func (s *UserService) CreateUser(env string, user *models.User) error {
 		return err
 	}
 
+	user.Source = sso.SourceLocal
+	user.ExternalID = nil
 	return db.Create(user).Error
 }
 
@@ -158,6 +161,9 @@ func (s *UserService) UpdateUser(env string, userID uint64, updates map[string]i
 		return nil, err
 	}
 
+	// 用户来源和外部身份只能由登录流程和 LinkIdentity 维护
+	delete(updates, "source")
+	delete(updates, "external_id")
 	if err := db.Model(&user).Updates(updates).Error; err != nil {
 		logger.GetLogger("quiver").Errorf("error updating user for user_id %d: %v", userID, err)
 		return nil, err
@@ -173,7 +179,36 @@ func (s *UserService) DeleteUser(env string, userID uint64) error {
 		return errors.New("db not initialized")
 	}
 
-	return db.Transaction(func(tx *gorm.DB) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// 先收集要失效的缓存 key，记录到变更消息中
+		var perms []models.Permission
+		var aks []models.AccessKey
+		var sessions []models.Session
+		if err := tx.Where("user_id = ?", userID).Find(&perms).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Find(&aks).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Find(&sessions).Error; err != nil {
+			return err
+		}
+		for i := range perms {
+			if err := AppendReleaseMessage(tx, models.MessageKindPermission, perms[i].CacheKey(env)); err != nil {
+				return err
+			}
+		}
+		for i := range aks {
+			if err := AppendReleaseMessage(tx, models.MessageKindAccessKey, aks[i].CacheKey(env)); err != nil {
+				return err
+			}
+		}
+		for i := range sessions {
+			if err := AppendReleaseMessage(tx, models.MessageKindSession, sessions[i].CacheKey(env)); err != nil {
+				return err
+			}
+		}
+
 		if err := tx.Where("id = ?", userID).Delete(&models.User{}).Error; err != nil {
 			logger.GetLogger("quiver").Errorf("delete user failed: %v", err)
 			return err
@@ -188,4 +223,9 @@ func (s *UserService) DeleteUser(env string, userID uint64) error {
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	NotifyChanges(env)
+	return nil
 }
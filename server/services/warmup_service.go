@@ -0,0 +1,99 @@
+package services
+
+import (
+	"context"
+	"quiver/config"
+	"quiver/database"
+	"quiver/logger"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 启动预加载是否已经结束，结束前 /ready 返回 503
+var warmedUp atomic.Bool
+
+// latestRelease 每个命名空间最近一次发布
+type latestRelease struct {
+	AppName       string
+	ClusterName   string
+	NamespaceName string
+	ReleaseID     string
+}
+
+// Ready 启动预加载是否已完成（或未开启、已超时）
+func Ready() bool {
+	return warmedUp.Load()
+}
+
+// WarmUp 启动时预加载所有命名空间的最新发布，避免重启后所有客户端同时回源数据库
+// latest 指针总是按数据库重新建立；持久化缓存中已有的发布快照直接复用，不会再查 item_release
+func WarmUp(envs []string) {
+	defer warmedUp.Store(true)
+
+	conf := config.GetCacheConfig()
+	if !conf.WarmUpEnabled() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), conf.WarmUpTimeout)
+	defer cancel()
+
+	start := time.Now()
+	for _, env := range envs {
+		loaded, failed := warmUpEnv(ctx, env, conf.WarmUpConcurrency)
+		logger.GetLogger("quiver").Infof("warm up env %s: loaded %d namespaces, failed %d", env, loaded, failed)
+		if ctx.Err() != nil {
+			logger.GetLogger("quiver").Warnf("warm up timeout after %s, continue without full cache", conf.WarmUpTimeout)
+			return
+		}
+	}
+	logger.GetLogger("quiver").Infof("warm up finished in %s", time.Since(start))
+}
+
+func warmUpEnv(ctx context.Context, env string, concurrency int) (int64, int64) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return 0, 0
+	}
+
+	// 只取仍然存在的命名空间
+	var releases []latestRelease
+	if err := db.WithContext(ctx).Raw(`SELECT nr.app_name, nr.cluster_name, nr.namespace_name, nr.release_id
+		FROM namespace_release nr
+		JOIN (SELECT namespace_id, MAX(id) AS id FROM namespace_release GROUP BY namespace_id) t ON nr.id = t.id
+		JOIN namespace n ON n.id = nr.namespace_id`).Scan(&releases).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("query latest releases for env %s failed: %v", env, err)
+		return 0, 0
+	}
+
+	var loaded, failed atomic.Int64
+	jobs := make(chan latestRelease)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := NewReleaseService().WithContext(ctx)
+			for r := range jobs {
+				if _, err := s.GetLatestReleaseAll(env, r.AppName, r.ClusterName, r.NamespaceName); err != nil {
+					logger.GetLogger("quiver").Warnf("warm up %s/%s/%s/%s failed: %v", env, r.AppName, r.ClusterName, r.NamespaceName, err)
+					failed.Add(1)
+					continue
+				}
+				loaded.Add(1)
+			}
+		}()
+	}
+
+	for _, r := range releases {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- r
+	}
+	close(jobs)
+	wg.Wait()
+	return loaded.Load(), failed.Load()
+}
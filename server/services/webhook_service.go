@@ -0,0 +1,738 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"quiver/config"
+	"quiver/database"
+	"quiver/logger"
+	"quiver/models"
+	"quiver/peer"
+	"quiver/telemetry"
+	"quiver/utils"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 投递请求头，签名与实例间通知相同：X-Quiver-Signature 为 hex(HMAC-SHA256(secret, timestamp + "." + body))
+const (
+	HeaderWebhookEvent    = "X-Quiver-Event"
+	HeaderWebhookDelivery = "X-Quiver-Delivery"
+)
+
+const (
+	webhookConcurrency = 8  // 每个实例同时投递的请求数
+	webhookMaxRounds   = 10 // 一次扫描最多连续投递的批数
+)
+
+// ErrWebhookNotFound webhook 不存在或不属于该应用
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrWebhookDeliveryNotFound 投递记录不存在或不属于该 webhook
+var ErrWebhookDeliveryNotFound = errors.New("delivery not found")
+
+var webhookClient = &http.Client{
+	Transport: newWebhookTransport(),
+	// 重定向按失败处理，避免 POST 被改写为 GET
+	CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+}
+
+// newWebhookTransport 在建立连接前按 webhook.allow_cidrs、deny_cidrs 检查解析后的地址，
+// 域名解析到内网地址（包括 DNS rebinding）同样会被拒绝；不使用代理，否则检查的是代理的地址
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: webhookDialControl}
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = dialer.DialContext
+	return t
+}
+
+func webhookDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return err
+	}
+	if !config.GetWebhookConfig().AddrAllowed(addrPort.Addr()) {
+		return fmt.Errorf("webhook address %s is not allowed by webhook.allow_cidrs and deny_cidrs", addrPort.Addr())
+	}
+	return nil
+}
+
+// webhookWake 事务提交后唤醒本实例的投递协程
+var webhookWake = make(chan string, 16)
+
+// WebhookService 配置变更 webhook 的订阅、投递和重放
+type WebhookService struct {
+	ctx context.Context
+}
+
+// WebhookEvent 投递给 webhook 的事件，序列化后作为请求体
+type WebhookEvent struct {
+	ID            string          `json:"id"` // 同一事件投递给多个 webhook 或重放时不变
+	Event         string          `json:"event"`
+	Env           string          `json:"env"`
+	Time          time.Time       `json:"time"`
+	AppName       string          `json:"app_name"`
+	ClusterName   string          `json:"cluster_name,omitempty"`
+	NamespaceName string          `json:"namespace_name,omitempty"`
+	Operator      string          `json:"operator,omitempty"`
+	Release       *WebhookRelease `json:"release,omitempty"` // publish、rollback 生成的版本
+	Key           string          `json:"key,omitempty"`     // item.set、item.delete 的 key，不包含值
+}
+
+// WebhookRelease 事件中的版本信息
+type WebhookRelease struct {
+	ReleaseID       string `json:"release_id"`
+	ReleaseName     string `json:"release_name"`
+	Comment         string `json:"comment,omitempty"`
+	TargetReleaseID string `json:"target_release_id,omitempty"` // rollback 回滚到的版本
+	SourceReleaseID string `json:"source_release_id,omitempty"` // 由其他集群的哪个版本提升而来
+	GitCommit       string `json:"git_commit,omitempty"`
+}
+
+// WebhookUpdate 修改 webhook，为 nil 的字段保持不变
+type WebhookUpdate struct {
+	URL         *string
+	Events      *[]string
+	Enabled     *bool
+	Description *string
+	Secret      *string // 为空字符串时重新生成
+}
+
+// NewWebhookService 创建 webhook 服务实例
+func NewWebhookService() *WebhookService {
+	return &WebhookService{}
+}
+
+// WithContext 返回绑定了请求上下文的服务实例，用于链路追踪
+func (s *WebhookService) WithContext(ctx context.Context) *WebhookService {
+	return &WebhookService{ctx: ctx}
+}
+
+func (s *WebhookService) trace(name string) (*WebhookService, trace.Span) {
+	ctx, span := telemetry.Start(s.ctx, "WebhookService."+name)
+	return &WebhookService{ctx: ctx}, span
+}
+
+// StartWebhookDispatcher 在后台投递各环境待投递的记录，并定期清理过期的投递记录
+// 每个实例都会投递，同一条记录通过条件更新只会被一个实例领取
+func StartWebhookDispatcher(envs []string) {
+	conf := config.GetWebhookConfig()
+
+	go func() {
+		ticker := time.NewTicker(conf.Interval)
+		defer ticker.Stop()
+		var lastPurge time.Time
+		for {
+			select {
+			case env := <-webhookWake:
+				NewWebhookService().Dispatch(env)
+			case <-ticker.C:
+				for _, env := range envs {
+					NewWebhookService().Dispatch(env)
+				}
+				if time.Since(lastPurge) >= time.Hour {
+					for _, env := range envs {
+						NewWebhookService().purge(env, conf.KeepDays)
+					}
+					lastPurge = time.Now()
+				}
+			}
+		}
+	}()
+}
+
+// WakeWebhooks 写入投递记录的事务提交后调用，本实例立即投递，不等下一次扫描
+func WakeWebhooks(env string) {
+	select {
+	case webhookWake <- env:
+	default:
+	}
+}
+
+// releaseEvent 发布、回滚生成的版本对应的事件
+func releaseEvent(event, env string, release *models.NamespaceRelease) *WebhookEvent {
+	return &WebhookEvent{
+		Event:         event,
+		Env:           env,
+		AppName:       release.AppName,
+		ClusterName:   release.ClusterName,
+		NamespaceName: release.NamespaceName,
+		Operator:      release.Operator,
+		Release: &WebhookRelease{
+			ReleaseID:       release.ReleaseID,
+			ReleaseName:     release.ReleaseName,
+			Comment:         release.Comment,
+			SourceReleaseID: release.SourceRelease,
+			GitCommit:       release.GitCommit,
+		},
+	}
+}
+
+// enqueueWebhooks 在业务事务 tx 中为订阅了这些事件的 webhook 写入投递记录，与变更一起提交；
+// 事务提交后调用 WakeWebhooks
+func enqueueWebhooks(tx *gorm.DB, events ...*WebhookEvent) error {
+	now := time.Now()
+	hooks := map[string][]models.Webhook{}
+	var deliveries []models.WebhookDelivery
+	for _, event := range events {
+		list, ok := hooks[event.AppName]
+		if !ok {
+			if err := tx.Where("app_name = ? AND enabled = ?", event.AppName, true).Find(&list).Error; err != nil {
+				return fmt.Errorf("failed to query webhooks: %w", err)
+			}
+			hooks[event.AppName] = list
+		}
+
+		var matched []uint64
+		for i := range list {
+			if webhookMatches(&list[i], event) {
+				matched = append(matched, list[i].ID)
+			}
+		}
+		if len(matched) == 0 {
+			continue
+		}
+
+		if event.ID == "" {
+			id, err := utils.GenerateReleaseID()
+			if err != nil {
+				return fmt.Errorf("failed to generate event id: %w", err)
+			}
+			event.ID = id
+		}
+		if event.Time.IsZero() {
+			event.Time = now
+		}
+		payload, err := json.Marshal(event)
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhook event: %w", err)
+		}
+		for _, id := range matched {
+			deliveries = append(deliveries, models.WebhookDelivery{
+				WebhookID:       id,
+				EventID:         event.ID,
+				Event:           event.Event,
+				Payload:         string(payload),
+				Status:          models.WebhookDeliveryPending,
+				NextAttemptTime: now,
+			})
+		}
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := tx.CreateInBatches(deliveries, 100).Error; err != nil {
+		return fmt.Errorf("failed to create webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// webhookMatches 订阅的范围包含事件（删除集群、应用的事件对其下的命名空间订阅同样可见），且订阅了该事件类型
+func webhookMatches(hook *models.Webhook, event *WebhookEvent) bool {
+	if hook.AppName != event.AppName {
+		return false
+	}
+	if hook.ClusterName != "" && event.ClusterName != "" && hook.ClusterName != event.ClusterName {
+		return false
+	}
+	if hook.NamespaceName != "" && event.NamespaceName != "" && hook.NamespaceName != event.NamespaceName {
+		return false
+	}
+	return len(hook.Events) == 0 || slices.Contains(hook.Events, event.Event)
+}
+
+// CreateWebhook 创建订阅，cluster_name 和 namespace_name 同时为空时订阅整个应用；
+// secret 为空时自动生成，只在本次返回
+func (s *WebhookService) CreateWebhook(env, appName string, hook *models.Webhook) (err error) {
+	s, span := s.trace("CreateWebhook")
+	defer func() { telemetry.End(span, err) }()
+
+	if (hook.ClusterName == "") != (hook.NamespaceName == "") {
+		return errors.New("cluster_name and namespace_name must be set together")
+	}
+	if hook.ClusterName == "" {
+		_, err = CheckACNKinDBContext(s.ctx, &env, &appName, nil, nil, nil)
+	} else {
+		_, err = CheckACNKinDBContext(s.ctx, &env, &appName, &hook.ClusterName, &hook.NamespaceName, nil)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := validateWebhook(hook.URL, hook.Events); err != nil {
+		return err
+	}
+	if hook.Secret == "" {
+		if hook.Secret, err = generateWebhookSecret(); err != nil {
+			logger.GetLogger("quiver").WithContext(s.ctx).Errorf("generate webhook secret failed: %v", err)
+			return err
+		}
+	} else if len(hook.Secret) > 128 {
+		return errors.New("secret is longer than 128 characters")
+	}
+
+	hook.ID = 0
+	hook.AppName = appName
+	if hook.Events == nil {
+		hook.Events = []string{}
+	}
+
+	db := database.GetDBContext(s.ctx, env)
+	if err := db.Create(hook).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("create webhook failed: %v", err)
+		return err
+	}
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("webhook %d for %s created by %s: %s", hook.ID, webhookScope(hook), hook.Operator, hook.URL)
+	return nil
+}
+
+// ListWebhooks 获取应用的全部订阅，包括命名空间级别的订阅，不返回 secret
+func (s *WebhookService) ListWebhooks(env, appName string) (_ []models.Webhook, err error) {
+	s, span := s.trace("ListWebhooks")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+
+	var hooks []models.Webhook
+	if err := db.Where("app_name = ?", appName).Order("id ASC").Find(&hooks).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query webhooks of %s: %v", appName, err)
+		return nil, fmt.Errorf("failed to query webhooks: %w", err)
+	}
+	for i := range hooks {
+		hooks[i].Secret = ""
+	}
+	return hooks, nil
+}
+
+// GetWebhook 获取应用下的单个订阅，不返回 secret
+func (s *WebhookService) GetWebhook(env, appName string, webhookID uint64) (_ *models.Webhook, err error) {
+	s, span := s.trace("GetWebhook")
+	defer func() { telemetry.End(span, err) }()
+
+	hook, err := s.webhook(database.GetDBContext(s.ctx, env), appName, webhookID)
+	if err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// UpdateWebhook 修改订阅的地址、事件、启用状态、备注或 secret，只有修改了 secret 时才返回它
+func (s *WebhookService) UpdateWebhook(env, appName string, webhookID uint64, update WebhookUpdate) (_ *models.Webhook, err error) {
+	s, span := s.trace("UpdateWebhook")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+	hook, err := s.webhook(db, appName, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	if update.URL != nil {
+		hook.URL = *update.URL
+		updates["url"] = hook.URL
+	}
+	if update.Events != nil {
+		hook.Events = *update.Events
+		if hook.Events == nil {
+			hook.Events = []string{}
+		}
+		encoded, err := json.Marshal(hook.Events)
+		if err != nil {
+			return nil, err
+		}
+		updates["events"] = string(encoded)
+	}
+	if err := validateWebhook(hook.URL, hook.Events); err != nil {
+		return nil, err
+	}
+	if update.Enabled != nil {
+		hook.Enabled = *update.Enabled
+		updates["enabled"] = hook.Enabled
+	}
+	if update.Description != nil {
+		hook.Description = *update.Description
+		updates["description"] = hook.Description
+	}
+	if update.Secret != nil {
+		hook.Secret = *update.Secret
+		if hook.Secret == "" {
+			if hook.Secret, err = generateWebhookSecret(); err != nil {
+				logger.GetLogger("quiver").WithContext(s.ctx).Errorf("generate webhook secret failed: %v", err)
+				return nil, err
+			}
+		} else if len(hook.Secret) > 128 {
+			return nil, errors.New("secret is longer than 128 characters")
+		}
+		updates["secret"] = hook.Secret
+	} else {
+		hook.Secret = ""
+	}
+	if len(updates) == 0 {
+		return hook, nil
+	}
+
+	if err := db.Model(&models.Webhook{}).Where("id = ?", hook.ID).Updates(updates).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("update webhook %d failed: %v", webhookID, err)
+		return nil, err
+	}
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("webhook %d for %s updated", hook.ID, webhookScope(hook))
+	return hook, nil
+}
+
+// DeleteWebhook 删除订阅及其投递记录
+func (s *WebhookService) DeleteWebhook(env, appName string, webhookID uint64) (err error) {
+	s, span := s.trace("DeleteWebhook")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+	result := db.Where("id = ? AND app_name = ?", webhookID, appName).Delete(&models.Webhook{})
+	if result.Error != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("delete webhook %d failed: %v", webhookID, result.Error)
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("webhook %d of %s deleted", webhookID, appName)
+	return nil
+}
+
+// ListDeliveries 获取订阅的投递记录，按创建时间倒序，status 为空时返回全部
+func (s *WebhookService) ListDeliveries(env, appName string, webhookID uint64, status string, page, size int) (_ []models.WebhookDelivery, _ int64, err error) {
+	s, span := s.trace("ListDeliveries")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+	if _, err := s.webhook(db, appName, webhookID); err != nil {
+		return nil, 0, err
+	}
+
+	query := db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", webhookID)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to count deliveries: %v", err)
+		return nil, 0, fmt.Errorf("failed to count deliveries: %w", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Offset((page - 1) * size).Limit(size).Find(&deliveries).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("failed to query deliveries: %v", err)
+		return nil, 0, fmt.Errorf("failed to query deliveries: %w", err)
+	}
+	return deliveries, total, nil
+}
+
+// GetDelivery 获取单条投递记录，包括请求体和最近一次的失败原因
+func (s *WebhookService) GetDelivery(env, appName string, webhookID, deliveryID uint64) (_ *models.WebhookDelivery, err error) {
+	s, span := s.trace("GetDelivery")
+	defer func() { telemetry.End(span, err) }()
+
+	db := database.GetDBContext(s.ctx, env)
+	if _, err := s.webhook(db, appName, webhookID); err != nil {
+		return nil, err
+	}
+
+	var delivery models.WebhookDelivery
+	if err := db.Where("id = ? AND webhook_id = ?", deliveryID, webhookID).First(&delivery).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookDeliveryNotFound
+		}
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query delivery %d failed: %v", deliveryID, err)
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ReplayDelivery 以相同的事件内容新建一条待投递记录，原记录保持不变
+func (s *WebhookService) ReplayDelivery(env, appName string, webhookID, deliveryID uint64) (_ *models.WebhookDelivery, err error) {
+	s, span := s.trace("ReplayDelivery")
+	defer func() { telemetry.End(span, err) }()
+
+	original, err := s.GetDelivery(env, appName, webhookID, deliveryID)
+	if err != nil {
+		return nil, err
+	}
+
+	replay := &models.WebhookDelivery{
+		WebhookID:       original.WebhookID,
+		EventID:         original.EventID,
+		Event:           original.Event,
+		Payload:         original.Payload,
+		Status:          models.WebhookDeliveryPending,
+		NextAttemptTime: time.Now(),
+		ReplayOf:        original.ID,
+	}
+	db := database.GetDBContext(s.ctx, env)
+	if err := db.Create(replay).Error; err != nil {
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("replay delivery %d failed: %v", deliveryID, err)
+		return nil, err
+	}
+	WakeWebhooks(env)
+
+	logger.GetLogger("quiver").WithContext(s.ctx).Infof("delivery %d of webhook %d replayed as %d", deliveryID, webhookID, replay.ID)
+	return replay, nil
+}
+
+// Dispatch 领取并投递环境中到期的记录，停用的 webhook 的记录保持待投递
+func (s *WebhookService) Dispatch(env string) {
+	db := database.GetDB(env)
+	if db == nil {
+		logger.GetLogger("quiver").Errorf("db is nil for env %s", env)
+		return
+	}
+
+	conf := config.GetWebhookConfig()
+	for i := 0; i < webhookMaxRounds; i++ {
+		if s.dispatchBatch(db, env, conf) < conf.BatchSize {
+			return
+		}
+	}
+}
+
+// dispatchBatch 投递一批到期的记录，返回查询到的记录数
+func (s *WebhookService) dispatchBatch(db *gorm.DB, env string, conf config.WebhookConfig) int {
+	now := time.Now()
+	var due []models.WebhookDelivery
+	err := db.Where("status = ? AND next_attempt_time <= ?", models.WebhookDeliveryPending, now).
+		Where("webhook_id IN (?)", db.Model(&models.Webhook{}).Select("id").Where("enabled = ?", true)).
+		Order("next_attempt_time ASC, id ASC").Limit(conf.BatchSize).Find(&due).Error
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("query due webhook deliveries for env %s failed: %v", env, err)
+		return 0
+	}
+	if len(due) == 0 {
+		return 0
+	}
+
+	hookIDs := make([]uint64, 0, len(due))
+	for _, d := range due {
+		hookIDs = append(hookIDs, d.WebhookID)
+	}
+	var hooks []models.Webhook
+	if err := db.Where("id IN ?", hookIDs).Find(&hooks).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("query webhooks for env %s failed: %v", env, err)
+		return 0
+	}
+	byID := make(map[uint64]*models.Webhook, len(hooks))
+	for i := range hooks {
+		byID[hooks[i].ID] = &hooks[i]
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, webhookConcurrency)
+	for i := range due {
+		d := &due[i]
+		hook := byID[d.WebhookID]
+		if hook == nil {
+			continue
+		}
+		// 领取：投递次数加一，并把下次投递时间推迟，投递过程中实例退出时由其他实例重试
+		result := db.Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND attempts = ?", d.ID, models.WebhookDeliveryPending, d.Attempts).
+			Updates(map[string]interface{}{
+				"attempts":          d.Attempts + 1,
+				"next_attempt_time": now.Add(2 * conf.Timeout),
+			})
+		if result.Error != nil {
+			logger.GetLogger("quiver").Errorf("claim webhook delivery %d failed: %v", d.ID, result.Error)
+			continue
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		d.Attempts++
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			s.deliver(db, hook, d, conf)
+		}()
+	}
+	wg.Wait()
+	return len(due)
+}
+
+// deliver 投递一次并记录结果，失败时按指数退避安排下次投递，达到最多次数后标记为 failed
+func (s *WebhookService) deliver(db *gorm.DB, hook *models.Webhook, d *models.WebhookDelivery, conf config.WebhookConfig) {
+	code, err := postWebhook(hook, d, conf.Timeout)
+
+	now := time.Now()
+	updates := map[string]interface{}{"response_code": code}
+	switch {
+	case err == nil:
+		updates["status"] = models.WebhookDeliverySucceeded
+		updates["delivered_time"] = now
+		updates["last_error"] = ""
+	case d.Attempts >= conf.MaxAttempts:
+		updates["status"] = models.WebhookDeliveryFailed
+		updates["last_error"] = truncateError(err.Error(), 1024)
+	default:
+		updates["next_attempt_time"] = now.Add(webhookBackoff(d.Attempts, conf))
+		updates["last_error"] = truncateError(err.Error(), 1024)
+	}
+	if err := db.Model(&models.WebhookDelivery{}).Where("id = ? AND attempts = ?", d.ID, d.Attempts).Updates(updates).Error; err != nil {
+		logger.GetLogger("quiver").Errorf("update webhook delivery %d failed: %v", d.ID, err)
+	}
+
+	if err != nil {
+		logger.GetLogger("quiver").Warnf("deliver %s event %s to webhook %d (%s) failed, attempt %d/%d: %v",
+			d.Event, d.EventID, hook.ID, hook.URL, d.Attempts, conf.MaxAttempts, err)
+	}
+}
+
+// postWebhook 发送请求，2xx 为成功；返回 HTTP 状态码，没有收到响应时为 0
+func postWebhook(hook *models.Webhook, d *models.WebhookDelivery, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	body := []byte(d.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, strings.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "quiver-webhook")
+	req.Header.Set(HeaderWebhookEvent, d.Event)
+	req.Header.Set(HeaderWebhookDelivery, strconv.FormatUint(d.ID, 10))
+	req.Header.Set(peer.HeaderTimestamp, ts)
+	req.Header.Set(peer.HeaderSignature, peer.Sign(hook.Secret, ts, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, errors.New("unexpected status " + resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// webhookBackoff 第 n 次投递失败后的重试间隔：backoff_base * 2^(n-1)，不超过 backoff_max
+func webhookBackoff(attempts int, conf config.WebhookConfig) time.Duration {
+	backoff := conf.BackoffBase
+	for i := 1; i < attempts && backoff < conf.BackoffMax; i++ {
+		backoff *= 2
+	}
+	return min(backoff, conf.BackoffMax)
+}
+
+// purge 删除 keepDays 天前已经结束（成功或失败）的投递记录
+func (s *WebhookService) purge(env string, keepDays int) {
+	db := database.GetDB(env)
+	if db == nil {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -keepDays)
+	var total int64
+	for {
+		result := db.Where("status <> ? AND create_time < ?", models.WebhookDeliveryPending, cutoff).
+			Limit(1000).Delete(&models.WebhookDelivery{})
+		if result.Error != nil {
+			logger.GetLogger("quiver").Errorf("purge webhook deliveries for env %s failed: %v", env, result.Error)
+			return
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < 1000 {
+			break
+		}
+	}
+	if total > 0 {
+		logger.GetLogger("quiver").Infof("purged %d webhook deliveries older than %d days for env %s", total, keepDays, env)
+	}
+}
+
+// webhook 查询应用下的订阅
+func (s *WebhookService) webhook(db *gorm.DB, appName string, webhookID uint64) (*models.Webhook, error) {
+	var hook models.Webhook
+	if err := db.Where("id = ? AND app_name = ?", webhookID, appName).First(&hook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		logger.GetLogger("quiver").WithContext(s.ctx).Errorf("query webhook %d failed: %v", webhookID, err)
+		return nil, err
+	}
+	return &hook, nil
+}
+
+// validateWebhook 校验地址和事件类型
+func validateWebhook(rawURL string, events []string) error {
+	if len(rawURL) > 1024 {
+		return errors.New("url is longer than 1024 characters")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https url")
+	}
+	// 域名在投递时解析后检查，这里只提前拒绝不允许的 IP 地址
+	if addr, err := netip.ParseAddr(u.Hostname()); err == nil && !config.GetWebhookConfig().AddrAllowed(addr) {
+		return fmt.Errorf("url host %s is not allowed by webhook.allow_cidrs and deny_cidrs", addr)
+	}
+	for _, event := range events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return fmt.Errorf("unknown event %q, must be one of %s", event, strings.Join(models.WebhookEvents, ", "))
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(secret), nil
+}
+
+func webhookScope(hook *models.Webhook) string {
+	if hook.ClusterName == "" {
+		return hook.AppName
+	}
+	return hook.AppName + "/" + hook.ClusterName + "/" + hook.NamespaceName
+}
+
+// truncateError 按字节截断，截断处位于多字节字符中间时退回到该字符之前；之前的无效字节原样保留
+func truncateError(msg string, limit int) string {
+	if len(msg) <= limit {
+		return msg
+	}
+	cut := limit
+	for cut > 0 && limit-cut < utf8.UTFMax-1 && !utf8.RuneStart(msg[cut]) {
+		cut--
+	}
+	if !utf8.RuneStart(msg[cut]) {
+		cut = limit
+	}
+	return msg[:cut]
+}
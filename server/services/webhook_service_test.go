@@ -0,0 +1,165 @@
+package services
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"quiver/config"
+	"quiver/models"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWebhookBackoff(t *testing.T) {
+	conf := config.WebhookConfig{BackoffBase: 10 * time.Second, BackoffMax: time.Minute}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 10 * time.Second},
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{100, time.Minute}, // 不会溢出
+	}
+	for _, tt := range tests {
+		if got := webhookBackoff(tt.attempts, conf); got != tt.want {
+			t.Errorf("webhookBackoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+
+	// backoff_base 大于 backoff_max 时取上限
+	if got := webhookBackoff(1, config.WebhookConfig{BackoffBase: time.Hour, BackoffMax: time.Minute}); got != time.Minute {
+		t.Errorf("webhookBackoff() = %s, want %s", got, time.Minute)
+	}
+}
+
+func TestTruncateError(t *testing.T) {
+	tests := []struct {
+		name  string
+		msg   string
+		limit int
+		want  string
+	}{
+		{"short", "error", 10, "error"},
+		{"exact", "error", 5, "error"},
+		{"ascii", "connection refused", 10, "connection"},
+		{"cut inside a rune", "失败原因", 4, "失"},
+		{"cut after a rune", "失败原因", 6, "失败"},
+		{"cut inside a four byte rune", "ab😀cd", 4, "ab"},
+		{"limit zero", "失败", 0, ""},
+		// 截断处之前的无效字节不影响截断位置
+		{"invalid bytes before the cut", "\xffab失败", 5, "\xffab"},
+		{"invalid bytes at the cut", "abc\x80\x80\x80\x80\x80", 6, "abc\x80\x80\x80"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := truncateError(tt.msg, tt.limit)
+			if got != tt.want {
+				t.Fatalf("truncateError(%q, %d) = %q, want %q", tt.msg, tt.limit, got, tt.want)
+			}
+			if len(got) > tt.limit && len(tt.msg) > tt.limit {
+				t.Fatalf("truncateError() returned %d bytes, limit %d", len(got), tt.limit)
+			}
+		})
+	}
+
+	long := strings.Repeat("错", 500)
+	if got := truncateError(long, 1024); len(got) != 1023 || !utf8.ValidString(got) {
+		t.Fatalf("truncateError() = %d bytes, valid %v", len(got), utf8.ValidString(got))
+	}
+}
+
+func TestWebhookAddrAllowed(t *testing.T) {
+	loadTestConfig(t, "webhook:\n  allow_cidrs: [10.1.0.0/16]\n")
+	conf := config.GetWebhookConfig()
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.216.34", true},
+		{"2606:2800:220:1::1", true},
+		{"127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"10.0.0.1", false},
+		{"10.1.2.3", true}, // allow_cidrs 优先
+		{"172.16.0.1", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"fd00::1", false},
+		{"fe80::1%eth0", false},
+		{"::ffff:127.0.0.1", false},
+		{"::ffff:10.1.0.1", true},
+	}
+	for _, tt := range tests {
+		if got := conf.AddrAllowed(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("AddrAllowed(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+
+	// deny_cidrs 写为空列表时不限制
+	loadTestConfig(t, "webhook:\n  deny_cidrs: []\n")
+	if !config.GetWebhookConfig().AddrAllowed(netip.MustParseAddr("127.0.0.1")) {
+		t.Error("AddrAllowed() denied an address with empty deny_cidrs")
+	}
+}
+
+func TestValidateWebhookAddress(t *testing.T) {
+	loadTestConfig(t, "webhook: {}\n")
+	tests := []struct {
+		url     string
+		wantErr bool
+	}{
+		{"https://hooks.example.com/quiver", false},
+		{"http://93.184.216.34:8080/hook", false},
+		{"http://127.0.0.1:8080/hook", true},
+		{"http://[::1]/hook", true},
+		{"http://169.254.169.254/latest/meta-data", true},
+		{"http://10.0.0.1/hook", true},
+		{"ftp://example.com/hook", true},
+		{"/relative", true},
+	}
+	for _, tt := range tests {
+		if err := validateWebhook(tt.url, []string{models.WebhookEventPublish}); (err != nil) != tt.wantErr {
+			t.Errorf("validateWebhook(%s) error = %v, wantErr %v", tt.url, err, tt.wantErr)
+		}
+	}
+}
+
+// 投递时按解析后的地址检查，域名或 IP 指向被禁止的地址都不会建立连接
+func TestPostWebhookDeniedAddress(t *testing.T) {
+	received := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+	}))
+	defer server.Close()
+
+	hook := &models.Webhook{URL: server.URL, Secret: "secret"}
+	localhost := &models.Webhook{URL: strings.Replace(server.URL, "127.0.0.1", "localhost", 1), Secret: "secret"}
+	delivery := &models.WebhookDelivery{ID: 1, Event: models.WebhookEventPublish, Payload: `{}`}
+
+	loadTestConfig(t, "webhook: {}\n")
+	for _, h := range []*models.Webhook{hook, localhost} {
+		if _, err := postWebhook(h, delivery, time.Second); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Fatalf("postWebhook(%s) error = %v, want address not allowed", h.URL, err)
+		}
+	}
+	if received != 0 {
+		t.Fatalf("server received %d requests", received)
+	}
+
+	loadTestConfig(t, "webhook:\n  allow_cidrs: [127.0.0.0/8]\n")
+	status, err := postWebhook(hook, delivery, time.Second)
+	if err != nil || status != http.StatusOK {
+		t.Fatalf("postWebhook() = %d, %v, want 200", status, err)
+	}
+	if received != 1 {
+		t.Fatalf("server received %d requests, want 1", received)
+	}
+}
@@ -0,0 +1,167 @@
+package sso
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"quiver/config"
+	"quiver/logger"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+const ldapTimeout = 10 * time.Second
+
+// LDAPProvider 通过 LDAP bind 校验密码，并查询用户所在的组
+type LDAPProvider struct {
+	conf config.LDAPConfig
+}
+
+func NewLDAPProvider(conf config.LDAPConfig) *LDAPProvider {
+	return &LDAPProvider{conf: conf}
+}
+
+func (p *LDAPProvider) dial() (*ldap.Conn, error) {
+	tlsConf := &tls.Config{InsecureSkipVerify: p.conf.InsecureSkipVerify}
+	conn, err := ldap.DialURL(p.conf.URL, ldap.DialWithTLSConfig(tlsConf))
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(ldapTimeout)
+
+	if p.conf.StartTLS {
+		if err := conn.StartTLS(tlsConf); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// bindService 用服务账号绑定，未配置时使用匿名查询
+func (p *LDAPProvider) bindService(conn *ldap.Conn) error {
+	if p.conf.BindDN == "" {
+		return nil
+	}
+	return conn.Bind(p.conf.BindDN, p.conf.BindPassword)
+}
+
+// Authenticate 搜索用户 DN，以用户身份 bind 校验密码，再查询组
+func (p *LDAPProvider) Authenticate(userName, password string) (*Identity, error) {
+	if !p.conf.Enabled {
+		return nil, ErrProviderDisabled
+	}
+	// 空密码会被很多 LDAP 服务当作匿名 bind 直接通过
+	if userName == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := p.dial()
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("ldap dial %s failed: %v", p.conf.URL, err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := p.bindService(conn); err != nil {
+		logger.GetLogger("quiver").Errorf("ldap service bind failed: %v", err)
+		return nil, err
+	}
+
+	attrs := []string{"dn", "memberOf", p.conf.EmailAttr, p.conf.PhoneAttr}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		p.conf.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, int(ldapTimeout.Seconds()), false,
+		fmt.Sprintf(p.conf.UserFilter, ldap.EscapeFilter(userName)),
+		attrs, nil,
+	))
+	if err != nil {
+		logger.GetLogger("quiver").Errorf("ldap search user %s failed: %v", userName, err)
+		return nil, err
+	}
+	if len(result.Entries) != 1 {
+		logger.GetLogger("quiver").Warnf("ldap user %s matched %d entries", userName, len(result.Entries))
+		return nil, ErrInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrInvalidCredentials
+		}
+		logger.GetLogger("quiver").Errorf("ldap bind %s failed: %v", entry.DN, err)
+		return nil, err
+	}
+
+	groups, err := p.searchGroups(conn, entry.DN)
+	if err != nil {
+		return nil, err
+	}
+	return p.identity(userName, entry, groups), nil
+}
+
+// identity 把用户条目映射为用户信息，组包括 group_filter 查到的组和 memberOf 中的组
+func (p *LDAPProvider) identity(userName string, entry *ldap.Entry, groups []string) *Identity {
+	for _, dn := range entry.GetAttributeValues("memberOf") {
+		if name := groupNameFromDN(dn, p.conf.GroupAttr); name != "" {
+			groups = append(groups, name)
+		}
+	}
+
+	return &Identity{
+		Source:     SourceLDAP,
+		ExternalID: entry.DN,
+		UserName:   userName,
+		Email:      entry.GetAttributeValue(p.conf.EmailAttr),
+		Phone:      entry.GetAttributeValue(p.conf.PhoneAttr),
+		Groups:     normalizeGroups(groups),
+	}
+}
+
+// searchGroups 以服务账号重新 bind 后按 group_filter 查询组
+func (p *LDAPProvider) searchGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	if p.conf.GroupBaseDN == "" {
+		return nil, nil
+	}
+	if err := p.bindService(conn); err != nil {
+		logger.GetLogger("quiver").Errorf("ldap service rebind failed: %v", err)
+		return nil, err
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		p.conf.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, int(ldapTimeout.Seconds()), false,
+		fmt.Sprintf(p.conf.GroupFilter, ldap.EscapeFilter(userDN)),
+		[]string{p.conf.GroupAttr}, nil,
+	))
+	if err != nil {
+		// 匿名查询时用户 bind 之后可能没有读组的权限，只记录日志不阻止登录
+		var ldapErr *ldap.Error
+		if errors.As(err, &ldapErr) && ldapErr.ResultCode == ldap.LDAPResultInsufficientAccessRights {
+			logger.GetLogger("quiver").Warnf("ldap search groups for %s denied: %v", userDN, err)
+			return nil, nil
+		}
+		logger.GetLogger("quiver").Errorf("ldap search groups for %s failed: %v", userDN, err)
+		return nil, err
+	}
+
+	groups := make([]string, 0, len(result.Entries))
+	for _, e := range result.Entries {
+		groups = append(groups, e.GetAttributeValue(p.conf.GroupAttr))
+	}
+	return groups, nil
+}
+
+// groupNameFromDN 取 memberOf DN 中第一个 RDN 的值，如 cn=ops,ou=groups,dc=corp -> ops
+func groupNameFromDN(dn, attr string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 {
+		return ""
+	}
+	for _, a := range parsed.RDNs[0].Attributes {
+		if attr == "" || strings.EqualFold(a.Type, attr) {
+			return a.Value
+		}
+	}
+	return ""
+}
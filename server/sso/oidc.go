@@ -0,0 +1,139 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"quiver/config"
+	"quiver/logger"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCProvider OpenID Connect 授权码流程（带 PKCE 与 nonce 校验）
+type OIDCProvider struct {
+	conf config.OIDCConfig
+
+	mu       sync.Mutex
+	provider *oidc.Provider
+}
+
+func NewOIDCProvider(conf config.OIDCConfig) *OIDCProvider {
+	return &OIDCProvider{conf: conf}
+}
+
+// discover 首次使用时请求 issuer 的 .well-known 配置，成功后缓存
+func (p *OIDCProvider) discover(ctx context.Context) (*oidc.Provider, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.provider != nil {
+		return p.provider, nil
+	}
+
+	provider, err := oidc.NewProvider(ctx, p.conf.Issuer)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("oidc discovery %s failed: %v", p.conf.Issuer, err)
+		return nil, err
+	}
+	p.provider = provider
+	return provider, nil
+}
+
+func (p *OIDCProvider) oauth2Config(provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.conf.ClientID,
+		ClientSecret: p.conf.ClientSecret,
+		RedirectURL:  p.conf.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       p.conf.Scopes,
+	}
+}
+
+// AuthCodeURL 生成跳转到 IdP 的登录地址
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	if !p.conf.Enabled {
+		return "", ErrProviderDisabled
+	}
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+	return p.oauth2Config(provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange 用授权码换取 id_token，校验签名、audience、nonce 后解析用户信息
+func (p *OIDCProvider) Exchange(ctx context.Context, code, nonce, verifier string) (*Identity, error) {
+	if !p.conf.Enabled {
+		return nil, ErrProviderDisabled
+	}
+	provider, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauth2Config(provider).Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("oidc exchange code failed: %v", err)
+		return nil, ErrInvalidCredentials
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("id_token missing in token response")
+	}
+
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: p.conf.ClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		logger.GetLogger("quiver").WithContext(ctx).Errorf("oidc verify id_token failed: %v", err)
+		return nil, ErrInvalidCredentials
+	}
+	if idToken.Nonce != nonce {
+		return nil, ErrInvalidCredentials
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+	return p.identity(idToken.Issuer, idToken.Subject, claims)
+}
+
+// identity 把 id_token 的 claims 映射为用户信息
+func (p *OIDCProvider) identity(issuer, subject string, claims map[string]any) (*Identity, error) {
+	if issuer == "" || subject == "" {
+		return nil, errors.New("iss or sub missing in id_token")
+	}
+	userName, _ := claims[p.conf.UsernameClaim].(string)
+	if userName == "" {
+		return nil, fmt.Errorf("claim %s missing in id_token", p.conf.UsernameClaim)
+	}
+	email, _ := claims["email"].(string)
+	phone, _ := claims["phone_number"].(string)
+
+	return &Identity{
+		Source:     SourceOIDC,
+		ExternalID: OIDCExternalID(issuer, subject),
+		UserName:   userName,
+		Email:      email,
+		Phone:      phone,
+		Groups:     normalizeGroups(stringsClaim(claims[p.conf.GroupsClaim])),
+	}, nil
+}
+
+// stringsClaim 组 claim 可能是字符串数组，也可能是单个字符串
+func stringsClaim(v any) []string {
+	switch val := v.(type) {
+	case string:
+		return []string{val}
+	case []any:
+		result := make([]string, 0, len(val))
+		for _, item := range val {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
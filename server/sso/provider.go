@@ -0,0 +1,54 @@
+package sso
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+// 外部身份来源，写入 user.source
+const (
+	SourceLocal = "local"
+	SourceLDAP  = "ldap"
+	SourceOIDC  = "oidc"
+)
+
+var (
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrProviderDisabled   = errors.New("identity provider disabled")
+)
+
+// Identity 身份提供方认证通过后返回的用户信息
+// ExternalID 是身份在提供方内稳定不变的标识：LDAP 为用户 DN，OIDC 为 iss 与 sub，用户按它关联，不按用户名
+type Identity struct {
+	Source     string   `json:"source"`
+	ExternalID string   `json:"external_id"`
+	UserName   string   `json:"user_name"`
+	Email      string   `json:"email"`
+	Phone      string   `json:"phone"`
+	Groups     []string `json:"groups"`
+}
+
+// OIDCExternalID issuer 不能包含 fragment，用 # 分隔不会产生歧义
+func OIDCExternalID(issuer, subject string) string {
+	return issuer + "#" + subject
+}
+
+// normalizeGroups 去重排序，便于日志与权限映射比较
+func normalizeGroups(groups []string) []string {
+	seen := make(map[string]struct{}, len(groups))
+	result := make([]string, 0, len(groups))
+	for _, g := range groups {
+		g = strings.TrimSpace(g)
+		if g == "" {
+			continue
+		}
+		if _, ok := seen[g]; ok {
+			continue
+		}
+		seen[g] = struct{}{}
+		result = append(result, g)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,107 @@
+package sso
+
+import (
+	"quiver/config"
+	"reflect"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+func TestOIDCIdentity(t *testing.T) {
+	p := NewOIDCProvider(config.OIDCConfig{UsernameClaim: "preferred_username", GroupsClaim: "groups"})
+	const issuer = "https://sso.example.com/realms/corp"
+
+	tests := []struct {
+		name    string
+		subject string
+		claims  map[string]any
+		want    *Identity
+		wantErr bool
+	}{
+		{
+			name:    "all claims",
+			subject: "2f6c0c1e",
+			claims: map[string]any{
+				"preferred_username": "alice",
+				"email":              "alice@example.com",
+				"phone_number":       "13800000000",
+				"groups":             []any{"ops", " dev ", "ops", 1},
+			},
+			want: &Identity{
+				Source:     SourceOIDC,
+				ExternalID: issuer + "#2f6c0c1e",
+				UserName:   "alice",
+				Email:      "alice@example.com",
+				Phone:      "13800000000",
+				Groups:     []string{"dev", "ops"},
+			},
+		},
+		{
+			name:    "single group",
+			subject: "2f6c0c1e",
+			claims:  map[string]any{"preferred_username": "alice", "groups": "ops"},
+			want:    &Identity{Source: SourceOIDC, ExternalID: issuer + "#2f6c0c1e", UserName: "alice", Groups: []string{"ops"}},
+		},
+		{
+			// 用户名相同、sub 不同的是另一个身份
+			name:    "external id comes from sub, not the user name",
+			subject: "9a8b7c6d",
+			claims:  map[string]any{"preferred_username": "admin"},
+			want:    &Identity{Source: SourceOIDC, ExternalID: issuer + "#9a8b7c6d", UserName: "admin", Groups: []string{}},
+		},
+		{name: "user name missing", subject: "2f6c0c1e", claims: map[string]any{"email": "alice@example.com"}, wantErr: true},
+		{name: "subject missing", claims: map[string]any{"preferred_username": "alice"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := p.identity(issuer, tt.subject, tt.claims)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("identity() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("identity() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLDAPIdentity(t *testing.T) {
+	p := NewLDAPProvider(config.LDAPConfig{GroupAttr: "cn", EmailAttr: "mail", PhoneAttr: "mobile"})
+	entry := ldap.NewEntry("uid=alice,ou=people,dc=example,dc=com", map[string][]string{
+		"mail":     {"alice@example.com"},
+		"mobile":   {"13800000000"},
+		"memberOf": {"cn=ops,ou=groups,dc=example,dc=com", "ou=nocn,dc=example,dc=com", "not a dn"},
+	})
+
+	got := p.identity("alice", entry, []string{"dev", "ops"})
+	want := &Identity{
+		Source:     SourceLDAP,
+		ExternalID: "uid=alice,ou=people,dc=example,dc=com",
+		UserName:   "alice",
+		Email:      "alice@example.com",
+		Phone:      "13800000000",
+		Groups:     []string{"dev", "ops"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("identity() = %+v, want %+v", got, want)
+	}
+}
+
+func TestGroupNameFromDN(t *testing.T) {
+	tests := []struct {
+		dn, attr, want string
+	}{
+		{"cn=ops,ou=groups,dc=example,dc=com", "cn", "ops"},
+		{"CN=Ops Team,OU=Groups,DC=corp", "cn", "Ops Team"},
+		{"ou=groups,dc=example,dc=com", "cn", ""},
+		{"ou=groups,dc=example,dc=com", "", "groups"},
+		{"invalid", "cn", ""},
+		{"", "cn", ""},
+	}
+	for _, tt := range tests {
+		if got := groupNameFromDN(tt.dn, tt.attr); got != tt.want {
+			t.Errorf("groupNameFromDN(%q, %q) = %q, want %q", tt.dn, tt.attr, got, tt.want)
+		}
+	}
+}
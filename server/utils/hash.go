@@ -1,11 +1,16 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+
 	"github.com/google/uuid"
 	"github.com/spaolacci/murmur3"
 )
 
 // MurmurHash64 计算key-value的MurmurHash64值
+// 高低 32 位各自可能碰撞，只作为旧版本发布的兼容标识，新的发布用 ContentHash 判断内容是否相同
 func MurmurHash64(key, value string) uint64 {
 	k := murmur3.Sum32([]byte(key))
 	v := murmur3.Sum32([]byte(value))
@@ -14,6 +19,17 @@ func MurmurHash64(key, value string) uint64 {
 	return uint64(k) | (uint64(v) << 32)
 }
 
+// ContentHash 计算 key-value 的 SHA-256（十六进制），key 前加长度，避免 ("ab","c") 与 ("a","bc") 相同
+func ContentHash(key, value string) string {
+	h := sha256.New()
+	var n [8]byte
+	binary.BigEndian.PutUint64(n[:], uint64(len(key)))
+	h.Write(n[:])
+	h.Write([]byte(key))
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
 func GenerateReleaseID() (string, error) {
 	id, err := uuid.NewV7()
 	if err != nil {
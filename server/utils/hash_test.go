@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+func TestContentHash(t *testing.T) {
+	// 已知向量：8 字节大端长度 + key + value
+	sum := sha256.Sum256(append([]byte{0, 0, 0, 0, 0, 0, 0, 3}, "keyvalue"...))
+	if got, want := ContentHash("key", "value"), hex.EncodeToString(sum[:]); got != want {
+		t.Fatalf("ContentHash(key, value) = %s, want %s", got, want)
+	}
+
+	tests := []struct {
+		name         string
+		key1, value1 string
+		key2, value2 string
+		same         bool
+	}{
+		{"same input", "db.url", "mysql://a", "db.url", "mysql://a", true},
+		{"key value boundary", "ab", "c", "a", "bc", false},
+		{"empty key vs empty value", "", "x", "x", "", false},
+		{"value changed", "k", "v1", "k", "v2", false},
+		{"key changed", "k1", "v", "k2", "v", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h1, h2 := ContentHash(tt.key1, tt.value1), ContentHash(tt.key2, tt.value2)
+			if len(h1) != 64 {
+				t.Fatalf("hash length = %d, want 64", len(h1))
+			}
+			if (h1 == h2) != tt.same {
+				t.Fatalf("ContentHash(%q, %q) == ContentHash(%q, %q) is %v, want %v",
+					tt.key1, tt.value1, tt.key2, tt.value2, h1 == h2, tt.same)
+			}
+		})
+	}
+}
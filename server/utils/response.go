@@ -48,6 +48,11 @@ func Unauthorized(c *fiber.Ctx, message string) error {
 	return Error(c, 401, message, nil)
 }
 
+// Forbidden 403错误
+func Forbidden(c *fiber.Ctx, message string) error {
+	return Error(c, 403, message, nil)
+}
+
 // InternalError 500错误
 func InternalError(c *fiber.Ctx, message string) error {
 	return Error(c, 500, message, nil)